@@ -2,111 +2,98 @@ package common
 
 import (
 	"errors"
-	"sync"
+	"fmt"
+	"strings"
+	"time"
 )
 
-// AbstractCache 实现了一个引用计数策略的缓存
+// AbstractCache 实现了一个引用计数策略的缓存，具体的加载和释放由嵌入的 Cache 实现。
+// 引用计数和淘汰逻辑由 TypedCache[interface{}] 提供
 type AbstractCache struct {
-	cache       map[int64]interface{}
-	references  map[int64]int
-	getting     map[int64]bool
-	maxResource int
-	count       int
-	lock        sync.Mutex
+	*TypedCache[interface{}]
 	Cache
 }
 
 type Cache interface {
 	getForCache(int64) (interface{}, error)
-	releaseForCache(interface{})
+	releaseForCache(interface{}) error
 }
 
 // NewAbstractCache 创建一个带有指定 maxResource 的新 AbstractCache
-func NewAbstractCache(maxResource int) *AbstractCache {
-	return &AbstractCache{
-		cache:       make(map[int64]interface{}),
-		references:  make(map[int64]int),
-		getting:     make(map[int64]bool),
-		maxResource: maxResource,
-		count:       0,
-		lock:        sync.Mutex{},
-	}
-}
-
-// Get 通过给定的键从缓存中检索元素
-func (ac *AbstractCache) Get(key int64) (interface{}, error) {
-	for {
-		ac.lock.Lock()
-		if ac.getting[key] {
-			ac.lock.Unlock()
-			continue
+func NewAbstractCache(maxResource int, opts ...Option) *AbstractCache {
+	ac := &AbstractCache{}
+	// Cache 在创建之后才被设置，所以加载和释放时再通过 ac 间接调用。
+	// 默认的代价来自 Cache 的 costForCache，之后的 WithCost 可以覆盖它
+	cost := WithCost(func(key int64) int {
+		if cc, ok := ac.Cache.(costCache); ok {
+			return cc.costForCache(key)
 		}
+		return 1
+	})
+	ac.TypedCache = NewTypedCache[interface{}](maxResource,
+		func(key int64) (interface{}, error) { return ac.getForCache(key) },
+		func(obj interface{}) error { return ac.releaseForCache(obj) },
+		append([]Option{cost}, opts...)...)
+	return ac
+}
 
-		if obj, ok := ac.cache[key]; ok {
-			ac.references[key]++
-			ac.lock.Unlock()
-			return obj, nil
-		}
+// NewAbstractCacheWithTTL 创建一个条目在加载 ttl 时长之后过期的 AbstractCache，clock 为 nil 时使用 time.Now
+func NewAbstractCacheWithTTL(maxResource int, ttl time.Duration, clock Clock, opts ...Option) *AbstractCache {
+	return NewAbstractCache(maxResource, append(opts, WithTTL(ttl, clock))...)
+}
 
-		if ac.maxResource > 0 && ac.count == ac.maxResource {
-			ac.lock.Unlock()
-			return nil, CacheFullError
-		}
-		ac.count++
-		ac.getting[key] = true
-		ac.lock.Unlock()
-		break
+// NewAbstractCacheFrom 创建一个预先装入 entries 的 AbstractCache，装入的条目引用计数为 0
+func NewAbstractCacheFrom(maxResource int, entries map[int64]interface{}, opts ...Option) (*AbstractCache, error) {
+	if maxResource > 0 && len(entries) > maxResource {
+		return nil, fmt.Errorf("%w: %d entries exceed maxResource %d", CacheFullError, len(entries), maxResource)
 	}
 
-	obj, err := ac.getForCache(key)
-	if err != nil {
-		ac.lock.Lock()
-		ac.count--
-		delete(ac.getting, key)
-		ac.lock.Unlock()
-		return nil, err
-	}
+	ac := NewAbstractCache(maxResource, opts...)
+	ac.seed(entries)
+	return ac, nil
+}
 
-	ac.lock.Lock()
-	delete(ac.getting, key)
-	ac.cache[key] = obj
-	ac.references[key] = 1
-	ac.lock.Unlock()
+// CacheFullError 是指示缓存已满的错误，返回的错误会包装它并带上键和容量信息，应使用 errors.Is 判断
+var CacheFullError = errors.New("cache is full")
+
+var (
+	// ErrKeyNotCached 表示释放或修改的键不在缓存中
+	ErrKeyNotCached = errors.New("key not cached")
+	// ErrOverRelease 表示释放的次数超过了获取的次数
+	ErrOverRelease = errors.New("key released more times than it was acquired")
+	// ErrCacheClosed 表示缓存正在关闭或已经关闭，不再接受新的 Get，关闭之后的其他操作和再次 Close 同样返回它
+	ErrCacheClosed = errors.New("cache is closed")
+)
 
-	return obj, nil
+// ReleaseError 表示释放 Key 对应的条目失败
+type ReleaseError struct {
+	Key int64
+	Err error
 }
 
-// Release 强制释放缓存条目
-func (ac *AbstractCache) Release(key int64) {
-	ac.lock.Lock()
-	defer ac.lock.Unlock()
+func (e *ReleaseError) Error() string {
+	return fmt.Sprintf("release key %d: %v", e.Key, e.Err)
+}
 
-	if ref, ok := ac.references[key]; ok {
-		ref--
-		if ref == 0 {
-			obj := ac.cache[key]
-			ac.releaseForCache(obj)
-			delete(ac.references, key)
-			delete(ac.cache, key)
-			ac.count--
-		} else {
-			ac.references[key] = ref
-		}
-	}
+func (e *ReleaseError) Unwrap() error {
+	return e.Err
 }
 
-// Close 关闭缓存并释放所有资源
-func (ac *AbstractCache) Close() {
-	ac.lock.Lock()
-	defer ac.lock.Unlock()
+// ReleaseErrors 收集一次淘汰或者 Close 中所有释放失败的条目
+type ReleaseErrors []*ReleaseError
 
-	for key, obj := range ac.cache {
-		ac.releaseForCache(obj)
-		delete(ac.references, key)
-		delete(ac.cache, key)
-		ac.count--
+func (e ReleaseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
 	}
+	return fmt.Sprintf("failed to release %d entries: %s", len(e), strings.Join(msgs, "; "))
 }
 
-// CacheFullError 是指示缓存已满的错误
-var CacheFullError = errors.New("cache is full")
+// orNil 在没有错误时返回 nil，避免返回一个非 nil 的空 ReleaseErrors
+func (e ReleaseErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
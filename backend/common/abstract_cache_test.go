@@ -0,0 +1,1037 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCache 是测试用的 Cache 实现，记录加载和释放的次数，failReleases 中的键释放时返回错误
+type testCache struct {
+	mu           sync.Mutex
+	loads        map[int64]int
+	releases     []interface{}
+	failReleases map[int64]bool
+}
+
+func newTestCache() *testCache {
+	return &testCache{loads: make(map[int64]int)}
+}
+
+func (c *testCache) getForCache(key int64) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loads[key]++
+	return key * 10, nil
+}
+
+var errReleaseFailed = errors.New("release failed")
+
+func (c *testCache) releaseForCache(obj interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failReleases[obj.(int64)/10] {
+		return errReleaseFailed
+	}
+	c.releases = append(c.releases, obj)
+	return nil
+}
+
+func (c *testCache) loadCount(key int64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loads[key]
+}
+
+func TestGetAndRelease(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	obj, err := ac.Get(1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if obj.(int64) != 10 {
+		t.Errorf("Expected 10, got %v", obj)
+	}
+
+	ac.Get(1)
+	if tc.loadCount(1) != 1 {
+		t.Errorf("Expected key 1 to be loaded once, got %d", tc.loadCount(1))
+	}
+
+	ac.Release(1)
+	ac.Release(1)
+	if ac.references[1] != 0 {
+		t.Errorf("Expected 0 references, got %d", ac.references[1])
+	}
+
+	// 引用归零的条目留在缓存中，再次 Get 不会重新加载
+	ac.Get(1)
+	if tc.loadCount(1) != 1 {
+		t.Errorf("Expected unreferenced entry to stay cached, got %d loads", tc.loadCount(1))
+	}
+	if tc.releaseCount() != 0 {
+		t.Errorf("Expected no releases before eviction, got %d", tc.releaseCount())
+	}
+}
+
+func TestNewAbstractCacheFrom(t *testing.T) {
+	tc := newTestCache()
+	ac, err := NewAbstractCacheFrom(4, map[int64]interface{}{1: "a", 2: "b"})
+	if err != nil {
+		t.Fatalf("NewAbstractCacheFrom failed: %v", err)
+	}
+	ac.Cache = tc
+
+	if ac.count != 2 {
+		t.Errorf("Expected count 2, got %d", ac.count)
+	}
+	for _, key := range []int64{1, 2} {
+		if ac.references[key] != 0 {
+			t.Errorf("Expected zero references for key %d, got %d", key, ac.references[key])
+		}
+	}
+
+	obj, err := ac.Get(1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if obj != "a" {
+		t.Errorf("Expected seeded value, got %v", obj)
+	}
+	if tc.loadCount(1) != 0 {
+		t.Errorf("Seeded key should not be loaded")
+	}
+	if ac.references[1] != 1 {
+		t.Errorf("Expected 1 reference after Get, got %d", ac.references[1])
+	}
+}
+
+func TestNewAbstractCacheFromTooMany(t *testing.T) {
+	_, err := NewAbstractCacheFrom(1, map[int64]interface{}{1: "a", 2: "b"})
+	if !errors.Is(err, CacheFullError) {
+		t.Errorf("Expected CacheFullError, got %v", err)
+	}
+}
+
+func (c *testCache) releaseCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.releases)
+}
+
+func TestIdleReleaseEventuallyCompletes(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1, WithIdleRelease(time.Millisecond))
+	ac.Cache = tc
+	defer ac.Close()
+
+	// Get(2) 淘汰了 1，1 的释放被推迟到空闲时
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	deadline := time.Now().Add(time.Second)
+	for tc.releaseCount() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Deferred releases did not complete, got %d", tc.releaseCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIdleReleaseWithFakeClock(t *testing.T) {
+	tc := newTestCache()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	// 后台协程一小时检查一次，测试中直接调用 releaseIdle，是否空闲完全由假的时钟决定
+	ac := NewAbstractCache(1, WithIdleRelease(time.Hour), WithClock(clock.Now))
+	ac.Cache = tc
+	defer ac.Close()
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	clock.advance(59 * time.Minute)
+	ac.releaseIdle()
+	if n := tc.releaseCount(); n != 0 {
+		t.Fatalf("Cache has not been idle long enough, got %d releases", n)
+	}
+	clock.advance(time.Minute)
+	ac.releaseIdle()
+	if n := tc.releaseCount(); n != 1 {
+		t.Errorf("Expected the evicted entry to be released once idle, got %d releases", n)
+	}
+}
+
+func TestIdleReleaseForcedByClose(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1, WithIdleRelease(time.Hour))
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	if tc.releaseCount() != 0 {
+		t.Fatalf("Release should be deferred, got %d releases", tc.releaseCount())
+	}
+	ac.Release(2)
+
+	// 延迟释放期间再次 Get 同一个键不会重新加载
+	ac.Get(1)
+	if tc.loadCount(1) != 1 {
+		t.Errorf("Expected pending entry to be reused, got %d loads", tc.loadCount(1))
+	}
+	ac.Release(1)
+
+	ac.Close()
+	if tc.releaseCount() != 2 {
+		t.Errorf("Expected Close to force 2 releases, got %d", tc.releaseCount())
+	}
+}
+
+// slowCache 的加载很慢，并统计加载次数和失败前的剩余次数
+type slowCache struct {
+	*testCache
+	delay time.Duration
+	fails int
+}
+
+func (c *slowCache) getForCache(key int64) (interface{}, error) {
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	if c.fails > 0 {
+		c.fails--
+		c.mu.Unlock()
+		return nil, errors.New("load failed")
+	}
+	c.mu.Unlock()
+	return c.testCache.getForCache(key)
+}
+
+func TestConcurrentGetWaitsForLoader(t *testing.T) {
+	sc := &slowCache{testCache: newTestCache(), delay: 50 * time.Millisecond}
+	ac := NewAbstractCache(0)
+	ac.Cache = sc
+
+	const callers = 32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj, err := ac.Get(7)
+			if err != nil || obj.(int64) != 70 {
+				t.Errorf("Get returned (%v, %v)", obj, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 所有调用者都等待同一次加载，而不是各自重试
+	if sc.loadCount(7) != 1 {
+		t.Errorf("Expected exactly 1 load, got %d", sc.loadCount(7))
+	}
+	if ac.references[7] != callers {
+		t.Errorf("Expected %d references, got %d", callers, ac.references[7])
+	}
+}
+
+func TestWaitersRetryAfterLoaderError(t *testing.T) {
+	sc := &slowCache{testCache: newTestCache(), delay: 20 * time.Millisecond, fails: 1}
+	ac := NewAbstractCache(0)
+	ac.Cache = sc
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ac.Get(3); err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 只有第一次加载失败，等待者被唤醒后重新加载成功
+	if failed != 1 {
+		t.Errorf("Expected exactly 1 failed Get, got %d", failed)
+	}
+	if sc.loadCount(3) != 1 {
+		t.Errorf("Expected 1 successful load, got %d", sc.loadCount(3))
+	}
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(3)
+	ac.Cache = tc
+
+	for _, key := range []int64{1, 2, 3} {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	// 访问 1 使 2 成为最久未访问的条目
+	ac.Get(1)
+	ac.Release(1)
+
+	ac.Get(4)
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 20 {
+		t.Fatalf("Expected key 2 to be evicted first, got %v", tc.releases)
+	}
+
+	ac.Get(5)
+	if tc.releaseCount() != 2 || tc.releases[1].(int64) != 30 {
+		t.Errorf("Expected key 3 to be evicted second, got %v", tc.releases)
+	}
+	if ac.count != 3 {
+		t.Errorf("Expected count 3, got %d", ac.count)
+	}
+}
+
+func TestLRUHotKeySurvivesChurn(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	// 每装入一个冷键之前都访问一次热点键 0，热点键总是最近访问的，不会被淘汰
+	for key := int64(1); key <= 1000; key++ {
+		if _, err := ac.Get(0); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(0)
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(key)
+	}
+	if tc.loadCount(0) != 1 {
+		t.Errorf("Expected the hot key to be loaded once, got %d loads", tc.loadCount(0))
+	}
+	for _, obj := range tc.releases {
+		if obj.(int64) == 0 {
+			t.Fatalf("Hot key was evicted")
+		}
+	}
+	if stats := ac.Stats(); stats.Hits != 999 {
+		t.Errorf("Expected 999 hits on the hot key, got %d", stats.Hits)
+	}
+}
+
+func TestLRUEvictionSkipsReferenced(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	// 1 一直被引用，虽然它最久未访问也不能被淘汰
+	ac.Get(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	if _, err := ac.Get(3); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 20 {
+		t.Errorf("Expected key 2 to be evicted, got %v", tc.releases)
+	}
+	if _, ok := ac.cache[1]; !ok {
+		t.Errorf("Referenced key 1 was evicted")
+	}
+}
+
+func TestLRUEvictionAllReferenced(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Get(2)
+
+	_, err := ac.Get(3)
+	if !errors.Is(err, CacheFullError) {
+		t.Errorf("Expected CacheFullError when every entry is referenced, got %v", err)
+	}
+	if tc.releaseCount() != 0 || tc.loadCount(3) != 0 {
+		t.Errorf("Nothing should be evicted or loaded")
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	ac.Get(1) // miss
+	ac.Get(1) // hit
+	ac.Release(1)
+	ac.Release(1)
+	ac.Get(2) // miss
+	ac.Release(2)
+	ac.Get(3) // miss, 淘汰 1
+	ac.Get(2) // hit
+
+	expected := CacheStats{Hits: 2, Misses: 3, Evictions: 1, Count: 2, MaxResource: 2}
+	if stats := ac.Stats(); stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestReleaseUnknownKey(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+
+	err := ac.Release(42)
+	if !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached, got %v", err)
+	}
+}
+
+func TestOverRelease(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+
+	ac.Get(1)
+	ac.Get(1)
+	if err := ac.Release(1); err != nil {
+		t.Fatalf("First Release failed: %v", err)
+	}
+	if err := ac.Release(1); err != nil {
+		t.Fatalf("Second Release failed: %v", err)
+	}
+
+	err := ac.Release(1)
+	if !errors.Is(err, ErrOverRelease) {
+		t.Errorf("Expected ErrOverRelease, got %v", err)
+	}
+	if ac.references[1] != 0 {
+		t.Errorf("Reference count went negative: %d", ac.references[1])
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	tc := newTestCache()
+	var ac *AbstractCache
+	var evicted []int64
+	var values []interface{}
+	ac = NewAbstractCache(1, WithOnEvict(func(key int64, value interface{}) {
+		// 回调时不持有锁，可以再次访问缓存
+		ac.Stats()
+		if tc.releaseCount() <= len(evicted) {
+			t.Errorf("OnEvict for key %d fired before releaseForCache", key)
+		}
+		evicted = append(evicted, key)
+		values = append(values, value)
+	}))
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2) // 淘汰 1
+	if len(evicted) != 1 || evicted[0] != 1 || values[0].(int64) != 10 {
+		t.Fatalf("Expected eviction of key 1, got %v %v", evicted, values)
+	}
+
+	ac.Close()
+	if len(evicted) != 2 || evicted[1] != 2 || values[1].(int64) != 20 {
+		t.Errorf("Expected Close to evict key 2, got %v %v", evicted, values)
+	}
+}
+
+func TestOnEvictWithIdleRelease(t *testing.T) {
+	tc := newTestCache()
+	var evicted []int64
+	ac := NewAbstractCache(1, WithIdleRelease(time.Hour), WithOnEvict(func(key int64, value interface{}) {
+		evicted = append(evicted, key)
+	}))
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	// 释放被推迟时回调也被推迟
+	if len(evicted) != 0 {
+		t.Fatalf("OnEvict fired before the deferred release, got %v", evicted)
+	}
+
+	ac.Close()
+	if len(evicted) != 2 {
+		t.Errorf("Expected 2 evictions after Close, got %v", evicted)
+	}
+}
+
+func TestSetMaxResourceGrow(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1)
+	ac.Cache = tc
+
+	ac.Get(1)
+	if _, err := ac.Get(2); !errors.Is(err, CacheFullError) {
+		t.Fatalf("Expected CacheFullError, got %v", err)
+	}
+
+	ac.SetMaxResource(2)
+	if _, err := ac.Get(2); err != nil {
+		t.Fatalf("Get after growing failed: %v", err)
+	}
+	if ac.Stats().MaxResource != 2 {
+		t.Errorf("Expected MaxResource 2, got %d", ac.Stats().MaxResource)
+	}
+
+	ac.SetMaxResource(0)
+	for key := int64(3); key <= 10; key++ {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get on unlimited cache failed: %v", err)
+		}
+	}
+}
+
+func TestSetMaxResourceShrink(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	for key := int64(1); key <= 4; key++ {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	ac.Get(1) // 1 变为最近使用
+	ac.Release(1)
+
+	ac.SetMaxResource(2)
+	stats := ac.Stats()
+	if stats.Count != 2 || stats.Evictions != 2 {
+		t.Fatalf("Expected count 2 and 2 evictions, got %+v", stats)
+	}
+	for _, key := range []int64{2, 3} {
+		if _, ok := ac.cache[key]; ok {
+			t.Errorf("Expected key %d to be evicted", key)
+		}
+	}
+	if len(tc.releases) != 2 {
+		t.Errorf("Expected 2 releases, got %d", len(tc.releases))
+	}
+}
+
+func TestSetMaxResourceShrinkPinned(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(3)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Get(2)
+	ac.Get(3)
+	ac.Release(3)
+
+	ac.SetMaxResource(1)
+	if ac.Stats().Count != 2 {
+		t.Fatalf("Expected pinned entries to stay, got count %d", ac.Stats().Count)
+	}
+	if _, ok := ac.cache[3]; ok {
+		t.Errorf("Expected unreferenced key 3 to be evicted")
+	}
+
+	// 释放之后下一次 Get 会继续淘汰到新的容量之内
+	ac.Release(1)
+	ac.Release(2)
+	if _, err := ac.Get(4); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if ac.Stats().Count != 2 {
+		t.Errorf("Expected count 2, got %d", ac.Stats().Count)
+	}
+}
+
+// fakeClock 是测试用的时钟，只有调用 advance 时才会前进
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTTLReloadsExpiredEntry(t *testing.T) {
+	tc := newTestCache()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ac := NewAbstractCacheWithTTL(2, time.Minute, clock.Now)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+
+	clock.advance(59 * time.Second)
+	ac.Get(1)
+	ac.Release(1)
+	if tc.loadCount(1) != 1 || tc.releaseCount() != 0 {
+		t.Fatalf("Entry should not expire before the TTL")
+	}
+
+	clock.advance(time.Second)
+	obj, err := ac.Get(1)
+	if err != nil || obj.(int64) != 10 {
+		t.Fatalf("Get failed: %v, %v", obj, err)
+	}
+	if tc.loadCount(1) != 2 {
+		t.Errorf("Expected the expired entry to be reloaded, got %d loads", tc.loadCount(1))
+	}
+	if tc.releaseCount() != 1 {
+		t.Errorf("Expected the stale object to be released, got %d releases", tc.releaseCount())
+	}
+	if ac.Stats().Count != 1 {
+		t.Errorf("Expected count 1, got %d", ac.Stats().Count)
+	}
+
+	// 重新加载之后从新的时间开始计算
+	ac.Release(1)
+	clock.advance(30 * time.Second)
+	ac.Get(1)
+	if tc.loadCount(1) != 2 {
+		t.Errorf("Reloaded entry should use the new load time")
+	}
+}
+
+func TestTTLKeepsReferencedEntry(t *testing.T) {
+	tc := newTestCache()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ac := NewAbstractCacheWithTTL(2, time.Minute, clock.Now)
+	ac.Cache = tc
+
+	ac.Get(1)
+	clock.advance(2 * time.Minute)
+
+	// 仍被引用的条目不能被替换
+	ac.Get(1)
+	if tc.loadCount(1) != 1 || tc.releaseCount() != 0 {
+		t.Errorf("Referenced entry should not be reloaded")
+	}
+
+	ac.Release(1)
+	ac.Release(1)
+	ac.Get(1)
+	if tc.loadCount(1) != 2 {
+		t.Errorf("Expected reload once the entry is unreferenced, got %d loads", tc.loadCount(1))
+	}
+}
+
+func TestTTLExpiresPendingEntry(t *testing.T) {
+	tc := newTestCache()
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ac := NewAbstractCacheWithTTL(1, time.Minute, clock.Now, WithIdleRelease(time.Hour))
+	ac.Cache = tc
+	defer ac.Close()
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2) // 1 进入待释放队列
+	ac.Release(2)
+
+	clock.advance(time.Minute)
+	ac.Get(1)
+	if tc.loadCount(1) != 2 {
+		t.Errorf("Expected expired pending entry to be reloaded, got %d loads", tc.loadCount(1))
+	}
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 10 {
+		t.Errorf("Expected the stale pending object to be released, got %v", tc.releases)
+	}
+}
+
+func TestCloseWaitDrainsOnLastRelease(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	done := make(chan error)
+	go func() {
+		done <- ac.CloseWait(context.Background())
+	}()
+
+	// 等待 CloseWait 开始拒绝新的 Get
+	for {
+		if _, err := ac.Get(3); err == ErrCacheClosed {
+			break
+		}
+		ac.Release(3)
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatalf("CloseWait returned while key 1 is still referenced")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if tc.releaseCount() != 0 {
+		t.Fatalf("Nothing should be released before the references drain")
+	}
+
+	ac.Release(1)
+	if err := <-done; err != nil {
+		t.Fatalf("CloseWait failed: %v", err)
+	}
+	if tc.releaseCount() != 3 || ac.Stats().Count != 0 {
+		t.Errorf("Expected every entry to be released, got %d releases", tc.releaseCount())
+	}
+}
+
+func TestCloseWaitTimeout(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ac.CloseWait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected DeadlineExceeded, got %v", err)
+	}
+	if tc.releaseCount() != 0 {
+		t.Errorf("Nothing should be released after a timeout")
+	}
+	if _, err := ac.Get(2); err != ErrCacheClosed {
+		t.Errorf("Expected ErrCacheClosed after the timeout, got %v", err)
+	}
+
+	// 强制关闭时返回仍被引用的条目数
+	if referenced, _ := ac.Close(); referenced != 1 {
+		t.Errorf("Expected 1 referenced entry, got %d", referenced)
+	}
+	if tc.releaseCount() != 1 {
+		t.Errorf("Expected Close to release the entry, got %d releases", tc.releaseCount())
+	}
+}
+
+func TestGetIfPresent(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	if obj, ok := ac.GetIfPresent(1); ok || obj != nil {
+		t.Fatalf("Expected a miss, got %v, %v", obj, ok)
+	}
+	if tc.loadCount(1) != 0 {
+		t.Fatalf("GetIfPresent should not load")
+	}
+
+	ac.Get(1)
+	obj, ok := ac.GetIfPresent(1)
+	if !ok || obj.(int64) != 10 {
+		t.Fatalf("Expected 10, got %v, %v", obj, ok)
+	}
+	if ac.references[1] != 2 || tc.loadCount(1) != 1 {
+		t.Errorf("Expected 2 references and 1 load, got %d and %d", ac.references[1], tc.loadCount(1))
+	}
+
+	// 每次成功的 GetIfPresent 都需要一次 Release
+	ac.Release(1)
+	ac.Release(1)
+	if err := ac.Release(1); !errors.Is(err, ErrOverRelease) {
+		t.Errorf("Expected ErrOverRelease, got %v", err)
+	}
+	if stats := ac.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+// releaseErrorKeys 返回 err 中释放失败的键
+func releaseErrorKeys(t *testing.T, err error) map[int64]bool {
+	t.Helper()
+	var errs ReleaseErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ReleaseErrors, got %v", err)
+	}
+	keys := make(map[int64]bool)
+	for _, e := range errs {
+		if !errors.Is(e, errReleaseFailed) {
+			t.Errorf("Expected the releaser's error for key %d, got %v", e.Key, e.Err)
+		}
+		keys[e.Key] = true
+	}
+	return keys
+}
+
+func TestCloseReturnsReleaseErrors(t *testing.T) {
+	tc := newTestCache()
+	tc.failReleases = map[int64]bool{2: true, 4: true}
+	ac := NewAbstractCache(0)
+	ac.Cache = tc
+
+	for key := int64(1); key <= 4; key++ {
+		ac.Get(key)
+		ac.Release(key)
+	}
+
+	_, err := ac.Close()
+	keys := releaseErrorKeys(t, err)
+	if len(keys) != 2 || !keys[2] || !keys[4] {
+		t.Errorf("Expected keys 2 and 4 to fail, got %v", keys)
+	}
+	if tc.releaseCount() != 2 {
+		t.Errorf("Expected the other 2 entries to be released, got %d", tc.releaseCount())
+	}
+}
+
+func TestEvictionReleaseError(t *testing.T) {
+	tc := newTestCache()
+	tc.failReleases = map[int64]bool{1: true}
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	// 1 写回失败后留在缓存中，改为淘汰 2
+	if _, err := ac.Get(3); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, ok := ac.cache[1]; !ok {
+		t.Errorf("Entry that failed to release should stay cached")
+	}
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 20 {
+		t.Errorf("Expected key 2 to be evicted, got %v", tc.releases)
+	}
+
+	// 唯一可淘汰的条目释放失败时 Get 返回这个错误
+	_, err := ac.Get(4)
+	keys := releaseErrorKeys(t, err)
+	if len(keys) != 1 || !keys[1] {
+		t.Errorf("Expected key 1 to fail, got %v", keys)
+	}
+	if tc.loadCount(4) != 0 {
+		t.Errorf("Key 4 should not be loaded")
+	}
+}
+
+func TestGetCtxWaitsForRelease(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1)
+	ac.Cache = tc
+
+	ac.Get(1)
+	done := make(chan error)
+	go func() {
+		obj, err := ac.GetCtx(context.Background(), 2)
+		if err == nil && obj.(int64) != 20 {
+			t.Errorf("Expected 20, got %v", obj)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("GetCtx returned before a release: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ac.Release(1)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("GetCtx failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("GetCtx was not woken by Release")
+	}
+	if _, ok := ac.cache[1]; ok {
+		t.Errorf("Expected key 1 to be evicted for key 2")
+	}
+}
+
+func TestGetCtxTimeout(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := ac.GetCtx(ctx, 2); err != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	if tc.loadCount(2) != 0 {
+		t.Errorf("Key 2 should not be loaded")
+	}
+
+	// 有空间时 GetCtx 与 Get 相同
+	ac.Release(1)
+	if _, err := ac.GetCtx(context.Background(), 2); err != nil {
+		t.Errorf("GetCtx failed: %v", err)
+	}
+}
+
+func TestCacheFullErrorContext(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+	ac.Get(1)
+	ac.Get(2)
+
+	_, err := ac.Get(42)
+	if !errors.Is(err, CacheFullError) {
+		t.Fatalf("Expected CacheFullError, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "key 42") || !strings.Contains(msg, "2 of 2") {
+		t.Errorf("Expected key and capacity in %q", msg)
+	}
+
+	_, err = NewAbstractCacheFrom(1, map[int64]interface{}{1: 1, 2: 2})
+	if !errors.Is(err, CacheFullError) || !strings.Contains(err.Error(), "maxResource 1") {
+		t.Errorf("Expected CacheFullError with context from NewAbstractCacheFrom, got %v", err)
+	}
+}
+
+// waitLoading 等待 key 开始加载
+func waitLoading(t *testing.T, ac *AbstractCache, key int64) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		ac.lock.Lock()
+		loading := ac.getting[key]
+		ac.lock.Unlock()
+		if loading {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("key %d never started loading", key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetWithLoaderSingleFlight(t *testing.T) {
+	ac := NewAbstractCache(0)
+	ac.Cache = newTestCache()
+
+	var mu sync.Mutex
+	loads := 0
+	loader := func(key int64) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		return key * 100, nil
+	}
+
+	const callers = 16
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obj, err := ac.GetWithLoader(4, loader)
+			if err != nil || obj.(int64) != 400 {
+				t.Errorf("GetWithLoader returned (%v, %v)", obj, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("Expected exactly 1 load, got %d", loads)
+	}
+	if ac.references[4] != callers {
+		t.Errorf("Expected %d references, got %d", callers, ac.references[4])
+	}
+	// 已经缓存的键不再调用任何加载函数
+	if obj, err := ac.Get(4); err != nil || obj.(int64) != 400 {
+		t.Errorf("Get returned (%v, %v)", obj, err)
+	}
+}
+
+func TestGetWithLoaderDoesNotMixInFlightLoaders(t *testing.T) {
+	ac := NewAbstractCache(0)
+	ac.Cache = newTestCache()
+
+	unblock := make(chan struct{})
+	cold := func(key int64) (interface{}, error) {
+		<-unblock
+		return "cold", nil
+	}
+	remoteCalls := 0
+	remote := func(key int64) (interface{}, error) {
+		remoteCalls++
+		return "remote", nil
+	}
+
+	results := make(chan interface{}, 2)
+	go func() {
+		obj, _ := ac.GetWithLoader(9, cold)
+		results <- obj
+	}()
+	waitLoading(t, ac, 9)
+	go func() {
+		obj, _ := ac.GetWithLoader(9, remote)
+		results <- obj
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+
+	// 第二个调用者等待正在进行的加载并使用它的结果，自己的 loader 不会被调用
+	for i := 0; i < 2; i++ {
+		if obj := <-results; obj != "cold" {
+			t.Errorf("Expected the in-flight loader's value, got %v", obj)
+		}
+	}
+	if remoteCalls != 0 {
+		t.Errorf("Expected the waiting loader not to run, ran %d times", remoteCalls)
+	}
+}
+
+func TestGetWithLoaderRetriesWithOwnLoader(t *testing.T) {
+	ac := NewAbstractCache(0)
+	ac.Cache = newTestCache()
+
+	errCold := errors.New("cold store unavailable")
+	unblock := make(chan struct{})
+	cold := func(key int64) (interface{}, error) {
+		<-unblock
+		return nil, errCold
+	}
+	remote := func(key int64) (interface{}, error) {
+		return "remote", nil
+	}
+
+	coldErr := make(chan error, 1)
+	go func() {
+		_, err := ac.GetWithLoader(9, cold)
+		coldErr <- err
+	}()
+	waitLoading(t, ac, 9)
+	remoteResult := make(chan interface{}, 1)
+	go func() {
+		obj, _ := ac.GetWithLoader(9, remote)
+		remoteResult <- obj
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+
+	// 失败的加载只影响发起它的调用者，等待者用自己的 loader 重新加载
+	if err := <-coldErr; !errors.Is(err, errCold) {
+		t.Errorf("Expected errCold, got %v", err)
+	}
+	if obj := <-remoteResult; obj != "remote" {
+		t.Errorf("Expected the waiter to load with its own loader, got %v", obj)
+	}
+}
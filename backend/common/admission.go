@@ -0,0 +1,124 @@
+package common
+
+// 准入过滤: 缓存已满时，新加载的条目只有在估计的访问频率高于淘汰策略选出的牺牲者时才会留在缓存中，
+// 否则它照常加载并返回给调用者，但不淘汰牺牲者，引用归零时立即释放。
+// 顺序扫描这类只访问一次的条目因此不会把热点条目冲出缓存
+
+// sketchDepth 是频率草图的行数，sketchMaxCount 是每个计数器的上限
+const (
+	sketchDepth    = 4
+	sketchMaxCount = 15
+)
+
+// sketchSeeds 让每一行使用不同的哈希函数
+var sketchSeeds = [sketchDepth]uint64{0x8e9f2a7c3b1d5e4f, 0xc2b2ae3d27d4eb4f, 0x165667b19e3779f9, 0xd6e8feb86659fd93}
+
+// WithAdmission 开启 TinyLFU 准入过滤，用一个 Count-Min 草图估计每个键最近的访问频率。
+// 草图每行有 counters 个计数器(向上取 2 的幂)，counters <= 0 时与缓存容量相同。
+// 记录的访问次数达到计数器个数的 10 倍时所有计数减半，使频率反映最近的访问。
+// 缓存不限制容量时不会发生淘汰，这个选项没有作用
+func WithAdmission(counters int) Option {
+	return func(o *options) {
+		o.admission = true
+		o.admissionCounters = counters
+	}
+}
+
+// frequencySketch 是一个 4 位计数器的 Count-Min 草图，估计值只会偏大
+type frequencySketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newFrequencySketch(counters int) *frequencySketch {
+	n := 16
+	for n < counters {
+		n <<= 1
+	}
+	s := &frequencySketch{mask: uint64(n - 1), resetAt: 10 * n}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	return s
+}
+
+func (s *frequencySketch) index(key int64, row int) uint64 {
+	return mixHash(uint64(key)^sketchSeeds[row]) & s.mask
+}
+
+// increment 记录 key 的一次访问，只增加等于最小值的计数器(保守更新)，减小哈希冲突带来的偏差
+func (s *frequencySketch) increment(key int64) {
+	min := s.estimate(key)
+	if min < sketchMaxCount {
+		for i := range s.rows {
+			if c := &s.rows[i][s.index(key, i)]; *c == min {
+				*c++
+			}
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.age()
+	}
+}
+
+// estimate 返回 key 的估计访问次数
+func (s *frequencySketch) estimate(key int64) uint8 {
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(key, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// age 把所有计数减半，让很久以前的访问逐渐失去作用
+func (s *frequencySketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// rejects 判断缓存已满时是否拒绝缓存 key: 淘汰策略选出的第一个可淘汰的条目比 key 更常被访问时拒绝。
+// 没有可淘汰的条目时不拒绝，由 get 按缓存已满处理。调用者需持有锁
+func (c *TypedCache[V]) rejects(key int64) bool {
+	if c.admission == nil {
+		return false
+	}
+	if _, ok := c.pending[key]; ok {
+		return false
+	}
+	victim, found := int64(0), false
+	c.policy.victims(func(k int64) bool {
+		if c.evictable(k) {
+			victim, found = k, true
+		}
+		return found
+	})
+	return found && c.admission.estimate(key) <= c.admission.estimate(victim)
+}
+
+// dropTransient 在未准入的条目引用归零时把它移出缓存并释放。
+// 它被固定了或者释放失败时转为普通的条目，之后按淘汰策略淘汰。调用者需持有锁
+func (c *TypedCache[V]) dropTransient(key int64) {
+	delete(c.transient, key)
+	if c.pinned[key] {
+		c.policy.access(key)
+		return
+	}
+	if err := c.release(key, c.cache[key]); err != nil {
+		c.policy.access(key)
+		return
+	}
+	c.removeBytes(key)
+	delete(c.versions, key)
+	delete(c.references, key)
+	delete(c.cache, key)
+	c.count--
+}
@@ -0,0 +1,172 @@
+package common
+
+import "testing"
+
+// hotSetHitRate 交替访问 hot 个热点键和 scan 个只访问一次的键，返回访问热点键时的命中率
+func hotSetHitRate(t *testing.T, opts ...Option) float64 {
+	t.Helper()
+	const capacity, hot, scan, rounds = 10, 5, 20, 50
+	c := NewTypedCache[int64](capacity,
+		func(key int64) (int64, error) { return key * 10, nil },
+		func(int64) error { return nil },
+		opts...)
+	defer c.Close()
+
+	var hits, accesses int64
+	next := int64(1000)
+	for r := 0; r < rounds; r++ {
+		for key := int64(0); key < hot; key++ {
+			before := c.Stats().Hits
+			if _, err := c.Get(key); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			c.Release(key)
+			hits += c.Stats().Hits - before
+			accesses++
+		}
+		for i := 0; i < scan; i++ {
+			if _, err := c.Get(next); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			c.Release(next)
+			next++
+		}
+	}
+	return float64(hits) / float64(accesses)
+}
+
+func TestAdmissionPreservesHotSet(t *testing.T) {
+	plain := hotSetHitRate(t)
+	filtered := hotSetHitRate(t, WithAdmission(0))
+	if plain > 0.1 {
+		t.Errorf("Expected the scan to flush the hot set without admission, hit rate %.2f", plain)
+	}
+	if filtered < 0.9 {
+		t.Errorf("Expected admission to keep the hot set cached, hit rate %.2f", filtered)
+	}
+}
+
+func TestAdmissionTransientEntry(t *testing.T) {
+	var released []int64
+	c := NewTypedCache[int64](2,
+		func(key int64) (int64, error) { return key * 10, nil },
+		func(v int64) error { released = append(released, v); return nil },
+		WithAdmission(0))
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		for _, key := range []int64{1, 2} {
+			c.Get(key)
+			c.Release(key)
+		}
+	}
+
+	// 冷的键照常加载和返回，只在被引用期间占用缓存
+	v, err := c.Get(3)
+	if err != nil || v != 30 {
+		t.Fatalf("Expected the rejected key to be loaded, got %v, %v", v, err)
+	}
+	if !c.Contains(3) || c.Len() != 3 {
+		t.Errorf("Expected the referenced entry to stay until released, len %d", c.Len())
+	}
+	if err := c.Release(3); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if c.Contains(3) || !c.Contains(1) || !c.Contains(2) {
+		t.Errorf("Expected the rejected key to be dropped and the hot keys kept")
+	}
+	if len(released) != 1 || released[0] != 30 {
+		t.Errorf("Expected the rejected value to be released, got %v", released)
+	}
+	if stats := c.Stats(); stats.Rejections != 1 || stats.Evictions != 0 || stats.Count != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// 被引用期间再次访问的键转为普通的条目
+	c.Get(4)
+	c.Get(4)
+	c.Release(4)
+	c.Release(4)
+	if !c.Contains(4) {
+		t.Errorf("Expected a key accessed again while referenced to be admitted")
+	}
+}
+
+func TestShardedStatsCountRejections(t *testing.T) {
+	sc := NewShardedCache(2, 4, WithAdmission(0))
+	sc.Cache = newTestCache()
+	defer sc.Close()
+
+	// 热点键访问多次之后，只访问一次的键不会被准入
+	for i := 0; i < 5; i++ {
+		for key := int64(0); key < 4; key++ {
+			sc.Get(key)
+			sc.Release(key)
+		}
+	}
+	for key := int64(100); key < 120; key++ {
+		sc.Get(key)
+		sc.Release(key)
+	}
+
+	var want int64
+	for _, shard := range sc.shards {
+		want += shard.Stats().Rejections
+	}
+	if want == 0 {
+		t.Fatalf("Expected some loads to be rejected")
+	}
+	if got := sc.Stats().Rejections; got != want {
+		t.Errorf("Expected %d rejections across shards, got %d", want, got)
+	}
+}
+
+func TestSetShardsKeepsTransientEntries(t *testing.T) {
+	tc := newTestCache()
+	sc := NewShardedCache(2, 8, WithAdmission(0))
+	sc.Cache = tc
+	defer sc.Close()
+
+	for i := 0; i < 5; i++ {
+		for key := int64(0); key < 8; key++ {
+			sc.Get(key)
+			sc.Release(key)
+		}
+	}
+	// 持有一些没有被准入的冷键，其中换了分片的键在新分片上仍然是未准入的
+	var held []int64
+	moved := 0
+	for key := int64(100); key < 140; key++ {
+		sc.Get(key)
+		if sc.shard(key).transient[key] {
+			held = append(held, key)
+			if key%2 != key%3 {
+				moved++
+			}
+			continue
+		}
+		sc.Release(key)
+	}
+	if moved == 0 {
+		t.Fatalf("Expected some rejected keys to change shards")
+	}
+	if err := sc.SetShards(3); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+
+	before := tc.releaseCount()
+	for _, key := range held {
+		if !sc.shard(key).transient[key] {
+			t.Errorf("Expected key %d to stay transient after SetShards", key)
+		}
+		if err := sc.Release(key); err != nil {
+			t.Fatalf("Release of key %d failed: %v", key, err)
+		}
+		if sc.Contains(key) {
+			t.Errorf("Expected rejected key %d to be dropped on its last release", key)
+		}
+	}
+	if n := tc.releaseCount() - before; n != len(held) {
+		t.Errorf("Expected %d rejected values to be released, got %d", len(held), n)
+	}
+}
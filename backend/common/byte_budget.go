@@ -0,0 +1,40 @@
+package common
+
+// WithMaxBytes 让缓存按字节而不是条目数限制容量，sizeof 返回一个值占用的字节数。
+// 新装入的条目使总字节数超过 maxBytes 时，按淘汰策略的顺序淘汰无引用的条目直到总字节数不超过 maxBytes。
+// 单个条目比整个预算还大时仍然装入，但会淘汰其他所有能淘汰的条目。
+// 可以和 maxResource 同时使用，maxResource <= 0 时只按字节限制。ShardedCache 的每个分片各有 maxBytes 的预算
+func WithMaxBytes(maxBytes int64, sizeof func(value interface{}) int64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+		o.sizeof = sizeof
+	}
+}
+
+// addBytes 记录新放入缓存的条目的大小，调用者需持有锁
+func (c *TypedCache[V]) addBytes(key int64, obj V) {
+	if c.sizeof == nil {
+		return
+	}
+	size := c.sizeof(obj)
+	c.sizes[key] = size
+	c.bytes += size
+}
+
+// removeBytes 在条目离开缓存时减去它的大小，调用者需持有锁
+func (c *TypedCache[V]) removeBytes(key int64) {
+	if c.sizeof == nil {
+		return
+	}
+	c.bytes -= c.sizes[key]
+	delete(c.sizes, key)
+}
+
+// fitBytes 淘汰无引用的条目直到总字节数不超过 maxBytes 或者没有可以淘汰的条目，调用者需持有锁
+func (c *TypedCache[V]) fitBytes() {
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		if ok, _ := c.evictOne(); !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package common
+
+import "testing"
+
+// newByteCache 创建一个按字节限制的缓存，键 k 的值占 sizes[k] 字节
+func newByteCache(maxBytes int64, sizes map[int64]int64) (*AbstractCache, *testCache) {
+	tc := newTestCache()
+	ac := NewAbstractCache(0, WithMaxBytes(maxBytes, func(value interface{}) int64 {
+		return sizes[value.(int64)/10]
+	}))
+	ac.Cache = tc
+	return ac, tc
+}
+
+func TestMaxBytesEviction(t *testing.T) {
+	sizes := map[int64]int64{1: 40, 2: 30, 3: 20, 4: 50}
+	ac, tc := newByteCache(100, sizes)
+	access := func(key int64) {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(key)
+	}
+
+	for _, key := range []int64{1, 2, 3} {
+		access(key)
+	}
+	if stats := ac.Stats(); stats.Bytes != 90 || stats.MaxBytes != 100 || tc.releaseCount() != 0 {
+		t.Fatalf("Expected 90 of 100 bytes with no evictions, got %+v", stats)
+	}
+
+	// 再装入 50 字节超出预算 40 字节，淘汰最久未访问的 1(40 字节)正好够
+	access(4)
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 10 {
+		t.Fatalf("Expected only key 1 to be evicted, got %v", tc.releases)
+	}
+	if stats := ac.Stats(); stats.Bytes != 100 || stats.Count != 3 {
+		t.Errorf("Expected 100 bytes in 3 entries, got %+v", stats)
+	}
+
+	// 再装入 40 字节需要淘汰 2(30 字节)和 3(20 字节)
+	access(1)
+	if tc.releaseCount() != 3 || tc.releases[1].(int64) != 20 || tc.releases[2].(int64) != 30 {
+		t.Errorf("Expected keys 2 and 3 to be evicted, got %v", tc.releases)
+	}
+	if stats := ac.Stats(); stats.Bytes != 90 || stats.Count != 2 {
+		t.Errorf("Expected 90 bytes in 2 entries, got %+v", stats)
+	}
+}
+
+func TestMaxBytesOversizedValue(t *testing.T) {
+	sizes := map[int64]int64{1: 30, 2: 30, 3: 500}
+	ac, tc := newByteCache(100, sizes)
+
+	ac.Get(1)
+	ac.Get(2)
+	ac.Release(2)
+
+	// 比整个预算还大的值仍然装入，其他没有引用的条目都被淘汰，被引用的 1 保留
+	obj, err := ac.Get(3)
+	if err != nil || obj.(int64) != 30 {
+		t.Fatalf("Get returned (%v, %v)", obj, err)
+	}
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 20 {
+		t.Errorf("Expected key 2 to be evicted, got %v", tc.releases)
+	}
+	if stats := ac.Stats(); stats.Bytes != 530 || stats.Count != 2 {
+		t.Errorf("Expected 530 bytes in 2 entries, got %+v", stats)
+	}
+
+	// 释放之后下一次装入会把它淘汰
+	ac.Release(3)
+	ac.Release(1)
+	ac.Get(2)
+	if stats := ac.Stats(); stats.Bytes > 100 {
+		t.Errorf("Expected the cache back under budget, got %+v", stats)
+	}
+
+	ac.Close()
+	if stats := ac.Stats(); stats.Bytes != 0 {
+		t.Errorf("Expected 0 bytes after Close, got %d", stats.Bytes)
+	}
+}
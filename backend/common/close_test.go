@@ -0,0 +1,138 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheOperationsAfterClose(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+	ac.Get(1)
+	ac.Pin(2)
+
+	if _, err := ac.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := ac.Close(); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("Second Close: expected ErrCacheClosed, got %v", err)
+	}
+
+	ops := map[string]func() error{
+		"Get": func() error {
+			_, err := ac.Get(1)
+			return err
+		},
+		"Release": func() error { return ac.Release(1) },
+		"Pin":     func() error { return ac.Pin(3) },
+		"Unpin":   func() error { return ac.Unpin(2) },
+		"CompareAndSwap": func() error {
+			_, err := ac.CompareAndSwap(1, 0, int64(0))
+			return err
+		},
+		"Warm": func() error { return ac.Warm([]int64{4}) },
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, ErrCacheClosed) {
+			t.Errorf("%s after Close: expected ErrCacheClosed, got %v", name, err)
+		}
+	}
+}
+
+func TestPageCacheOperationsAfterClose(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	pc, err := NewPageCache(file, 2)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+	pgno, _ := pc.NewPage(nil)
+
+	if err := pc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := pc.Close(); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("Second Close: expected ErrCacheClosed, got %v", err)
+	}
+	if _, err := pc.NewPage(nil); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("NewPage after Close: expected ErrCacheClosed, got %v", err)
+	}
+	if _, err := pc.GetPage(pgno); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("GetPage after Close: expected ErrCacheClosed, got %v", err)
+	}
+	if err := pc.FlushDirty(); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("FlushDirty after Close: expected ErrCacheClosed, got %v", err)
+	}
+}
+
+func TestCloseWaitsForInFlightLoads(t *testing.T) {
+	errLoad := errors.New("load failed")
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var released []int64
+	c := NewTypedCache[int64](0,
+		func(key int64) (int64, error) {
+			<-block
+			if key == 2 {
+				return 0, errLoad
+			}
+			return key * 10, nil
+		},
+		func(v int64) error {
+			mu.Lock()
+			released = append(released, v)
+			mu.Unlock()
+			return nil
+		},
+		WithIdleRelease(time.Hour))
+
+	// 一个加载会成功，一个会失败，Close 在它们进行中开始
+	results := make(chan error, 2)
+	for _, key := range []int64{1, 2} {
+		go func(key int64) {
+			_, err := c.Get(key)
+			results <- err
+		}(key)
+	}
+	for deadline := time.Now().Add(time.Second); c.Len() != 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Loads did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.Close()
+			closed <- err
+		}()
+	}
+	select {
+	case <-closed:
+		t.Fatalf("Expected Close to wait for the in-flight loads")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(block)
+
+	errs := []error{<-results, <-results}
+	if !(errs[0] == nil && errors.Is(errs[1], errLoad) || errs[1] == nil && errors.Is(errs[0], errLoad)) {
+		t.Errorf("Expected one load to succeed and one to fail, got %v", errs)
+	}
+	// 并发的两个 Close 只有一个成功
+	first, second := <-closed, <-closed
+	if (first == nil) == (second == nil) || !errors.Is(first, ErrCacheClosed) && !errors.Is(second, ErrCacheClosed) {
+		t.Errorf("Expected exactly one Close to succeed, got %v and %v", first, second)
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Expected an empty cache after Close, got %d entries", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(released) != 1 || released[0] != 10 {
+		t.Errorf("Expected the loaded value to be released by Close, got %v", released)
+	}
+}
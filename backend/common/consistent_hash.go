@@ -0,0 +1,289 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultRingReplicas 是 WithConsistentHashing 没有指定时每个分片在哈希环上的虚拟节点数
+const defaultRingReplicas = 128
+
+// WithConsistentHashing 让 ShardedCache 按一致性哈希选择分片，每个分片在哈希环上有 replicas 个虚拟节点，
+// replicas <= 0 时使用 defaultRingReplicas。这样 SetShards 从 N 个分片变为 N+1 个时只有约 1/(N+1) 的键换了分片，
+// 而按 key mod N 选择时几乎所有的键都会换分片。对单独的 AbstractCache 没有作用
+func WithConsistentHashing(replicas int) Option {
+	return func(o *options) {
+		if replicas <= 0 {
+			replicas = defaultRingReplicas
+		}
+		o.ringReplicas = replicas
+	}
+}
+
+// ringNode 是哈希环上的一个虚拟节点
+type ringNode struct {
+	hash  uint64
+	shard int
+}
+
+// buildRing 为 shardCount 个分片创建哈希环，分片 i 的虚拟节点只取决于 i，
+// 所以增加或减少分片时其余分片的节点位置不变
+func buildRing(shardCount, replicas int) []ringNode {
+	ring := make([]ringNode, 0, shardCount*replicas)
+	for i := 0; i < shardCount; i++ {
+		for r := 0; r < replicas; r++ {
+			ring = append(ring, ringNode{hash: mixHash(uint64(i)<<32 | uint64(r)), shard: i})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	return ring
+}
+
+// ringLookup 返回哈希环上 key 之后的第一个虚拟节点所属的分片
+func ringLookup(ring []ringNode, key int64) int {
+	h := mixHash(uint64(key))
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= h })
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].shard
+}
+
+// mixHash 是 splitmix64 的混合函数，把相邻的整数打散到整个 uint64 空间
+func mixHash(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// SetShards 把分片数调整为 n (n <= 0 时为 1)，每个分片的容量按创建时的 maxResource 重新分配。
+// 只有换了分片的键被移到新的分片，它们的值、引用计数、固定和版本号都保持不变，其余条目原地不动；
+// 开启 WithConsistentHashing 时换分片的键只占很小的一部分。
+// SetShards 等待进行中的操作结束，执行期间其他操作被阻塞。新分片超出容量时淘汰的条目释放失败时返回 ReleaseErrors
+func (sc *ShardedCache) SetShards(n int) error {
+	if n <= 0 {
+		n = 1
+	}
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.closed {
+		return ErrCacheClosed
+	}
+
+	old := sc.shards
+	perShard := perShardResource(sc.maxResource, n)
+	shards := make([]*AbstractCache, n)
+	copy(shards, old)
+	for i := len(old); i < n; i++ {
+		shards[i] = sc.newShard(perShard)
+	}
+	sc.shards = shards
+	if sc.ring != nil {
+		sc.ring = buildRing(n, sc.replicas)
+	}
+
+	moved := make([][]movedEntry[interface{}], n)
+	for i, shard := range old {
+		entries := shard.takeEntries(func(key int64) bool { return i >= n || sc.shardIndex(key) != i })
+		for _, e := range entries {
+			dest := sc.shardIndex(e.key)
+			moved[dest] = append(moved[dest], e)
+		}
+	}
+	// 被移除的分片已经没有条目了，关闭它们以停止后台协程
+	if len(old) > n {
+		for _, shard := range old[n:] {
+			shard.Close()
+		}
+	}
+
+	var errs ReleaseErrors
+	for i, shard := range shards {
+		err := shard.putEntries(moved[i], perShard)
+		if err != nil {
+			errs = append(errs, err.(ReleaseErrors)...)
+		}
+	}
+	return errs.orNil()
+}
+
+// movedEntry 是 SetShards 从一个分片移到另一个分片的条目及其状态
+type movedEntry[V any] struct {
+	key    int64
+	value  V
+	refs   int
+	pinned bool
+	// transient 为 true 时条目没有被准入，不在淘汰策略中，引用归零时释放
+	transient bool
+	version   uint64
+	loadedAt  time.Time
+	// owners 是 GetOwned 记在各个 owner 名下的引用数
+	owners map[int64]int
+	stacks []string
+	// pending 为 true 时条目已经被淘汰，还在待释放队列中
+	pending bool
+	// stale 是被 Invalidate 移出缓存、仍被引用的旧值，detached 为 true 时键只剩下这些旧值，value 无效
+	stale    []V
+	detached bool
+}
+
+// takeEntries 把 move 返回 true 的条目连同它们的状态移出缓存，不释放它们，这些键的墓碑被丢弃。
+// 先等待后台协程释放完这些键、进行中的加载结束，避免同一个键在另一个分片中重新加载时这里还没有写回；
+// 加载结束后也就没有加载期间被 Invalidate 的标记，被 Invalidate 移出缓存的旧值作为 stale 一起移动
+func (c *TypedCache[V]) takeEntries(move func(key int64) bool) []movedEntry[V] {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for c.releasing(move) {
+		c.loaded.Wait()
+	}
+
+	var entries []movedEntry[V]
+	for key, obj := range c.cache {
+		if !move(key) {
+			continue
+		}
+		entries = append(entries, movedEntry[V]{
+			key:       key,
+			value:     obj,
+			pinned:    c.pinned[key],
+			transient: c.transient[key],
+			version:   c.versions[key],
+			loadedAt:  c.loadedAt[key],
+		})
+		c.policy.remove(key)
+		c.removeBytes(key)
+		delete(c.cache, key)
+		delete(c.pinned, key)
+		delete(c.versions, key)
+		delete(c.loadedAt, key)
+		delete(c.transient, key)
+		c.count--
+	}
+	// 只剩下旧值的键也要带走它们的引用，否则之后在新分片上的 Release 找不到这个键
+	for key := range c.stale {
+		if move(key) {
+			if _, ok := c.cache[key]; !ok {
+				entries = append(entries, movedEntry[V]{key: key, detached: true})
+			}
+		}
+	}
+	for i := range entries {
+		e := &entries[i]
+		key := e.key
+		e.refs = c.references[key]
+		e.stacks = c.refStacks[key]
+		e.stale = c.stale[key]
+		for owner, keys := range c.owned {
+			if keys[key] > 0 {
+				if e.owners == nil {
+					e.owners = make(map[int64]int)
+				}
+				e.owners[owner] = keys[key]
+				delete(keys, key)
+			}
+			if len(keys) == 0 {
+				delete(c.owned, owner)
+			}
+		}
+		delete(c.references, key)
+		delete(c.stale, key)
+		if c.refStacks != nil {
+			delete(c.refStacks, key)
+		}
+	}
+	for key, obj := range c.pending {
+		if move(key) {
+			entries = append(entries, movedEntry[V]{key: key, value: obj, loadedAt: c.loadedAt[key], pending: true})
+			delete(c.pending, key)
+			delete(c.loadedAt, key)
+		}
+	}
+	for key := range c.negative {
+		if move(key) {
+			delete(c.negative, key)
+		}
+	}
+	c.freed.Broadcast()
+	c.checkDrained()
+	return entries
+}
+
+// releasing 判断是否有 move 返回 true 的键正在被后台协程释放，调用者需持有锁
+func (c *TypedCache[V]) releasing(move func(key int64) bool) bool {
+	for key := range c.getting {
+		if move(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// putEntries 把 takeEntries 取出的条目放入缓存并把容量设为 maxResource，
+// 超出容量时按淘汰策略淘汰无引用的条目，返回淘汰时释放失败的条目
+func (c *TypedCache[V]) putEntries(entries []movedEntry[V], maxResource int) error {
+	c.lock.Lock()
+	c.maxResource = maxResource
+	c.policy.setCapacity(maxResource)
+	for _, e := range entries {
+		if !e.loadedAt.IsZero() {
+			c.loadedAt[e.key] = e.loadedAt
+		}
+		if e.pending {
+			c.pending[e.key] = e.value
+			continue
+		}
+		if !e.detached {
+			c.cache[e.key] = e.value
+			c.count++
+			if e.transient {
+				c.transient[e.key] = true
+			} else {
+				c.touch(e.key)
+			}
+			c.addBytes(e.key, e.value)
+			if e.pinned {
+				c.pinned[e.key] = true
+			}
+			c.versions[e.key] = e.version
+			// 新的版本号必须大于移入的版本号，否则之后分配的版本号可能与它重复
+			if e.version > c.nextVersion {
+				c.nextVersion = e.version
+			}
+		}
+		if e.refs > 0 || e.detached {
+			c.references[e.key] = e.refs
+		}
+		if len(e.stale) > 0 {
+			c.stale[e.key] = e.stale
+		}
+		for owner, n := range e.owners {
+			keys := c.owned[owner]
+			if keys == nil {
+				keys = make(map[int64]int)
+				c.owned[owner] = keys
+			}
+			keys[e.key] += n
+		}
+		if c.refStacks != nil && e.stacks != nil {
+			c.refStacks[e.key] = e.stacks
+		}
+	}
+
+	var errs ReleaseErrors
+	for maxResource > 0 && c.count > maxResource {
+		ok, err := c.evictOne()
+		if !ok {
+			if err != nil {
+				errs = append(errs, err.(ReleaseErrors)...)
+			}
+			break
+		}
+	}
+	c.fitBytes()
+	c.freed.Broadcast()
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return errs.orNil()
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+// remappedFraction 返回 SetShards(to) 之后换了分片的键所占的比例
+func remappedFraction(t *testing.T, sc *ShardedCache, to int, keys int) float64 {
+	before := make([]int, keys)
+	for key := range before {
+		before[key] = sc.shardIndex(int64(key))
+	}
+	if err := sc.SetShards(to); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+	moved := 0
+	for key, shard := range before {
+		if sc.shardIndex(int64(key)) != shard {
+			moved++
+		}
+	}
+	return float64(moved) / float64(keys)
+}
+
+func TestConsistentHashingRemapFraction(t *testing.T) {
+	const keys = 100000
+	sc := NewShardedCache(4, 0, WithConsistentHashing(0))
+	sc.Cache = newTestCache()
+	fraction := remappedFraction(t, sc, 5, keys)
+	// 理论上最少有 1/5 的键要移到新的分片
+	if fraction < 0.15 || fraction > 0.25 {
+		t.Errorf("Expected about 1/5 of the keys to move, got %.3f", fraction)
+	}
+	for key := 0; key < keys; key += 97 {
+		if shard := sc.shardIndex(int64(key)); shard < 0 || shard >= 5 {
+			t.Fatalf("Key %d mapped to shard %d", key, shard)
+		}
+	}
+
+	mod := NewShardedCache(4, 0)
+	mod.Cache = newTestCache()
+	if fraction := remappedFraction(t, mod, 5, keys); fraction < 0.5 {
+		t.Errorf("Expected key mod N to move most keys, got %.3f", fraction)
+	}
+}
+
+func TestConsistentHashingOnlyMovesToNewShard(t *testing.T) {
+	sc := NewShardedCache(4, 0, WithConsistentHashing(64))
+	sc.Cache = newTestCache()
+	before := make(map[int64]int)
+	for key := int64(0); key < 10000; key++ {
+		before[key] = sc.shardIndex(key)
+	}
+	sc.SetShards(5)
+	for key, shard := range before {
+		if now := sc.shardIndex(key); now != shard && now != 4 {
+			t.Fatalf("Key %d moved from shard %d to old shard %d", key, shard, now)
+		}
+	}
+}
+
+func TestSetShardsPreservesEntries(t *testing.T) {
+	tc := newTestCache()
+	sc := NewShardedCache(4, 0, WithConsistentHashing(0))
+	sc.Cache = tc
+	for key := int64(0); key < 100; key++ {
+		sc.Get(key)
+		if key%2 == 0 {
+			sc.Release(key)
+		}
+	}
+	sc.Pin(200)
+
+	if err := sc.SetShards(5); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+	if stats := sc.Stats(); stats.Count != 101 {
+		t.Errorf("Expected 101 entries after SetShards, got %d", stats.Count)
+	}
+	// 奇数键的引用随条目一起移动
+	for key := int64(1); key < 100; key += 2 {
+		if err := sc.Release(key); err != nil {
+			t.Fatalf("Release of key %d after SetShards failed: %v", key, err)
+		}
+	}
+	for key := int64(0); key < 100; key++ {
+		obj, err := sc.Get(key)
+		if err != nil || obj.(int64) != key*10 {
+			t.Fatalf("Expected %d, got %v, %v", key*10, obj, err)
+		}
+		sc.Release(key)
+		if n := tc.loadCount(key); n != 1 {
+			t.Errorf("Expected key %d to stay cached, loaded %d times", key, n)
+		}
+	}
+	if err := sc.Unpin(200); err != nil {
+		t.Errorf("Unpin after SetShards failed: %v", err)
+	}
+
+	if err := sc.SetShards(2); err != nil {
+		t.Fatalf("Shrinking failed: %v", err)
+	}
+	if stats := sc.Stats(); stats.Count != 101 || tc.releaseCount() != 0 {
+		t.Errorf("Expected shrinking to keep all entries, got %+v and %d releases", stats, tc.releaseCount())
+	}
+	sc.Close()
+	if tc.releaseCount() != 101 {
+		t.Errorf("Expected Close to release every entry, got %d", tc.releaseCount())
+	}
+	if err := sc.SetShards(3); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("Expected ErrCacheClosed, got %v", err)
+	}
+}
+
+func TestSetShardsEvictsOverCapacity(t *testing.T) {
+	tc := newTestCache()
+	sc := NewShardedCache(2, 8)
+	sc.Cache = tc
+	for key := int64(0); key < 8; key++ {
+		sc.Get(key)
+		sc.Release(key)
+	}
+	// 缩到一个分片之后容量仍然是 8
+	if err := sc.SetShards(1); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+	if stats := sc.Stats(); stats.Count != 8 || stats.MaxResource != 8 {
+		t.Errorf("Expected 8 of 8 entries, got %+v", stats)
+	}
+	if err := sc.SetShards(3); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+	// 每个分片的容量是 8/3 = 2
+	if stats := sc.Stats(); stats.Count > 6 || stats.MaxResource != 6 {
+		t.Errorf("Expected at most 6 entries, got %+v", stats)
+	}
+	if tc.releaseCount() < 2 {
+		t.Errorf("Expected entries over capacity to be evicted, got %d releases", tc.releaseCount())
+	}
+}
@@ -0,0 +1,251 @@
+package common
+
+import "container/list"
+
+// Policy 是缓存选择淘汰条目的策略
+type Policy int
+
+const (
+	// PolicyLRU 淘汰最久未访问的条目，这是默认的策略
+	PolicyLRU Policy = iota
+	// PolicyARC 使用自适应替换缓存(ARC)，同时考虑访问的新近程度和频率。
+	// 只访问过一次的条目(例如顺序扫描)先被淘汰，访问过多次的热点条目不容易被扫描冲掉
+	PolicyARC
+	// PolicyGreedyDual 同时考虑访问的新近程度和重新加载的代价(见 WithCost)，代价高的条目更晚被淘汰
+	PolicyGreedyDual
+)
+
+// WithPolicy 设置缓存的淘汰策略
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = p
+	}
+}
+
+// evictionPolicy 决定淘汰的顺序，所有方法都在缓存的锁下调用
+type evictionPolicy interface {
+	// access 在条目被访问或者装入缓存时调用
+	access(key int64)
+	// evicted 在条目被淘汰之后调用
+	evicted(key int64)
+	// remove 在条目因为过期等原因离开缓存时调用，不影响之后的淘汰决策
+	remove(key int64)
+	// victims 按淘汰的优先顺序对缓存中的键调用 fn，fn 返回 true 时停止。fn 中可以调用 evicted
+	victims(fn func(key int64) bool)
+	// setCapacity 在缓存容量改变时调用
+	setCapacity(n int)
+	reset()
+}
+
+func newEvictionPolicy(p Policy, capacity int, cost func(key int64) int) evictionPolicy {
+	switch p {
+	case PolicyARC:
+		return newARCPolicy(capacity)
+	case PolicyGreedyDual:
+		return newGDPolicy(cost)
+	}
+	return newLRUPolicy()
+}
+
+// keyList 是一个按访问顺序保存键的链表，队头是最近访问的。elems 指向每个键的链表元素，所有操作都是 O(1)
+type keyList struct {
+	list  *list.List
+	elems map[int64]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{list: list.New(), elems: make(map[int64]*list.Element)}
+}
+
+func (l *keyList) contains(key int64) bool {
+	_, ok := l.elems[key]
+	return ok
+}
+
+func (l *keyList) len() int {
+	return l.list.Len()
+}
+
+func (l *keyList) pushFront(key int64) {
+	l.elems[key] = l.list.PushFront(key)
+}
+
+func (l *keyList) moveToFront(key int64) {
+	l.list.MoveToFront(l.elems[key])
+}
+
+func (l *keyList) remove(key int64) bool {
+	elem, ok := l.elems[key]
+	if !ok {
+		return false
+	}
+	l.list.Remove(elem)
+	delete(l.elems, key)
+	return true
+}
+
+// removeBack 删除最久未访问的键
+func (l *keyList) removeBack() {
+	if elem := l.list.Back(); elem != nil {
+		l.remove(elem.Value.(int64))
+	}
+}
+
+// each 从最久未访问的键开始调用 fn，fn 返回 true 时停止，fn 中可以删除当前的键
+func (l *keyList) each(fn func(key int64) bool) bool {
+	for elem := l.list.Back(); elem != nil; {
+		prev := elem.Prev()
+		if fn(elem.Value.(int64)) {
+			return true
+		}
+		elem = prev
+	}
+	return false
+}
+
+func (l *keyList) reset() {
+	l.list.Init()
+	l.elems = make(map[int64]*list.Element)
+}
+
+// lruPolicy 淘汰最久未访问的条目
+type lruPolicy struct {
+	keys *keyList
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{keys: newKeyList()}
+}
+
+func (p *lruPolicy) access(key int64) {
+	if p.keys.contains(key) {
+		p.keys.moveToFront(key)
+		return
+	}
+	p.keys.pushFront(key)
+}
+
+func (p *lruPolicy) evicted(key int64) {
+	p.keys.remove(key)
+}
+
+func (p *lruPolicy) remove(key int64) {
+	p.keys.remove(key)
+}
+
+func (p *lruPolicy) victims(fn func(key int64) bool) {
+	p.keys.each(fn)
+}
+
+func (p *lruPolicy) setCapacity(n int) {}
+
+func (p *lruPolicy) reset() {
+	p.keys.reset()
+}
+
+// arcPolicy 实现 ARC: t1 保存只访问过一次的条目，t2 保存访问过多次的条目，
+// b1/b2 是最近从 t1/t2 淘汰的键(幽灵列表，不保存值)。
+// 幽灵列表命中说明对应的列表太小，据此调整 t1 的目标大小 target
+type arcPolicy struct {
+	t1, t2, b1, b2 *keyList
+	target         int
+	capacity       int
+}
+
+func newARCPolicy(capacity int) *arcPolicy {
+	return &arcPolicy{
+		t1:       newKeyList(),
+		t2:       newKeyList(),
+		b1:       newKeyList(),
+		b2:       newKeyList(),
+		capacity: capacity,
+	}
+}
+
+func (p *arcPolicy) access(key int64) {
+	switch {
+	case p.t1.contains(key):
+		p.t1.remove(key)
+		p.t2.pushFront(key)
+	case p.t2.contains(key):
+		p.t2.moveToFront(key)
+	case p.b1.contains(key):
+		// 最近从 t1 淘汰的键又被访问，增大 t1 的目标大小
+		p.target += maxInt(p.b2.len()/p.b1.len(), 1)
+		if p.capacity > 0 && p.target > p.capacity {
+			p.target = p.capacity
+		}
+		p.b1.remove(key)
+		p.t2.pushFront(key)
+	case p.b2.contains(key):
+		p.target -= maxInt(p.b1.len()/p.b2.len(), 1)
+		if p.target < 0 {
+			p.target = 0
+		}
+		p.b2.remove(key)
+		p.t2.pushFront(key)
+	default:
+		p.t1.pushFront(key)
+	}
+}
+
+func (p *arcPolicy) evicted(key int64) {
+	if p.t1.remove(key) {
+		p.b1.pushFront(key)
+	} else if p.t2.remove(key) {
+		p.b2.pushFront(key)
+	}
+	p.trimGhosts()
+}
+
+// trimGhosts 限制幽灵列表的长度: |t1|+|b1| <= c，总长度 <= 2c
+func (p *arcPolicy) trimGhosts() {
+	if p.capacity <= 0 {
+		return
+	}
+	for p.b1.len() > 0 && p.t1.len()+p.b1.len() > p.capacity {
+		p.b1.removeBack()
+	}
+	for p.b2.len() > 0 && p.t1.len()+p.t2.len()+p.b1.len()+p.b2.len() > 2*p.capacity {
+		p.b2.removeBack()
+	}
+}
+
+func (p *arcPolicy) remove(key int64) {
+	p.t1.remove(key)
+	p.t2.remove(key)
+}
+
+// victims 在 t1 超过目标大小时优先淘汰 t1，否则优先淘汰 t2，优先的列表中都被引用时再尝试另一个
+func (p *arcPolicy) victims(fn func(key int64) bool) {
+	first, second := p.t2, p.t1
+	if p.t1.len() > 0 && p.t1.len() > p.target {
+		first, second = p.t1, p.t2
+	}
+	if !first.each(fn) {
+		second.each(fn)
+	}
+}
+
+func (p *arcPolicy) setCapacity(n int) {
+	p.capacity = n
+	if n > 0 && p.target > n {
+		p.target = n
+	}
+	p.trimGhosts()
+}
+
+func (p *arcPolicy) reset() {
+	p.t1.reset()
+	p.t2.reset()
+	p.b1.reset()
+	p.b2.reset()
+	p.target = 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
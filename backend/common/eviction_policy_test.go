@@ -0,0 +1,84 @@
+package common
+
+import "testing"
+
+// scanWorkload 先反复访问热点键，再顺序扫描大量只访问一次的键，最后重新访问热点键，
+// 返回最后一轮访问热点键时的命中次数
+func scanWorkload(t *testing.T, policy Policy) int64 {
+	const capacity, hot, scan = 10, 5, 100
+	ac := NewAbstractCache(capacity, WithPolicy(policy))
+	ac.Cache = newTestCache()
+	access := func(key int64) {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(key)
+	}
+
+	for round := 0; round < 3; round++ {
+		for key := int64(0); key < hot; key++ {
+			access(key)
+		}
+	}
+	for key := int64(1000); key < 1000+scan; key++ {
+		access(key)
+	}
+
+	before := ac.Stats().Hits
+	for key := int64(0); key < hot; key++ {
+		access(key)
+	}
+	return ac.Stats().Hits - before
+}
+
+func TestARCProtectsFrequentSetFromScan(t *testing.T) {
+	lruHits := scanWorkload(t, PolicyLRU)
+	arcHits := scanWorkload(t, PolicyARC)
+	if lruHits != 0 {
+		t.Errorf("Expected the scan to flush the hot set under LRU, got %d hits", lruHits)
+	}
+	if arcHits != 5 {
+		t.Errorf("Expected ARC to keep all 5 hot keys, got %d hits", arcHits)
+	}
+}
+
+func TestARCSkipsReferencedEntries(t *testing.T) {
+	ac := NewAbstractCache(2, WithPolicy(PolicyARC))
+	tc := newTestCache()
+	ac.Cache = tc
+
+	// 1 只访问一次并且一直被引用，2 访问过两次
+	ac.Get(1)
+	ac.Get(2)
+	ac.Release(2)
+	ac.Get(2)
+	ac.Release(2)
+
+	// t1 中唯一的条目被引用，只能淘汰 t2 中的 2
+	if _, err := ac.Get(3); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	ac.Release(3)
+	if _, ok := ac.GetIfPresent(2); ok {
+		t.Errorf("Expected key 2 to be evicted")
+	}
+	if _, ok := ac.GetIfPresent(1); !ok {
+		t.Errorf("Expected referenced key 1 to stay cached")
+	}
+}
+
+func TestARCGhostHitAdaptsTarget(t *testing.T) {
+	p := newARCPolicy(2)
+	p.access(1)
+	p.access(2)
+	p.evicted(1)
+	if !p.b1.contains(1) {
+		t.Fatalf("Expected evicted key 1 in the b1 ghost list")
+	}
+
+	// 幽灵命中后键进入 t2，t1 的目标大小增大
+	p.access(1)
+	if !p.t2.contains(1) || p.b1.contains(1) || p.target != 1 {
+		t.Errorf("Unexpected state after ghost hit: t2=%v b1=%v target=%d", p.t2.contains(1), p.b1.contains(1), p.target)
+	}
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetUnref(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	if _, ok := ac.GetUnref(1); ok {
+		t.Errorf("Expected a miss for an uncached key")
+	}
+	if tc.loadCount(1) != 0 {
+		t.Errorf("GetUnref should not load the key")
+	}
+
+	ac.Get(1)
+	ac.Release(1)
+	v, ok := ac.GetUnref(1)
+	if !ok || v != int64(10) {
+		t.Fatalf("Expected the cached value, got %v, %v", v, ok)
+	}
+	// 没有取得引用，再释放一次就是多释放
+	if err := ac.Release(1); !errors.Is(err, ErrOverRelease) {
+		t.Errorf("Expected GetUnref not to add a reference, got %v", err)
+	}
+
+	// 条目仍然可以被正常淘汰
+	ac.Get(2)
+	ac.Release(2)
+	ac.Get(3)
+	ac.Release(3)
+	if ac.Contains(1) {
+		t.Errorf("Expected key 1 to be evicted")
+	}
+	if len(tc.releases) != 1 || tc.releases[0] != int64(10) {
+		t.Errorf("Expected key 1 to be released on eviction, got %v", tc.releases)
+	}
+	if _, ok := ac.GetUnref(1); ok {
+		t.Errorf("Expected GetUnref to miss after eviction")
+	}
+}
+
+func TestGetUnrefKeepsReferences(t *testing.T) {
+	ac := NewAbstractCache(1)
+	ac.Cache = newTestCache()
+
+	ac.Get(1)
+	ac.GetUnref(1)
+	ac.Release(1)
+	// 唯一的引用已经释放，缓存已满时可以装入其他键
+	if _, err := ac.Get(2); err != nil {
+		t.Fatalf("Expected key 1 to be evictable, got %v", err)
+	}
+	ac.Release(2)
+}
@@ -0,0 +1,81 @@
+package common
+
+import "sort"
+
+// WithCost 设置重新加载每个键的代价，只对 PolicyGreedyDual 有作用，没有设置时每个键的代价都是 1。
+// cost 在缓存的锁下调用，不能再访问缓存。AbstractCache 的 Cache 实现了 costForCache 时默认用它
+func WithCost(cost func(key int64) int) Option {
+	return func(o *options) {
+		o.cost = cost
+	}
+}
+
+// costCache 可以由 Cache 实现，报告重新加载 key 的代价，例如索引的根页比叶子页的代价更高
+type costCache interface {
+	costForCache(key int64) int
+}
+
+// gdPolicy 实现 GreedyDual: 条目被访问时的优先级 H 是当前的膨胀值 L 加上它的代价，
+// 淘汰 H 最小的条目，并把 L 提高到被淘汰条目的 H。
+// 代价高的条目要经过更多次淘汰才会被淘汰，所有条目代价相同时淘汰顺序与 LRU 相同
+type gdPolicy struct {
+	cost      func(key int64) int
+	inflation int64
+	priority  map[int64]int64
+	// seq 记录每个键最近一次访问的顺序，H 相同时先淘汰更早访问的
+	seq     map[int64]uint64
+	nextSeq uint64
+}
+
+func newGDPolicy(cost func(key int64) int) *gdPolicy {
+	if cost == nil {
+		cost = func(int64) int { return 1 }
+	}
+	return &gdPolicy{cost: cost, priority: make(map[int64]int64), seq: make(map[int64]uint64)}
+}
+
+func (p *gdPolicy) access(key int64) {
+	p.priority[key] = p.inflation + int64(p.cost(key))
+	p.nextSeq++
+	p.seq[key] = p.nextSeq
+}
+
+func (p *gdPolicy) evicted(key int64) {
+	if h, ok := p.priority[key]; ok && h > p.inflation {
+		p.inflation = h
+	}
+	p.remove(key)
+}
+
+func (p *gdPolicy) remove(key int64) {
+	delete(p.priority, key)
+	delete(p.seq, key)
+}
+
+// victims 按 H 从小到大调用 fn，每次都要对所有键排序，适合条目数不多的缓存
+func (p *gdPolicy) victims(fn func(key int64) bool) {
+	keys := make([]int64, 0, len(p.priority))
+	for key := range p.priority {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		hi, hj := p.priority[keys[i]], p.priority[keys[j]]
+		if hi != hj {
+			return hi < hj
+		}
+		return p.seq[keys[i]] < p.seq[keys[j]]
+	})
+	for _, key := range keys {
+		if fn(key) {
+			return
+		}
+	}
+}
+
+func (p *gdPolicy) setCapacity(n int) {}
+
+func (p *gdPolicy) reset() {
+	p.inflation = 0
+	p.priority = make(map[int64]int64)
+	p.seq = make(map[int64]uint64)
+}
@@ -0,0 +1,85 @@
+package common
+
+import "testing"
+
+// costTestCache 的键 1 重新加载的代价是 costlyKeyCost，其他键是 1
+type costTestCache struct {
+	*testCache
+}
+
+const costlyKeyCost = 4
+
+func (c costTestCache) costForCache(key int64) int {
+	if key == 1 {
+		return costlyKeyCost
+	}
+	return 1
+}
+
+// survivingLoads 先访问键 1 再依次访问 2、3，然后不断装入新的键，返回键 1 被淘汰之前装入的新键数
+func survivingLoads(t *testing.T, ac *AbstractCache) int {
+	access := func(key int64) {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(key)
+	}
+	for key := int64(1); key <= 3; key++ {
+		access(key)
+	}
+	for n := 0; n < 100; n++ {
+		access(int64(100 + n))
+		if !ac.Contains(1) {
+			return n
+		}
+	}
+	return 100
+}
+
+func TestGreedyDualKeepsCostlyEntries(t *testing.T) {
+	lru := NewAbstractCache(3)
+	lru.Cache = costTestCache{newTestCache()}
+	if n := survivingLoads(t, lru); n != 0 {
+		t.Errorf("Expected LRU to evict the oldest entry first, survived %d loads", n)
+	}
+
+	gd := NewAbstractCache(3, WithPolicy(PolicyGreedyDual))
+	gd.Cache = costTestCache{newTestCache()}
+	// 代价为 4 的条目要等到膨胀值追上它，期间每次装入淘汰一个代价为 1 的条目
+	if n := survivingLoads(t, gd); n < costlyKeyCost-1 {
+		t.Errorf("Expected the costly entry to survive at least %d loads, survived %d", costlyKeyCost-1, n)
+	}
+	if n := survivingLoads(t, gd); n == 100 {
+		t.Errorf("Expected the costly entry to be evicted eventually")
+	}
+}
+
+func TestGreedyDualUniformCostIsLRU(t *testing.T) {
+	ac := NewAbstractCache(2, WithPolicy(PolicyGreedyDual))
+	tc := newTestCache()
+	ac.Cache = tc
+	for _, key := range []int64{1, 2, 1, 3} {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	// 代价相同时 2 是最久未访问的
+	if ac.Contains(2) || !ac.Contains(1) || !ac.Contains(3) {
+		t.Errorf("Expected key 2 to be evicted with uniform costs")
+	}
+}
+
+func TestGreedyDualWithCost(t *testing.T) {
+	tc := NewTypedCache[int64](2,
+		func(key int64) (int64, error) { return key, nil },
+		func(int64) error { return nil },
+		WithPolicy(PolicyGreedyDual),
+		WithCost(func(key int64) int { return int(key) }))
+	// 键的代价等于它本身，访问顺序相同时先淘汰代价低的
+	for _, key := range []int64{5, 1, 2} {
+		tc.Get(key)
+		tc.Release(key)
+	}
+	if tc.Contains(1) || !tc.Contains(5) || !tc.Contains(2) {
+		t.Errorf("Expected the cheapest key 1 to be evicted")
+	}
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// KeyStat 是一个键自上次 ResetTopKeys 以来的访问次数
+type KeyStat struct {
+	Key   int64
+	Count int64
+}
+
+// WithHotKeys 让缓存统计访问最多的键，最多跟踪 capacity 个键，用 TopKeys 查看。
+// 使用 Space-Saving 算法: 跟踪的键满了之后，新键替换掉计数最小的键并继承它的计数，
+// 所以计数可能偏大，但访问次数超过总访问次数 1/capacity 的键一定会被跟踪。
+// sampleEvery > 1 时每 sampleEvery 次访问只记录一次，计数按比例放大，用精度换取更小的开销。
+// 每次记录的开销是 O(log capacity)，不开启时没有开销
+func WithHotKeys(capacity int, sampleEvery int) Option {
+	return func(o *options) {
+		o.hotKeys = capacity
+		o.hotKeysSample = sampleEvery
+	}
+}
+
+// hotKeys 是一个按计数排列的最小堆，index 记录每个键在堆中的位置
+type hotKeys struct {
+	capacity    int
+	sampleEvery int64
+	accesses    int64
+	stats       []KeyStat
+	index       map[int64]int
+}
+
+func newHotKeys(capacity int, sampleEvery int) *hotKeys {
+	if sampleEvery < 1 {
+		sampleEvery = 1
+	}
+	return &hotKeys{capacity: capacity, sampleEvery: int64(sampleEvery), index: make(map[int64]int)}
+}
+
+func (h *hotKeys) Len() int           { return len(h.stats) }
+func (h *hotKeys) Less(i, j int) bool { return h.stats[i].Count < h.stats[j].Count }
+
+func (h *hotKeys) Swap(i, j int) {
+	h.stats[i], h.stats[j] = h.stats[j], h.stats[i]
+	h.index[h.stats[i].Key] = i
+	h.index[h.stats[j].Key] = j
+}
+
+func (h *hotKeys) Push(x interface{}) {
+	stat := x.(KeyStat)
+	h.index[stat.Key] = len(h.stats)
+	h.stats = append(h.stats, stat)
+}
+
+func (h *hotKeys) Pop() interface{} {
+	stat := h.stats[len(h.stats)-1]
+	h.stats = h.stats[:len(h.stats)-1]
+	delete(h.index, stat.Key)
+	return stat
+}
+
+// record 记录一次对 key 的访问，采样时跳过没有被采到的访问
+func (h *hotKeys) record(key int64) {
+	h.accesses++
+	if h.accesses%h.sampleEvery != 0 {
+		return
+	}
+	if i, ok := h.index[key]; ok {
+		h.stats[i].Count++
+		heap.Fix(h, i)
+		return
+	}
+	if len(h.stats) < h.capacity {
+		heap.Push(h, KeyStat{Key: key, Count: 1})
+		return
+	}
+	// 替换计数最小的键
+	delete(h.index, h.stats[0].Key)
+	h.stats[0].Key = key
+	h.stats[0].Count++
+	h.index[key] = 0
+	heap.Fix(h, 0)
+}
+
+// top 返回计数最大的 k 个键，按计数降序排列
+func (h *hotKeys) top(k int) []KeyStat {
+	stats := append([]KeyStat(nil), h.stats...)
+	for i := range stats {
+		stats[i].Count *= h.sampleEvery
+	}
+	sortKeyStats(stats)
+	if k < len(stats) {
+		stats = stats[:k]
+	}
+	return stats
+}
+
+func (h *hotKeys) reset() {
+	h.accesses = 0
+	h.stats = nil
+	h.index = make(map[int64]int)
+}
+
+// sortKeyStats 按计数降序排列，计数相同时按键升序
+func sortKeyStats(stats []KeyStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Key < stats[j].Key
+	})
+}
+
+// TopKeys 返回自上次 ResetTopKeys 以来访问最多的 k 个键，按访问次数降序排列。
+// 命中和未命中的 Get 都算一次访问，采样时访问次数是估计值。没有开启 WithHotKeys 时返回 nil
+func (c *TypedCache[V]) TopKeys(k int) []KeyStat {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.hotKeys == nil {
+		return nil
+	}
+	return c.hotKeys.top(k)
+}
+
+// ResetTopKeys 清空访问统计
+func (c *TypedCache[V]) ResetTopKeys() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.hotKeys != nil {
+		c.hotKeys.reset()
+	}
+}
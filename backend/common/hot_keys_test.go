@@ -0,0 +1,92 @@
+package common
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTopKeysSkewedAccess(t *testing.T) {
+	ac := NewAbstractCache(0, WithHotKeys(16, 1))
+	ac.Cache = newTestCache()
+
+	// Zipf 分布下少数键占了大部分访问
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.5, 1, 999)
+	counts := make(map[int64]int64)
+	for i := 0; i < 20000; i++ {
+		key := int64(zipf.Uint64())
+		counts[key]++
+		ac.Get(key)
+		ac.Release(key)
+	}
+
+	top := ac.TopKeys(3)
+	if len(top) != 3 {
+		t.Fatalf("Expected 3 top keys, got %v", top)
+	}
+	for i, stat := range top {
+		if stat.Key != int64(i) {
+			t.Errorf("Expected key %d at rank %d, got %v", i, i, top)
+		}
+		// 热点键一直在跟踪中，计数是准确的
+		if stat.Count != counts[stat.Key] {
+			t.Errorf("Expected %d accesses for key %d, got %d", counts[stat.Key], stat.Key, stat.Count)
+		}
+	}
+
+	ac.ResetTopKeys()
+	if top := ac.TopKeys(3); len(top) != 0 {
+		t.Errorf("Expected no stats after reset, got %v", top)
+	}
+	ac.Get(42)
+	if top := ac.TopKeys(3); len(top) != 1 || top[0] != (KeyStat{Key: 42, Count: 1}) {
+		t.Errorf("Expected only key 42 after reset, got %v", top)
+	}
+}
+
+func TestTopKeysDisabled(t *testing.T) {
+	ac := NewAbstractCache(0)
+	ac.Cache = newTestCache()
+	ac.Get(1)
+	if top := ac.TopKeys(1); top != nil {
+		t.Errorf("Expected nil without WithHotKeys, got %v", top)
+	}
+}
+
+func TestShardedTopKeys(t *testing.T) {
+	sc := NewShardedCache(4, 0, WithHotKeys(4, 1))
+	sc.Cache = newTestCache()
+	for key := int64(0); key < 8; key++ {
+		for i := int64(0); i <= key; i++ {
+			sc.Get(key)
+		}
+	}
+	top := sc.TopKeys(2)
+	if len(top) != 2 || top[0] != (KeyStat{Key: 7, Count: 8}) || top[1] != (KeyStat{Key: 6, Count: 7}) {
+		t.Errorf("Expected keys 7 and 6 across shards, got %v", top)
+	}
+}
+
+func TestTopKeysSampled(t *testing.T) {
+	ac := NewAbstractCache(0, WithHotKeys(8, 4))
+	ac.Cache = newTestCache()
+
+	// 键 1 占一半的访问，其余访问分散在 200 个键上
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 4000; i++ {
+		key := int64(1)
+		if rng.Intn(2) == 1 {
+			key = int64(100 + rng.Intn(200))
+		}
+		ac.Get(key)
+		ac.Release(key)
+	}
+	top := ac.TopKeys(1)
+	if len(top) != 1 || top[0].Key != 1 {
+		t.Fatalf("Expected key 1 to be the hottest, got %v", top)
+	}
+	// 每 4 次访问采样一次，估计值按比例放大
+	if top[0].Count < 1600 || top[0].Count > 2400 {
+		t.Errorf("Expected about 2000 accesses for key 1, got %d", top[0].Count)
+	}
+}
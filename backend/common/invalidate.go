@@ -0,0 +1,136 @@
+package common
+
+// Invalidate 丢弃 key 在缓存中的值，用于底层数据被绕过缓存修改之后(例如恢复时重写了页)。
+// 没有引用的条目立即释放并移出缓存(包括等待释放的条目)，释放失败时返回 *ReleaseError；
+// 仍被引用的条目立即移出缓存，之后的 Get 马上加载新的值，持有者继续使用旧值直到释放。
+// Release 只按键计数，分不清释放的是旧值还是新值，所以旧值在键的所有引用(包括新值的引用)都释放之后才释放。
+// 正在加载的值同样可能是旧的，加载完成后交给等待它的调用者，但不留在缓存中。key 的墓碑也被删除。
+// 不在缓存中的键什么也不做
+func (c *TypedCache[V]) Invalidate(key int64) error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrCacheClosed
+	}
+	err := c.invalidate(key)
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return err
+}
+
+// InvalidateAll 对缓存中的每个键调用 Invalidate，某些条目释放失败时仍然处理其余的条目，返回它们的 ReleaseErrors
+func (c *TypedCache[V]) InvalidateAll() error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrCacheClosed
+	}
+	keys := make([]int64, 0, len(c.cache)+len(c.pending)+len(c.getting))
+	for key := range c.cache {
+		keys = append(keys, key)
+	}
+	for key := range c.pending {
+		keys = append(keys, key)
+	}
+	for key := range c.getting {
+		keys = append(keys, key)
+	}
+	var errs ReleaseErrors
+	for _, key := range keys {
+		if err := c.invalidate(key); err != nil {
+			errs = append(errs, err.(*ReleaseError))
+		}
+	}
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return errs.orNil()
+}
+
+// invalidate 实现 Invalidate，调用者需持有锁
+func (c *TypedCache[V]) invalidate(key int64) error {
+	delete(c.negative, key)
+	if c.getting[key] {
+		c.invalid[key] = true
+		return nil
+	}
+	if obj, ok := c.pending[key]; ok {
+		return c.expire(key, obj)
+	}
+	obj, ok := c.cache[key]
+	if !ok {
+		return nil
+	}
+	if c.references[key] > 0 {
+		c.detach(key)
+		return nil
+	}
+	err := c.expire(key, obj)
+	if err != nil {
+		return err
+	}
+	delete(c.pinned, key)
+	delete(c.transient, key)
+	return nil
+}
+
+// detach 把仍被引用的 key 移出缓存放入 stale，保留它的引用数，调用者需持有锁
+func (c *TypedCache[V]) detach(key int64) {
+	c.stale[key] = append(c.stale[key], c.cache[key])
+	c.policy.remove(key)
+	c.removeBytes(key)
+	delete(c.cache, key)
+	delete(c.versions, key)
+	delete(c.loadedAt, key)
+	delete(c.pinned, key)
+	delete(c.transient, key)
+	c.count--
+	c.freed.Broadcast()
+}
+
+// releaseStale 在 key 的引用归零时释放它被移出缓存的旧值，释放失败的旧值留在 stale 中，调用者需持有锁
+func (c *TypedCache[V]) releaseStale(key int64) error {
+	values := c.stale[key]
+	if len(values) == 0 {
+		return nil
+	}
+	var failed []V
+	var err error
+	for _, obj := range values {
+		if relErr := c.releaser(obj); relErr != nil {
+			failed = append(failed, obj)
+			err = &ReleaseError{Key: key, Err: relErr}
+			continue
+		}
+		if c.onEvict != nil {
+			c.evicted = append(c.evicted, evictedEntry[V]{key: key, value: obj})
+		}
+	}
+	if len(failed) > 0 {
+		c.stale[key] = failed
+	} else {
+		delete(c.stale, key)
+	}
+	return err
+}
+
+// Invalidate 丢弃 key 所在分片中 key 的值
+func (sc *ShardedCache) Invalidate(key int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Invalidate(key)
+}
+
+// InvalidateAll 丢弃所有分片中的值，返回所有分片中释放失败的条目
+func (sc *ShardedCache) InvalidateAll() error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var errs ReleaseErrors
+	for _, shard := range sc.shards {
+		if err := shard.InvalidateAll(); err != nil {
+			errs = append(errs, err.(ReleaseErrors)...)
+		}
+	}
+	return errs.orNil()
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInvalidateUnreferenced(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	ac.Get(1)
+	ac.Release(1)
+	if err := ac.Invalidate(1); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if ac.Contains(1) || len(tc.releases) != 1 {
+		t.Errorf("Expected the entry to be released and removed, releases %v", tc.releases)
+	}
+
+	// 之后的 Get 通过加载函数重新加载
+	v, err := ac.Get(1)
+	if err != nil || v != int64(10) || tc.loadCount(1) != 2 {
+		t.Errorf("Expected a fresh load, got %v, %v, %d loads", v, err, tc.loadCount(1))
+	}
+	ac.Release(1)
+
+	// 不在缓存中的键什么也不做
+	if err := ac.Invalidate(99); err != nil {
+		t.Errorf("Expected Invalidate of an absent key to succeed, got %v", err)
+	}
+}
+
+func TestInvalidateReferenced(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	ac.Get(1)
+	if err := ac.Invalidate(1); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+	if ac.Contains(1) || ac.Len() != 0 || len(tc.releases) != 0 {
+		t.Errorf("Expected a referenced entry to leave the cache without being released, releases %v", tc.releases)
+	}
+	if _, ok := ac.GetIfPresent(1); ok {
+		t.Errorf("Expected GetIfPresent to ignore an invalidated entry")
+	}
+
+	// 新的 Get 不等待持有者，马上加载新的值；持有者自己再次 Get 也不会死锁
+	got := make(chan error, 1)
+	go func() {
+		_, err := ac.Get(1)
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Get to load a fresh value without waiting for the holder")
+	}
+	if tc.loadCount(1) != 2 || !ac.Contains(1) {
+		t.Errorf("Expected a fresh load, got %d loads", tc.loadCount(1))
+	}
+
+	// 旧值在键的所有引用释放后才释放
+	if err := ac.Release(1); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if len(tc.releases) != 0 {
+		t.Errorf("Expected the stale value to wait for every reference, releases %v", tc.releases)
+	}
+	if err := ac.Release(1); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if len(tc.releases) != 1 || !ac.Contains(1) {
+		t.Errorf("Expected only the stale value to be released, releases %v", tc.releases)
+	}
+	if err := ac.Release(1); !errors.Is(err, ErrOverRelease) {
+		t.Errorf("Expected ErrOverRelease, got %v", err)
+	}
+}
+
+func TestInvalidateStaleOnly(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(1)
+	ac.Cache = tc
+
+	// 旧值不占用容量，只剩下旧值的键释放时释放旧值
+	ac.Get(1)
+	ac.Invalidate(1)
+	if _, err := ac.Get(2); err != nil {
+		t.Fatalf("Expected the stale value not to count against capacity, got %v", err)
+	}
+	ac.Release(2)
+	if err := ac.Release(1); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if len(tc.releases) != 1 || tc.releases[0] != int64(10) {
+		t.Errorf("Expected the stale value to be released, releases %v", tc.releases)
+	}
+	if err := ac.Release(1); !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached, got %v", err)
+	}
+
+	// Close 释放还没有释放的旧值
+	ac.Get(2)
+	ac.Invalidate(2)
+	ac.Close()
+	if len(tc.releases) != 2 {
+		t.Errorf("Expected Close to release the stale value, releases %v", tc.releases)
+	}
+}
+
+func TestInvalidateAll(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(4)
+	ac.Cache = tc
+
+	for key := int64(1); key <= 3; key++ {
+		ac.Get(key)
+	}
+	ac.Release(1)
+	ac.Release(2)
+	if err := ac.InvalidateAll(); err != nil {
+		t.Fatalf("InvalidateAll failed: %v", err)
+	}
+	if ac.Contains(1) || ac.Contains(2) || ac.Contains(3) || len(tc.releases) != 2 {
+		t.Errorf("Expected every entry to leave the cache, releases %v", tc.releases)
+	}
+	ac.Release(3)
+	if ac.Len() != 0 || len(tc.releases) != 3 {
+		t.Errorf("Expected every entry to be released, len %d, releases %v", ac.Len(), tc.releases)
+	}
+}
+
+func TestInvalidateSurvivesSetShards(t *testing.T) {
+	tc := newTestCache()
+	sc := NewShardedCache(4, 0, WithConsistentHashing(0))
+	sc.Cache = tc
+
+	// 只剩下旧值的键和同时有新值的键都随分片移动，之后仍然可以释放
+	for key := int64(0); key < 100; key++ {
+		sc.Get(key)
+	}
+	sc.InvalidateAll()
+	for key := int64(0); key < 100; key += 2 {
+		sc.Get(key)
+	}
+	if err := sc.SetShards(5); err != nil {
+		t.Fatalf("SetShards failed: %v", err)
+	}
+	for key := int64(0); key < 100; key++ {
+		if err := sc.Release(key); err != nil {
+			t.Fatalf("Release of key %d after SetShards failed: %v", key, err)
+		}
+	}
+	// 重新读过的键的旧值要等新值的引用也释放
+	if n := tc.releaseCount(); n != 50 {
+		t.Errorf("Expected only the stale values of keys without a fresh reference to be released, got %d", n)
+	}
+	for key := int64(0); key < 100; key += 2 {
+		sc.Release(key)
+	}
+	if n := tc.releaseCount(); n != 100 {
+		t.Errorf("Expected every stale value to be released, got %d", n)
+	}
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Leak 描述 Close 时仍被引用的一个条目
+type Leak struct {
+	Key  int64
+	Refs int // Close 时的引用计数
+	// Stacks 是取得这些引用的 Get 的调用栈，只有开启 WithLeakStacks 时才会记录
+	Stacks []string
+}
+
+// LeakReport 是 Close 时所有仍被引用的条目，按 Key 升序排列
+type LeakReport []Leak
+
+func (r LeakReport) Error() string {
+	msgs := make([]string, len(r))
+	for i, leak := range r {
+		msgs[i] = fmt.Sprintf("key %d (%d refs)", leak.Key, leak.Refs)
+	}
+	return "cache closed with referenced entries: " + strings.Join(msgs, ", ")
+}
+
+// WithLeakStacks 让缓存为每个未释放的引用记录取得它的 Get 的调用栈，Close 时放入 LeakReport。
+// 记录调用栈的开销很大，只应在排查引用泄漏时开启
+func WithLeakStacks() Option {
+	return func(o *options) {
+		o.leakStacks = true
+	}
+}
+
+// Leaks 返回最近一次 Close 时仍被引用的条目，没有泄漏或者还没有 Close 时返回 nil
+func (c *TypedCache[V]) Leaks() LeakReport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.leaks
+}
+
+// trackRef 在 key 的引用计数加一之后记录调用栈，调用者需持有锁
+func (c *TypedCache[V]) trackRef(key int64) {
+	if c.refStacks == nil {
+		return
+	}
+	c.refStacks[key] = append(c.refStacks[key], string(debug.Stack()))
+}
+
+// untrackRef 在 key 的引用计数减一之后丢弃一个调用栈。
+// Release 不知道对应的是哪一次 Get，所以剩下的调用栈只是近似地指向泄漏的引用
+func (c *TypedCache[V]) untrackRef(key int64) {
+	stacks := c.refStacks[key]
+	if len(stacks) == 0 {
+		return
+	}
+	if len(stacks) == 1 {
+		delete(c.refStacks, key)
+		return
+	}
+	c.refStacks[key] = stacks[:len(stacks)-1]
+}
+
+// leakReport 收集仍被引用的条目，调用者需持有锁
+func (c *TypedCache[V]) leakReport() LeakReport {
+	var report LeakReport
+	for key, ref := range c.references {
+		if ref > 0 {
+			report = append(report, Leak{Key: key, Refs: ref, Stacks: c.refStacks[key]})
+		}
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+	return report
+}
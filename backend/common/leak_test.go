@@ -0,0 +1,73 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeakReport(t *testing.T) {
+	ac := NewAbstractCache(10)
+	ac.Cache = newTestCache()
+
+	// 键 1 被 Get 两次只释放一次，键 2 正常释放，键 3 没有释放
+	ac.Get(1)
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+	ac.Get(3)
+
+	if leaks := ac.Leaks(); leaks != nil {
+		t.Errorf("Expected no leak report before Close, got %v", leaks)
+	}
+	n, err := ac.Close()
+	if err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 referenced entries, got %d", n)
+	}
+
+	leaks := ac.Leaks()
+	if len(leaks) != 2 || leaks[0].Key != 1 || leaks[0].Refs != 1 || leaks[1].Key != 3 || leaks[1].Refs != 1 {
+		t.Fatalf("Unexpected leak report %+v", leaks)
+	}
+	if leaks[0].Stacks != nil {
+		t.Errorf("Expected no stacks without WithLeakStacks")
+	}
+	if !strings.Contains(leaks.Error(), "key 3") {
+		t.Errorf("Expected key 3 in %q", leaks.Error())
+	}
+}
+
+func TestLeakReportStacks(t *testing.T) {
+	ac := NewAbstractCache(10, WithLeakStacks())
+	ac.Cache = newTestCache()
+
+	ac.Get(1)
+	ac.Get(2)
+	ac.Release(2)
+	ac.Get(1)
+	ac.Release(1)
+	ac.Close()
+
+	leaks := ac.Leaks()
+	if len(leaks) != 1 || leaks[0].Key != 1 || leaks[0].Refs != 1 {
+		t.Fatalf("Unexpected leak report %+v", leaks)
+	}
+	if len(leaks[0].Stacks) != 1 || !strings.Contains(leaks[0].Stacks[0], "TestLeakReportStacks") {
+		t.Errorf("Expected stack of the leaking Get, got %v", leaks[0].Stacks)
+	}
+}
+
+func TestLeakReportClean(t *testing.T) {
+	ac := NewAbstractCache(10, WithLeakStacks())
+	ac.Cache = newTestCache()
+	ac.Get(1)
+	ac.Release(1)
+	ac.Close()
+
+	if leaks := ac.Leaks(); leaks != nil {
+		t.Errorf("Expected no leaks, got %+v", leaks)
+	}
+}
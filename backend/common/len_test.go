@@ -0,0 +1,89 @@
+package common
+
+import "testing"
+
+func TestLen(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+
+	if n := ac.Len(); n != 0 {
+		t.Errorf("Expected empty cache, got %d", n)
+	}
+	ac.Get(1)
+	ac.Get(1)
+	if n := ac.Len(); n != 1 {
+		t.Errorf("Expected 1 entry, got %d", n)
+	}
+	ac.Release(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+	if n := ac.Len(); n != 2 {
+		t.Errorf("Expected released entries to stay cached, got %d", n)
+	}
+
+	// 淘汰一个条目再装入一个，条目数不变
+	ac.Get(3)
+	if n := ac.Len(); n != 2 {
+		t.Errorf("Expected 2 entries after eviction, got %d", n)
+	}
+	ac.Release(3)
+	ac.Close()
+	if n := ac.Len(); n != 0 {
+		t.Errorf("Expected Close to empty the cache, got %d", n)
+	}
+}
+
+func TestContains(t *testing.T) {
+	tc := newTestCache()
+	ac := NewAbstractCache(2)
+	ac.Cache = tc
+
+	if ac.Contains(1) {
+		t.Errorf("Expected key 1 not to be cached")
+	}
+	if tc.loadCount(1) != 0 {
+		t.Errorf("Contains should not load the key")
+	}
+
+	ac.Get(1)
+	ac.Release(1)
+	ac.Get(2)
+	ac.Release(2)
+	if !ac.Contains(1) || !ac.Contains(2) {
+		t.Errorf("Expected keys 1 and 2 to be cached")
+	}
+
+	// Contains 不算作访问，1 仍然是最久未访问的条目
+	ac.Get(3)
+	ac.Release(3)
+	if ac.Contains(1) {
+		t.Errorf("Expected key 1 to be evicted")
+	}
+	if !ac.Contains(2) || !ac.Contains(3) {
+		t.Errorf("Expected keys 2 and 3 to be cached")
+	}
+	if stats := ac.Stats(); stats.Hits != 0 || stats.Misses != 3 {
+		t.Errorf("Contains should not change the statistics, got %+v", stats)
+	}
+
+	// Contains 不取得引用，条目可以被淘汰
+	if err := ac.Release(2); err == nil {
+		t.Errorf("Expected Release without a reference to fail")
+	}
+}
+
+func TestShardedCacheLenAndContains(t *testing.T) {
+	sc := NewShardedCache(3, 0)
+	sc.Cache = newTestCache()
+	for key := int64(0); key < 10; key++ {
+		sc.Get(key)
+		sc.Release(key)
+	}
+	if n := sc.Len(); n != 10 {
+		t.Errorf("Expected 10 entries, got %d", n)
+	}
+	if !sc.Contains(7) || sc.Contains(10) {
+		t.Errorf("Expected Contains to reflect the cached keys")
+	}
+}
@@ -0,0 +1,37 @@
+package common
+
+import "context"
+
+// WithMaxConcurrentLoads 限制同时执行的加载函数最多 n 个，n <= 0 表示不限制。
+// 许多不同的键同时未命中时，超出的 Get 等待有加载结束后再调用加载函数，避免压垮底层存储；
+// GetCtx 在等待期间 ctx 被取消时返回 ctx.Err()。ShardedCache 的每个分片各自限制
+func WithMaxConcurrentLoads(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentLoads = n
+	}
+}
+
+// acquireLoad 占用一个加载名额，没有空闲名额时等待，ctx 不为 nil 且被取消时返回 ctx.Err()。
+// 调用者不能持有锁
+func (c *TypedCache[V]) acquireLoad(ctx context.Context) error {
+	if c.loadSlots == nil {
+		return nil
+	}
+	if ctx == nil {
+		c.loadSlots <- struct{}{}
+		return nil
+	}
+	select {
+	case c.loadSlots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseLoad 归还 acquireLoad 占用的加载名额
+func (c *TypedCache[V]) releaseLoad() {
+	if c.loadSlots != nil {
+		<-c.loadSlots
+	}
+}
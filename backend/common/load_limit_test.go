@@ -0,0 +1,125 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadTracker 记录同时执行的加载函数个数的最大值
+type loadTracker struct {
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (lt *loadTracker) enter() {
+	n := lt.running.Add(1)
+	for {
+		peak := lt.peak.Load()
+		if n <= peak || lt.peak.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+func (lt *loadTracker) exit() {
+	lt.running.Add(-1)
+}
+
+func TestMaxConcurrentLoads(t *testing.T) {
+	const limit = 3
+	var lt loadTracker
+	errLoad := errors.New("load failed")
+	c := NewTypedCache[int64](0,
+		func(key int64) (int64, error) {
+			lt.enter()
+			defer lt.exit()
+			time.Sleep(2 * time.Millisecond)
+			switch key % 3 {
+			case 1:
+				return 0, errLoad
+			case 2:
+				panic("loader failed")
+			}
+			return key * 10, nil
+		},
+		func(int64) error { return nil },
+		WithMaxConcurrentLoads(limit))
+	defer c.Close()
+
+	// 一批不同的键同时未命中，加载成功、失败和 panic 都要归还名额
+	var wg sync.WaitGroup
+	for i := int64(0); i < 60; i++ {
+		wg.Add(1)
+		go func(key int64) {
+			defer wg.Done()
+			defer func() { recover() }()
+			if v, err := c.Get(key); err == nil {
+				if v != key*10 {
+					t.Errorf("Expected %d for key %d, got %d", key*10, key, v)
+				}
+				c.Release(key)
+			} else if !errors.Is(err, errLoad) {
+				t.Errorf("Unexpected error for key %d: %v", key, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak := lt.peak.Load(); peak > limit {
+		t.Errorf("Expected at most %d concurrent loads, got %d", limit, peak)
+	} else if peak == 0 {
+		t.Errorf("Expected some loads to run")
+	}
+	if n := len(c.loadSlots); n != 0 {
+		t.Errorf("Expected all load slots to be returned, %d still held", n)
+	}
+}
+
+func TestMaxConcurrentLoadsCtx(t *testing.T) {
+	block := make(chan struct{})
+	c := NewTypedCache[int64](0,
+		func(key int64) (int64, error) {
+			<-block
+			return key * 10, nil
+		},
+		func(int64) error { return nil },
+		WithMaxConcurrentLoads(1))
+	defer c.Close()
+
+	// 唯一的名额被键 1 的加载占用
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(1)
+		done <- err
+	}()
+	for deadline := time.Now().Add(time.Second); len(c.loadSlots) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Load of key 1 did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetCtx(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded while waiting for a load slot, got %v", err)
+	}
+	// 等待名额失败的加载不占用缓存的位置
+	if c.Contains(2) || c.Len() != 1 {
+		t.Errorf("Expected only key 1 to be counted, got %d entries", c.Len())
+	}
+
+	close(block)
+	if err := <-done; err != nil {
+		t.Fatalf("Get(1) failed: %v", err)
+	}
+	c.Release(1)
+	if v, err := c.Get(2); err != nil || v != 20 {
+		t.Errorf("Expected key 2 to load after the slot is free, got %d, %v", v, err)
+	}
+	c.Release(2)
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+// panicCache 在 release 被关闭之后加载时 panic
+type panicCache struct {
+	*testCache
+	release chan struct{}
+}
+
+func (c *panicCache) getForCache(key int64) (interface{}, error) {
+	<-c.release
+	panic("loader failed")
+}
+
+func TestLoaderPanicDoesNotWedgeKey(t *testing.T) {
+	pc := &panicCache{testCache: newTestCache(), release: make(chan struct{})}
+	ac := NewAbstractCache(1)
+	ac.Cache = pc
+	defer ac.Close()
+
+	// 第一个 Get 在加载中 panic，第二个 Get 在等待同一次加载
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		ac.Get(1)
+	}()
+	waitLoading(t, ac, 1)
+	waited := make(chan interface{})
+	go func() {
+		v, _ := ac.GetWithLoader(1, func(key int64) (interface{}, error) { return int64(42), nil })
+		waited <- v
+	}()
+	close(pc.release)
+
+	if r := <-panicked; r != "loader failed" {
+		t.Fatalf("Expected the panic to propagate, got %v", r)
+	}
+	select {
+	case v := <-waited:
+		if v != int64(42) {
+			t.Errorf("Expected the waiting Get to load with its own loader, got %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Waiting Get is stuck after the loader panicked")
+	}
+	ac.Release(1)
+
+	// panic 没有占用缓存的位置
+	if stats := ac.Stats(); stats.Count != 1 {
+		t.Errorf("Expected 1 entry, got %d", stats.Count)
+	}
+	v, err := ac.GetWithLoader(2, func(key int64) (interface{}, error) { return int64(7), nil })
+	if err != nil || v != int64(7) {
+		t.Errorf("Expected key 2 to load into the freed slot, got %v, %v", v, err)
+	}
+	ac.Release(2)
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"io"
+	"sync"
+)
+
+// MemPageFile 是保存在内存中的 PageFile，写入超出末尾时像文件一样增长，
+// 页面缓存分配新页时它随之增加一个页的长度。Sync 和 Close 什么也不做
+type MemPageFile struct {
+	lock sync.RWMutex
+	data []byte
+}
+
+// NewMemPageFile 创建一个空的 MemPageFile
+func NewMemPageFile() *MemPageFile {
+	return &MemPageFile{}
+}
+
+func (f *MemPageFile) ReadAt(p []byte, off int64) (int, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *MemPageFile) WriteAt(p []byte, off int64) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.data)
+		f.data = grown
+	}
+	return copy(f.data[off:], p), nil
+}
+
+// Size 返回当前的长度
+func (f *MemPageFile) Size() int64 {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return int64(len(f.data))
+}
+
+func (f *MemPageFile) Sync() error {
+	return nil
+}
+
+func (f *MemPageFile) Close() error {
+	return nil
+}
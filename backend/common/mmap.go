@@ -0,0 +1,124 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// errMmapUnsupported 表示当前平台不支持 mmap，页面缓存退回到 ReadAt/WriteAt
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// pageMapping 把数据文件映射到内存中，getForCache 直接返回映射中的切片而不是读出一份拷贝。
+// 映射只覆盖映射时文件的长度，之后追加的页仍然通过 ReadAt 读取；
+// 只有在没有任何映射中的页留在缓存(包括待释放队列)中时才会重新映射，持有页的调用者不会看到映射被替换
+type pageMapping struct {
+	lock sync.Mutex
+	file *os.File
+	data []byte
+	// pages 是缓存中来自映射的页数
+	pages int
+}
+
+// NewMmapPageCache 与 NewPageCacheWithPageSize 相同，但通过 mmap 访问数据文件: 读取页不需要系统调用和拷贝，
+// 修改直接写入映射，FlushDirty 用 msync 刷盘。不支持 mmap 的平台或者映射失败时退回到 ReadAt/WriteAt。
+// 页在 ReleasePage 之后不能再访问；Close 时仍有页被引用则不解除映射，避免持有者访问到已经解除的内存
+func NewMmapPageCache(file *os.File, maxPages int, pageSize int, allocator PageAllocator) (*PageCache, error) {
+	pc, err := NewPageCacheWithPageSize(file, maxPages, pageSize, allocator)
+	if err != nil {
+		return nil, err
+	}
+	pc.mapping = &pageMapping{file: file}
+	err = pc.mapping.remap()
+	if err != nil {
+		pc.mapping = nil
+	}
+	return pc, nil
+}
+
+// remap 按文件当前的长度重新映射，调用者需持有 lock 或者独占 m
+func (m *pageMapping) remap() error {
+	info, err := m.file.Stat()
+	if err != nil {
+		return err
+	}
+	if int64(len(m.data)) == info.Size() {
+		return nil
+	}
+	if m.data != nil {
+		err = munmap(m.data)
+		m.data = nil
+		if err != nil {
+			return err
+		}
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	m.data, err = mmap(m.file, info.Size())
+	return err
+}
+
+// page 返回映射中从 off 开始的 size 字节，超出映射的范围时先尝试重新映射，仍然超出时 ok 为 false
+func (m *pageMapping) page(off int64, size int) ([]byte, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if off+int64(size) > int64(len(m.data)) && m.pages == 0 {
+		// 重新映射失败时保留原来的映射，这个页退回到 ReadAt
+		_ = m.remap()
+	}
+	if off+int64(size) > int64(len(m.data)) {
+		return nil, false
+	}
+	m.pages++
+	return m.data[off : off+int64(size) : off+int64(size)], true
+}
+
+// release 在映射中的页离开缓存时调用
+func (m *pageMapping) release() {
+	m.lock.Lock()
+	m.pages--
+	m.lock.Unlock()
+}
+
+// sync 把映射中修改过的页刷到文件
+func (m *pageMapping) sync() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.data == nil {
+		return nil
+	}
+	return msync(m.data)
+}
+
+// close 解除映射，referenced 为 true 时还有页被引用，保留映射
+func (m *pageMapping) close(referenced bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if referenced || m.data == nil {
+		return nil
+	}
+	err := munmap(m.data)
+	m.data = nil
+	return err
+}
+
+// writePage 把页写回数据文件，映射中的页已经在文件里，不需要再写
+func (pc *PageCache) writePage(page *Page) error {
+	if page.mapped {
+		return nil
+	}
+	_, err := pc.file.WriteAt(page.data, pc.pageOffset(page.pgno))
+	return err
+}
+
+// syncFile 刷新映射和数据文件
+func (pc *PageCache) syncFile() error {
+	if pc.mapping != nil {
+		err := pc.mapping.sync()
+		if err != nil {
+			return err
+		}
+	}
+	return pc.file.Sync()
+}
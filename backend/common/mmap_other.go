@@ -0,0 +1,17 @@
+//go:build !unix
+
+package common
+
+import "os"
+
+func mmap(file *os.File, size int64) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(data []byte) error {
+	return errMmapUnsupported
+}
+
+func msync(data []byte) error {
+	return errMmapUnsupported
+}
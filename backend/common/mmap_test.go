@@ -0,0 +1,154 @@
+package common
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// createPages 创建有 n 个页的数据文件，页 i 的第一个字节是 i
+func createPages(t testing.TB, n int) {
+	t.Helper()
+	file, err := os.Create("test_file.db")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	pc, err := NewPageCache(file, 1)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		pc.NewPage([]byte{byte(i)})
+	}
+	pc.Close()
+}
+
+func openMmapPageCache(t testing.TB, maxPages int) *PageCache {
+	t.Helper()
+	file, err := os.OpenFile("test_file.db", os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	pc, err := NewMmapPageCache(file, maxPages, PageSize, NewAppendAllocator())
+	if err != nil {
+		t.Fatalf("NewMmapPageCache failed: %v", err)
+	}
+	return pc
+}
+
+func TestMmapPageCacheReadModifyFlush(t *testing.T) {
+	createPages(t, 4)
+	defer os.Remove("test_file.db")
+	pc := openMmapPageCache(t, 2)
+	if pc.mapping == nil {
+		t.Skip("mmap is not available")
+	}
+
+	page, err := pc.GetPage(2)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if !page.mapped || page.Data()[0] != 2 {
+		t.Fatalf("Expected page 2 to be read through the mapping, got mapped=%v first byte %d", page.mapped, page.Data()[0])
+	}
+	page.Lock()
+	copy(page.Data()[1:], "mapped")
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+	if err := pc.FlushDirty(); err != nil {
+		t.Fatalf("FlushDirty failed: %v", err)
+	}
+	data, _ := os.ReadFile("test_file.db")
+	if off := pc.pageOffset(2); string(data[off+1:off+7]) != "mapped" {
+		t.Errorf("Modification was not written through the mapping")
+	}
+
+	// 映射之后追加的页通过 ReadAt 读取，没有映射中的页留在缓存里时重新映射
+	pgno, _ := pc.NewPage([]byte("new"))
+	page, err = pc.GetPage(pgno)
+	if err != nil {
+		t.Fatalf("GetPage of the new page failed: %v", err)
+	}
+	if string(page.Data()[:3]) != "new" {
+		t.Errorf("Expected the new page's data, got %q", page.Data()[:3])
+	}
+	pc.ReleasePage(page)
+
+	// 被淘汰的脏页在 Close 时刷盘
+	page, _ = pc.GetPage(0)
+	page.Lock()
+	page.Data()[1] = 'x'
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+	for _, pgno := range []int64{1, 3} {
+		page, _ := pc.GetPage(pgno)
+		pc.ReleasePage(page)
+	}
+	if err := pc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, _ = os.ReadFile("test_file.db")
+	if data[pc.pageOffset(0)+1] != 'x' || string(data[pc.pageOffset(pgno):pc.pageOffset(pgno)+3]) != "new" {
+		t.Errorf("Pages were not persisted")
+	}
+}
+
+func TestMmapPageCacheRemapWaitsForHolders(t *testing.T) {
+	createPages(t, 2)
+	defer os.Remove("test_file.db")
+	pc := openMmapPageCache(t, 4)
+	if pc.mapping == nil {
+		t.Skip("mmap is not available")
+	}
+	defer pc.Close()
+
+	held, _ := pc.GetPage(0)
+	pgno, _ := pc.NewPage(nil)
+	page, _ := pc.GetPage(pgno)
+	if page.mapped {
+		t.Errorf("Expected the new page to be read with ReadAt while page 0 is held")
+	}
+	pc.ReleasePage(page)
+	if held.Data()[0] != 0 {
+		t.Errorf("Held page changed under the holder")
+	}
+	pc.ReleasePage(held)
+}
+
+// BenchmarkPageCacheRandomRead 比较随机读页时 mmap 和 ReadAt 的开销，缓存很小，大部分读取都要加载页
+func BenchmarkPageCacheRandomRead(b *testing.B) {
+	const pages = 1024
+	createPages(b, pages)
+	defer os.Remove("test_file.db")
+
+	open := map[string]func() *PageCache{
+		"ReadAt": func() *PageCache {
+			file, _ := os.OpenFile("test_file.db", os.O_RDWR, 0666)
+			pc, err := NewPageCache(file, 16)
+			if err != nil {
+				b.Fatalf("NewPageCache failed: %v", err)
+			}
+			return pc
+		},
+		"Mmap": func() *PageCache { return openMmapPageCache(b, 16) },
+	}
+	for _, name := range []string{"ReadAt", "Mmap"} {
+		b.Run(name, func(b *testing.B) {
+			pc := open[name]()
+			defer pc.Close()
+			r := rand.New(rand.NewSource(1))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				page, err := pc.GetPage(r.Int63n(pages))
+				if err != nil {
+					b.Fatal(err)
+				}
+				pc.ReleasePage(page)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package common
+
+import "sort"
+
+// MultiGet 获取 keys 中所有键的引用，返回的值与 keys 一一对应，之后每个键都需要 Release 一次。
+// 任何一个键获取失败时先释放已经获取的引用再返回错误，不会留下悬挂的引用。
+// 键按升序获取，并发的 MultiGet 以相同的顺序等待，不会互相死锁
+func (c *TypedCache[V]) MultiGet(keys []int64) ([]V, error) {
+	return multiGet(keys, c.Get, c.Release)
+}
+
+// MultiGet 与 TypedCache.MultiGet 相同，键可以分布在不同的分片上
+func (sc *ShardedCache) MultiGet(keys []int64) ([]interface{}, error) {
+	return multiGet(keys, sc.Get, sc.Release)
+}
+
+func multiGet[V any](keys []int64, get func(int64) (V, error), release func(int64) error) ([]V, error) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+
+	values := make([]V, len(keys))
+	for n, i := range order {
+		v, err := get(keys[i])
+		if err != nil {
+			for _, j := range order[:n] {
+				release(keys[j])
+			}
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
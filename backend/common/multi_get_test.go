@@ -0,0 +1,75 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// failKeyCache 加载 failKey 时返回错误
+type failKeyCache struct {
+	*testCache
+	failKey int64
+}
+
+var errLoadFailed = errors.New("load failed")
+
+func (c *failKeyCache) getForCache(key int64) (interface{}, error) {
+	if key == c.failKey {
+		return nil, errLoadFailed
+	}
+	return c.testCache.getForCache(key)
+}
+
+func TestMultiGet(t *testing.T) {
+	ac := NewAbstractCache(10)
+	ac.Cache = newTestCache()
+
+	keys := []int64{5, 1, 3}
+	values, err := ac.MultiGet(keys)
+	if err != nil {
+		t.Fatalf("MultiGet failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, []interface{}{int64(50), int64(10), int64(30)}) {
+		t.Errorf("Expected values in the order of keys, got %v", values)
+	}
+	for _, key := range keys {
+		if err := ac.Release(key); err != nil {
+			t.Errorf("Release(%d) failed: %v", key, err)
+		}
+	}
+}
+
+func TestMultiGetRollsBackOnFailure(t *testing.T) {
+	ac := NewAbstractCache(10)
+	ac.Cache = &failKeyCache{testCache: newTestCache(), failKey: 4}
+
+	if _, err := ac.MultiGet([]int64{6, 4, 2, 3}); !errors.Is(err, errLoadFailed) {
+		t.Fatalf("Expected errLoadFailed, got %v", err)
+	}
+	// 失败之前按升序获取的 2、3 已经释放，失败之后的 6 没有获取
+	for _, key := range []int64{2, 3} {
+		if err := ac.Release(key); !errors.Is(err, ErrOverRelease) {
+			t.Errorf("Expected key %d to have no references left, got %v", key, err)
+		}
+	}
+	if err := ac.Release(6); !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected key 6 not to be loaded, got %v", err)
+	}
+	if n, _ := ac.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
+
+func TestMultiGetCacheFull(t *testing.T) {
+	sc := NewShardedCache(2, 4)
+	sc.Cache = newTestCache()
+
+	// 每个分片容量为 2，偶数键都在同一个分片上
+	if _, err := sc.MultiGet([]int64{0, 2, 4}); !errors.Is(err, CacheFullError) {
+		t.Fatalf("Expected CacheFullError, got %v", err)
+	}
+	if n, _ := sc.Close(); n != 0 {
+		t.Errorf("Expected MultiGet to release partial references, got %d referenced", n)
+	}
+}
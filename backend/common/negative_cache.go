@@ -0,0 +1,87 @@
+package common
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound 表示底层存储中没有这个键。开启 WithNegativeCache 时，
+// 加载函数返回的错误包含它时缓存会记住这次未命中
+var ErrNotFound = errors.New("key not found")
+
+// WithNegativeCache 开启未命中缓存: 加载函数返回包含 ErrNotFound 的错误时为这个键记录一个墓碑，
+// ttl 时长内的 Get 直接返回同一个错误而不再调用加载函数。
+// 墓碑不占用 maxResource 的容量，只在数量超过 maxResource 时清理过期和最早的墓碑。
+// clock 为 nil 时使用 WithClock 设置的时钟或者 time.Now
+func WithNegativeCache(ttl time.Duration, clock Clock) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// tombstone 记录一次 ErrNotFound 的加载结果，在 expires 之前有效
+type tombstone struct {
+	err     error
+	expires time.Time
+}
+
+// cachedMiss 返回 key 仍然有效的墓碑中记录的错误，过期的墓碑被删除。调用者需持有锁
+func (c *TypedCache[V]) cachedMiss(key int64) (error, bool) {
+	t, ok := c.negative[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.clock().Before(t.expires) {
+		delete(c.negative, key)
+		return nil, false
+	}
+	return t.err, true
+}
+
+// recordMiss 在 err 包含 ErrNotFound 时为 key 记录墓碑。调用者需持有锁
+func (c *TypedCache[V]) recordMiss(key int64, err error) {
+	if c.negativeTTL <= 0 || !errors.Is(err, ErrNotFound) {
+		return
+	}
+	now := c.clock()
+	if c.maxResource > 0 && len(c.negative) >= c.maxResource {
+		c.pruneMisses(now)
+	}
+	c.negative[key] = tombstone{err: err, expires: now.Add(c.negativeTTL)}
+}
+
+// pruneMisses 删除过期的墓碑，仍然超过 maxResource 时再删除最早过期的墓碑。调用者需持有锁
+func (c *TypedCache[V]) pruneMisses(now time.Time) {
+	for key, t := range c.negative {
+		if !now.Before(t.expires) {
+			delete(c.negative, key)
+		}
+	}
+	for len(c.negative) >= c.maxResource {
+		var oldest int64
+		first := true
+		for key, t := range c.negative {
+			if first || t.expires.Before(c.negative[oldest].expires) {
+				oldest, first = key, false
+			}
+		}
+		delete(c.negative, oldest)
+	}
+}
+
+// ForgetMiss 删除 key 的墓碑，下一次 Get 重新调用加载函数，用于键在底层存储中被创建之后
+func (c *TypedCache[V]) ForgetMiss(key int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.negative, key)
+}
+
+// MissCount 返回当前记录的墓碑数，包括已经过期但还没有被清理的
+func (c *TypedCache[V]) MissCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.negative)
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newMissingCache 创建一个只有偶数键存在的缓存，loads 记录每个键的加载次数
+func newMissingCache(maxResource int, ttl time.Duration, clock *fakeClock) (*TypedCache[int64], map[int64]int) {
+	loads := make(map[int64]int)
+	c := NewTypedCache[int64](maxResource,
+		func(key int64) (int64, error) {
+			loads[key]++
+			if key%2 != 0 {
+				return 0, fmt.Errorf("%w: %d", ErrNotFound, key)
+			}
+			return key * 10, nil
+		},
+		func(int64) error { return nil },
+		WithNegativeCache(ttl, clock.Now))
+	return c, loads
+}
+
+func TestNegativeCacheWithinTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, loads := newMissingCache(2, time.Second, clock)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(1); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get %d: expected ErrNotFound, got %v", i, err)
+		}
+		clock.advance(300 * time.Millisecond)
+	}
+	if loads[1] != 1 {
+		t.Errorf("Expected the loader to be called once within the TTL, got %d", loads[1])
+	}
+
+	// 墓碑不占用容量，两个存在的键仍然可以同时被引用
+	for _, key := range []int64{2, 4} {
+		if _, err := c.Get(key); err != nil {
+			t.Fatalf("Get(%d) failed: %v", key, err)
+		}
+	}
+	if stats := c.Stats(); stats.Count != 2 || c.MissCount() != 1 {
+		t.Errorf("Expected 2 entries and 1 tombstone, got %d and %d", stats.Count, c.MissCount())
+	}
+}
+
+func TestNegativeCacheExpires(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, loads := newMissingCache(2, time.Second, clock)
+
+	c.Get(1)
+	clock.advance(time.Second)
+	if _, err := c.Get(1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if loads[1] != 2 {
+		t.Errorf("Expected the loader to be called again after the TTL, got %d", loads[1])
+	}
+
+	// ForgetMiss 之后立即重新加载
+	c.ForgetMiss(1)
+	c.Get(1)
+	if loads[1] != 3 {
+		t.Errorf("Expected the loader to be called after ForgetMiss, got %d", loads[1])
+	}
+}
+
+func TestNegativeCacheOnlyForNotFound(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	loads := 0
+	c := NewTypedCache[int64](2,
+		func(key int64) (int64, error) {
+			loads++
+			return 0, errors.New("disk error")
+		},
+		func(int64) error { return nil },
+		WithNegativeCache(time.Second, clock.Now))
+
+	c.Get(1)
+	c.Get(1)
+	if loads != 2 || c.MissCount() != 0 {
+		t.Errorf("Other errors should not be cached, got %d loads and %d tombstones", loads, c.MissCount())
+	}
+}
+
+func TestNegativeCachePrunesTombstones(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	c, loads := newMissingCache(2, time.Second, clock)
+
+	for _, key := range []int64{1, 3, 5} {
+		c.Get(key)
+		clock.advance(time.Millisecond)
+	}
+	// 最多保留 maxResource 个墓碑，最早的被清理
+	if n := c.MissCount(); n != 2 {
+		t.Errorf("Expected 2 tombstones, got %d", n)
+	}
+	c.Get(1)
+	c.Get(5)
+	if loads[1] != 2 || loads[5] != 1 {
+		t.Errorf("Expected only the oldest tombstone to be dropped, got loads %v", loads)
+	}
+}
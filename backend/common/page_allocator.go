@@ -0,0 +1,55 @@
+package common
+
+import "sync"
+
+// PageAllocator 决定新建页面时使用哪个页号
+type PageAllocator interface {
+	// Allocate 根据当前页数返回新页的页号，grow 为 true 表示需要在文件末尾追加新页
+	Allocate(pageCount int64) (pgno int64, grow bool)
+	// Free 归还一个不再使用的页
+	Free(pgno int64)
+}
+
+// AppendAllocator 总是在文件末尾追加新页
+type AppendAllocator struct{}
+
+// NewAppendAllocator 创建一个追加分配器
+func NewAppendAllocator() *AppendAllocator {
+	return &AppendAllocator{}
+}
+
+func (a *AppendAllocator) Allocate(pageCount int64) (int64, bool) {
+	return pageCount, true
+}
+
+// Free 对追加分配器没有效果，释放的页不会被复用
+func (a *AppendAllocator) Free(pgno int64) {}
+
+// FreeListFirstAllocator 优先复用空闲链表中的页，空闲链表为空时才追加新页
+type FreeListFirstAllocator struct {
+	lock     sync.Mutex
+	freeList []int64
+}
+
+// NewFreeListFirstAllocator 创建一个优先复用空闲页的分配器
+func NewFreeListFirstAllocator() *FreeListFirstAllocator {
+	return &FreeListFirstAllocator{}
+}
+
+func (a *FreeListFirstAllocator) Allocate(pageCount int64) (int64, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if n := len(a.freeList); n > 0 {
+		pgno := a.freeList[n-1]
+		a.freeList = a.freeList[:n-1]
+		return pgno, false
+	}
+	return pageCount, true
+}
+
+func (a *FreeListFirstAllocator) Free(pgno int64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.freeList = append(a.freeList, pgno)
+}
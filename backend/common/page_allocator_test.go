@@ -0,0 +1,43 @@
+package common
+
+import "testing"
+
+func TestAppendAllocator(t *testing.T) {
+	a := NewAppendAllocator()
+	pageCount := int64(0)
+
+	for i := 0; i < 3; i++ {
+		pgno, grow := a.Allocate(pageCount)
+		if !grow || pgno != pageCount {
+			t.Errorf("Expected append at %d, got %d (grow=%v)", pageCount, pgno, grow)
+		}
+		pageCount++
+	}
+
+	// 释放的页不会被复用
+	a.Free(1)
+	pgno, grow := a.Allocate(pageCount)
+	if !grow || pgno != pageCount {
+		t.Errorf("Expected append at %d after Free, got %d (grow=%v)", pageCount, pgno, grow)
+	}
+}
+
+func TestFreeListFirstAllocator(t *testing.T) {
+	a := NewFreeListFirstAllocator()
+
+	pgno, grow := a.Allocate(5)
+	if !grow || pgno != 5 {
+		t.Errorf("Expected append at 5 with empty free list, got %d (grow=%v)", pgno, grow)
+	}
+
+	a.Free(2)
+	pgno, grow = a.Allocate(6)
+	if grow || pgno != 2 {
+		t.Errorf("Expected freed page 2 to be reused, got %d (grow=%v)", pgno, grow)
+	}
+
+	pgno, grow = a.Allocate(6)
+	if !grow || pgno != 6 {
+		t.Errorf("Expected append at 6 once free list drained, got %d (grow=%v)", pgno, grow)
+	}
+}
@@ -0,0 +1,409 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// PageSize 是页面缓存默认的页大小
+	PageSize = 1 << 13
+	// MinPageSize 和 MaxPageSize 是可以选择的页大小的范围，页大小还必须是 2 的幂
+	MinPageSize = 512
+	MaxPageSize = 64 << 10
+)
+
+// 数据文件格式:
+//
+// 第一个页大小的区域是文件头，以 pageFileMagic 开始，接着是 4 字节的页大小，其余部分填 0。
+// 页号为 pgno 的页从 (pgno+1)*pageSize 开始，文件头占满一个页使页仍然按页大小对齐。
+// 旧格式的文件没有文件头，页号为 pgno 的页从 pgno*PageSize 开始，只能以默认的页大小打开
+const (
+	offPageFileMagic = 0
+	offPageFileSize  = 4
+	lenPageFileMagic = 4
+)
+
+// pageFileMagic 标识有文件头的数据文件。
+// 它的第一个字节大于 dm 中页的 fso 的最高字节，旧格式的文件不会以它开始
+var pageFileMagic = []byte{'P', 'G', 'F', 0x01}
+
+var (
+	// ErrPageNotFound 表示请求的页号超出了数据文件的范围
+	ErrPageNotFound = errors.New("page not found")
+	// ErrBadPageFile 表示数据文件的长度不是页大小的整数倍，或者文件头不正确
+	ErrBadPageFile = errors.New("bad page file")
+	// ErrBadPageSize 表示页大小不是 2 的幂，或者不在 [MinPageSize, MaxPageSize] 之间
+	ErrBadPageSize = errors.New("invalid page size")
+	// ErrPageSizeMismatch 表示打开数据文件时指定的页大小与文件头中记录的不同
+	ErrPageSizeMismatch = errors.New("page size does not match the page file")
+)
+
+// Page 是页面缓存中的一个页，修改 Data 后需要调用 SetDirty 才会被写回
+type Page struct {
+	lock  sync.Mutex
+	pgno  int64
+	data  []byte
+	dirty bool
+	// mapped 为 true 时 data 是数据文件映射中的切片，修改直接写入映射
+	mapped bool
+}
+
+// PageNumber 返回页号，页号从 0 开始
+func (p *Page) PageNumber() int64 {
+	return p.pgno
+}
+
+// Data 返回页的数据，调用者修改数据时需要持有页锁
+func (p *Page) Data() []byte {
+	return p.data
+}
+
+func (p *Page) Lock() {
+	p.lock.Lock()
+}
+
+func (p *Page) Unlock() {
+	p.lock.Unlock()
+}
+
+// SetDirty 标记页是否被修改过
+func (p *Page) SetDirty(dirty bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.dirty = dirty
+}
+
+func (p *Page) IsDirty() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.dirty
+}
+
+// PageFile 是页面缓存读写页的数据文件，*os.File 和 MemPageFile 都实现了它
+type PageFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+	Close() error
+}
+
+// PageCache 以页号为键缓存数据文件中的页，被淘汰的脏页会被写回文件
+type PageCache struct {
+	*AbstractCache
+	file      PageFile
+	fileLock  sync.Mutex
+	pageCount int64
+	allocator PageAllocator
+
+	pageSize int
+	// dataStart 是页 0 在文件中的偏移，即文件头的长度
+	dataStart int64
+
+	// mapping 在 NewMmapPageCache 映射成功时不为 nil
+	mapping *pageMapping
+
+	// StartFlusher 启动的后台写回协程，没有启动时为 nil
+	flushStop chan struct{}
+	flushDone chan struct{}
+}
+
+// NewPageCache 创建一个最多缓存 maxPages 个页的页面缓存，新页总是追加在文件末尾
+func NewPageCache(file *os.File, maxPages int) (*PageCache, error) {
+	return NewPageCacheWithAllocator(file, maxPages, NewAppendAllocator())
+}
+
+// NewPageCacheWithAllocator 创建一个使用 allocator 分配新页的页面缓存
+func NewPageCacheWithAllocator(file *os.File, maxPages int, allocator PageAllocator) (*PageCache, error) {
+	return NewPageCacheWithPageSize(file, maxPages, PageSize, allocator)
+}
+
+// NewPageCacheWithPageSize 创建一个页大小为 pageSize 的页面缓存。
+// file 为空时写入记录 pageSize 的文件头；否则 pageSize 必须与文件头中的相同，不同时返回 ErrPageSizeMismatch
+func NewPageCacheWithPageSize(file *os.File, maxPages int, pageSize int, allocator PageAllocator) (*PageCache, error) {
+	err := checkPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var dataStart int64
+	size := info.Size()
+	if size == 0 {
+		err = writePageFileHeader(file, pageSize)
+		dataStart = int64(pageSize)
+		size = dataStart
+	} else {
+		dataStart, err = readPageFileHeader(file, pageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size < dataStart || (size-dataStart)%int64(pageSize) != 0 {
+		return nil, fmt.Errorf("%w: file length %d is not a multiple of %d", ErrBadPageFile, size, pageSize)
+	}
+	pageCount := (size - dataStart) / int64(pageSize)
+	return newPageCache(file, pageCount, maxPages, pageSize, dataStart, allocator), nil
+}
+
+// NewMemPageCache 创建一个以 MemPageFile 为数据文件的页面缓存，页面只保存在内存中
+func NewMemPageCache(maxPages int) *PageCache {
+	return newPageCache(NewMemPageFile(), 0, maxPages, PageSize, 0, NewAppendAllocator())
+}
+
+// newPageCache 创建一个读写 file 的页面缓存，file 中从 dataStart 开始已有 pageCount 个页
+func newPageCache(file PageFile, pageCount int64, maxPages int, pageSize int, dataStart int64, allocator PageAllocator) *PageCache {
+	pc := &PageCache{
+		AbstractCache: NewAbstractCache(maxPages),
+		file:          file,
+		pageCount:     pageCount,
+		allocator:     allocator,
+		pageSize:      pageSize,
+		dataStart:     dataStart,
+	}
+	pc.AbstractCache.Cache = pc
+	return pc
+}
+
+// checkPageSize 检查 pageSize 是否是 [MinPageSize, MaxPageSize] 之间的 2 的幂
+func checkPageSize(pageSize int) error {
+	if pageSize < MinPageSize || pageSize > MaxPageSize || pageSize&(pageSize-1) != 0 {
+		return fmt.Errorf("%w: %d must be a power of two in [%d, %d]", ErrBadPageSize, pageSize, MinPageSize, MaxPageSize)
+	}
+	return nil
+}
+
+// writePageFileHeader 在空文件的开头写入占满一个页的文件头并刷盘
+func writePageFileHeader(file *os.File, pageSize int) error {
+	buf := make([]byte, pageSize)
+	copy(buf[offPageFileMagic:], pageFileMagic)
+	binary.BigEndian.PutUint32(buf[offPageFileSize:], uint32(pageSize))
+	_, err := file.WriteAt(buf, 0)
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// readPageFileHeader 检查文件头中的页大小是否为 pageSize，返回页 0 在文件中的偏移。
+// 没有文件头的旧格式文件只能以默认的 PageSize 打开
+func readPageFileHeader(file *os.File, pageSize int) (int64, error) {
+	buf := make([]byte, offPageFileSize+4)
+	_, err := file.ReadAt(buf, 0)
+	if err == io.EOF {
+		return 0, fmt.Errorf("%w: truncated header", ErrBadPageFile)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(buf[offPageFileMagic:offPageFileMagic+lenPageFileMagic], pageFileMagic) {
+		if pageSize != PageSize {
+			return 0, fmt.Errorf("%w: file without header uses page size %d, got %d", ErrPageSizeMismatch, PageSize, pageSize)
+		}
+		return 0, nil
+	}
+	stored := int(binary.BigEndian.Uint32(buf[offPageFileSize:]))
+	if stored != pageSize {
+		return 0, fmt.Errorf("%w: file uses page size %d, got %d", ErrPageSizeMismatch, stored, pageSize)
+	}
+	return int64(pageSize), nil
+}
+
+// PageSize 返回每个页的字节数
+func (pc *PageCache) PageSize() int {
+	return pc.pageSize
+}
+
+// pageOffset 返回页 pgno 在文件中的偏移
+func (pc *PageCache) pageOffset(pgno int64) int64 {
+	return pc.dataStart + pgno*int64(pc.pageSize)
+}
+
+// NewPage 分配一个新页并写入 initData，返回新页的页号
+func (pc *PageCache) NewPage(initData []byte) (int64, error) {
+	pc.lock.Lock()
+	closed := pc.closed
+	pc.lock.Unlock()
+	if closed {
+		return 0, ErrCacheClosed
+	}
+
+	pc.fileLock.Lock()
+	pgno, grow := pc.allocator.Allocate(pc.pageCount)
+	if grow {
+		// 新页直接写到文件末尾，之后再通过缓存访问
+		buf := make([]byte, pc.pageSize)
+		copy(buf, initData)
+		_, err := pc.file.WriteAt(buf, pc.pageOffset(pgno))
+		if err != nil {
+			pc.fileLock.Unlock()
+			pc.allocator.Free(pgno)
+			return 0, err
+		}
+		pc.pageCount++
+		pc.fileLock.Unlock()
+		return pgno, nil
+	}
+	pc.fileLock.Unlock()
+
+	// 复用的页可能还在缓存中，通过缓存覆盖它的内容
+	page, err := pc.GetPage(pgno)
+	if err != nil {
+		pc.allocator.Free(pgno)
+		return 0, err
+	}
+
+	page.Lock()
+	n := copy(page.data, initData)
+	for i := n; i < len(page.data); i++ {
+		page.data[i] = 0
+	}
+	page.dirty = true
+	page.Unlock()
+	return pgno, pc.ReleasePage(page)
+}
+
+// FreePage 把一个不再使用的页交还给分配器
+func (pc *PageCache) FreePage(pgno int64) {
+	pc.allocator.Free(pgno)
+}
+
+// GetPage 获取一个页并增加它的引用，使用完后需要调用 ReleasePage
+func (pc *PageCache) GetPage(pgno int64) (*Page, error) {
+	obj, err := pc.Get(pgno)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*Page), nil
+}
+
+// ReleasePage 释放一个页的引用
+func (pc *PageCache) ReleasePage(page *Page) error {
+	return pc.Release(page.pgno)
+}
+
+// MarkDirty 把缓存中的页 pgno 标记为脏页，页不在缓存中时返回 ErrKeyNotCached
+func (pc *PageCache) MarkDirty(pgno int64) error {
+	pc.lock.Lock()
+	obj, ok := pc.cache[pgno]
+	pc.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrKeyNotCached, pgno)
+	}
+	obj.(*Page).SetDirty(true)
+	return nil
+}
+
+// FlushDirty 把缓存中所有的脏页写回文件并刷盘一次，页仍然留在缓存中。
+// 写回期间持有缓存的锁和每个页的页锁，与正在修改页的协程互斥
+func (pc *PageCache) FlushDirty() error {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	if pc.closed {
+		return ErrCacheClosed
+	}
+
+	var firstErr error
+	flush := func(page *Page) {
+		page.Lock()
+		defer page.Unlock()
+		if !page.dirty {
+			return
+		}
+		err := pc.writePage(page)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		page.dirty = false
+	}
+	for _, obj := range pc.cache {
+		flush(obj.(*Page))
+	}
+	// 已被淘汰但还没释放的页也可能是脏页
+	for _, obj := range pc.pending {
+		flush(obj.(*Page))
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return pc.syncFile()
+}
+
+// PageCount 返回数据文件中的页数
+func (pc *PageCache) PageCount() int64 {
+	pc.fileLock.Lock()
+	defer pc.fileLock.Unlock()
+	return pc.pageCount
+}
+
+// Close 停止后台写回协程，写回所有脏页并关闭数据文件，写回失败的页通过 ReleaseErrors 返回
+func (pc *PageCache) Close() error {
+	pc.stopFlusher()
+	referenced, err := pc.AbstractCache.Close()
+	if errors.Is(err, ErrCacheClosed) {
+		return err
+	}
+	if pc.mapping != nil {
+		if syncErr := pc.mapping.sync(); err == nil && syncErr != nil {
+			err = syncErr
+		}
+		pc.mapping.close(referenced > 0)
+	}
+	closeErr := pc.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func (pc *PageCache) getForCache(key int64) (interface{}, error) {
+	if key < 0 || key >= pc.PageCount() {
+		return nil, fmt.Errorf("%w: %d", ErrPageNotFound, key)
+	}
+
+	if pc.mapping != nil {
+		if data, ok := pc.mapping.page(pc.pageOffset(key), pc.pageSize); ok {
+			return &Page{pgno: key, data: data, mapped: true}, nil
+		}
+	}
+
+	buf := make([]byte, pc.pageSize)
+	_, err := pc.file.ReadAt(buf, pc.pageOffset(key))
+	if err != nil {
+		return nil, err
+	}
+	return &Page{pgno: key, data: buf}, nil
+}
+
+func (pc *PageCache) releaseForCache(obj interface{}) error {
+	page := obj.(*Page)
+	page.Lock()
+	defer page.Unlock()
+	if page.mapped {
+		// 修改已经在映射中，由 FlushDirty 或 Close 的 msync 刷盘
+		page.dirty = false
+		pc.mapping.release()
+		return nil
+	}
+	if !page.dirty {
+		return nil
+	}
+
+	err := pc.writePage(page)
+	if err != nil {
+		return err
+	}
+	page.dirty = false
+	return nil
+}
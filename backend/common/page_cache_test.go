@@ -0,0 +1,267 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func createPageFile(t *testing.T) *os.File {
+	t.Helper()
+	file, err := os.Create("test_file.db")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return file
+}
+
+func TestPageCacheReadWrite(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+
+	pc, err := NewPageCache(file, 1)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+
+	p0, err := pc.NewPage([]byte("page zero"))
+	if err != nil {
+		t.Fatalf("NewPage failed: %v", err)
+	}
+	p1, _ := pc.NewPage([]byte("page one"))
+	if p0 != 0 || p1 != 1 || pc.PageCount() != 2 {
+		t.Fatalf("Unexpected page numbers %d, %d (count %d)", p0, p1, pc.PageCount())
+	}
+
+	page, err := pc.GetPage(p0)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if !bytes.HasPrefix(page.Data(), []byte("page zero")) {
+		t.Errorf("Unexpected page data %q", page.Data()[:16])
+	}
+	page.Lock()
+	copy(page.Data(), "modified")
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+
+	// 第二次获取命中缓存
+	page, _ = pc.GetPage(p0)
+	if !bytes.HasPrefix(page.Data(), []byte("modified")) {
+		t.Errorf("Expected cached modification, got %q", page.Data()[:16])
+	}
+	pc.ReleasePage(page)
+	if stats := pc.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// 获取页 1 会淘汰页 0，脏页被写回文件
+	page, _ = pc.GetPage(p1)
+	pc.ReleasePage(page)
+	buf := make([]byte, 8)
+	file.ReadAt(buf, pc.pageOffset(p0))
+	if string(buf) != "modified" {
+		t.Errorf("Dirty page not persisted on eviction, got %q", buf)
+	}
+
+	if err := pc.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestPageCacheReopen(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+
+	pc, _ := NewPageCache(file, 4)
+	pgno, _ := pc.NewPage(nil)
+	page, _ := pc.GetPage(pgno)
+	page.Lock()
+	copy(page.Data(), "persisted")
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+	pc.Close()
+
+	file, err := os.OpenFile("test_file.db", os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	pc, err = NewPageCache(file, 4)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+	defer pc.Close()
+
+	page, err = pc.GetPage(pgno)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if !bytes.HasPrefix(page.Data(), []byte("persisted")) {
+		t.Errorf("Dirty page not written back on Close, got %q", page.Data()[:16])
+	}
+	pc.ReleasePage(page)
+
+	if _, err := pc.GetPage(pc.PageCount()); err == nil {
+		t.Errorf("Expected an error for a page past the end of the file")
+	}
+}
+
+func TestPageCacheAllocators(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+
+	pc, _ := NewPageCacheWithAllocator(file, 4, NewAppendAllocator())
+	pc.NewPage(nil)
+	pc.FreePage(0)
+	pgno, _ := pc.NewPage(nil)
+	if pgno != 1 || pc.PageCount() != 2 {
+		t.Errorf("Append allocator should grow the file, got page %d (count %d)", pgno, pc.PageCount())
+	}
+	pc.Close()
+
+	file = createPageFile(t)
+	pc, _ = NewPageCacheWithAllocator(file, 4, NewFreeListFirstAllocator())
+	defer pc.Close()
+	pc.NewPage(nil)
+	pc.NewPage([]byte("old"))
+	pc.FreePage(1)
+	pgno, _ = pc.NewPage([]byte("new"))
+	if pgno != 1 || pc.PageCount() != 2 {
+		t.Errorf("Free-list allocator should reuse page 1, got page %d (count %d)", pgno, pc.PageCount())
+	}
+	page, _ := pc.GetPage(pgno)
+	if !bytes.HasPrefix(page.Data(), []byte("new\x00")) {
+		t.Errorf("Reused page not reinitialized, got %q", page.Data()[:8])
+	}
+	pc.ReleasePage(page)
+}
+
+func TestPageCacheFlushDirty(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+
+	pc, _ := NewPageCache(file, 4)
+	defer pc.Close()
+
+	var pages []*Page
+	for i := 0; i < 3; i++ {
+		pgno, _ := pc.NewPage(nil)
+		page, _ := pc.GetPage(pgno)
+		pages = append(pages, page)
+	}
+	for i, page := range []*Page{pages[0], pages[2]} {
+		page.Lock()
+		copy(page.Data(), []string{"flushed 0", "flushed 2"}[i])
+		page.Unlock()
+		if err := pc.MarkDirty(page.PageNumber()); err != nil {
+			t.Fatalf("MarkDirty failed: %v", err)
+		}
+	}
+
+	if err := pc.FlushDirty(); err != nil {
+		t.Fatalf("FlushDirty failed: %v", err)
+	}
+	buf := make([]byte, 9)
+	for _, pgno := range []int64{0, 2} {
+		file.ReadAt(buf, pc.pageOffset(pgno))
+		if string(buf) != fmt.Sprintf("flushed %d", pgno) {
+			t.Errorf("Page %d not flushed, got %q", pgno, buf)
+		}
+	}
+	for _, page := range pages {
+		if page.IsDirty() {
+			t.Errorf("Page %d is still dirty after FlushDirty", page.PageNumber())
+		}
+		pc.ReleasePage(page)
+	}
+	if stats := pc.Stats(); stats.Count != 3 || stats.Evictions != 0 {
+		t.Errorf("FlushDirty should not evict pages, got %+v", stats)
+	}
+
+	if err := pc.MarkDirty(10); !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached, got %v", err)
+	}
+}
+
+func TestPageCacheFlushDirtyConcurrentWrite(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+
+	pc, _ := NewPageCache(file, 4)
+	defer pc.Close()
+	pgno, _ := pc.NewPage(nil)
+	page, _ := pc.GetPage(pgno)
+	defer pc.ReleasePage(page)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			page.Lock()
+			copy(page.Data(), fmt.Sprintf("write %03d", i))
+			page.Unlock()
+			page.SetDirty(true)
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := pc.FlushDirty(); err != nil {
+			t.Errorf("FlushDirty failed: %v", err)
+		}
+	}
+	wg.Wait()
+
+	if err := pc.FlushDirty(); err != nil {
+		t.Fatalf("FlushDirty failed: %v", err)
+	}
+	buf := make([]byte, 9)
+	file.ReadAt(buf, pc.pageOffset(pgno))
+	if string(buf) != "write 099" {
+		t.Errorf("Expected the last write on disk, got %q", buf)
+	}
+}
+
+func TestMemPageCache(t *testing.T) {
+	pc := NewMemPageCache(1)
+	file := pc.file.(*MemPageFile)
+
+	p0, _ := pc.NewPage([]byte("page zero"))
+	p1, _ := pc.NewPage([]byte("page one"))
+	// 和文件一样，每分配一个页增长一个页的长度
+	if p0 != 0 || p1 != 1 || file.Size() != 2*PageSize {
+		t.Fatalf("Unexpected pages %d, %d with size %d", p0, p1, file.Size())
+	}
+
+	page, err := pc.GetPage(p0)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	page.Lock()
+	copy(page.Data(), "modified")
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+
+	// 缓存只能放一个页，读 p1 会把修改过的 p0 淘汰并写回内存
+	page, _ = pc.GetPage(p1)
+	pc.ReleasePage(page)
+	page, err = pc.GetPage(p0)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	if !bytes.HasPrefix(page.Data(), []byte("modified")) {
+		t.Errorf("Expected the evicted page to keep its changes, got %q", page.Data()[:16])
+	}
+	pc.ReleasePage(page)
+	if _, err := pc.GetPage(2); !errors.Is(err, ErrPageNotFound) {
+		t.Errorf("Expected ErrPageNotFound, got %v", err)
+	}
+	if err := pc.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
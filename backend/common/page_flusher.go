@@ -0,0 +1,79 @@
+package common
+
+import "time"
+
+// StartFlusher 启动一个后台协程，每隔 interval 把最多 batchSize 个没有引用的脏页写回文件并刷盘，
+// 这样被淘汰时大多数页已经是干净的。Close 会停止这个协程，并在关闭前把剩余的脏页同步写回
+func (pc *PageCache) StartFlusher(interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	pc.startFlusher(ticker.C, batchSize, ticker.Stop)
+}
+
+// startFlusher 在每次从 tick 收到时间时写回一批脏页，协程退出时调用 stop
+func (pc *PageCache) startFlusher(tick <-chan time.Time, batchSize int, stop func()) {
+	pc.flushStop = make(chan struct{})
+	pc.flushDone = make(chan struct{})
+	go func() {
+		defer close(pc.flushDone)
+		defer stop()
+		for {
+			select {
+			case <-pc.flushStop:
+				return
+			case <-tick:
+				pc.flushBatch(batchSize)
+			}
+		}
+	}()
+}
+
+// stopFlusher 停止后台写回协程并等待它退出
+func (pc *PageCache) stopFlusher() {
+	if pc.flushStop == nil {
+		return
+	}
+	close(pc.flushStop)
+	<-pc.flushDone
+	pc.flushStop = nil
+}
+
+// flushBatch 写回最多 batchSize 个没有引用的脏页，返回写回的页数。
+// 只在挑选页时持有缓存的锁，写回时只持有页锁；页在此期间被淘汰时淘汰已经把它写回，
+// 这里看到它不再是脏页就会跳过。写回失败的页保持为脏页，留给下一次或者淘汰时再写
+func (pc *PageCache) flushBatch(batchSize int) (int, error) {
+	pc.lock.Lock()
+	var pages []*Page
+	for key, obj := range pc.cache {
+		if len(pages) >= batchSize {
+			break
+		}
+		page := obj.(*Page)
+		if pc.references[key] == 0 && page.IsDirty() {
+			pages = append(pages, page)
+		}
+	}
+	pc.lock.Unlock()
+
+	flushed := 0
+	var firstErr error
+	for _, page := range pages {
+		page.Lock()
+		if page.dirty {
+			err := pc.writePage(page)
+			if err == nil {
+				page.dirty = false
+				flushed++
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}
+		page.Unlock()
+	}
+	if flushed > 0 {
+		err := pc.syncFile()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return flushed, firstErr
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// dirtyPage 把 pgno 的开头改成 data 并标记为脏页
+func dirtyPage(t *testing.T, pc *PageCache, pgno int64, data string) {
+	t.Helper()
+	page, err := pc.GetPage(pgno)
+	if err != nil {
+		t.Fatalf("GetPage failed: %v", err)
+	}
+	page.Lock()
+	copy(page.Data(), data)
+	page.Unlock()
+	page.SetDirty(true)
+	pc.ReleasePage(page)
+}
+
+func countDirty(pc *PageCache) int {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+	n := 0
+	for _, obj := range pc.cache {
+		if obj.(*Page).IsDirty() {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPageFlusher(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	pc, err := NewPageCache(file, 10)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		pc.NewPage(nil)
+	}
+	for i := int64(0); i < 3; i++ {
+		dirtyPage(t, pc, i, "dirty")
+	}
+	// 被引用的页不会被后台协程写回
+	held, _ := pc.GetPage(3)
+	held.Lock()
+	copy(held.Data(), "held")
+	held.Unlock()
+	held.SetDirty(true)
+
+	tick := make(chan time.Time)
+	stopped := false
+	pc.startFlusher(tick, 2, func() { stopped = true })
+
+	// 每批最多 2 页，需要两批。tick 没有缓冲，第三次发送成功说明前两批已经写完
+	for i := 0; i < 3; i++ {
+		tick <- time.Now()
+	}
+	if n := countDirty(pc); n != 1 {
+		t.Errorf("Expected only the referenced page to stay dirty, got %d dirty pages", n)
+	}
+	buf := make([]byte, 5)
+	for i := int64(0); i < 3; i++ {
+		file.ReadAt(buf, pc.pageOffset(i))
+		if string(buf) != "dirty" {
+			t.Errorf("Expected page %d on disk, got %q", i, buf)
+		}
+	}
+
+	// Close 停止协程并同步写回剩下的脏页
+	pc.ReleasePage(held)
+	dirtyPage(t, pc, 0, "final")
+	if err := pc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-pc.flushDone:
+	default:
+		t.Errorf("Close did not stop the flusher")
+	}
+	if !stopped {
+		t.Errorf("Expected the ticker to be stopped")
+	}
+
+	data, _ := os.ReadFile("test_file.db")
+	if !bytes.HasPrefix(data[pc.pageOffset(0):], []byte("final")) || !bytes.HasPrefix(data[pc.pageOffset(3):], []byte("held")) {
+		t.Errorf("Expected every dirty page on disk after Close")
+	}
+}
+
+func TestPageFlusherBatchSize(t *testing.T) {
+	pc := NewMemPageCache(10)
+	for i := int64(0); i < 5; i++ {
+		pc.NewPage(nil)
+		dirtyPage(t, pc, i, "dirty")
+	}
+
+	if n, err := pc.flushBatch(2); n != 2 || err != nil {
+		t.Errorf("Expected 2 pages in one batch, got (%d, %v)", n, err)
+	}
+	if n := countDirty(pc); n != 3 {
+		t.Errorf("Expected 3 dirty pages left, got %d", n)
+	}
+	pc.StartFlusher(time.Millisecond, 2)
+	deadline := time.Now().Add(time.Second)
+	for countDirty(pc) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := countDirty(pc); n != 0 {
+		t.Errorf("Expected the flusher to clean every page, got %d dirty", n)
+	}
+	pc.Close()
+}
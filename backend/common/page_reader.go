@@ -0,0 +1,81 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrLeaseClosed 表示 PageReader 已经被关闭
+var ErrLeaseClosed = errors.New("page lease is closed")
+
+// PageReader 是对缓存中一个页的只读租约，它持有页的一个引用，页在 Close 之前不会被淘汰。
+// ReadAt 直接从缓存的页中读取，不需要先把整个页拷贝出来。
+// 租约只保证页留在缓存中，不阻止其他持有引用的调用者修改页；Close 之后(包括之后页被 Release 或淘汰)
+// 不能再使用 Bytes 返回的切片，此时读到的内容是未定义的
+type PageReader struct {
+	pc   *PageCache
+	page *Page
+
+	lock   sync.Mutex
+	closed bool
+}
+
+// OpenPage 获取页 pgno 的一个引用并返回它的 PageReader，使用完后需要调用 Close 释放引用
+func (pc *PageCache) OpenPage(pgno int64) (*PageReader, error) {
+	page, err := pc.GetPage(pgno)
+	if err != nil {
+		return nil, err
+	}
+	return &PageReader{pc: pc, page: page}, nil
+}
+
+// PageNumber 返回租约对应的页号
+func (r *PageReader) PageNumber() int64 {
+	return r.page.pgno
+}
+
+// Size 返回页的字节数
+func (r *PageReader) Size() int64 {
+	return int64(len(r.page.data))
+}
+
+// ReadAt 在页锁下把页中从 off 开始的数据读入 p，读到页尾时返回 io.EOF，实现了 io.ReaderAt。
+// 租约关闭之后返回 ErrLeaseClosed
+func (r *PageReader) ReadAt(p []byte, off int64) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return 0, ErrLeaseClosed
+	}
+	if off < 0 {
+		return 0, errors.New("page reader: negative offset")
+	}
+	if off >= r.Size() {
+		return 0, io.EOF
+	}
+	r.page.Lock()
+	n := copy(p, r.page.data[off:])
+	r.page.Unlock()
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Bytes 返回页数据本身而不是拷贝，只在租约关闭之前有效，不能修改。
+// 读取时不持有页锁，需要与修改者同步时由调用者加页锁
+func (r *PageReader) Bytes() []byte {
+	return r.page.data
+}
+
+// Close 释放租约持有的引用，重复的 Close 返回 ErrLeaseClosed
+func (r *PageReader) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return ErrLeaseClosed
+	}
+	r.closed = true
+	return r.pc.ReleasePage(r.page)
+}
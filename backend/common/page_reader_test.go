@@ -0,0 +1,79 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestPageReader(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	pc, err := NewPageCache(file, 1)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+	defer pc.Close()
+
+	data := make([]byte, PageSize)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	pgno, _ := pc.NewPage(data)
+
+	r, err := pc.OpenPage(pgno)
+	if err != nil {
+		t.Fatalf("OpenPage failed: %v", err)
+	}
+	if r.Size() != PageSize || r.PageNumber() != pgno {
+		t.Errorf("Expected page %d of %d bytes, got page %d of %d", pgno, PageSize, r.PageNumber(), r.Size())
+	}
+
+	buf := make([]byte, 100)
+	n, err := r.ReadAt(buf, 1000)
+	if err != nil || n != 100 || string(buf) != string(data[1000:1100]) {
+		t.Errorf("ReadAt in the middle returned %d, %v", n, err)
+	}
+	n, err = r.ReadAt(buf, PageSize-40)
+	if err != io.EOF || n != 40 || string(buf[:n]) != string(data[PageSize-40:]) {
+		t.Errorf("Expected a short read with io.EOF at the end, got %d, %v", n, err)
+	}
+	if _, err := r.ReadAt(buf, PageSize); err != io.EOF {
+		t.Errorf("Expected io.EOF past the end, got %v", err)
+	}
+
+	sr := io.NewSectionReader(r, 10, 20)
+	part, err := io.ReadAll(sr)
+	if err != nil || string(part) != string(data[10:30]) {
+		t.Errorf("SectionReader returned %v, %v", part, err)
+	}
+	if &r.Bytes()[0] != &r.page.data[0] {
+		t.Errorf("Expected Bytes to return the cached buffer")
+	}
+
+	// 租约持有引用，缓存已满时不能装入其他页
+	other, _ := pc.NewPage(nil)
+	if _, err := pc.GetPage(other); !errors.Is(err, CacheFullError) {
+		t.Errorf("Expected the lease to hold the only slot, got %v", err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := pc.Release(pgno); !errors.Is(err, ErrOverRelease) {
+		t.Errorf("Expected Close to release the reference, got %v", err)
+	}
+	if _, err := r.ReadAt(buf, 0); !errors.Is(err, ErrLeaseClosed) {
+		t.Errorf("Expected ErrLeaseClosed after Close, got %v", err)
+	}
+	if err := r.Close(); !errors.Is(err, ErrLeaseClosed) {
+		t.Errorf("Expected a second Close to fail, got %v", err)
+	}
+
+	page, err := pc.GetPage(other)
+	if err != nil {
+		t.Fatalf("Expected the slot to be free after Close, got %v", err)
+	}
+	pc.ReleasePage(page)
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPageCachePageSizes(t *testing.T) {
+	for _, size := range []int{MinPageSize, 4096, PageSize, MaxPageSize} {
+		file := createPageFile(t)
+		pc, err := NewPageCacheWithPageSize(file, 2, size, NewAppendAllocator())
+		if err != nil {
+			os.Remove("test_file.db")
+			t.Fatalf("NewPageCacheWithPageSize(%d) failed: %v", size, err)
+		}
+		for i := 0; i < 3; i++ {
+			pc.NewPage(nil)
+		}
+		page, _ := pc.GetPage(2)
+		if len(page.Data()) != size {
+			t.Errorf("Page size %d: got %d bytes of data", size, len(page.Data()))
+		}
+		page.Lock()
+		copy(page.Data()[size-4:], "last")
+		page.Unlock()
+		page.SetDirty(true)
+		pc.ReleasePage(page)
+		pc.Close()
+
+		file, _ = os.OpenFile("test_file.db", os.O_RDWR, 0666)
+		pc, err = NewPageCacheWithPageSize(file, 2, size, NewAppendAllocator())
+		if err != nil {
+			os.Remove("test_file.db")
+			t.Fatalf("Reopen with page size %d failed: %v", size, err)
+		}
+		if pc.PageSize() != size || pc.PageCount() != 3 {
+			t.Errorf("Page size %d: reopened with size %d and %d pages", size, pc.PageSize(), pc.PageCount())
+		}
+		page, _ = pc.GetPage(2)
+		if !bytes.HasSuffix(page.Data(), []byte("last")) {
+			t.Errorf("Page size %d: page data not persisted", size)
+		}
+		pc.ReleasePage(page)
+		pc.Close()
+		os.Remove("test_file.db")
+	}
+}
+
+func TestPageCacheReopenWithWrongPageSize(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	pc, _ := NewPageCacheWithPageSize(file, 2, 4096, NewAppendAllocator())
+	pc.NewPage(nil)
+	pc.Close()
+
+	for _, size := range []int{2048, PageSize} {
+		file, _ = os.OpenFile("test_file.db", os.O_RDWR, 0666)
+		_, err := NewPageCacheWithPageSize(file, 2, size, NewAppendAllocator())
+		if !errors.Is(err, ErrPageSizeMismatch) {
+			t.Errorf("Reopen with page size %d: expected ErrPageSizeMismatch, got %v", size, err)
+		}
+		file.Close()
+	}
+}
+
+func TestPageCacheRejectsBadPageSize(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	defer file.Close()
+
+	for _, size := range []int{0, 3000, MinPageSize / 2, MaxPageSize * 2} {
+		if _, err := NewPageCacheWithPageSize(file, 2, size, NewAppendAllocator()); !errors.Is(err, ErrBadPageSize) {
+			t.Errorf("Page size %d: expected ErrBadPageSize, got %v", size, err)
+		}
+	}
+	if info, _ := file.Stat(); info.Size() != 0 {
+		t.Errorf("Rejected page size should not write a header, file has %d bytes", info.Size())
+	}
+}
+
+func TestPageCacheOpensFileWithoutHeader(t *testing.T) {
+	file := createPageFile(t)
+	defer os.Remove("test_file.db")
+	// 旧格式的文件直接从页 0 开始
+	old := make([]byte, 2*PageSize)
+	copy(old[PageSize:], "legacy")
+	file.WriteAt(old, 0)
+
+	pc, err := NewPageCache(file, 2)
+	if err != nil {
+		t.Fatalf("NewPageCache failed: %v", err)
+	}
+	defer pc.Close()
+	if pc.PageCount() != 2 {
+		t.Errorf("Expected 2 pages, got %d", pc.PageCount())
+	}
+	page, _ := pc.GetPage(1)
+	if !bytes.HasPrefix(page.Data(), []byte("legacy")) {
+		t.Errorf("Unexpected page data %q", page.Data()[:8])
+	}
+	pc.ReleasePage(page)
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotPinned 表示 Unpin 的键没有被 Pin
+var ErrNotPinned = errors.New("key not pinned")
+
+// Pin 把 key 加载进缓存并固定，固定的条目不会被淘汰或过期，直到 Unpin。
+// 固定与 Get/Release 的引用计数相互独立，重复 Pin 同一个键只需要 Unpin 一次。Close 仍然会释放固定的条目
+func (c *TypedCache[V]) Pin(key int64) error {
+	_, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+
+	// Get 持有的引用保证条目在固定之前不会被淘汰
+	c.lock.Lock()
+	c.pinned[key] = true
+	c.lock.Unlock()
+	return c.Release(key)
+}
+
+// Unpin 取消 key 的固定，之后它和普通条目一样按淘汰策略淘汰
+func (c *TypedCache[V]) Unpin(key int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return ErrCacheClosed
+	}
+	if !c.pinned[key] {
+		return fmt.Errorf("%w: %d", ErrNotPinned, key)
+	}
+	delete(c.pinned, key)
+	if c.references[key] == 0 {
+		c.freed.Broadcast()
+	}
+	return nil
+}
+
+// evictable 判断 key 能否被淘汰: 没有引用也没有被固定，调用者需持有锁
+func (c *TypedCache[V]) evictable(key int64) bool {
+	return c.references[key] == 0 && !c.pinned[key]
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPinSurvivesEviction(t *testing.T) {
+	ac := NewAbstractCache(3)
+	tc := newTestCache()
+	ac.Cache = tc
+
+	if err := ac.Pin(1); err != nil {
+		t.Fatalf("Pin failed: %v", err)
+	}
+	// 反复 Get/Release 不影响固定
+	for i := 0; i < 3; i++ {
+		ac.Get(1)
+		ac.Release(1)
+	}
+
+	// 装入远超容量的键，固定的条目一直留在缓存中
+	for key := int64(2); key < 20; key++ {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		ac.Release(key)
+	}
+	if _, ok := ac.GetIfPresent(1); !ok {
+		t.Fatalf("Expected pinned key 1 to stay cached")
+	}
+	ac.Release(1)
+	if tc.loadCount(1) != 1 {
+		t.Errorf("Expected key 1 to be loaded once, got %d", tc.loadCount(1))
+	}
+	if ac.Stats().Evictions != 16 {
+		t.Errorf("Expected 16 evictions of unpinned keys, got %d", ac.Stats().Evictions)
+	}
+
+	// 取消固定之后可以被淘汰
+	if err := ac.Unpin(1); err != nil {
+		t.Fatalf("Unpin failed: %v", err)
+	}
+	for key := int64(20); key < 23; key++ {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	if _, ok := ac.GetIfPresent(1); ok {
+		t.Errorf("Expected unpinned key 1 to be evicted")
+	}
+}
+
+func TestPinAllFull(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+	ac.Pin(1)
+	ac.Pin(2)
+	if _, err := ac.Get(3); !errors.Is(err, CacheFullError) {
+		t.Errorf("Expected CacheFullError when every entry is pinned, got %v", err)
+	}
+}
+
+func TestPinDoesNotExpire(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	ac := NewAbstractCacheWithTTL(10, time.Second, clock.Now)
+	tc := newTestCache()
+	ac.Cache = tc
+
+	ac.Pin(1)
+	clock.advance(2 * time.Second)
+	ac.Get(1)
+	ac.Release(1)
+	if tc.loadCount(1) != 1 {
+		t.Errorf("Expected pinned key not to expire, loaded %d times", tc.loadCount(1))
+	}
+}
+
+func TestUnpinErrors(t *testing.T) {
+	ac := NewAbstractCache(2)
+	ac.Cache = newTestCache()
+	if err := ac.Unpin(1); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("Expected ErrNotPinned, got %v", err)
+	}
+	ac.Pin(1)
+	ac.Pin(1)
+	if err := ac.Unpin(1); err != nil {
+		t.Errorf("Unpin failed: %v", err)
+	}
+	if err := ac.Unpin(1); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("Expected ErrNotPinned after Unpin, got %v", err)
+	}
+}
+
+func TestCloseReleasesPinned(t *testing.T) {
+	ac := NewAbstractCache(2)
+	tc := newTestCache()
+	ac.Cache = tc
+	ac.Pin(1)
+
+	n, err := ac.Close()
+	if err != nil || n != 0 {
+		t.Errorf("Expected Close to report no references, got (%d, %v)", n, err)
+	}
+	if tc.releaseCount() != 1 {
+		t.Errorf("Expected the pinned entry to be released, got %d releases", tc.releaseCount())
+	}
+}
@@ -0,0 +1,99 @@
+package common
+
+// ReleaseAll 在一次加锁中释放 keys 中每个键的一个引用，同一个键出现几次就释放几次。
+// 某个键释放失败时仍然释放其余的键，返回第一个错误
+func (c *TypedCache[V]) ReleaseAll(keys []int64) error {
+	c.lock.Lock()
+	var firstErr error
+	for _, key := range keys {
+		err := c.decRef(key)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return firstErr
+}
+
+// GetOwned 与 Get 相同，同时把取得的引用记在 owner 名下(例如事务的 XID)，之后由 ReleaseOwner 一次释放。
+// 这样取得的引用不应再用 Release 释放，否则 ReleaseOwner 会多释放
+func (c *TypedCache[V]) GetOwned(owner int64, key int64) (V, error) {
+	obj, err := c.Get(key)
+	if err != nil {
+		return obj, err
+	}
+	c.lock.Lock()
+	keys := c.owned[owner]
+	if keys == nil {
+		keys = make(map[int64]int)
+		c.owned[owner] = keys
+	}
+	keys[key]++
+	c.lock.Unlock()
+	return obj, nil
+}
+
+// ReleaseOwner 在一次加锁中释放 GetOwned 记在 owner 名下的所有引用，owner 没有引用时什么也不做。
+// 某个键释放失败时仍然释放其余的键，返回第一个错误
+func (c *TypedCache[V]) ReleaseOwner(owner int64) error {
+	c.lock.Lock()
+	var firstErr error
+	for key, n := range c.owned[owner] {
+		for i := 0; i < n; i++ {
+			err := c.decRef(key)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	delete(c.owned, owner)
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return firstErr
+}
+
+// ReleaseAll 把 keys 按分片分组，每个分片加锁一次
+func (sc *ShardedCache) ReleaseAll(keys []int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	groups := make(map[*AbstractCache][]int64)
+	for _, key := range keys {
+		shard := sc.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	var firstErr error
+	for _, shard := range sc.shards {
+		if len(groups[shard]) == 0 {
+			continue
+		}
+		err := shard.ReleaseAll(groups[shard])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// GetOwned 从 key 所在的分片获取资源，并把引用记在该分片的 owner 名下
+func (sc *ShardedCache) GetOwned(owner int64, key int64) (interface{}, error) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).GetOwned(owner, key)
+}
+
+// ReleaseOwner 释放 owner 在所有分片中的引用
+func (sc *ShardedCache) ReleaseOwner(owner int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var firstErr error
+	for _, shard := range sc.shards {
+		err := shard.ReleaseOwner(owner)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
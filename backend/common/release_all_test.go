@@ -0,0 +1,103 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReleaseAll(t *testing.T) {
+	ac := NewAbstractCache(10)
+	ac.Cache = newTestCache()
+
+	keys := []int64{1, 2, 2, 3}
+	for _, key := range keys {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get(%d) failed: %v", key, err)
+		}
+	}
+	if err := ac.ReleaseAll(keys); err != nil {
+		t.Fatalf("ReleaseAll failed: %v", err)
+	}
+	for _, key := range []int64{1, 2, 3} {
+		if refs := ac.references[key]; refs != 0 {
+			t.Errorf("Expected key %d to have no references, got %d", key, refs)
+		}
+	}
+
+	// 出错的键不影响其余的键
+	ac.Get(4)
+	err := ac.ReleaseAll([]int64{9, 1, 4})
+	if !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached, got %v", err)
+	}
+	if refs := ac.references[4]; refs != 0 {
+		t.Errorf("Expected key 4 to be released, got %d references", refs)
+	}
+	if n, _ := ac.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
+
+func TestReleaseOwner(t *testing.T) {
+	ac := NewAbstractCache(10)
+	ac.Cache = newTestCache()
+
+	// 两个 owner 交错地获取引用，其中 2 被两个 owner 共同引用，1 被 owner 1 引用两次
+	const owner1, owner2 = 100, 200
+	gets := []struct {
+		owner, key int64
+	}{{owner1, 1}, {owner2, 2}, {owner1, 2}, {owner2, 3}, {owner1, 1}}
+	for _, g := range gets {
+		if _, err := ac.GetOwned(g.owner, g.key); err != nil {
+			t.Fatalf("GetOwned failed: %v", err)
+		}
+	}
+	// 不属于任何 owner 的引用不受影响
+	ac.Get(3)
+
+	if err := ac.ReleaseOwner(owner1); err != nil {
+		t.Fatalf("ReleaseOwner failed: %v", err)
+	}
+	expected := map[int64]int{1: 0, 2: 1, 3: 2}
+	for key, refs := range expected {
+		if got := ac.references[key]; got != refs {
+			t.Errorf("After releasing owner 1, expected key %d to have %d references, got %d", key, refs, got)
+		}
+	}
+	// 已经释放过的 owner 没有引用
+	if err := ac.ReleaseOwner(owner1); err != nil {
+		t.Errorf("Expected releasing owner 1 again to do nothing, got %v", err)
+	}
+
+	ac.ReleaseOwner(owner2)
+	ac.Release(3)
+	if n, _ := ac.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
+
+func TestShardedReleaseOwner(t *testing.T) {
+	sc := NewShardedCache(4, 0)
+	sc.Cache = newTestCache()
+
+	for key := int64(0); key < 8; key++ {
+		if _, err := sc.GetOwned(key%2, key); err != nil {
+			t.Fatalf("GetOwned failed: %v", err)
+		}
+	}
+	for owner := int64(0); owner < 2; owner++ {
+		if err := sc.ReleaseOwner(owner); err != nil {
+			t.Fatalf("ReleaseOwner failed: %v", err)
+		}
+	}
+	keys := []int64{8, 9, 10, 11, 12}
+	if _, err := sc.MultiGet(keys); err != nil {
+		t.Fatalf("MultiGet failed: %v", err)
+	}
+	if err := sc.ReleaseAll(keys); err != nil {
+		t.Fatalf("ReleaseAll failed: %v", err)
+	}
+	if n, _ := sc.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
@@ -0,0 +1,232 @@
+package common
+
+import (
+	"sort"
+	"sync"
+)
+
+// ShardedCache 把键按 key mod N (或者开启 WithConsistentHashing 时按一致性哈希)分到 N 个独立的 AbstractCache 上，
+// 每个分片有自己的锁，以减少多核下单个锁的竞争。所有分片共用嵌入的 Cache 来加载和释放
+type ShardedCache struct {
+	// lock 保护 shards 和 ring，SetShards 和 Close 持有写锁，其余操作在访问分片期间持有读锁
+	lock   sync.RWMutex
+	shards []*AbstractCache
+	// ring 是一致性哈希环上按哈希值排序的虚拟节点，为 nil 时按 key mod N 选择分片，replicas 是每个分片的虚拟节点数
+	ring     []ringNode
+	replicas int
+	// maxResource 和 opts 是创建时的参数，SetShards 用它们创建新的分片
+	maxResource int
+	opts        []Option
+	closed      bool
+	Cache
+}
+
+// NewShardedCache 创建一个有 shardCount 个分片的 ShardedCache，每个分片最多容纳 maxResource/shardCount 个条目
+// (至少 1 个)，maxResource <= 0 表示不限制
+func NewShardedCache(shardCount int, maxResource int, opts ...Option) *ShardedCache {
+	if shardCount <= 0 {
+		shardCount = 1
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sc := &ShardedCache{shards: make([]*AbstractCache, shardCount), maxResource: maxResource, opts: opts}
+	for i := range sc.shards {
+		sc.shards[i] = sc.newShard(perShardResource(maxResource, shardCount))
+	}
+	if o.ringReplicas > 0 {
+		sc.ring = buildRing(shardCount, o.ringReplicas)
+		sc.replicas = o.ringReplicas
+	}
+	return sc
+}
+
+// perShardResource 返回 shardCount 个分片分 maxResource 时每个分片的容量
+func perShardResource(maxResource, shardCount int) int {
+	if maxResource <= 0 {
+		return maxResource
+	}
+	perShard := maxResource / shardCount
+	if perShard == 0 {
+		perShard = 1
+	}
+	return perShard
+}
+
+// newShard 用创建时的选项创建一个分片
+func (sc *ShardedCache) newShard(maxResource int) *AbstractCache {
+	shard := NewAbstractCache(maxResource, sc.opts...)
+	// Cache 在创建之后才被设置，分片通过 sc 间接调用
+	shard.Cache = sc
+	return shard
+}
+
+// shard 返回 key 所在的分片，调用者需持有 lock
+func (sc *ShardedCache) shard(key int64) *AbstractCache {
+	return sc.shards[sc.shardIndex(key)]
+}
+
+// shardIndex 返回 key 所在分片的下标，调用者需持有 lock
+func (sc *ShardedCache) shardIndex(key int64) int {
+	if sc.ring != nil {
+		return ringLookup(sc.ring, key)
+	}
+	return int(uint64(key) % uint64(len(sc.shards)))
+}
+
+// Get 从 key 所在的分片获取资源
+func (sc *ShardedCache) Get(key int64) (interface{}, error) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Get(key)
+}
+
+// GetUnref 从 key 所在的分片返回已经缓存的资源，不增加引用，见 TypedCache.GetUnref
+func (sc *ShardedCache) GetUnref(key int64) (interface{}, bool) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).GetUnref(key)
+}
+
+// GetWithLoader 从 key 所在的分片获取资源，未命中时用 loader 加载
+func (sc *ShardedCache) GetWithLoader(key int64, loader func(int64) (interface{}, error)) (interface{}, error) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).GetWithLoader(key, loader)
+}
+
+// GetVersioned 从 key 所在的分片获取资源和它的版本号
+func (sc *ShardedCache) GetVersioned(key int64) (interface{}, uint64, error) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).GetVersioned(key)
+}
+
+// CompareAndSwap 在 key 所在的分片中按版本号替换资源
+func (sc *ShardedCache) CompareAndSwap(key int64, expected uint64, value interface{}) (bool, error) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).CompareAndSwap(key, expected, value)
+}
+
+// Release 释放 key 所在分片中的一个引用
+func (sc *ShardedCache) Release(key int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Release(key)
+}
+
+// Pin 在 key 所在的分片中固定 key
+func (sc *ShardedCache) Pin(key int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Pin(key)
+}
+
+// Unpin 在 key 所在的分片中取消固定 key
+func (sc *ShardedCache) Unpin(key int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Unpin(key)
+}
+
+// ForgetMiss 删除 key 所在分片中 key 的墓碑
+func (sc *ShardedCache) ForgetMiss(key int64) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	sc.shard(key).ForgetMiss(key)
+}
+
+// MissCount 返回所有分片的墓碑数之和
+func (sc *ShardedCache) MissCount() int {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	n := 0
+	for _, shard := range sc.shards {
+		n += shard.MissCount()
+	}
+	return n
+}
+
+// Stats 返回所有分片统计信息的总和
+func (sc *ShardedCache) Stats() CacheStats {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var total CacheStats
+	for _, shard := range sc.shards {
+		stats := shard.Stats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Rejections += stats.Rejections
+		total.Count += stats.Count
+		total.MaxResource += stats.MaxResource
+		total.Bytes += stats.Bytes
+		total.MaxBytes += stats.MaxBytes
+	}
+	return total
+}
+
+// Len 返回所有分片的条目数之和
+func (sc *ShardedCache) Len() int {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	n := 0
+	for _, shard := range sc.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Contains 判断 key 是否已经加载在它所在的分片中
+func (sc *ShardedCache) Contains(key int64) bool {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.shard(key).Contains(key)
+}
+
+// TopKeys 合并所有分片的访问统计，返回访问最多的 k 个键
+func (sc *ShardedCache) TopKeys(k int) []KeyStat {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var stats []KeyStat
+	for _, shard := range sc.shards {
+		stats = append(stats, shard.TopKeys(k)...)
+	}
+	sortKeyStats(stats)
+	if k < len(stats) {
+		stats = stats[:k]
+	}
+	return stats
+}
+
+// Close 立即关闭所有分片，返回关闭时仍被引用的条目总数以及所有分片中释放失败的条目
+func (sc *ShardedCache) Close() (int, error) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	sc.closed = true
+	referenced := 0
+	var errs ReleaseErrors
+	for _, shard := range sc.shards {
+		n, err := shard.Close()
+		referenced += n
+		if err != nil {
+			errs = append(errs, err.(ReleaseErrors)...)
+		}
+	}
+	return referenced, errs.orNil()
+}
+
+// Leaks 返回所有分片在最近一次 Close 时仍被引用的条目，按 Key 升序排列
+func (sc *ShardedCache) Leaks() LeakReport {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	var report LeakReport
+	for _, shard := range sc.shards {
+		report = append(report, shard.Leaks()...)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+	return report
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedCacheEviction(t *testing.T) {
+	tc := newTestCache()
+	sc := NewShardedCache(2, 4)
+	sc.Cache = tc
+
+	// 1、3、5 都落在分片 1 上，分片容量为 2
+	for _, key := range []int64{1, 3} {
+		sc.Get(key)
+		sc.Release(key)
+	}
+	sc.Get(2)
+	sc.Get(5)
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 10 {
+		t.Fatalf("Expected key 1 to be evicted from its shard, got %v", tc.releases)
+	}
+
+	// 分片 1 已满且都被引用，分片 0 还有空间
+	sc.Get(3)
+	if _, err := sc.Get(7); !errors.Is(err, CacheFullError) {
+		t.Errorf("Expected CacheFullError from the full shard, got %v", err)
+	}
+	if _, err := sc.Get(4); err != nil {
+		t.Errorf("Get on the other shard failed: %v", err)
+	}
+
+	sc.Get(2) // hit
+	expected := CacheStats{Hits: 2, Misses: 5, Evictions: 1, Count: 4, MaxResource: 4}
+	if stats := sc.Stats(); stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+
+	sc.Close()
+	if tc.releaseCount() != 5 {
+		t.Errorf("Expected Close to release every shard, got %d releases", tc.releaseCount())
+	}
+}
+
+func TestShardedCacheNegativeKey(t *testing.T) {
+	sc := NewShardedCache(3, 0)
+	sc.Cache = newTestCache()
+
+	obj, err := sc.Get(-7)
+	if err != nil || obj.(int64) != -70 {
+		t.Fatalf("Expected -70, got %v, %v", obj, err)
+	}
+	if err := sc.Release(-7); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+}
+
+const benchmarkKeys = 1024
+
+func benchmarkParallelGet(b *testing.B, get func(int64) (interface{}, error), release func(int64) error) {
+	var next int64
+	b.RunParallel(func(pb *testing.PB) {
+		key := atomic.AddInt64(&next, 1)
+		for pb.Next() {
+			key = (key + 1) % benchmarkKeys
+			if _, err := get(key); err != nil {
+				b.Error(err)
+				return
+			}
+			release(key)
+		}
+	})
+}
+
+func BenchmarkAbstractCacheParallelGet(b *testing.B) {
+	ac := NewAbstractCache(benchmarkKeys)
+	ac.Cache = newTestCache()
+	benchmarkParallelGet(b, ac.Get, ac.Release)
+}
+
+func BenchmarkShardedCacheParallelGet(b *testing.B) {
+	sc := NewShardedCache(16, benchmarkKeys)
+	sc.Cache = newTestCache()
+	benchmarkParallelGet(b, sc.Get, sc.Release)
+}
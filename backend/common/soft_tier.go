@@ -0,0 +1,51 @@
+package common
+
+// WithSoftTier 开启软引用层: 被淘汰的条目不立即释放，而是保留在缓存之外，不计入 maxResource。
+// Get 命中软引用层中的条目时直接把它放回缓存，不调用加载函数，用于缓解工作集来回变化时的反复加载。
+// 软引用层中的条目由 ReclaimSoft 释放；pressure 不为 nil 时，每从 pressure 收到一个信号就调用一次 ReclaimSoft。
+// Close 会释放软引用层中剩余的条目
+func WithSoftTier(pressure <-chan struct{}) Option {
+	return func(o *options) {
+		o.softTier = true
+		o.softPressure = pressure
+	}
+}
+
+// ReclaimSoft 释放软引用层(以及空闲释放的待释放队列)中的所有条目，释放失败的条目留在软引用层中，
+// 返回它们的 ReleaseErrors
+func (c *TypedCache[V]) ReclaimSoft() error {
+	return c.releasePending(true)
+}
+
+// SoftCount 返回软引用层中的条目数
+func (c *TypedCache[V]) SoftCount() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.pending)
+}
+
+// softReclaimLoop 每收到一个内存压力信号释放一次软引用层
+func (c *TypedCache[V]) softReclaimLoop(pressure <-chan struct{}, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-pressure:
+			c.ReclaimSoft()
+		}
+	}
+}
+
+// stopSoftReclaim 停止等待内存压力信号的协程，可以并发调用，只有一个调用者真正停止它
+func (c *TypedCache[V]) stopSoftReclaim() {
+	c.lock.Lock()
+	stop, done := c.softStop, c.softDone
+	c.softStop = nil
+	c.lock.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftTierHitAvoidsLoader(t *testing.T) {
+	ac := NewAbstractCache(1, WithSoftTier(nil))
+	tc := newTestCache()
+	ac.Cache = tc
+
+	access := func(key int64) {
+		if _, err := ac.Get(key); err != nil {
+			t.Fatalf("Get(%d) failed: %v", key, err)
+		}
+		ac.Release(key)
+	}
+
+	access(1)
+	// 2 淘汰 1，1 留在软引用层中不计入容量
+	access(2)
+	if n := ac.SoftCount(); n != 1 || tc.releaseCount() != 0 {
+		t.Fatalf("Expected 1 soft entry and no releases, got %d, %v", n, tc.releases)
+	}
+	if stats := ac.Stats(); stats.Count != 1 {
+		t.Errorf("Expected 1 entry counted against the capacity, got %d", stats.Count)
+	}
+
+	// 再次访问 1 从软引用层放回缓存，不调用加载函数
+	access(1)
+	if loads := tc.loadCount(1); loads != 1 {
+		t.Errorf("Expected key 1 to be loaded once, got %d", loads)
+	}
+
+	// ReclaimSoft 释放被挤到软引用层的 2
+	if err := ac.ReclaimSoft(); err != nil {
+		t.Fatalf("ReclaimSoft failed: %v", err)
+	}
+	if n := ac.SoftCount(); n != 0 {
+		t.Errorf("Expected the soft tier to be empty, got %d", n)
+	}
+	if tc.releaseCount() != 1 || tc.releases[0].(int64) != 20 {
+		t.Errorf("Expected key 2 to be released, got %v", tc.releases)
+	}
+	access(2)
+	if loads := tc.loadCount(2); loads != 2 {
+		t.Errorf("Expected key 2 to be loaded again after reclaim, got %d loads", loads)
+	}
+	ac.Close()
+}
+
+func TestSoftTierPressureSignal(t *testing.T) {
+	pressure := make(chan struct{})
+	ac := NewAbstractCache(1, WithSoftTier(pressure))
+	tc := newTestCache()
+	ac.Cache = tc
+
+	for key := int64(1); key <= 3; key++ {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	if n := ac.SoftCount(); n != 2 {
+		t.Fatalf("Expected 2 soft entries, got %d", n)
+	}
+
+	pressure <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for tc.releaseCount() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if tc.releaseCount() != 2 {
+		t.Errorf("Expected 2 releases after the pressure signal, got %v", tc.releases)
+	}
+
+	// Close 停止等待信号的协程并释放剩下的条目
+	if n, err := ac.Close(); n != 0 || err != nil {
+		t.Errorf("Close failed: %d, %v", n, err)
+	}
+	if tc.releaseCount() != 3 {
+		t.Errorf("Expected all entries to be released, got %v", tc.releases)
+	}
+}
@@ -0,0 +1,949 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Loader 从底层存储加载 key 对应的值
+type Loader[V any] func(key int64) (V, error)
+
+// Releaser 在值被移出缓存时释放它，例如把脏页写回。返回错误时值仍然保留在缓存中
+type Releaser[V any] func(V) error
+
+// TypedCache 是类型安全的引用计数缓存。
+// 引用计数归零的条目仍然留在缓存中，直到缓存已满时按淘汰策略(默认 LRU)被淘汰
+type TypedCache[V any] struct {
+	cache       map[int64]V
+	references  map[int64]int
+	getting     map[int64]bool
+	maxResource int
+	count       int
+	lock        sync.Mutex
+	// loaded 在某个键加载结束(成功或失败)时广播，等待该键的 Get 被唤醒后重新检查
+	loaded *sync.Cond
+	// freed 在出现可淘汰的条目或者空出容量时广播，唤醒因缓存已满而等待的 GetCtx
+	freed *sync.Cond
+	// policy 决定缓存已满时淘汰哪个无引用的条目，默认是 LRU
+	policy evictionPolicy
+	// pinned 中的条目被 Pin 固定，不会被淘汰或过期
+	pinned map[int64]bool
+	// versions 记录缓存中每个条目的版本号，nextVersion 是最近分配的版本号
+	versions    map[int64]uint64
+	nextVersion uint64
+	// owned 记录 GetOwned 取得的引用: owner -> key -> 引用数
+	owned map[int64]map[int64]int
+
+	// 按字节限制容量: sizeof 不为 nil 时 sizes 记录缓存中每个条目的大小，bytes 是它们的总和
+	maxBytes int64
+	sizeof   func(value interface{}) int64
+	sizes    map[int64]int64
+	bytes    int64
+
+	loader   Loader[V]
+	releaser Releaser[V]
+	onEvict  func(key int64, value interface{})
+	// evicted 保存已经释放、还没有通知 onEvict 的条目，在释放锁之后统一通知
+	evicted []evictedEntry[V]
+
+	// 统计信息，在 lock 下更新
+	hits      int64
+	misses    int64
+	evictions int64
+
+	// 过期: ttl > 0 时记录每个条目的加载时间，过期且无引用的条目在 Get 时重新加载
+	ttl      time.Duration
+	clock    Clock
+	loadedAt map[int64]time.Time
+
+	// 空闲释放: 被淘汰的条目先放入 pending，等缓存空闲后由后台协程释放
+	idleRelease time.Duration
+	pending     map[int64]V
+	inFlight    int
+	lastAccess  time.Time
+	stopIdle    chan struct{}
+	idleDone    chan struct{}
+
+	// 软引用层: 开启 WithSoftTier 时被淘汰的条目同样放入 pending，直到 ReclaimSoft 或内存压力信号释放它们
+	softTier bool
+	softStop chan struct{}
+	softDone chan struct{}
+
+	// 未命中缓存: negativeTTL > 0 时 negative 保存加载返回 ErrNotFound 的键的墓碑
+	negativeTTL time.Duration
+	negative    map[int64]tombstone
+
+	// CloseWait 开始后 closing 为 true，不再接受新的 Get，所有引用归零时关闭 drained
+	closing bool
+	drained chan struct{}
+	// closed 在 Close 之后为 true，之后的操作返回 ErrCacheClosed
+	closed bool
+
+	// hotKeys 在开启 WithHotKeys 时统计访问最多的键，在 lock 下更新
+	hotKeys *hotKeys
+
+	// admission 在开启 WithAdmission 时估计每个键的访问频率，transient 中是没有被准入、引用归零时就释放的条目
+	admission  *frequencySketch
+	transient  map[int64]bool
+	rejections int64
+
+	// stale 保存被 Invalidate 移出缓存、仍被引用的旧值，references 中的引用数包括它们的持有者，
+	// 键的引用全部释放时释放这些旧值。invalid 标记加载期间被 Invalidate 的键，加载完成后同样移入 stale
+	stale   map[int64][]V
+	invalid map[int64]bool
+
+	// loadSlots 在开启 WithMaxConcurrentLoads 时限制同时执行的加载函数个数，每个进行中的加载占用一个位置
+	loadSlots chan struct{}
+
+	// refStacks 在开启 WithLeakStacks 时记录每个未释放引用的调用栈，leaks 是最近一次 Close 的泄漏报告
+	refStacks map[int64][]string
+	leaks     LeakReport
+}
+
+type evictedEntry[V any] struct {
+	key   int64
+	value V
+}
+
+// options 保存创建缓存时的可选配置
+type options struct {
+	idleRelease   time.Duration
+	onEvict       func(key int64, value interface{})
+	ttl           time.Duration
+	clock         Clock
+	leakStacks    bool
+	policy        Policy
+	maxBytes      int64
+	sizeof        func(value interface{}) int64
+	hotKeys       int
+	hotKeysSample int
+	softTier      bool
+	softPressure  <-chan struct{}
+	negativeTTL   time.Duration
+	ringReplicas  int
+	cost          func(key int64) int
+
+	admission         bool
+	admissionCounters int
+
+	maxConcurrentLoads int
+}
+
+// Clock 返回当前时间，测试中可以替换成假的时钟
+type Clock func() time.Time
+
+// WithClock 设置缓存读取当前时间的时钟，过期、未命中缓存的墓碑和空闲释放都按它判断时间，
+// 测试中换成假的时钟后这些行为不再依赖真实的时间流逝。后台协程的检查周期仍然使用真实的定时器
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// Option 用于在创建时配置缓存
+type Option func(*options)
+
+// WithIdleRelease 让被淘汰的条目不立即释放，
+// 而是等到缓存至少 idle 时长没有进行中的 Get 时再由后台协程释放，Close 会强制释放剩余条目
+func WithIdleRelease(idle time.Duration) Option {
+	return func(o *options) {
+		o.idleRelease = idle
+	}
+}
+
+// WithOnEvict 设置条目离开缓存(被淘汰或 Close)并释放之后的回调，每个条目只回调一次。
+// 回调时不持有缓存的锁，回调中可以再次访问缓存
+func WithOnEvict(fn func(key int64, value interface{})) Option {
+	return func(o *options) {
+		o.onEvict = fn
+	}
+}
+
+// WithTTL 让条目在加载 ttl 时长之后过期，过期且无引用的条目在下一次 Get 时释放并重新加载。
+// clock 不为 nil 时与 WithClock 相同，为 nil 时使用 WithClock 设置的时钟或者 time.Now
+func WithTTL(ttl time.Duration, clock Clock) Option {
+	return func(o *options) {
+		o.ttl = ttl
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// NewTypedCache 创建一个最多容纳 maxResource 个条目的 TypedCache，maxResource <= 0 表示不限制
+func NewTypedCache[V any](maxResource int, loader Loader[V], releaser Releaser[V], opts ...Option) *TypedCache[V] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.clock == nil {
+		o.clock = time.Now
+	}
+
+	c := &TypedCache[V]{
+		cache:       make(map[int64]V),
+		references:  make(map[int64]int),
+		getting:     make(map[int64]bool),
+		maxResource: maxResource,
+		policy:      newEvictionPolicy(o.policy, maxResource, o.cost),
+		pinned:      make(map[int64]bool),
+		versions:    make(map[int64]uint64),
+		owned:       make(map[int64]map[int64]int),
+		maxBytes:    o.maxBytes,
+		sizeof:      o.sizeof,
+		sizes:       make(map[int64]int64),
+		loader:      loader,
+		releaser:    releaser,
+		onEvict:     o.onEvict,
+		ttl:         o.ttl,
+		clock:       o.clock,
+		loadedAt:    make(map[int64]time.Time),
+		idleRelease: o.idleRelease,
+		pending:     make(map[int64]V),
+		softTier:    o.softTier,
+		negativeTTL: o.negativeTTL,
+		negative:    make(map[int64]tombstone),
+		transient:   make(map[int64]bool),
+		stale:       make(map[int64][]V),
+		invalid:     make(map[int64]bool),
+	}
+	c.loaded = sync.NewCond(&c.lock)
+	c.freed = sync.NewCond(&c.lock)
+	if o.leakStacks {
+		c.refStacks = make(map[int64][]string)
+	}
+	if o.maxConcurrentLoads > 0 {
+		c.loadSlots = make(chan struct{}, o.maxConcurrentLoads)
+	}
+	if o.hotKeys > 0 {
+		c.hotKeys = newHotKeys(o.hotKeys, o.hotKeysSample)
+	}
+	if o.admission {
+		counters := o.admissionCounters
+		if counters <= 0 {
+			counters = maxResource
+		}
+		c.admission = newFrequencySketch(counters)
+	}
+
+	if c.idleRelease > 0 {
+		c.stopIdle = make(chan struct{})
+		c.idleDone = make(chan struct{})
+		go c.idleReleaseLoop(c.stopIdle, c.idleDone)
+	}
+	if o.softPressure != nil {
+		c.softStop = make(chan struct{})
+		c.softDone = make(chan struct{})
+		go c.softReclaimLoop(o.softPressure, c.softStop, c.softDone)
+	}
+	return c
+}
+
+// seed 直接把 entries 装入缓存，装入的条目引用计数为 0，调用者需保证 entries 不超过 maxResource
+func (c *TypedCache[V]) seed(entries map[int64]V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, obj := range entries {
+		c.cache[key] = obj
+		c.references[key] = 0
+		c.addBytes(key, obj)
+		c.stamp(key)
+		// 装入不算访问，不计入 TopKeys
+		c.policy.access(key)
+		c.count++
+		if c.ttl > 0 {
+			c.loadedAt[key] = c.clock()
+		}
+	}
+}
+
+// Get 通过给定的键从缓存中检索元素
+func (c *TypedCache[V]) Get(key int64) (V, error) {
+	return c.get(nil, key, c.loader)
+}
+
+// GetCtx 与 Get 相同，但缓存已满且所有条目都被引用时不立即返回 CacheFullError，
+// 而是等待有条目被释放，直到 ctx 被取消时返回 ctx.Err()
+func (c *TypedCache[V]) GetCtx(ctx context.Context, key int64) (V, error) {
+	return c.get(ctx, key, c.loader)
+}
+
+// GetWithLoader 与 Get 相同，但缓存未命中时用 loader 而不是创建缓存时的加载函数加载 key。
+// 同一个键同时只会有一个加载在进行: 其他协程正在加载这个键时等待它结束并使用它加载的值，
+// 此时 loader 不会被调用；那次加载失败时再用自己的 loader 加载
+func (c *TypedCache[V]) GetWithLoader(key int64, loader Loader[V]) (V, error) {
+	return c.get(nil, key, loader)
+}
+
+// get 实现 Get、GetCtx 和 GetWithLoader，ctx 为 nil 时缓存已满不等待，未命中时用 loader 加载
+func (c *TypedCache[V]) get(ctx context.Context, key int64, loader Loader[V]) (V, error) {
+	var zero V
+	if c.idleRelease > 0 {
+		c.beginAccess()
+		defer c.endAccess()
+	}
+
+	c.lock.Lock()
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+	transient := false
+	for {
+		// 其他协程正在加载这个键时等待加载结束
+		for c.getting[key] {
+			c.loaded.Wait()
+		}
+		if c.closing {
+			c.lock.Unlock()
+			return zero, ErrCacheClosed
+		}
+		if err, ok := c.cachedMiss(key); ok {
+			c.lock.Unlock()
+			return zero, err
+		}
+
+		if obj, ok := c.cache[key]; ok {
+			if !c.expired(key) {
+				c.references[key]++
+				c.trackRef(key)
+				c.touch(key)
+				c.hits++
+				c.lock.Unlock()
+				return obj, nil
+			}
+			if err := c.expire(key, obj); err != nil {
+				c.lock.Unlock()
+				return zero, err
+			}
+		}
+
+		// 缓存已满时淘汰最久未访问的无引用条目。
+		// 所有条目都被引用时返回 CacheFullError(或等待)，可淘汰的条目都释放失败时返回 ReleaseErrors
+		if c.maxResource <= 0 || c.count < c.maxResource {
+			break
+		}
+		// 比牺牲者更冷的键不淘汰任何条目，加载后只在被引用期间占用缓存
+		if c.rejects(key) {
+			transient = true
+			break
+		}
+		ok, err := c.evictOne()
+		if ok {
+			break
+		}
+		if err == nil && ctx != nil {
+			err = ctx.Err()
+			if err == nil {
+				c.waitFreed(ctx)
+				continue
+			}
+		}
+		count, maxResource := c.count, c.maxResource
+		c.lock.Unlock()
+		if err != nil {
+			return zero, err
+		}
+		return zero, fmt.Errorf("%w: get key %d: %d of %d entries in use", CacheFullError, key, count, maxResource)
+	}
+	// 还没来得及释放的条目直接放回缓存，避免同一个键同时存在两份
+	if obj, ok := c.pending[key]; ok && c.expired(key) {
+		if err := c.expire(key, obj); err != nil {
+			c.lock.Unlock()
+			return zero, err
+		}
+	}
+	evicted := c.takeEvicted()
+
+	if obj, ok := c.pending[key]; ok {
+		delete(c.pending, key)
+		c.cache[key] = obj
+		// 键可能还有被 Invalidate 移出缓存的旧值的引用
+		c.references[key]++
+		c.trackRef(key)
+		c.touch(key)
+		c.addBytes(key, obj)
+		c.stamp(key)
+		c.fitBytes()
+		evicted = append(evicted, c.takeEvicted()...)
+		c.count++
+		c.hits++
+		c.lock.Unlock()
+		c.notifyEvicted(evicted)
+		return obj, nil
+	}
+
+	c.count++
+	c.misses++
+	c.getting[key] = true
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+
+	obj, err := c.load(ctx, key, loader)
+	if err != nil {
+		c.lock.Lock()
+		c.recordMiss(key, err)
+		c.abandonLoad(key)
+		c.lock.Unlock()
+		return zero, err
+	}
+
+	c.lock.Lock()
+	if c.closed {
+		// Close 会等待进行中的加载结束，这里只是防御: 关闭之后才到达的值不放入缓存
+		c.abandonLoad(key)
+		c.lock.Unlock()
+		c.releaser(obj)
+		return zero, ErrCacheClosed
+	}
+	delete(c.getting, key)
+	c.cache[key] = obj
+	c.references[key]++
+	c.trackRef(key)
+	if c.ttl > 0 {
+		c.loadedAt[key] = c.clock()
+	}
+	c.stamp(key)
+	c.addBytes(key, obj)
+	if transient {
+		// 未准入的条目不进入淘汰策略，也不为它淘汰其他条目
+		c.transient[key] = true
+		c.rejections++
+		if c.hotKeys != nil {
+			c.hotKeys.record(key)
+		}
+	} else {
+		c.touch(key)
+		// 装入之后才知道条目的大小，超出字节预算时淘汰其他条目
+		c.fitBytes()
+	}
+	if c.invalid[key] {
+		// 加载期间被 Invalidate，加载到的值可能是旧的: 交给调用者使用，但不留在缓存中
+		delete(c.invalid, key)
+		c.detach(key)
+	}
+	evicted = c.takeEvicted()
+	c.loaded.Broadcast()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+
+	return obj, nil
+}
+
+// GetIfPresent 在 key 已经在缓存中时增加它的引用并返回，否则返回 false，不会调用加载函数。
+// 成功时与 Get 命中一样需要调用 Release。正在加载、已过期或者已被淘汰的条目都视为不在缓存中
+func (c *TypedCache[V]) GetIfPresent(key int64) (V, bool) {
+	var zero V
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+	obj, ok := c.cache[key]
+	if !ok || c.closing || c.expired(key) {
+		return zero, false
+	}
+	c.references[key]++
+	c.trackRef(key)
+	c.touch(key)
+	c.hits++
+	return obj, true
+}
+
+// GetUnref 在 key 已经在缓存中时返回它的值，但不增加引用，不需要也不能调用 Release。
+// 与 GetIfPresent 一样算作一次命中和访问，不会调用加载函数。
+// 注意: 没有引用保护的值随时可能被其他协程的 Get 淘汰并释放(例如脏页被写回、缓冲区被复用)，
+// 只能在拿到之后立即使用，不能保存下来；需要在使用期间保持有效时应使用 GetIfPresent
+func (c *TypedCache[V]) GetUnref(key int64) (V, bool) {
+	var zero V
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+	obj, ok := c.cache[key]
+	if !ok || c.closing || c.expired(key) {
+		return zero, false
+	}
+	c.touch(key)
+	c.hits++
+	return obj, true
+}
+
+// CacheStats 是缓存的命中统计
+type CacheStats struct {
+	Hits        int64 // 直接从缓存中取到的次数
+	Misses      int64 // 需要调用加载函数的次数
+	Evictions   int64 // 被淘汰的条目数
+	Rejections  int64 // 开启 WithAdmission 时没有被准入缓存的加载次数
+	Count       int   // 当前缓存的条目数
+	MaxResource int   // 最大条目数，<= 0 表示不限制
+	Bytes       int64 // 开启 WithMaxBytes 时缓存中条目的总字节数
+	MaxBytes    int64 // 字节预算，<= 0 表示不按字节限制
+}
+
+// Stats 返回当前统计信息的一份拷贝
+func (c *TypedCache[V]) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return CacheStats{
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+		Rejections:  c.rejections,
+		Count:       c.count,
+		MaxResource: c.maxResource,
+		Bytes:       c.bytes,
+		MaxBytes:    c.maxBytes,
+	}
+}
+
+// Len 返回缓存中的条目数，包括正在加载的条目，不包括已经淘汰、等待释放的条目
+func (c *TypedCache[V]) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.count
+}
+
+// Contains 判断 key 是否已经加载在缓存中，不加载、不取得引用，也不算作一次访问
+func (c *TypedCache[V]) Contains(key int64) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.cache[key]
+	return ok
+}
+
+// Release 释放一个引用，引用归零的条目留在缓存中等待淘汰(没有被准入的条目立即释放)。
+// 键不在缓存中时返回 ErrKeyNotCached，键已经没有引用时返回 ErrOverRelease。
+// 键的引用归零时释放被 Invalidate 移出缓存的旧值，释放失败时返回 *ReleaseError，旧值留到下次引用归零或 Close 时再释放
+func (c *TypedCache[V]) Release(key int64) error {
+	c.lock.Lock()
+	err := c.decRef(key)
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+	return err
+}
+
+// decRef 把 key 的引用计数减一，调用者需持有锁
+func (c *TypedCache[V]) decRef(key int64) error {
+	if c.closed {
+		return ErrCacheClosed
+	}
+	ref, ok := c.references[key]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrKeyNotCached, key)
+	}
+	if ref == 0 {
+		return fmt.Errorf("%w: %d", ErrOverRelease, key)
+	}
+	c.references[key] = ref - 1
+	c.untrackRef(key)
+	var err error
+	if ref == 1 {
+		err = c.releaseStale(key)
+		if _, ok := c.cache[key]; !ok {
+			if len(c.stale[key]) == 0 {
+				delete(c.references, key)
+			}
+		} else if c.transient[key] {
+			c.dropTransient(key)
+		}
+		c.freed.Broadcast()
+	}
+	c.checkDrained()
+	return err
+}
+
+// load 调用 loader 加载 key，开启 WithMaxConcurrentLoads 时先等待加载名额，ctx 被取消时返回 ctx.Err()。
+// loader panic 时先撤销 get 为这次加载占用的位置和加载中标记并唤醒等待者，
+// 再让 panic 继续传播，之后这个键仍然可以重新加载。无论成功、失败还是 panic 都会归还加载名额
+func (c *TypedCache[V]) load(ctx context.Context, key int64, loader Loader[V]) (obj V, err error) {
+	if err = c.acquireLoad(ctx); err != nil {
+		return obj, err
+	}
+	returned := false
+	defer func() {
+		c.releaseLoad()
+		if !returned {
+			c.lock.Lock()
+			c.abandonLoad(key)
+			c.lock.Unlock()
+		}
+	}()
+	obj, err = loader(key)
+	returned = true
+	return obj, err
+}
+
+// abandonLoad 撤销一次失败的加载，调用者需持有锁
+func (c *TypedCache[V]) abandonLoad(key int64) {
+	c.count--
+	delete(c.getting, key)
+	delete(c.invalid, key)
+	c.loaded.Broadcast()
+	c.freed.Broadcast()
+	c.checkDrained()
+}
+
+// SetMaxResource 调整缓存容量，n <= 0 表示不限制。
+// 缩小容量时按淘汰策略的顺序淘汰未被引用的条目，直到 count <= n 或者剩下的条目都被引用
+func (c *TypedCache[V]) SetMaxResource(n int) {
+	c.lock.Lock()
+	c.maxResource = n
+	c.policy.setCapacity(n)
+	c.freed.Broadcast()
+	for n > 0 && c.count > n {
+		if ok, _ := c.evictOne(); !ok {
+			break
+		}
+	}
+	evicted := c.takeEvicted()
+	c.lock.Unlock()
+	c.notifyEvicted(evicted)
+}
+
+// waitFreed 等待 freed 广播或者 ctx 被取消，调用者需持有锁
+func (c *TypedCache[V]) waitFreed(ctx context.Context) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.lock.Lock()
+			c.freed.Broadcast()
+			c.lock.Unlock()
+		case <-stop:
+		}
+	}()
+	c.freed.Wait()
+}
+
+// touch 记录一次对键的访问，命中、装入和从 pending 放回缓存时都要调用，
+// 否则经常读的条目会像冷条目一样被淘汰。未准入的条目再次被访问时转为普通的条目。调用者需持有锁
+func (c *TypedCache[V]) touch(key int64) {
+	delete(c.transient, key)
+	c.policy.access(key)
+	if c.hotKeys != nil {
+		c.hotKeys.record(key)
+	}
+}
+
+// evictOne 按淘汰策略的顺序淘汰第一个没有引用并且释放成功的条目，没有淘汰任何条目时返回 false。
+// 释放失败的条目留在缓存中，没有淘汰成功时返回这些条目的 ReleaseErrors，调用者需持有锁
+func (c *TypedCache[V]) evictOne() (bool, error) {
+	var errs ReleaseErrors
+	evicted := false
+	c.policy.victims(func(key int64) bool {
+		if !c.evictable(key) {
+			return false
+		}
+		err := c.evict(key)
+		if err != nil {
+			errs = append(errs, err.(*ReleaseError))
+			return false
+		}
+		evicted = true
+		return true
+	})
+	if evicted {
+		return true, nil
+	}
+	return false, errs.orNil()
+}
+
+// evict 释放条目并把它移出缓存，释放失败时条目留在缓存中，调用者需持有锁
+func (c *TypedCache[V]) evict(key int64) error {
+	obj := c.cache[key]
+	err := c.release(key, obj)
+	if err != nil {
+		return err
+	}
+	c.policy.evicted(key)
+	c.removeBytes(key)
+	delete(c.versions, key)
+	delete(c.references, key)
+	delete(c.cache, key)
+	c.count--
+	c.evictions++
+	return nil
+}
+
+// release 释放一个要移出缓存的条目，开启空闲释放或软引用层时只放入待释放队列，调用者需持有锁
+func (c *TypedCache[V]) release(key int64, obj V) error {
+	if c.idleRelease > 0 || c.softTier {
+		c.pending[key] = obj
+		return nil
+	}
+	err := c.releaser(obj)
+	if err != nil {
+		return &ReleaseError{Key: key, Err: err}
+	}
+	delete(c.loadedAt, key)
+	if c.onEvict != nil {
+		c.evicted = append(c.evicted, evictedEntry[V]{key: key, value: obj})
+	}
+	return nil
+}
+
+// expired 判断无引用的 key 是否已经超过 ttl，调用者需持有锁
+func (c *TypedCache[V]) expired(key int64) bool {
+	if c.ttl <= 0 || !c.evictable(key) {
+		return false
+	}
+	return !c.clock().Before(c.loadedAt[key].Add(c.ttl))
+}
+
+// expire 立即释放过期的条目并把它移出缓存(或待释放队列)，释放失败时条目保持原样，调用者需持有锁
+func (c *TypedCache[V]) expire(key int64, obj V) error {
+	err := c.releaser(obj)
+	if err != nil {
+		return &ReleaseError{Key: key, Err: err}
+	}
+	if _, ok := c.pending[key]; ok {
+		delete(c.pending, key)
+	} else {
+		c.policy.remove(key)
+		c.removeBytes(key)
+		delete(c.versions, key)
+		delete(c.references, key)
+		delete(c.cache, key)
+		c.count--
+		c.freed.Broadcast()
+	}
+	delete(c.loadedAt, key)
+	if c.onEvict != nil {
+		c.evicted = append(c.evicted, evictedEntry[V]{key: key, value: obj})
+	}
+	return nil
+}
+
+// takeEvicted 取出等待通知的条目，调用者需持有锁
+func (c *TypedCache[V]) takeEvicted() []evictedEntry[V] {
+	evicted := c.evicted
+	c.evicted = nil
+	return evicted
+}
+
+// notifyEvicted 对每个条目调用 onEvict，调用者不能持有锁
+func (c *TypedCache[V]) notifyEvicted(evicted []evictedEntry[V]) {
+	for _, e := range evicted {
+		c.onEvict(e.key, e.value)
+	}
+}
+
+func (c *TypedCache[V]) beginAccess() {
+	c.lock.Lock()
+	c.inFlight++
+	c.lock.Unlock()
+}
+
+func (c *TypedCache[V]) endAccess() {
+	c.lock.Lock()
+	c.inFlight--
+	c.lastAccess = c.clock()
+	c.lock.Unlock()
+}
+
+// idleReleaseLoop 周期性地检查缓存是否空闲，空闲时释放待释放队列中的条目
+func (c *TypedCache[V]) idleReleaseLoop(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.idleRelease)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.releaseIdle()
+		}
+	}
+}
+
+// stopIdleRelease 停止空闲释放的后台协程，可以并发调用，只有一个调用者真正停止它
+func (c *TypedCache[V]) stopIdleRelease() {
+	c.lock.Lock()
+	stop, done := c.stopIdle, c.idleDone
+	c.stopIdle = nil
+	c.lock.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (c *TypedCache[V]) releaseIdle() {
+	c.releasePending(false)
+}
+
+// releasePending 释放待释放队列中的所有条目，force 为 false 时只在缓存空闲时释放。
+// 释放失败的条目放回队列，返回它们的 ReleaseErrors
+func (c *TypedCache[V]) releasePending(force bool) error {
+	c.lock.Lock()
+	if len(c.pending) == 0 || !force && (c.inFlight > 0 || c.clock().Sub(c.lastAccess) < c.idleRelease) {
+		c.lock.Unlock()
+		return nil
+	}
+	pending := c.pending
+	c.pending = make(map[int64]V)
+	// 释放期间标记为加载中，使同一个键的 Get 等待释放完成后再重新加载
+	for key := range pending {
+		c.getting[key] = true
+	}
+	c.lock.Unlock()
+
+	failed := make(map[int64]V)
+	var errs ReleaseErrors
+	for key, obj := range pending {
+		if err := c.releaser(obj); err != nil {
+			failed[key] = obj
+			errs = append(errs, &ReleaseError{Key: key, Err: err})
+			continue
+		}
+		if c.onEvict != nil {
+			c.onEvict(key, obj)
+		}
+	}
+
+	c.lock.Lock()
+	for key := range pending {
+		delete(c.getting, key)
+		if _, ok := failed[key]; ok {
+			// 释放失败的条目放回待释放队列，下次空闲时重试，Close 时返回错误
+			c.pending[key] = failed[key]
+			continue
+		}
+		delete(c.loadedAt, key)
+	}
+	c.loaded.Broadcast()
+	c.checkDrained()
+	c.lock.Unlock()
+	return errs.orNil()
+}
+
+// CloseWait 停止接受新的 Get，等待所有引用都被释放后再释放所有资源。
+// ctx 被取消时返回 ctx.Err()，此时缓存仍然拒绝新的 Get 但不会释放任何条目，调用者可以继续等待或者调用 Close 强制释放
+func (c *TypedCache[V]) CloseWait(ctx context.Context) error {
+	c.lock.Lock()
+	c.closing = true
+	if c.drained == nil {
+		c.drained = make(chan struct{})
+		c.checkDrained()
+	}
+	drained := c.drained
+	c.lock.Unlock()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	_, err := c.Close()
+	return err
+}
+
+// referenced 返回仍被引用或正在加载的条目数，调用者需持有锁
+func (c *TypedCache[V]) referenced() int {
+	n := len(c.getting)
+	for _, ref := range c.references {
+		if ref > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// checkDrained 在 CloseWait 等待期间所有引用都已释放时通知它，调用者需持有锁
+func (c *TypedCache[V]) checkDrained() {
+	if c.drained == nil || !c.closing {
+		return
+	}
+	select {
+	case <-c.drained:
+		return
+	default:
+	}
+	if c.referenced() == 0 {
+		close(c.drained)
+	}
+}
+
+// Close 不等待引用释放，立即释放所有资源，返回关闭时仍被引用的条目数以及释放失败的条目的 ReleaseErrors。
+// 仍持有引用的调用者之后不能再使用这些条目，正常关闭应使用 CloseWait。仍被引用的条目可以通过 Leaks 查看。
+// Close 不再接受新的 Get，但会等待进行中的加载和后台释放结束，加载到的值和其他条目一样被释放
+func (c *TypedCache[V]) Close() (int, error) {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return 0, ErrCacheClosed
+	}
+	c.closing = true
+	c.lock.Unlock()
+	c.stopIdleRelease()
+	c.stopSoftReclaim()
+
+	c.lock.Lock()
+	for len(c.getting) > 0 {
+		c.loaded.Wait()
+	}
+	// 并发的另一个 Close 已经完成
+	if c.closed {
+		c.lock.Unlock()
+		return 0, ErrCacheClosed
+	}
+	c.closed = true
+	referenced := c.referenced()
+	c.leaks = c.leakReport()
+	var evicted []evictedEntry[V]
+	var errs ReleaseErrors
+	releaseOne := func(key int64, obj V) {
+		if err := c.releaser(obj); err != nil {
+			errs = append(errs, &ReleaseError{Key: key, Err: err})
+			return
+		}
+		evicted = append(evicted, evictedEntry[V]{key: key, value: obj})
+	}
+	for key, obj := range c.pending {
+		releaseOne(key, obj)
+	}
+	for key, obj := range c.cache {
+		releaseOne(key, obj)
+	}
+	for key, values := range c.stale {
+		for _, obj := range values {
+			releaseOne(key, obj)
+		}
+	}
+	c.pending = make(map[int64]V)
+	c.cache = make(map[int64]V)
+	c.references = make(map[int64]int)
+	c.pinned = make(map[int64]bool)
+	if c.refStacks != nil {
+		c.refStacks = make(map[int64][]string)
+	}
+	c.count = 0
+	c.sizes = make(map[int64]int64)
+	c.bytes = 0
+	c.versions = make(map[int64]uint64)
+	c.owned = make(map[int64]map[int64]int)
+	c.negative = make(map[int64]tombstone)
+	c.transient = make(map[int64]bool)
+	c.stale = make(map[int64][]V)
+	c.invalid = make(map[int64]bool)
+	c.loaded.Broadcast()
+	c.freed.Broadcast()
+	c.policy.reset()
+	c.loadedAt = make(map[int64]time.Time)
+	evicted = append(c.takeEvicted(), evicted...)
+	c.lock.Unlock()
+
+	if c.onEvict != nil {
+		c.notifyEvicted(evicted)
+	}
+	return referenced, errs.orNil()
+}
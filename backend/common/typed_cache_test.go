@@ -0,0 +1,72 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+type testPage struct {
+	pgno  int64
+	data  []byte
+	dirty bool
+}
+
+func TestTypedCache(t *testing.T) {
+	loads := 0
+	var released []*testPage
+	c := NewTypedCache[*testPage](2,
+		func(key int64) (*testPage, error) {
+			loads++
+			return &testPage{pgno: key, data: make([]byte, 16)}, nil
+		},
+		func(p *testPage) error {
+			released = append(released, p)
+			return nil
+		})
+
+	// 不需要类型断言
+	page, err := c.Get(1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if page.pgno != 1 || len(page.data) != 16 {
+		t.Errorf("Unexpected page %+v", page)
+	}
+	page.dirty = true
+
+	again, _ := c.Get(1)
+	if again != page || loads != 1 {
+		t.Errorf("Expected the cached page to be returned")
+	}
+	if c.references[1] != 2 {
+		t.Errorf("Expected 2 references, got %d", c.references[1])
+	}
+	c.Release(1)
+	c.Release(1)
+
+	c.Get(2)
+	c.Get(3)
+	if len(released) != 1 || released[0] != page || !released[0].dirty {
+		t.Errorf("Expected page 1 to be evicted with its dirty flag, got %v", released)
+	}
+
+	c.Close()
+	if len(released) != 3 {
+		t.Errorf("Expected Close to release the remaining pages, got %d", len(released))
+	}
+	if c.count != 0 {
+		t.Errorf("Expected count 0 after Close, got %d", c.count)
+	}
+}
+
+func TestTypedCacheFull(t *testing.T) {
+	c := NewTypedCache[*testPage](1,
+		func(key int64) (*testPage, error) { return &testPage{pgno: key}, nil },
+		func(p *testPage) error { return nil })
+
+	c.Get(1)
+	page, err := c.Get(2)
+	if !errors.Is(err, CacheFullError) || page != nil {
+		t.Errorf("Expected (nil, CacheFullError), got (%v, %v)", page, err)
+	}
+}
@@ -0,0 +1,48 @@
+package common
+
+import "fmt"
+
+// 版本号: 条目每次装入缓存或者被 CompareAndSwap 修改时获得一个新的版本号。
+// 版本号来自整个缓存共用的递增计数器，条目被淘汰后重新装入也不会得到以前用过的版本号
+
+// stamp 给刚放入缓存的 key 分配新的版本号，调用者需持有锁
+func (c *TypedCache[V]) stamp(key int64) uint64 {
+	c.nextVersion++
+	c.versions[key] = c.nextVersion
+	return c.nextVersion
+}
+
+// GetVersioned 与 Get 相同，同时返回条目当前的版本号，之后可以用 CompareAndSwap 乐观地修改它。
+// 成功时与 Get 一样需要调用 Release
+func (c *TypedCache[V]) GetVersioned(key int64) (V, uint64, error) {
+	obj, err := c.Get(key)
+	if err != nil {
+		return obj, 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.cache[key], c.versions[key], nil
+}
+
+// CompareAndSwap 在 key 的版本号仍为 expected 时把它的值替换为 value 并分配新的版本号，返回是否替换成功。
+// 替换在原地进行，不影响引用计数，其他持有引用的调用者之后的 GetVersioned 会看到新值。
+// 被替换的旧值不会被释放；新值在条目被淘汰时像其他条目一样释放。key 不在缓存中时返回 ErrKeyNotCached
+func (c *TypedCache[V]) CompareAndSwap(key int64, expected uint64, value V) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return false, ErrCacheClosed
+	}
+	if _, ok := c.cache[key]; !ok {
+		return false, fmt.Errorf("%w: %d", ErrKeyNotCached, key)
+	}
+	if c.versions[key] != expected {
+		return false, nil
+	}
+	c.cache[key] = value
+	c.removeBytes(key)
+	c.addBytes(key, value)
+	c.stamp(key)
+	return true, nil
+}
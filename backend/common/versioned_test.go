@@ -0,0 +1,139 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func newCounterCache(releases *[]int) *TypedCache[int] {
+	return NewTypedCache[int](0, func(key int64) (int, error) {
+		return 0, nil
+	}, func(value int) error {
+		*releases = append(*releases, value)
+		return nil
+	})
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	var releases []int
+	c := newCounterCache(&releases)
+
+	value, version, err := c.GetVersioned(1)
+	if err != nil {
+		t.Fatalf("GetVersioned failed: %v", err)
+	}
+	if value != 0 {
+		t.Fatalf("Expected 0, got %d", value)
+	}
+
+	ok, err := c.CompareAndSwap(1, version, 5)
+	if err != nil || !ok {
+		t.Fatalf("Expected CompareAndSwap to succeed, got %v, %v", ok, err)
+	}
+	// 旧版本号已经失效
+	ok, err = c.CompareAndSwap(1, version, 7)
+	if err != nil || ok {
+		t.Fatalf("Expected stale CompareAndSwap to fail, got %v, %v", ok, err)
+	}
+
+	value, newVersion, err := c.GetVersioned(1)
+	if err != nil {
+		t.Fatalf("GetVersioned failed: %v", err)
+	}
+	if value != 5 || newVersion <= version {
+		t.Errorf("Expected 5 with a newer version than %d, got %d at %d", version, value, newVersion)
+	}
+
+	// 替换不影响引用计数，也不释放条目
+	if refs := c.references[1]; refs != 2 {
+		t.Errorf("Expected 2 references, got %d", refs)
+	}
+	if len(releases) != 0 {
+		t.Errorf("Expected no releases, got %v", releases)
+	}
+	c.Release(1)
+	c.Release(1)
+	c.Close()
+	if len(releases) != 1 || releases[0] != 5 {
+		t.Errorf("Expected the swapped value to be released, got %v", releases)
+	}
+}
+
+func TestCompareAndSwapNotCached(t *testing.T) {
+	var releases []int
+	c := newCounterCache(&releases)
+	defer c.Close()
+
+	_, err := c.CompareAndSwap(1, 0, 5)
+	if !errors.Is(err, ErrKeyNotCached) {
+		t.Errorf("Expected ErrKeyNotCached, got %v", err)
+	}
+}
+
+func TestCompareAndSwapReloadedVersion(t *testing.T) {
+	var releases []int
+	c := NewTypedCache[int](1, func(key int64) (int, error) {
+		return 0, nil
+	}, func(value int) error {
+		releases = append(releases, value)
+		return nil
+	})
+	defer c.Close()
+
+	_, version, _ := c.GetVersioned(1)
+	c.Release(1)
+	// 装入 2 淘汰 1，1 重新装入后得到新的版本号，之前的版本号不能再用
+	c.Get(2)
+	c.Release(2)
+	c.Get(1)
+	defer c.Release(1)
+
+	ok, err := c.CompareAndSwap(1, version, 5)
+	if err != nil || ok {
+		t.Errorf("Expected CompareAndSwap with a version from before eviction to fail, got %v, %v", ok, err)
+	}
+}
+
+func TestCompareAndSwapContention(t *testing.T) {
+	var releases []int
+	c := newCounterCache(&releases)
+	defer c.Close()
+
+	const workers, increments = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				for {
+					value, version, err := c.GetVersioned(1)
+					if err != nil {
+						t.Errorf("GetVersioned failed: %v", err)
+						return
+					}
+					ok, err := c.CompareAndSwap(1, version, value+1)
+					c.Release(1)
+					if err != nil {
+						t.Errorf("CompareAndSwap failed: %v", err)
+						return
+					}
+					if ok {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := c.Get(1)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	c.Release(1)
+	if value != workers*increments {
+		t.Errorf("Expected %d, got %d", workers*increments, value)
+	}
+}
@@ -0,0 +1,92 @@
+package common
+
+import "sync"
+
+// warmWorkers 是 Warm 同时加载的最大条目数
+const warmWorkers = 8
+
+// Warm 并发地加载 keys 并立即释放引用，使它们以无引用的状态留在缓存中，用于启动时预热。
+// 已经在缓存中的键和重复的键不会再加载。缓存有容量限制时只加载能放进剩余空间的键，
+// 其余的键被跳过，预热不会淘汰已有的条目，也不会淘汰先加载的预热条目。
+// 某个键加载失败时仍然加载其余的键，返回第一个错误
+func (c *TypedCache[V]) Warm(keys []int64) error {
+	c.lock.Lock()
+	seen := make(map[int64]bool, len(keys))
+	var todo []int64
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if _, ok := c.cache[key]; ok || c.getting[key] {
+			continue
+		}
+		todo = append(todo, key)
+	}
+	if c.maxResource > 0 {
+		room := c.maxResource - c.count
+		if room < 0 {
+			room = 0
+		}
+		if len(todo) > room {
+			todo = todo[:room]
+		}
+	}
+	c.lock.Unlock()
+
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var firstErr error
+	next := make(chan int64)
+	workers := warmWorkers
+	if len(todo) < workers {
+		workers = len(todo)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range next {
+				_, err := c.Get(key)
+				if err == nil {
+					err = c.Release(key)
+				}
+				if err != nil {
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLock.Unlock()
+				}
+			}
+		}()
+	}
+	for _, key := range todo {
+		next <- key
+	}
+	close(next)
+	wg.Wait()
+	return firstErr
+}
+
+// Warm 把 keys 按分片分组，依次预热每个分片
+func (sc *ShardedCache) Warm(keys []int64) error {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	groups := make(map[*AbstractCache][]int64)
+	for _, key := range keys {
+		shard := sc.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	var firstErr error
+	for _, shard := range sc.shards {
+		if len(groups[shard]) == 0 {
+			continue
+		}
+		err := shard.Warm(groups[shard])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
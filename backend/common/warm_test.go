@@ -0,0 +1,87 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWarm(t *testing.T) {
+	ac := NewAbstractCache(0)
+	tc := newTestCache()
+	ac.Cache = tc
+
+	ac.Get(3)
+	ac.Release(3)
+
+	keys := []int64{1, 2, 3, 4, 5, 2, 6, 7, 8, 9, 10}
+	if err := ac.Warm(keys); err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+	for key := int64(1); key <= 10; key++ {
+		if loads := tc.loadCount(key); loads != 1 {
+			t.Errorf("Expected key %d to be loaded once, got %d", key, loads)
+		}
+		if refs, ok := ac.references[key]; !ok || refs != 0 {
+			t.Errorf("Expected key %d to be cached without references, got %d, %v", key, refs, ok)
+		}
+	}
+
+	// 预热之后的访问都命中
+	before := ac.Stats()
+	for key := int64(1); key <= 10; key++ {
+		ac.Get(key)
+		ac.Release(key)
+	}
+	if stats := ac.Stats(); stats.Misses != before.Misses {
+		t.Errorf("Expected no misses after warming, got %d", stats.Misses-before.Misses)
+	}
+	if n, _ := ac.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
+
+func TestWarmRespectsCapacity(t *testing.T) {
+	ac := NewAbstractCache(4)
+	tc := newTestCache()
+	ac.Cache = tc
+
+	ac.Get(100)
+	ac.Release(100)
+
+	// 只有 3 个空位，预热不淘汰已有的条目
+	if err := ac.Warm([]int64{1, 2, 3, 4, 5}); err != nil {
+		t.Fatalf("Warm failed: %v", err)
+	}
+	if stats := ac.Stats(); stats.Count != 4 || stats.Evictions != 0 {
+		t.Errorf("Expected 4 entries and no evictions, got %+v", stats)
+	}
+	if _, ok := ac.cache[100]; !ok {
+		t.Errorf("Expected key 100 to stay cached")
+	}
+	loaded := 0
+	for key := int64(1); key <= 5; key++ {
+		loaded += tc.loadCount(key)
+	}
+	if loaded != 3 {
+		t.Errorf("Expected 3 keys to be warmed, got %d", loaded)
+	}
+	ac.Close()
+}
+
+func TestWarmLoadError(t *testing.T) {
+	ac := NewAbstractCache(0)
+	ac.Cache = &failKeyCache{testCache: newTestCache(), failKey: 2}
+
+	err := ac.Warm([]int64{1, 2, 3})
+	if !errors.Is(err, errLoadFailed) {
+		t.Errorf("Expected errLoadFailed, got %v", err)
+	}
+	for _, key := range []int64{1, 3} {
+		if _, ok := ac.cache[key]; !ok {
+			t.Errorf("Expected key %d to be warmed despite the failure", key)
+		}
+	}
+	if n, _ := ac.Close(); n != 0 {
+		t.Errorf("Expected no referenced entries, got %d", n)
+	}
+}
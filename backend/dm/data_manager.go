@@ -0,0 +1,405 @@
+package dm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"mydb-go/backend/common"
+	"mydb-go/backend/dm/logger"
+	"mydb-go/backend/tm"
+)
+
+// 数据文件中每个页的格式:
+//
+//	[fso: 2 字节][record][record]...
+//
+// fso 是页内空闲空间的起始偏移，每条记录的格式为 [size: 2 字节][data: size 字节]。
+// Insert 返回的 offset 是记录在数据文件中的全局偏移，即 pgno*PageSize + 页内偏移。
+//
+// 修改数据页之前先写日志: Insert 记录 redo，Update 同时记录旧值和新值。
+// 事务取消时 DataManager 作为事务管理器的 Observer 把这个事务的更新按相反的顺序写回旧值，
+// 并把它插入的记录占用的空间交给 PageIndex，之后的插入优先复用这些空间，撤销同样写日志。
+// 数据页不在提交时写回，打开时由 Recover 根据日志把数据文件恢复到一致的状态
+const (
+	DbSuffix = ".db"
+	// DefaultCachePages 是页面缓存默认缓存的页数
+	DefaultCachePages = 64
+
+	lenPageFSO       = 2
+	lenRecordSize    = 2
+	maxRecordDataLen = common.PageSize - lenPageFSO - lenRecordSize
+)
+
+var (
+	// ErrTransactionNotActive 表示操作使用的事务不处于活跃状态
+	ErrTransactionNotActive = errors.New("transaction is not active")
+	// ErrDataTooLarge 表示插入的数据放不进一个页
+	ErrDataTooLarge = errors.New("data is too large to fit in a page")
+	// ErrBadOffset 表示 offset 处没有记录
+	ErrBadOffset = errors.New("no record at offset")
+	// ErrSizeMismatch 表示更新的数据与原记录长度不同
+	ErrSizeMismatch = errors.New("update must keep the record size")
+)
+
+// DataManager 定义了一个数据管理器接口，它把事务管理器和页面缓存组合在一起
+type DataManager interface {
+	Read(xid int64, offset int64) ([]byte, error)      // 读取 offset 处的记录
+	Insert(xid int64, data []byte) (int64, error)      // 插入一条记录，返回它的 offset
+	Update(xid int64, offset int64, data []byte) error // 更新 offset 处的记录，事务取消时回滚
+	Savepoint(xid int64) (SavepointID, error)          // 返回 xid 当前的保存点
+	RollbackTo(xid int64, sp SavepointID) error        // 撤销 xid 在保存点之后的修改
+	TransactionManager() tm.TransactionManager         // 返回使用的事务管理器
+	Close() error                                      // 关闭DM
+}
+
+// observableTM 是 DataManagerImpl 使用的事务管理器，事务取消时通过 Observer 回滚
+type observableTM interface {
+	tm.TransactionManager
+	SetObserver(tm.Observer)
+}
+
+// DataManagerImpl 结构体实现了 DataManager 接口
+type DataManagerImpl struct {
+	tm observableTM
+	pc *common.PageCache
+	// lg 为 nil 时不写日志，只有内存中的 DataManagerImpl 是这样
+	lg *logger.Logger
+
+	// undo 保存每个活跃事务的更新之前的旧值，事务结束时清除
+	undo *undoBuffer
+
+	// pageIndex 保存被取消的插入释放的空间，插入时优先复用
+	pageIndex *PageIndex
+
+	// insertLock 保护 insertPage，没有可复用的空间时插入写到最后一个页，放不下时再分配新页
+	insertLock sync.Mutex
+	insertPage int64
+}
+
+// Create 创建一个新的 DataManagerImpl，同时创建 .xid、.db 和 .log 文件
+func Create(path string) (*DataManagerImpl, error) {
+	t, err := tm.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path + DbSuffix)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	lg, err := logger.Create(path)
+	if err != nil {
+		file.Close()
+		t.Close()
+		return nil, err
+	}
+	return newDataManager(path, t, file, lg, tm.RecoveryReport{})
+}
+
+// Open 打开一个已存在的 DataManagerImpl，并调用 Recover 撤销崩溃时没有结束的事务
+func Open(path string) (*DataManagerImpl, error) {
+	t, report, err := tm.OpenWithRecoveryReport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path+DbSuffix, os.O_RDWR, 0666)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	lg, err := logger.Open(path)
+	if err != nil {
+		file.Close()
+		t.Close()
+		return nil, err
+	}
+	return newDataManager(path, t, file, lg, report)
+}
+
+// NewMemoryDataManager 创建一个完全在内存中的 DataManagerImpl，不创建任何文件，关闭后数据全部丢失。
+// 它使用 tm.MemoryTransactionManager 和 common.NewMemPageCache，不写日志，undo 记录不溢出到文件
+func NewMemoryDataManager() *DataManagerImpl {
+	t := tm.NewMemoryTransactionManager()
+	dm := &DataManagerImpl{
+		tm:         t,
+		pc:         common.NewMemPageCache(DefaultCachePages),
+		undo:       newUndoBuffer(nil, DefaultUndoBudget),
+		pageIndex:  NewPageIndex(),
+		insertPage: -1,
+	}
+	t.SetObserver(dm)
+	return dm
+}
+
+// newDataManager 组装 DataManagerImpl，根据日志恢复数据文件并取消 report 中没有结束的事务，
+// 再把它注册为 t 的 Observer 以便在事务取消时回滚
+func newDataManager(path string, t *tm.TransactionManagerImpl, file *os.File, lg *logger.Logger, report tm.RecoveryReport) (*DataManagerImpl, error) {
+	pc, err := common.NewPageCache(file, DefaultCachePages)
+	if err != nil {
+		lg.Close()
+		file.Close()
+		t.Close()
+		return nil, err
+	}
+	undoFile, err := os.Create(path + UndoSuffix)
+	if err != nil {
+		pc.Close()
+		lg.Close()
+		t.Close()
+		return nil, err
+	}
+
+	dm := &DataManagerImpl{
+		tm:         t,
+		pc:         pc,
+		lg:         lg,
+		undo:       newUndoBuffer(undoFile, DefaultUndoBudget),
+		pageIndex:  NewPageIndex(),
+		insertPage: pc.PageCount() - 1,
+	}
+	err = dm.Recover(report)
+	if err != nil {
+		dm.Close()
+		return nil, err
+	}
+	t.SetObserver(dm)
+	return dm, nil
+}
+
+// TransactionManager 返回使用的事务管理器，用来开启和结束事务
+func (dm *DataManagerImpl) TransactionManager() tm.TransactionManager {
+	return dm.tm
+}
+
+// checkActive 检查 xid 是否处于活跃状态
+func (dm *DataManagerImpl) checkActive(xid int64) error {
+	active, err := dm.tm.IsActive(xid)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("%w: %d", ErrTransactionNotActive, xid)
+	}
+	return nil
+}
+
+// Insert 在 xid 中插入一条记录，返回它在数据文件中的 offset
+func (dm *DataManagerImpl) Insert(xid int64, data []byte) (int64, error) {
+	if len(data) > maxRecordDataLen {
+		return 0, fmt.Errorf("%w: %d bytes", ErrDataTooLarge, len(data))
+	}
+	err := dm.checkActive(xid)
+	if err != nil {
+		return 0, err
+	}
+
+	// 优先复用被取消的插入释放的空间
+	if pgno, off, ok := dm.pageIndex.Select(lenRecordSize + len(data)); ok {
+		return dm.insertAt(xid, pgno, off, data)
+	}
+
+	dm.insertLock.Lock()
+	defer dm.insertLock.Unlock()
+
+	if dm.insertPage >= 0 {
+		offset, ok, err := dm.insertInto(xid, dm.insertPage, data)
+		if err != nil || ok {
+			return offset, err
+		}
+	}
+
+	// 最后一个页放不下，分配一个新页
+	pgno, err := dm.pc.NewPage(emptyPage())
+	if err != nil {
+		return 0, err
+	}
+	dm.insertPage = pgno
+
+	offset, _, err := dm.insertInto(xid, pgno, data)
+	return offset, err
+}
+
+// emptyPage 返回新页的初始内容，页中还没有记录
+func emptyPage() []byte {
+	init := make([]byte, lenPageFSO)
+	binary.BigEndian.PutUint16(init, lenPageFSO)
+	return init
+}
+
+// insertInto 尝试把记录写入 pgno，页内空间不足时 ok 为 false
+func (dm *DataManagerImpl) insertInto(xid int64, pgno int64, data []byte) (offset int64, ok bool, err error) {
+	page, err := dm.pc.GetPage(pgno)
+	if err != nil {
+		return 0, false, err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	buf := page.Data()
+	fso := int(binary.BigEndian.Uint16(buf))
+	if fso+lenRecordSize+len(data) > common.PageSize {
+		page.Unlock()
+		return 0, false, nil
+	}
+	offset = pgno*common.PageSize + int64(fso)
+	err = dm.appendLog(xid, encodeInsertLog(offset, data))
+	if err != nil {
+		page.Unlock()
+		return 0, false, err
+	}
+	binary.BigEndian.PutUint16(buf[fso:], uint16(len(data)))
+	copy(buf[fso+lenRecordSize:], data)
+	binary.BigEndian.PutUint16(buf, uint16(fso+lenRecordSize+len(data)))
+	page.Unlock()
+	page.SetDirty(true)
+
+	return offset, true, dm.undo.push(xid, undoEntry{offset: offset, insert: true})
+}
+
+// insertAt 把记录写入 PageIndex 选出的页 pgno 中页内偏移 off 处的空闲空间。
+// 那里是一条被取消的插入留下的记录，新记录不比它长。整块空间的大小记在 undo 记录中，插入被取消时整块交还
+func (dm *DataManagerImpl) insertAt(xid int64, pgno int64, off int, data []byte) (int64, error) {
+	page, err := dm.pc.GetPage(pgno)
+	if err != nil {
+		return 0, err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	offset := pgno*common.PageSize + int64(off)
+	page.Lock()
+	_, size, err := locateRecord(page.Data(), offset)
+	if err == nil {
+		err = dm.appendLog(xid, encodeInsertLog(offset, data))
+		if err != nil {
+			// 没有写入，空间放回去留给之后的插入
+			dm.pageIndex.Add(pgno, off, lenRecordSize+size)
+		}
+	}
+	if err != nil {
+		page.Unlock()
+		return 0, err
+	}
+	buf := page.Data()
+	binary.BigEndian.PutUint16(buf[off:], uint16(len(data)))
+	copy(buf[off+lenRecordSize:], data)
+	page.Unlock()
+	page.SetDirty(true)
+
+	return offset, dm.undo.push(xid, undoEntry{offset: offset, insert: true, space: lenRecordSize + size})
+}
+
+// Read 在 xid 中读取 offset 处的记录
+func (dm *DataManagerImpl) Read(xid int64, offset int64) ([]byte, error) {
+	err := dm.checkActive(xid)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrBadOffset, offset)
+	}
+
+	page, err := dm.pc.GetPage(offset / common.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	defer page.Unlock()
+	off, size, err := locateRecord(page.Data(), offset)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	copy(data, page.Data()[off+lenRecordSize:])
+	return data, nil
+}
+
+// Update 在 xid 中把 offset 处的记录改为 data，data 的长度必须与原记录相同。
+// 修改之前先把旧值和新值写入日志，xid 取消时旧值会被写回
+func (dm *DataManagerImpl) Update(xid int64, offset int64, data []byte) error {
+	err := dm.checkActive(xid)
+	if err != nil {
+		return err
+	}
+	if offset < 0 {
+		return fmt.Errorf("%w: %d", ErrBadOffset, offset)
+	}
+
+	page, err := dm.pc.GetPage(offset / common.PageSize)
+	if err != nil {
+		return err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	off, size, err := locateRecord(page.Data(), offset)
+	if err == nil && size != len(data) {
+		err = fmt.Errorf("%w: record at %d has %d bytes, got %d", ErrSizeMismatch, offset, size, len(data))
+	}
+	if err != nil {
+		page.Unlock()
+		return err
+	}
+
+	record := page.Data()[off+lenRecordSize : off+lenRecordSize+size]
+	old := make([]byte, size)
+	copy(old, record)
+	// 先记 undo 再写日志: 写日志失败时多出的 undo 记录写回的就是当前的值，不会造成影响
+	err = dm.undo.push(xid, undoEntry{offset: offset, old: old})
+	if err == nil {
+		err = dm.appendLog(xid, encodeUpdateLog(offset, old, data))
+	}
+	if err != nil {
+		page.Unlock()
+		return err
+	}
+	copy(record, data)
+	page.Unlock()
+	page.SetDirty(true)
+	return nil
+}
+
+// appendLog 把 xid 的一条记录写入日志，没有日志时什么也不做
+func (dm *DataManagerImpl) appendLog(xid int64, data []byte) error {
+	if dm.lg == nil {
+		return nil
+	}
+	_, err := dm.lg.Append(xid, data)
+	return err
+}
+
+// locateRecord 返回 offset 处的记录在页 buf 中的偏移和数据长度
+func locateRecord(buf []byte, offset int64) (off int, size int, err error) {
+	fso := int(binary.BigEndian.Uint16(buf))
+	off = int(offset % common.PageSize)
+	if off < lenPageFSO || off+lenRecordSize > fso {
+		return 0, 0, fmt.Errorf("%w: %d", ErrBadOffset, offset)
+	}
+	size = int(binary.BigEndian.Uint16(buf[off:]))
+	if off+lenRecordSize+size > fso {
+		return 0, 0, fmt.Errorf("%w: %d", ErrBadOffset, offset)
+	}
+	return off, size, nil
+}
+
+// Close 写回所有脏页并关闭数据文件、日志文件和 XID 文件，删除 undo 溢出文件
+func (dm *DataManagerImpl) Close() error {
+	errs := []error{dm.pc.Close(), dm.undo.close()}
+	if dm.lg != nil {
+		errs = append(errs, dm.lg.Close())
+	}
+	errs = append(errs, dm.tm.Close())
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
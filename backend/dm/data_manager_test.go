@@ -0,0 +1,149 @@
+package dm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"mydb-go/backend/common"
+	"mydb-go/backend/dm/logger"
+	"mydb-go/backend/tm"
+)
+
+func removeFiles(path string) {
+	os.Remove(path + tm.XidSuffix)
+	os.Remove(path + DbSuffix)
+	os.Remove(path + logger.LogSuffix)
+	os.Remove(path + UndoSuffix)
+}
+
+func TestInsertAndRead(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid, err := dm.TransactionManager().Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	inputs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{'x'}, 5000), bytes.Repeat([]byte{'y'}, 5000)}
+	var offsets []int64
+	for _, data := range inputs {
+		offset, err := dm.Insert(xid, data)
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		offsets = append(offsets, offset)
+	}
+	// 第 4 条放不进第一个页
+	if offsets[2]/common.PageSize != 0 || offsets[3]/common.PageSize != 1 {
+		t.Errorf("Expected the last record on a new page, got offsets %v", offsets)
+	}
+
+	for i, offset := range offsets {
+		data, err := dm.Read(xid, offset)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !bytes.Equal(data, inputs[i]) {
+			t.Errorf("Unexpected data at offset %d", offset)
+		}
+	}
+	dm.TransactionManager().Commit(xid)
+	if err := dm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 重新打开后数据仍然存在，新记录追加在最后一个页
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dm.Close()
+	xid, _ = dm.TransactionManager().Begin()
+	data, err := dm.Read(xid, offsets[0])
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello after reopen, got %q, %v", data, err)
+	}
+	offset, err := dm.Insert(xid, []byte("again"))
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if offset/common.PageSize != 1 || offset <= offsets[3] {
+		t.Errorf("Expected the record after offset %d, got %d", offsets[3], offset)
+	}
+}
+
+func TestUpdateCommitSurvivesReopen(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid, _ := dm.TransactionManager().Begin()
+	offset, _ := dm.Insert(xid, []byte("old value"))
+	if err := dm.Update(xid, offset, []byte("new value")); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	dm.TransactionManager().Commit(xid)
+	if err := dm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dm.Close()
+	xid, _ = dm.TransactionManager().Begin()
+	data, err := dm.Read(xid, offset)
+	if err != nil || string(data) != "new value" {
+		t.Errorf("Expected new value after reopen, got %q, %v", data, err)
+	}
+}
+
+func TestUpdateWritesLog(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer dm.Close()
+
+	xid, _ := dm.TransactionManager().Begin()
+	offset, _ := dm.Insert(xid, []byte("aaa"))
+	dm.Update(xid, offset, []byte("bbb"))
+
+	var records []logger.Record
+	it := dm.lg.Iterator()
+	for {
+		rec, err := it.Next()
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 log records, got %d", len(records))
+	}
+	if !bytes.Equal(records[0].Data, encodeInsertLog(offset, []byte("aaa"))) {
+		t.Errorf("Unexpected insert record %v", records[0].Data)
+	}
+	if !bytes.Equal(records[1].Data, encodeUpdateLog(offset, []byte("aaa"), []byte("bbb"))) {
+		t.Errorf("Unexpected update record %v", records[1].Data)
+	}
+	for _, rec := range records {
+		if rec.Xid != xid {
+			t.Errorf("Expected log records for xid %d, got %d", xid, rec.Xid)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 日志文件由连续的记录组成，每条记录的格式为:
+//
+//	[size: 4 字节][checksum: 4 字节][xid: 8 字节][data: size 字节]
+//
+// checksum 是对 xid 和 data 计算的 CRC32，LSN 是记录在整个日志中的起始偏移。
+// 崩溃可能在文件末尾留下写了一半的记录，读取时遇到第一条校验失败的记录就停止。
+//
+// 默认所有记录写在一个 path.log 文件中。使用 WithSegmentSize 时日志分成多个段文件，
+// 每个段以它第一个字节的 LSN 命名，为 path.<20 位 LSN>.log，当前的段写满后追加到新的段中
+const (
+	LogSuffix       = ".log"
+	recordHeaderLen = 16
+	offSize         = 0
+	offChecksum     = 4
+	offXid          = 8
+
+	// segmentLSNDigits 是段文件名中 LSN 的位数
+	segmentLSNDigits = 20
+)
+
+var (
+	// ErrBadRecord 表示日志记录不完整或校验和不匹配
+	ErrBadRecord = errors.New("bad log record")
+	// ErrMissingSegment 表示段文件之间不连续，中间的段丢失了
+	ErrMissingSegment = errors.New("log segments are not contiguous")
+)
+
+// Record 是一条日志记录
+type Record struct {
+	LSN  int64
+	Xid  int64
+	Data []byte
+}
+
+// segment 是日志中 LSN 在 [start, end) 之间的部分，保存在 file 中，文件内的偏移为 LSN - start
+type segment struct {
+	start int64
+	end   int64
+	file  *os.File
+	path  string
+}
+
+// Logger 是按 XID 记录日志的预写日志
+type Logger struct {
+	path        string
+	segmentSize int64
+
+	lock sync.Mutex
+	// segments 按 LSN 排列，只向最后一个段追加
+	segments []*segment
+}
+
+// Option 用于在创建或打开日志时配置 Logger
+type Option func(*Logger)
+
+// WithSegmentSize 把日志分成多个段文件，当前的段追加下一条记录会超过 size 字节时换到新的段。
+// 一条记录不会跨越两个段，比 size 大的记录单独占一个段
+func WithSegmentSize(size int64) Option {
+	return func(l *Logger) {
+		l.segmentSize = size
+	}
+}
+
+func newLogger(path string, opts []Option) *Logger {
+	l := &Logger{path: path}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// segmentPath 返回从 start 开始的段的文件名
+func segmentPath(path string, start int64) string {
+	return fmt.Sprintf("%s.%0*d%s", path, segmentLSNDigits, start, LogSuffix)
+}
+
+// listSegments 返回 path 已有的段文件和它们的起始 LSN，按 LSN 排序
+func listSegments(path string) ([]int64, []string, error) {
+	names, err := filepath.Glob(path + ".*" + LogSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	var starts []int64
+	found := make(map[int64]string)
+	for _, name := range names {
+		lsn := strings.TrimSuffix(strings.TrimPrefix(name, path+"."), LogSuffix)
+		if len(lsn) != segmentLSNDigits {
+			continue
+		}
+		start, err := strconv.ParseInt(lsn, 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start)
+		found[start] = name
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	paths := make([]string, len(starts))
+	for i, start := range starts {
+		paths[i] = found[start]
+	}
+	return starts, paths, nil
+}
+
+// Create 创建一个新的日志，分段时同时删除 path 之前留下的段文件
+func Create(path string, opts ...Option) (*Logger, error) {
+	l := newLogger(path, opts)
+	name := path + LogSuffix
+	if l.segmentSize > 0 {
+		_, old, err := listSegments(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range old {
+			err = os.Remove(p)
+			if err != nil {
+				return nil, err
+			}
+		}
+		name = segmentPath(path, 0)
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	l.segments = []*segment{{file: file, path: name}}
+	return l, nil
+}
+
+// Open 打开一个已存在的日志，并截掉末尾不完整的记录。分段时必须使用 WithSegmentSize 打开
+func Open(path string, opts ...Option) (*Logger, error) {
+	l := newLogger(path, opts)
+	starts, names := []int64{0}, []string{path + LogSuffix}
+	if l.segmentSize > 0 {
+		var err error
+		starts, names, err = listSegments(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("open %s: no log segments: %w", path, os.ErrNotExist)
+		}
+	}
+
+	for i, name := range names {
+		file, err := os.OpenFile(name, os.O_RDWR, 0666)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		seg := &segment{start: starts[i], end: starts[i], file: file, path: name}
+		l.segments = append(l.segments, seg)
+
+		if i == len(names)-1 {
+			err = seg.truncateBadTail()
+		} else {
+			err = seg.readSize()
+			if err == nil && seg.end != starts[i+1] {
+				err = fmt.Errorf("%w: %s ends at %d, next segment starts at %d", ErrMissingSegment, name, seg.end, starts[i+1])
+			}
+		}
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// readSize 根据文件长度设置 end，用于不再追加的段
+func (s *segment) readSize() error {
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	s.end = s.start + info.Size()
+	return nil
+}
+
+// truncateBadTail 找到最后一条完整的记录，截掉它之后的内容
+func (s *segment) truncateBadTail() error {
+	fileLen, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	for offset < fileLen {
+		rec, err := s.readRecord(offset, fileLen)
+		if errors.Is(err, ErrBadRecord) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		offset += recordHeaderLen + int64(len(rec.Data))
+	}
+
+	if offset < fileLen {
+		err = s.file.Truncate(offset)
+		if err != nil {
+			return err
+		}
+	}
+	s.end = s.start + offset
+	return nil
+}
+
+// active 返回正在追加的段，调用者需要持有 lock
+func (l *Logger) active() *segment {
+	return l.segments[len(l.segments)-1]
+}
+
+// rotate 在当前段之后创建一个新段，调用者需要持有 lock
+func (l *Logger) rotate() error {
+	start := l.active().end
+	name := segmentPath(l.path, start)
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	l.segments = append(l.segments, &segment{start: start, end: start, file: file, path: name})
+	return nil
+}
+
+// Append 为 xid 追加一条日志并刷盘，返回这条记录的 LSN
+func (l *Logger) Append(xid int64, data []byte) (int64, error) {
+	buf := make([]byte, recordHeaderLen+len(data))
+	binary.BigEndian.PutUint32(buf[offSize:], uint32(len(data)))
+	binary.BigEndian.PutUint64(buf[offXid:], uint64(xid))
+	copy(buf[recordHeaderLen:], data)
+	binary.BigEndian.PutUint32(buf[offChecksum:], crc32.ChecksumIEEE(buf[offXid:]))
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	seg := l.active()
+	if l.segmentSize > 0 && seg.end > seg.start && seg.end-seg.start+int64(len(buf)) > l.segmentSize {
+		err := l.rotate()
+		if err != nil {
+			return 0, err
+		}
+		seg = l.active()
+	}
+
+	lsn := seg.end
+	_, err := seg.file.WriteAt(buf, lsn-seg.start)
+	if err != nil {
+		return 0, err
+	}
+	err = seg.file.Sync()
+	if err != nil {
+		return 0, err
+	}
+	seg.end += int64(len(buf))
+	return lsn, nil
+}
+
+// readRecord 读出段内偏移 offset 处的记录，fileLen 是段中可以读取的长度。
+// 记录不完整或校验失败时返回 ErrBadRecord
+func (s *segment) readRecord(offset, fileLen int64) (Record, error) {
+	if offset+recordHeaderLen > fileLen {
+		return Record{}, ErrBadRecord
+	}
+	header := make([]byte, recordHeaderLen)
+	_, err := s.file.ReadAt(header, offset)
+	if err != nil {
+		return Record{}, err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[offSize:]))
+	if offset+recordHeaderLen+size > fileLen {
+		return Record{}, ErrBadRecord
+	}
+	body := make([]byte, 8+size)
+	_, err = s.file.ReadAt(body, offset+offXid)
+	if err != nil {
+		return Record{}, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[offChecksum:]) {
+		return Record{}, ErrBadRecord
+	}
+
+	return Record{
+		LSN:  s.start + offset,
+		Xid:  int64(binary.BigEndian.Uint64(body[:8])),
+		Data: body[8:],
+	}, nil
+}
+
+// RetentionPolicy 判断 xid 的日志记录是否已经不再需要，
+// 例如 xid 已经提交，并且它修改的页已经写回数据文件
+type RetentionPolicy func(xid int64) (bool, error)
+
+// Retain 从最早的段开始删除所有记录都满足 policy 的段，遇到第一个不能删除的段就停止，
+// 剩下的日志仍然是连续的。正在追加的段不会被删除。返回删除的段数。
+// 删除段时不能有正在使用的 Iterator
+func (l *Logger) Retain(policy RetentionPolicy) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	removed := 0
+	for len(l.segments) > 1 {
+		seg := l.segments[0]
+		ok, err := seg.retainable(policy)
+		if err != nil || !ok {
+			return removed, err
+		}
+		err = seg.file.Close()
+		if err != nil {
+			return removed, err
+		}
+		err = os.Remove(seg.path)
+		if err != nil {
+			return removed, err
+		}
+		l.segments = l.segments[1:]
+		removed++
+	}
+	return removed, nil
+}
+
+// retainable 判断段中的每一条记录是否都满足 policy
+func (s *segment) retainable(policy RetentionPolicy) (bool, error) {
+	var offset int64
+	for offset < s.end-s.start {
+		rec, err := s.readRecord(offset, s.end-s.start)
+		if err != nil {
+			return false, err
+		}
+		ok, err := policy(rec.Xid)
+		if err != nil || !ok {
+			return false, err
+		}
+		offset += recordHeaderLen + int64(len(rec.Data))
+	}
+	return true, nil
+}
+
+// SegmentCount 返回日志当前的段数，不分段时总是 1
+func (l *Logger) SegmentCount() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.segments)
+}
+
+// Iterator 返回一个从最早的记录开始按 LSN 顺序读取日志的迭代器，依次读完每一个段
+func (l *Logger) Iterator() *Iterator {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	segments := make([]segment, len(l.segments))
+	for i, seg := range l.segments {
+		segments[i] = *seg
+	}
+	return &Iterator{segments: segments, offset: segments[0].start}
+}
+
+// Close 关闭所有段文件
+func (l *Logger) Close() error {
+	var firstErr error
+	for _, seg := range l.segments {
+		err := seg.file.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Iterator 顺序读取创建迭代器时已经写入的日志记录
+type Iterator struct {
+	// segments 是创建迭代器时各个段的拷贝，之后追加的记录不可见
+	segments []segment
+	// offset 是下一条记录的 LSN
+	offset int64
+}
+
+// Next 返回下一条记录，没有更多记录或遇到损坏的记录时返回 io.EOF
+func (it *Iterator) Next() (Record, error) {
+	for len(it.segments) > 0 && it.offset >= it.segments[0].end {
+		it.segments = it.segments[1:]
+	}
+	if len(it.segments) == 0 {
+		return Record{}, io.EOF
+	}
+
+	seg := &it.segments[0]
+	rec, err := seg.readRecord(it.offset-seg.start, seg.end-seg.start)
+	if errors.Is(err, ErrBadRecord) {
+		it.segments = nil
+		return Record{}, io.EOF
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	it.offset += recordHeaderLen + int64(len(rec.Data))
+	return rec, nil
+}
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func readAll(t *testing.T, l *Logger) []Record {
+	t.Helper()
+	var records []Record
+	it := l.Iterator()
+	for {
+		rec, err := it.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestAppendAndReplay(t *testing.T) {
+	path := "test_file"
+	l, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer os.Remove(path + LogSuffix)
+
+	inputs := []Record{
+		{Xid: 1, Data: []byte("insert a")},
+		{Xid: 2, Data: []byte("insert b")},
+		{Xid: 1, Data: nil},
+	}
+	var lsns []int64
+	for _, in := range inputs {
+		lsn, err := l.Append(in.Xid, in.Data)
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		lsns = append(lsns, lsn)
+	}
+	l.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	records := readAll(t, l)
+	if len(records) != len(inputs) {
+		t.Fatalf("Expected %d records, got %d", len(inputs), len(records))
+	}
+	for i, rec := range records {
+		if rec.LSN != lsns[i] || rec.Xid != inputs[i].Xid || string(rec.Data) != string(inputs[i].Data) {
+			t.Errorf("Record %d: expected (%d, %d, %q), got (%d, %d, %q)",
+				i, lsns[i], inputs[i].Xid, inputs[i].Data, rec.LSN, rec.Xid, rec.Data)
+		}
+	}
+}
+
+func TestCorruptedTailRecord(t *testing.T) {
+	path := "test_file"
+	l, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer os.Remove(path + LogSuffix)
+
+	l.Append(1, []byte("first"))
+	l.Append(2, []byte("second"))
+	last, _ := l.Append(3, []byte("third"))
+
+	// 破坏最后一条记录的数据
+	l.active().file.WriteAt([]byte{'X'}, last+recordHeaderLen)
+	records := readAll(t, l)
+	if len(records) != 2 {
+		t.Fatalf("Expected replay to stop before the corrupted record, got %d records", len(records))
+	}
+	l.Close()
+
+	// 重新打开时截掉损坏的记录，后续追加从截断处继续
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	lsn, _ := l.Append(4, []byte("fourth"))
+	if lsn != last {
+		t.Errorf("Expected append at %d after truncation, got %d", last, lsn)
+	}
+	records = readAll(t, l)
+	if len(records) != 3 || records[2].Xid != 4 {
+		t.Errorf("Unexpected records after truncation: %v", records)
+	}
+}
+
+func TestTruncatedTailRecord(t *testing.T) {
+	path := "test_file"
+	l, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer os.Remove(path + LogSuffix)
+
+	l.Append(1, []byte("complete"))
+	last, _ := l.Append(2, []byte("half written"))
+	l.active().file.Truncate(last + recordHeaderLen + 4)
+	l.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	records := readAll(t, l)
+	if len(records) != 1 || string(records[0].Data) != "complete" {
+		t.Errorf("Expected only the complete record, got %v", records)
+	}
+}
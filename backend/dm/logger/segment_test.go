@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// removeSegments 删除 path 的所有段文件
+func removeSegments(path string) {
+	_, names, _ := listSegments(path)
+	for _, name := range names {
+		os.Remove(name)
+	}
+}
+
+// appendSegmented 为每个 xid 追加一条 4 字节的记录，每条记录占 20 字节
+func appendSegmented(t *testing.T, l *Logger, xids ...int64) []int64 {
+	t.Helper()
+	var lsns []int64
+	for _, xid := range xids {
+		lsn, err := l.Append(xid, []byte(fmt.Sprintf("x%03d", xid)))
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		lsns = append(lsns, lsn)
+	}
+	return lsns
+}
+
+func TestSegmentRotation(t *testing.T) {
+	path := "test_file"
+	defer removeSegments(path)
+	l, err := Create(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer l.Close()
+
+	// 两条记录正好写满第一个段
+	appendSegmented(t, l, 1, 2)
+	if n := l.SegmentCount(); n != 1 {
+		t.Fatalf("Expected 1 segment at the size boundary, got %d", n)
+	}
+	lsns := appendSegmented(t, l, 3)
+	if n := l.SegmentCount(); n != 2 || lsns[0] != 40 {
+		t.Fatalf("Expected a second segment starting at 40, got %d segments and lsn %d", n, lsns[0])
+	}
+	for _, start := range []int64{0, 40} {
+		if _, err := os.Stat(segmentPath(path, start)); err != nil {
+			t.Errorf("Segment starting at %d: %v", start, err)
+		}
+	}
+	if info, _ := os.Stat(segmentPath(path, 0)); info.Size() != 40 {
+		t.Errorf("First segment should hold exactly 40 bytes, got %d", info.Size())
+	}
+
+	// 比段大的记录单独占一个段
+	big, _ := l.Append(4, make([]byte, 100))
+	next := appendSegmented(t, l, 5)
+	if big != 60 || next[0] != 176 || l.SegmentCount() != 4 {
+		t.Errorf("Unexpected layout: big record at %d, next at %d, %d segments", big, next[0], l.SegmentCount())
+	}
+}
+
+func TestSegmentReplayInOrder(t *testing.T) {
+	path := "test_file"
+	defer removeSegments(path)
+	l, err := Create(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	lsns := appendSegmented(t, l, 1, 2, 3, 4, 5, 6)
+	l.Close()
+
+	l, err = Open(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+	if n := l.SegmentCount(); n != 3 {
+		t.Fatalf("Expected 3 segments, got %d", n)
+	}
+
+	records := readAll(t, l)
+	if len(records) != 6 {
+		t.Fatalf("Expected 6 records, got %d", len(records))
+	}
+	for i, rec := range records {
+		if rec.LSN != lsns[i] || rec.Xid != int64(i+1) {
+			t.Errorf("Record %d: expected xid %d at %d, got xid %d at %d", i, i+1, lsns[i], rec.Xid, rec.LSN)
+		}
+	}
+
+	// 重新打开后继续向最后一个段之后追加
+	next := appendSegmented(t, l, 7)
+	if next[0] != 120 || l.SegmentCount() != 4 {
+		t.Errorf("Expected the next record at 120 in a new segment, got %d with %d segments", next[0], l.SegmentCount())
+	}
+}
+
+func TestSegmentRetention(t *testing.T) {
+	path := "test_file"
+	defer removeSegments(path)
+	l, err := Create(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	appendSegmented(t, l, 1, 1, 2, 3, 4)
+
+	// 第二个段中的 xid 3 还不能删除，删除在这里停止
+	removed, err := l.Retain(func(xid int64) (bool, error) { return xid <= 2, nil })
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 removed segment, got %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(segmentPath(path, 0)); !os.IsNotExist(err) {
+		t.Errorf("Expected the first segment to be deleted, got %v", err)
+	}
+
+	// 正在追加的段不会被删除
+	removed, _ = l.Retain(func(xid int64) (bool, error) { return true, nil })
+	if removed != 1 || l.SegmentCount() != 1 {
+		t.Errorf("Expected only the active segment to remain, removed %d, %d left", removed, l.SegmentCount())
+	}
+	records := readAll(t, l)
+	if len(records) != 1 || records[0].Xid != 4 || records[0].LSN != 80 {
+		t.Errorf("Unexpected records after retention: %v", records)
+	}
+	l.Close()
+
+	// 删除过段的日志仍然可以打开，LSN 保持不变
+	l, err = Open(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+	records = readAll(t, l)
+	if len(records) != 1 || records[0].LSN != 80 {
+		t.Errorf("Unexpected records after reopen: %v", records)
+	}
+
+	policyErr := errors.New("policy failed")
+	appendSegmented(t, l, 5, 6)
+	if _, err := l.Retain(func(xid int64) (bool, error) { return false, policyErr }); !errors.Is(err, policyErr) {
+		t.Errorf("Expected the policy error, got %v", err)
+	}
+}
+
+func TestSegmentMissing(t *testing.T) {
+	path := "test_file"
+	defer removeSegments(path)
+	l, err := Create(path, WithSegmentSize(40))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	appendSegmented(t, l, 1, 2, 3, 4, 5)
+	l.Close()
+
+	os.Remove(segmentPath(path, 40))
+	if _, err := Open(path, WithSegmentSize(40)); !errors.Is(err, ErrMissingSegment) {
+		t.Errorf("Expected ErrMissingSegment, got %v", err)
+	}
+}
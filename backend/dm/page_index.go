@@ -0,0 +1,61 @@
+package dm
+
+import (
+	"sync"
+
+	"mydb-go/backend/common"
+)
+
+const (
+	// pageIndexIntervals 是 PageIndex 中桶的个数，每个桶覆盖 pageIndexBucketSize 字节的空闲空间
+	pageIndexIntervals  = 40
+	pageIndexBucketSize = common.PageSize / pageIndexIntervals
+)
+
+// freeSlot 是页 pgno 中从页内偏移 offset 开始的 space 字节的空闲空间
+type freeSlot struct {
+	pgno   int64
+	offset int
+	space  int
+}
+
+// PageIndex 按空闲空间的大小把页中可以复用的空间分到粗粒度的桶中，
+// 第 i 个桶保存大小在 [i*pageIndexBucketSize, (i+1)*pageIndexBucketSize) 之间的空间。
+// 它只保存在内存中，重新打开后之前释放的空间不再被复用
+type PageIndex struct {
+	lock    sync.Mutex
+	buckets [pageIndexIntervals + 1][]freeSlot
+}
+
+// NewPageIndex 创建一个空的 PageIndex
+func NewPageIndex() *PageIndex {
+	return &PageIndex{}
+}
+
+// Add 记录页 pgno 中从 offset 开始有 space 字节的空间可以复用
+func (pi *PageIndex) Add(pgno int64, offset int, space int) {
+	pi.lock.Lock()
+	defer pi.lock.Unlock()
+	i := space / pageIndexBucketSize
+	pi.buckets[i] = append(pi.buckets[i], freeSlot{pgno: pgno, offset: offset, space: space})
+}
+
+// Select 找到一块不小于 spaceNeeded 字节的空间并把它从索引中移除，返回它所在的页和页内偏移。
+// 先在 spaceNeeded 所在的桶中找，再从更大的桶中找，没有时 ok 为 false
+func (pi *PageIndex) Select(spaceNeeded int) (pgno int64, offset int, ok bool) {
+	pi.lock.Lock()
+	defer pi.lock.Unlock()
+
+	for i := spaceNeeded / pageIndexBucketSize; i < len(pi.buckets); i++ {
+		for j, slot := range pi.buckets[i] {
+			if slot.space < spaceNeeded {
+				continue
+			}
+			last := len(pi.buckets[i]) - 1
+			pi.buckets[i][j] = pi.buckets[i][last]
+			pi.buckets[i] = pi.buckets[i][:last]
+			return slot.pgno, slot.offset, true
+		}
+	}
+	return 0, 0, false
+}
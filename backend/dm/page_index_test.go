@@ -0,0 +1,26 @@
+package dm
+
+import "testing"
+
+func TestPageIndexSelect(t *testing.T) {
+	pi := NewPageIndex()
+	if _, _, ok := pi.Select(10); ok {
+		t.Errorf("Expected no space in an empty index")
+	}
+
+	pi.Add(1, 100, 50)
+	pi.Add(2, 200, 3*pageIndexBucketSize)
+	// 同一个桶中放不下时到更大的桶中找
+	pgno, off, ok := pi.Select(60)
+	if !ok || pgno != 2 || off != 200 {
+		t.Errorf("Expected page 2 at 200, got %d at %d (%v)", pgno, off, ok)
+	}
+	pgno, off, ok = pi.Select(50)
+	if !ok || pgno != 1 || off != 100 {
+		t.Errorf("Expected page 1 at 100, got %d at %d (%v)", pgno, off, ok)
+	}
+	// 选出的空间从索引中移除
+	if _, _, ok := pi.Select(1); ok {
+		t.Errorf("Expected the index to be empty after selecting every slot")
+	}
+}
@@ -0,0 +1,143 @@
+package dm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"mydb-go/backend/common"
+	"mydb-go/backend/tm"
+)
+
+// 恢复:
+//
+// 日志按发生的顺序记录了对数据页的每一次修改，包括撤销时写入的补偿和释放记录。
+// Recover 先按顺序重做全部日志，不管事务最后是否提交，数据页都回到崩溃前最后一次修改之后的状态；
+// 同时为没有提交的事务重建 undo 记录: 插入和更新入栈，补偿和释放记录弹出最近的一条，
+// 剩下的就是这些事务还没有撤销的修改。然后撤销它们，并取消崩溃时还在进行或已经准备的事务。
+// 撤销同样写补偿和释放记录，恢复中途再次崩溃时，下一次恢复不会把已经撤销的修改再撤销一次
+
+// Recover 根据日志把数据文件恢复到一致的状态: 已提交事务的修改全部保留，其他事务的修改全部撤销。
+// report 是打开事务管理器时还没有结束的事务，它们在撤销之后被取消，已准备的事务同样被取消。
+// Open 在注册 Observer 之前调用它，取消事务时不会再触发一次回滚
+func (dm *DataManagerImpl) Recover(report tm.RecoveryReport) error {
+	committed := make(map[int64]bool)
+	var losers []int64
+	// Checkpoint 丢弃了 base 及之前的 XID 的状态，它们在丢弃前已经结束，撤销也已经完成，按已提交处理
+	var base int64
+	if b, ok := dm.tm.(interface{ BaseXID() int64 }); ok {
+		base = b.BaseXID()
+	}
+	it := dm.lg.Iterator()
+	for {
+		rec, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		typ, offset, old, data, err := decodeLog(rec.Data)
+		if err != nil {
+			return err
+		}
+		err = dm.redo(typ, offset, data)
+		if err != nil {
+			return fmt.Errorf("redo log record at %d: %w", rec.LSN, err)
+		}
+
+		isCommitted, ok := committed[rec.Xid]
+		if !ok && rec.Xid <= base {
+			isCommitted = true
+			committed[rec.Xid] = true
+		} else if !ok {
+			isCommitted, err = dm.tm.IsCommitted(rec.Xid)
+			if err != nil {
+				return err
+			}
+			committed[rec.Xid] = isCommitted
+			if !isCommitted {
+				losers = append(losers, rec.Xid)
+			}
+		}
+		if isCommitted {
+			continue
+		}
+
+		switch typ {
+		case logTypeInsert, logTypeUpdate:
+			err = dm.undo.push(rec.Xid, undoEntry{offset: offset, old: old, insert: typ == logTypeInsert})
+		default:
+			if n := dm.undo.count(rec.Xid); n > 0 {
+				_, err = dm.undo.takeAfter(rec.Xid, n-1)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	dm.insertPage = dm.pc.PageCount() - 1
+
+	// 已经取消的事务也可能因为崩溃没有撤销完
+	for _, xid := range losers {
+		err := dm.rollback(xid)
+		if err != nil {
+			return err
+		}
+	}
+	for _, xids := range [][]int64{report.Active, report.Prepared} {
+		for _, xid := range xids {
+			err := dm.tm.Abort(xid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// redo 把日志记录中的新值重新写入数据页，释放记录不修改数据页
+func (dm *DataManagerImpl) redo(typ byte, offset int64, data []byte) error {
+	switch typ {
+	case logTypeInsert:
+		return dm.redoInsert(offset, data)
+	case logTypeUpdate, logTypeCompensate:
+		return dm.writeRecord(offset, data)
+	}
+	return nil
+}
+
+// redoInsert 把插入的记录重新写到 offset 处。崩溃前新分配的页可能没有写入数据文件，这时先分配到 offset 所在的页；
+// 页的 fso 没有覆盖这条记录时把它推后到记录的末尾
+func (dm *DataManagerImpl) redoInsert(offset int64, data []byte) error {
+	pgno := offset / common.PageSize
+	for dm.pc.PageCount() <= pgno {
+		_, err := dm.pc.NewPage(emptyPage())
+		if err != nil {
+			return err
+		}
+	}
+
+	page, err := dm.pc.GetPage(pgno)
+	if err != nil {
+		return err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	off := int(offset % common.PageSize)
+	end := off + lenRecordSize + len(data)
+	if off < lenPageFSO || end > common.PageSize {
+		return fmt.Errorf("%w: %d", ErrBadOffset, offset)
+	}
+	page.Lock()
+	buf := page.Data()
+	binary.BigEndian.PutUint16(buf[off:], uint16(len(data)))
+	copy(buf[off+lenRecordSize:], data)
+	if int(binary.BigEndian.Uint16(buf)) < end {
+		binary.BigEndian.PutUint16(buf, uint16(end))
+	}
+	page.Unlock()
+	page.SetDirty(true)
+	return nil
+}
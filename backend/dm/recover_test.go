@@ -0,0 +1,241 @@
+package dm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"mydb-go/backend/tm"
+)
+
+// crash 模拟崩溃: 日志和事务状态已经写入文件，页面缓存中的脏页不写回就被丢弃
+func crash(dm *DataManagerImpl) {
+	dm.lg.Close()
+	dm.tm.Close()
+	dm.undo.close()
+}
+
+// readCommitted 在一个新事务中读取 offset 处的记录
+func readCommitted(t *testing.T, dm *DataManagerImpl, offset int64) string {
+	t.Helper()
+	xid, _ := dm.TransactionManager().Begin()
+	defer dm.TransactionManager().Commit(xid)
+	data, err := dm.Read(xid, offset)
+	if err != nil {
+		t.Fatalf("Read at %d failed: %v", offset, err)
+	}
+	return string(data)
+}
+
+func TestRecoverAfterCrash(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	txm := dm.TransactionManager()
+	xid, _ := txm.Begin()
+	first, _ := dm.Insert(xid, []byte("first v0"))
+	shared, _ := dm.Insert(xid, []byte("shared v0"))
+	txm.Commit(xid)
+
+	// 没有提交的修改被写回了数据文件
+	dangling, _ := txm.Begin()
+	dm.Update(dangling, shared, []byte("shared v1"))
+	lost, _ := dm.Insert(dangling, []byte("lost"))
+	if err := dm.pc.FlushDirty(); err != nil {
+		t.Fatalf("FlushDirty failed: %v", err)
+	}
+
+	// 已提交的修改只在页面缓存中
+	committed, _ := txm.Begin()
+	dm.Update(committed, first, []byte("first v1"))
+	second, _ := dm.Insert(committed, []byte("second"))
+	txm.Commit(committed)
+
+	prepared, _ := txm.Begin()
+	dm.Update(prepared, first, []byte("first v2"))
+	if err := dm.tm.(*tm.TransactionManagerImpl).Prepare(prepared); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	crash(dm)
+
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dm.Close()
+
+	if got := readCommitted(t, dm, first); got != "first v1" {
+		t.Errorf("Expected first v1, got %q", got)
+	}
+	if got := readCommitted(t, dm, second); got != "second" {
+		t.Errorf("Expected the committed insert to survive, got %q", got)
+	}
+	if got := readCommitted(t, dm, shared); got != "shared v0" {
+		t.Errorf("Expected the uncommitted update to be undone, got %q", got)
+	}
+	for _, x := range []int64{dangling, prepared} {
+		if aborted, err := dm.TransactionManager().IsAborted(x); err != nil || !aborted {
+			t.Errorf("Expected xid %d to be aborted, got %v, %v", x, aborted, err)
+		}
+	}
+
+	// 被撤销的插入留下的空间可以复用
+	xid, _ = dm.TransactionManager().Begin()
+	offset, err := dm.Insert(xid, []byte("lost"))
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if offset != lost {
+		t.Errorf("Expected the insert to reuse offset %d, got %d", lost, offset)
+	}
+}
+
+func TestRecoverUnfinishedAbort(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	txm := dm.TransactionManager()
+	xid, _ := txm.Begin()
+	offset, _ := dm.Insert(xid, []byte("old"))
+	txm.Commit(xid)
+
+	// 状态已经写为取消，但是崩溃前没有来得及回滚
+	aborted, _ := txm.Begin()
+	dm.Update(aborted, offset, []byte("new"))
+	dm.pc.FlushDirty()
+	dm.tm.SetObserver(nil)
+	txm.Abort(aborted)
+	crash(dm)
+
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dm.Close()
+	if got := readCommitted(t, dm, offset); got != "old" {
+		t.Errorf("Expected old after recovery, got %q", got)
+	}
+}
+
+func TestRecoverKeepsUpdatesAfterAbort(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	txm := dm.TransactionManager()
+	xid, _ := txm.Begin()
+	offset, _ := dm.Insert(xid, []byte("v0"))
+	txm.Commit(xid)
+
+	// 取消的事务已经回滚，之后提交的事务更新同一条记录
+	aborted, _ := txm.Begin()
+	dm.Update(aborted, offset, []byte("v1"))
+	txm.Abort(aborted)
+	xid, _ = txm.Begin()
+	dm.Update(xid, offset, []byte("v2"))
+	txm.Commit(xid)
+
+	// 回滚到保存点的修改在提交后也不会被重做
+	xid, _ = txm.Begin()
+	other, _ := dm.Insert(xid, []byte("s0"))
+	sp, _ := dm.Savepoint(xid)
+	dm.Update(xid, other, []byte("s1"))
+	dm.RollbackTo(xid, sp)
+	txm.Commit(xid)
+	crash(dm)
+
+	// 恢复之后再次崩溃，第二次恢复的结果相同
+	for i := 0; i < 2; i++ {
+		dm, err = Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if got := readCommitted(t, dm, offset); got != "v2" {
+			t.Errorf("Recovery %d: expected v2, got %q", i, got)
+		}
+		if got := readCommitted(t, dm, other); got != "s0" {
+			t.Errorf("Recovery %d: expected s0, got %q", i, got)
+		}
+		crash(dm)
+	}
+}
+
+func TestRecoverAfterCleanClose(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid, _ := dm.TransactionManager().Begin()
+	offset, _ := dm.Insert(xid, []byte("old"))
+	dm.TransactionManager().Commit(xid)
+
+	// 事务没有结束就关闭，它的修改已经写入数据文件
+	dangling, _ := dm.TransactionManager().Begin()
+	dm.Update(dangling, offset, []byte("new"))
+	dm.Close()
+
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer dm.Close()
+	if aborted, _ := dm.TransactionManager().IsAborted(dangling); !aborted {
+		t.Errorf("Expected xid %d to be aborted on open", dangling)
+	}
+	if got := readCommitted(t, dm, offset); got != "old" {
+		t.Errorf("Expected old after recovery, got %q", got)
+	}
+	if n := dm.undo.count(dangling); n != 0 {
+		t.Errorf("Expected no undo records left, got %d", n)
+	}
+}
+
+func TestRecoverAfterCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	txm := dm.TransactionManager()
+	xid, _ := txm.Begin()
+	kept, _ := dm.Insert(xid, []byte("kept"))
+	txm.Commit(xid)
+	aborted, _ := txm.Begin()
+	dm.Insert(aborted, []byte("undone"))
+	txm.Abort(aborted)
+	base, err := dm.tm.(*tm.TransactionManagerImpl).Checkpoint()
+	if err != nil || base != aborted {
+		t.Fatalf("Expected checkpoint at %d, got %d, %v", aborted, base, err)
+	}
+
+	// 检查点之后的事务在崩溃时没有结束
+	dangling, _ := txm.Begin()
+	dm.Update(dangling, kept, []byte("lost"))
+	crash(dm)
+
+	dm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open after checkpoint failed: %v", err)
+	}
+	defer dm.Close()
+	if got := readCommitted(t, dm, kept); got != "kept" {
+		t.Errorf("Expected kept, got %q", got)
+	}
+	if isAborted, err := dm.TransactionManager().IsAborted(dangling); err != nil || !isAborted {
+		t.Errorf("Expected xid %d to be aborted, got %v, %v", dangling, isAborted, err)
+	}
+}
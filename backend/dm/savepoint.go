@@ -0,0 +1,84 @@
+package dm
+
+import (
+	"errors"
+	"fmt"
+
+	"mydb-go/backend/common"
+)
+
+// SavepointID 标记一个事务在某一时刻的 undo 记录位置，即当时已有的 undo 记录条数
+type SavepointID int
+
+// ErrInvalidSavepoint 表示保存点晚于事务现有的 undo 记录，通常是因为已经回滚到了更早的保存点
+var ErrInvalidSavepoint = errors.New("invalid savepoint")
+
+// Savepoint 返回 xid 当前的保存点，之后可以用 RollbackTo 撤销它之后的修改
+func (dm *DataManagerImpl) Savepoint(xid int64) (SavepointID, error) {
+	err := dm.checkActive(xid)
+	if err != nil {
+		return 0, err
+	}
+	return SavepointID(dm.undo.count(xid)), nil
+}
+
+// RollbackTo 从后往前撤销 xid 在 sp 之后的修改，xid 仍然保持活跃，sp 之前的修改不受影响。
+// 回滚到 sp 之后 sp 仍然有效，比 sp 更晚的保存点失效。
+// 写回的旧值作为 xid 的补偿记录写入日志，这样 xid 之后提交时重做日志不会恢复被撤销的修改
+func (dm *DataManagerImpl) RollbackTo(xid int64, sp SavepointID) error {
+	err := dm.checkActive(xid)
+	if err != nil {
+		return err
+	}
+	if sp < 0 || int(sp) > dm.undo.count(xid) {
+		return fmt.Errorf("%w: %d", ErrInvalidSavepoint, sp)
+	}
+
+	entries, err := dm.undo.takeAfter(xid, int(sp))
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].insert {
+			err = dm.freeRecord(xid, entries[i].offset, entries[i].space)
+		} else {
+			err = dm.restoreRecord(xid, entries[i].offset, entries[i].old)
+		}
+		if err != nil {
+			// 没有撤销的记录放回去，之后回滚到更早的保存点或者取消 xid 时再撤销
+			for _, entry := range entries[:i+1] {
+				dm.undo.push(xid, entry)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreRecord 把 old 写回 offset 处的记录，并作为 xid 的补偿记录写入日志
+func (dm *DataManagerImpl) restoreRecord(xid int64, offset int64, old []byte) error {
+	page, err := dm.pc.GetPage(offset / common.PageSize)
+	if err != nil {
+		return err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	off, size, err := locateRecord(page.Data(), offset)
+	if err == nil && size != len(old) {
+		err = fmt.Errorf("%w: record at %d has %d bytes, got %d", ErrSizeMismatch, offset, size, len(old))
+	}
+	if err != nil {
+		page.Unlock()
+		return err
+	}
+	err = dm.appendLog(xid, encodeCompensateLog(offset, old))
+	if err != nil {
+		page.Unlock()
+		return err
+	}
+	copy(page.Data()[off+lenRecordSize:], old)
+	page.Unlock()
+	page.SetDirty(true)
+	return nil
+}
@@ -0,0 +1,328 @@
+package dm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"mydb-go/backend/common"
+)
+
+// testDataManagerSuite 对任意 DataManager 实现运行同一组行为测试
+func testDataManagerSuite(t *testing.T, newDM func(t *testing.T) DataManager) {
+	t.Run("InsertAcrossPages", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+
+		xid, _ := dm.TransactionManager().Begin()
+		inputs := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{'x'}, 5000), bytes.Repeat([]byte{'y'}, 5000)}
+		var offsets []int64
+		for _, data := range inputs {
+			offset, err := dm.Insert(xid, data)
+			if err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			offsets = append(offsets, offset)
+		}
+		if offsets[2]/common.PageSize != 0 || offsets[3]/common.PageSize != 1 {
+			t.Errorf("Expected the last record on a new page, got offsets %v", offsets)
+		}
+		for i, offset := range offsets {
+			data, err := dm.Read(xid, offset)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if !bytes.Equal(data, inputs[i]) {
+				t.Errorf("Unexpected data at offset %d", offset)
+			}
+		}
+	})
+
+	t.Run("RejectInactiveTransaction", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+
+		xid, _ := dm.TransactionManager().Begin()
+		offset, err := dm.Insert(xid, []byte("data"))
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		dm.TransactionManager().Abort(xid)
+
+		if _, err := dm.Insert(xid, []byte("more")); !errors.Is(err, ErrTransactionNotActive) {
+			t.Errorf("Expected ErrTransactionNotActive from Insert, got %v", err)
+		}
+		if _, err := dm.Read(xid, offset); !errors.Is(err, ErrTransactionNotActive) {
+			t.Errorf("Expected ErrTransactionNotActive from Read, got %v", err)
+		}
+	})
+
+	t.Run("ReadBadOffset", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+
+		xid, _ := dm.TransactionManager().Begin()
+		dm.Insert(xid, []byte("data"))
+		for _, offset := range []int64{0, 100, -1} {
+			if _, err := dm.Read(xid, offset); !errors.Is(err, ErrBadOffset) {
+				t.Errorf("Expected ErrBadOffset at %d, got %v", offset, err)
+			}
+		}
+		if _, err := dm.Insert(xid, make([]byte, common.PageSize)); !errors.Is(err, ErrDataTooLarge) {
+			t.Errorf("Expected ErrDataTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("UpdateAbortRestoresOldValue", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		offset, _ := dm.Insert(xid, []byte("old value"))
+		tm.Commit(xid)
+
+		// 同一个事务中更新两次，取消后回到最初的值
+		xid, _ = tm.Begin()
+		if err := dm.Update(xid, offset, []byte("new value")); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if err := dm.Update(xid, offset, []byte("newer val")); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if data, _ := dm.Read(xid, offset); string(data) != "newer val" {
+			t.Errorf("Expected the update to be visible in its transaction, got %q", data)
+		}
+		tm.Abort(xid)
+
+		xid, _ = tm.Begin()
+		data, err := dm.Read(xid, offset)
+		if err != nil || string(data) != "old value" {
+			t.Errorf("Expected old value after abort, got %q, %v", data, err)
+		}
+	})
+
+	t.Run("UpdateCommitKeepsNewValue", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		offset, _ := dm.Insert(xid, []byte("old value"))
+		dm.Update(xid, offset, []byte("new value"))
+		tm.Commit(xid)
+
+		xid, _ = tm.Begin()
+		data, err := dm.Read(xid, offset)
+		if err != nil || string(data) != "new value" {
+			t.Errorf("Expected new value after commit, got %q, %v", data, err)
+		}
+	})
+
+	t.Run("UpdateErrors", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+
+		xid, _ := dm.TransactionManager().Begin()
+		offset, _ := dm.Insert(xid, []byte("data"))
+		if err := dm.Update(xid, offset, []byte("longer")); !errors.Is(err, ErrSizeMismatch) {
+			t.Errorf("Expected ErrSizeMismatch, got %v", err)
+		}
+		if err := dm.Update(xid, 100, []byte("data")); !errors.Is(err, ErrBadOffset) {
+			t.Errorf("Expected ErrBadOffset, got %v", err)
+		}
+		dm.TransactionManager().Commit(xid)
+		if err := dm.Update(xid, offset, []byte("more")); !errors.Is(err, ErrTransactionNotActive) {
+			t.Errorf("Expected ErrTransactionNotActive, got %v", err)
+		}
+	})
+
+	t.Run("AbortedInsertSpaceReused", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		kept, _ := dm.Insert(xid, []byte("kept"))
+		tm.Commit(xid)
+
+		xid, _ = tm.Begin()
+		aborted, _ := dm.Insert(xid, []byte("aborted record"))
+		tm.Abort(xid)
+
+		// 长度相同或更短的插入复用被取消的插入留下的空间，更长的插入不能复用
+		xid, _ = tm.Begin()
+		longer, _ := dm.Insert(xid, []byte("a longer record"))
+		if longer == aborted {
+			t.Errorf("Expected a longer record not to reuse offset %d", aborted)
+		}
+		reused, err := dm.Insert(xid, []byte("similar size"))
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if reused != aborted {
+			t.Errorf("Expected the insert to reuse offset %d, got %d", aborted, reused)
+		}
+		if data, _ := dm.Read(xid, reused); string(data) != "similar size" {
+			t.Errorf("Unexpected data %q in the reused slot", data)
+		}
+		if data, _ := dm.Read(xid, kept); string(data) != "kept" {
+			t.Errorf("Unexpected data %q in the committed record", data)
+		}
+
+		// 空间只能被复用一次
+		next, _ := dm.Insert(xid, []byte("similar size"))
+		if next == aborted {
+			t.Errorf("Expected the slot at %d to be used only once", aborted)
+		}
+	})
+
+	t.Run("ReusedSlotKeepsSize", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		slot, _ := dm.Insert(xid, []byte("a long aborted record"))
+		tm.Abort(xid)
+
+		// 较短的插入复用这块空间后又被取消，整块空间都要交还
+		xid, _ = tm.Begin()
+		if short, _ := dm.Insert(xid, []byte("short")); short != slot {
+			t.Fatalf("Expected the short insert to reuse offset %d, got %d", slot, short)
+		}
+		tm.Abort(xid)
+
+		xid, _ = tm.Begin()
+		defer tm.Commit(xid)
+		reused, err := dm.Insert(xid, []byte("same length as first!"))
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if reused != slot {
+			t.Errorf("Expected the whole slot at %d to be reused, got %d", slot, reused)
+		}
+	})
+
+	t.Run("RollbackToSavepoint", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		first, _ := dm.Insert(xid, []byte("first"))
+		dm.Update(xid, first, []byte("FIRST"))
+		sp, err := dm.Savepoint(xid)
+		if err != nil {
+			t.Fatalf("Savepoint failed: %v", err)
+		}
+
+		dm.Update(xid, first, []byte("later"))
+		second, _ := dm.Insert(xid, []byte("second"))
+		inner, _ := dm.Savepoint(xid)
+		dm.Update(xid, second, []byte("SECOND"))
+
+		if err := dm.RollbackTo(xid, sp); err != nil {
+			t.Fatalf("RollbackTo failed: %v", err)
+		}
+		if active, _ := tm.IsActive(xid); !active {
+			t.Fatalf("Expected xid %d to stay active after RollbackTo", xid)
+		}
+		// 保存点之前的修改保留，之后的修改被撤销
+		if data, _ := dm.Read(xid, first); string(data) != "FIRST" {
+			t.Errorf("Expected the change before the savepoint to survive, got %q", data)
+		}
+		if err := dm.RollbackTo(xid, inner); !errors.Is(err, ErrInvalidSavepoint) {
+			t.Errorf("Expected ErrInvalidSavepoint for a rolled back savepoint, got %v", err)
+		}
+		// 撤销的插入释放的空间可以复用
+		if reused, _ := dm.Insert(xid, []byte("third!")); reused != second {
+			t.Errorf("Expected the rolled back insert at %d to be reused, got %d", second, reused)
+		}
+
+		// 回滚之后还可以继续修改并提交，也可以再次回滚到同一个保存点
+		dm.Update(xid, first, []byte("again"))
+		if err := dm.RollbackTo(xid, sp); err != nil {
+			t.Fatalf("RollbackTo failed: %v", err)
+		}
+		tm.Commit(xid)
+
+		xid, _ = tm.Begin()
+		if data, _ := dm.Read(xid, first); string(data) != "FIRST" {
+			t.Errorf("Expected FIRST after commit, got %q", data)
+		}
+		if _, err := dm.Savepoint(xid + 1); err == nil {
+			t.Errorf("Expected Savepoint on an unknown transaction to fail")
+		}
+	})
+
+	t.Run("AbortAfterRollbackTo", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		xid, _ := tm.Begin()
+		offset, _ := dm.Insert(xid, []byte("base"))
+		tm.Commit(xid)
+
+		xid, _ = tm.Begin()
+		dm.Update(xid, offset, []byte("one!"))
+		sp, _ := dm.Savepoint(xid)
+		dm.Update(xid, offset, []byte("two!"))
+		dm.RollbackTo(xid, sp)
+		tm.Abort(xid)
+
+		// 取消事务撤销保存点之前的修改
+		xid, _ = tm.Begin()
+		if data, _ := dm.Read(xid, offset); string(data) != "base" {
+			t.Errorf("Expected base after abort, got %q", data)
+		}
+	})
+
+	t.Run("ManyPagesBeyondCache", func(t *testing.T) {
+		dm := newDM(t)
+		defer dm.Close()
+		tm := dm.TransactionManager()
+
+		// 写满比页面缓存更多的页，被淘汰的页之后仍能读回
+		xid, _ := tm.Begin()
+		record := bytes.Repeat([]byte{'r'}, maxRecordDataLen)
+		var offsets []int64
+		for i := 0; i < DefaultCachePages*2; i++ {
+			record[0] = byte(i)
+			offset, err := dm.Insert(xid, record)
+			if err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+			offsets = append(offsets, offset)
+		}
+		tm.Commit(xid)
+
+		xid, _ = tm.Begin()
+		for i, offset := range offsets {
+			data, err := dm.Read(xid, offset)
+			if err != nil || len(data) != maxRecordDataLen || data[0] != byte(i) {
+				t.Fatalf("Unexpected record %d: %v", i, err)
+			}
+		}
+	})
+}
+
+func TestFileDataManagerSuite(t *testing.T) {
+	path := "test_file"
+	testDataManagerSuite(t, func(t *testing.T) DataManager {
+		removeFiles(path)
+		t.Cleanup(func() { removeFiles(path) })
+		dm, err := Create(path)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		return dm
+	})
+}
+
+func TestMemoryDataManagerSuite(t *testing.T) {
+	testDataManagerSuite(t, func(t *testing.T) DataManager {
+		return NewMemoryDataManager()
+	})
+}
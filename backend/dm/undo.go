@@ -0,0 +1,183 @@
+package dm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"mydb-go/backend/common"
+)
+
+// DataManager 写入日志的记录格式:
+//
+//	插入 (redo): [type: 1 字节][offset: 8 字节][data]
+//	更新 (undo+redo): [type: 1 字节][offset: 8 字节][old][new]
+//	补偿 (redo): [type: 1 字节][offset: 8 字节][data]
+//	释放 (redo): [type: 1 字节][offset: 8 字节]
+//
+// 更新不改变记录长度，所以 old 和 new 各占剩余部分的一半。
+// 撤销一次更新时写入补偿记录，data 是写回的旧值；撤销一次插入时写入释放记录，它不修改数据页。
+// 恢复时每条补偿或释放记录抵消同一事务中最近一条还没有撤销的更新或插入
+const (
+	logTypeInsert     = byte(0)
+	logTypeUpdate     = byte(1)
+	logTypeCompensate = byte(2)
+	logTypeFree       = byte(3)
+
+	lenLogType   = 1
+	lenLogOffset = 8
+)
+
+// ErrBadLogRecord 表示日志中的记录不是 DataManager 写入的格式
+var ErrBadLogRecord = errors.New("bad data manager log record")
+
+// undoEntry 是一次更新之前的旧值，事务取消时写回 offset 处。
+// insert 为 true 时表示 offset 处的记录是事务插入的，事务取消时把它的空间交给 PageIndex 复用。
+// 插入复用了更大的空闲空间时 space 是整块空间的字节数，取消时整块交还，为 0 时只交还记录本身占用的空间
+type undoEntry struct {
+	offset int64
+	old    []byte
+	insert bool
+	space  int
+}
+
+func encodeInsertLog(offset int64, data []byte) []byte {
+	buf := make([]byte, lenLogType+lenLogOffset+len(data))
+	buf[0] = logTypeInsert
+	binary.BigEndian.PutUint64(buf[lenLogType:], uint64(offset))
+	copy(buf[lenLogType+lenLogOffset:], data)
+	return buf
+}
+
+func encodeUpdateLog(offset int64, old, data []byte) []byte {
+	buf := make([]byte, lenLogType+lenLogOffset+len(old)+len(data))
+	buf[0] = logTypeUpdate
+	binary.BigEndian.PutUint64(buf[lenLogType:], uint64(offset))
+	copy(buf[lenLogType+lenLogOffset:], old)
+	copy(buf[lenLogType+lenLogOffset+len(old):], data)
+	return buf
+}
+
+func encodeCompensateLog(offset int64, data []byte) []byte {
+	buf := encodeInsertLog(offset, data)
+	buf[0] = logTypeCompensate
+	return buf
+}
+
+func encodeFreeLog(offset int64) []byte {
+	buf := encodeInsertLog(offset, nil)
+	buf[0] = logTypeFree
+	return buf
+}
+
+// decodeLog 解析 DataManager 写入的日志记录，插入、补偿和释放记录的 old 为 nil
+func decodeLog(data []byte) (typ byte, offset int64, old, new []byte, err error) {
+	if len(data) < lenLogType+lenLogOffset {
+		return 0, 0, nil, nil, fmt.Errorf("%w: log record of %d bytes", ErrBadLogRecord, len(data))
+	}
+	typ = data[0]
+	offset = int64(binary.BigEndian.Uint64(data[lenLogType:]))
+	body := data[lenLogType+lenLogOffset:]
+	switch typ {
+	case logTypeInsert, logTypeCompensate:
+		return typ, offset, nil, body, nil
+	case logTypeFree:
+		if len(body) != 0 {
+			return 0, 0, nil, nil, fmt.Errorf("%w: free record with %d bytes of body", ErrBadLogRecord, len(body))
+		}
+		return typ, offset, nil, nil, nil
+	case logTypeUpdate:
+		if len(body)%2 != 0 {
+			return 0, 0, nil, nil, fmt.Errorf("%w: odd update body of %d bytes", ErrBadLogRecord, len(body))
+		}
+		return typ, offset, body[:len(body)/2], body[len(body)/2:], nil
+	}
+	return 0, 0, nil, nil, fmt.Errorf("%w: unknown type %d", ErrBadLogRecord, typ)
+}
+
+// rollback 从后往前把 xid 更新之前的旧值写回数据页，并把 xid 插入的记录占用的空间交还给 PageIndex，
+// 每撤销一条记录都写一条补偿或释放日志
+func (dm *DataManagerImpl) rollback(xid int64) error {
+	entries, err := dm.undo.take(xid)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		var err error
+		if entries[i].insert {
+			err = dm.freeRecord(xid, entries[i].offset, entries[i].space)
+		} else {
+			err = dm.restoreRecord(xid, entries[i].offset, entries[i].old)
+		}
+		if err != nil {
+			// 没有写回的记录放回去，留给恢复流程处理
+			dm.undo.putBack(xid, entries[:i+1])
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRecord 不记日志地把 data 写到 offset 处的记录中，长度必须与原记录相同，用于恢复时重做日志
+func (dm *DataManagerImpl) writeRecord(offset int64, data []byte) error {
+	page, err := dm.pc.GetPage(offset / common.PageSize)
+	if err != nil {
+		return err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	off, size, err := locateRecord(page.Data(), offset)
+	if err == nil && size != len(data) {
+		err = fmt.Errorf("%w: record at %d has %d bytes, got %d", ErrSizeMismatch, offset, size, len(data))
+	}
+	if err != nil {
+		page.Unlock()
+		return err
+	}
+	copy(page.Data()[off+lenRecordSize:], data)
+	page.Unlock()
+	page.SetDirty(true)
+	return nil
+}
+
+// freeRecord 把 offset 处的记录占用的空间交还给 PageIndex 并作为 xid 的释放记录写入日志，记录的内容保持不变。
+// space 不为 0 时交还从 offset 开始的 space 字节
+func (dm *DataManagerImpl) freeRecord(xid int64, offset int64, space int) error {
+	page, err := dm.pc.GetPage(offset / common.PageSize)
+	if err != nil {
+		return err
+	}
+	defer dm.pc.ReleasePage(page)
+
+	page.Lock()
+	off, size, err := locateRecord(page.Data(), offset)
+	if err == nil {
+		err = dm.appendLog(xid, encodeFreeLog(offset))
+	}
+	page.Unlock()
+	if err != nil {
+		return err
+	}
+	if space == 0 {
+		space = lenRecordSize + size
+	}
+	dm.pageIndex.Add(page.PageNumber(), off, space)
+	return nil
+}
+
+// DataManagerImpl 作为事务管理器的 Observer，在事务结束时处理它的 undo 记录
+
+// OnBegin 实现 tm.Observer
+func (dm *DataManagerImpl) OnBegin(xid int64) {}
+
+// OnCommit 实现 tm.Observer，提交的事务不再需要 undo 记录
+func (dm *DataManagerImpl) OnCommit(xid int64) {
+	dm.undo.discard(xid)
+}
+
+// OnAbort 实现 tm.Observer，把事务的更新回滚。
+// 回滚失败的记录留在内存中，未能回滚的修改要等下次打开时由 Recover 根据日志撤销
+func (dm *DataManagerImpl) OnAbort(xid int64) {
+	dm.rollback(xid)
+}
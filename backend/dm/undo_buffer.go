@@ -0,0 +1,222 @@
+package dm
+
+import (
+	"container/list"
+	"encoding/binary"
+	"os"
+	"sync"
+)
+
+// 溢出文件中每条 undo 记录的格式: [offset: 8 字节][size: 4 字节][insert: 1 字节][old: size 字节]。
+// 插入的记录没有 old，size 字段保存 undoEntry.space。
+// 溢出文件只是内存的延伸，崩溃后由日志重建，所以不需要刷盘和校验
+const (
+	UndoSuffix = ".undo"
+	// DefaultUndoBudget 是 undo 记录在内存中最多占用的字节数
+	DefaultUndoBudget = 1 << 20
+
+	lenSpillHeader = 13
+)
+
+// undoBuffer 按 XID 保存 undo 记录。内存中的记录超过 budget 字节时，
+// 按写入顺序把最早的记录溢出到文件，因此每个 XID 溢出的记录总是比它留在内存中的记录更早。
+// 没有溢出文件(file 为 nil)时所有记录都留在内存中
+type undoBuffer struct {
+	lock   sync.Mutex
+	budget int
+	size   int
+
+	// mem 按写入顺序保存所有在内存中的记录，memByXid 指向其中属于每个 XID 的元素
+	mem      *list.List
+	memByXid map[int64][]*list.Element
+
+	// spilled 按写入顺序保存每个 XID 溢出到文件的记录，nSpilled 是它们的总数
+	file     *os.File
+	fileSize int64
+	spilled  map[int64][]spillRef
+	nSpilled int
+}
+
+type memUndo struct {
+	xid   int64
+	entry undoEntry
+}
+
+// spillRef 指向溢出文件中的一条记录
+type spillRef struct {
+	pos  int64
+	size int
+}
+
+func newUndoBuffer(file *os.File, budget int) *undoBuffer {
+	return &undoBuffer{
+		budget:   budget,
+		mem:      list.New(),
+		memByXid: make(map[int64][]*list.Element),
+		file:     file,
+		spilled:  make(map[int64][]spillRef),
+	}
+}
+
+func entrySize(entry undoEntry) int {
+	return lenLogOffset + len(entry.old)
+}
+
+// push 记录 xid 的一次更新，超过预算时把最早的记录溢出到文件
+func (b *undoBuffer) push(xid int64, entry undoEntry) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	elem := b.mem.PushBack(&memUndo{xid: xid, entry: entry})
+	b.memByXid[xid] = append(b.memByXid[xid], elem)
+	b.size += entrySize(entry)
+	return b.spill()
+}
+
+// spill 把最早的记录写入溢出文件直到内存占用不超过预算，调用者需持有锁
+func (b *undoBuffer) spill() error {
+	for b.file != nil && b.size > b.budget && b.mem.Len() > 0 {
+		elem := b.mem.Front()
+		u := elem.Value.(*memUndo)
+
+		buf := make([]byte, lenSpillHeader+len(u.entry.old))
+		binary.BigEndian.PutUint64(buf, uint64(u.entry.offset))
+		binary.BigEndian.PutUint32(buf[lenLogOffset:], uint32(len(u.entry.old)))
+		if u.entry.insert {
+			binary.BigEndian.PutUint32(buf[lenLogOffset:], uint32(u.entry.space))
+			buf[lenSpillHeader-1] = 1
+		}
+		copy(buf[lenSpillHeader:], u.entry.old)
+		_, err := b.file.WriteAt(buf, b.fileSize)
+		if err != nil {
+			return err
+		}
+
+		b.spilled[u.xid] = append(b.spilled[u.xid], spillRef{pos: b.fileSize, size: len(buf)})
+		b.fileSize += int64(len(buf))
+		b.nSpilled++
+		b.removeMem(u.xid, elem)
+	}
+	return nil
+}
+
+// removeMem 从内存中删除 xid 最早的记录 elem，调用者需持有锁
+func (b *undoBuffer) removeMem(xid int64, elem *list.Element) {
+	u := b.mem.Remove(elem).(*memUndo)
+	b.size -= entrySize(u.entry)
+	elems := b.memByXid[xid][1:]
+	if len(elems) == 0 {
+		delete(b.memByXid, xid)
+		return
+	}
+	b.memByXid[xid] = elems
+}
+
+// count 返回 xid 现有的 undo 记录条数
+func (b *undoBuffer) count(xid int64) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.spilled[xid]) + len(b.memByXid[xid])
+}
+
+// take 按写入顺序取出并删除 xid 的所有 undo 记录
+func (b *undoBuffer) take(xid int64) ([]undoEntry, error) {
+	return b.takeAfter(xid, 0)
+}
+
+// takeAfter 按写入顺序取出并删除 xid 的前 n 条之后的 undo 记录，前 n 条保留
+func (b *undoBuffer) takeAfter(xid int64, n int) ([]undoEntry, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// 溢出的记录比内存中的更早，先跳过溢出的记录，再跳过内存中的记录
+	spilled := b.spilled[xid]
+	keepSpilled := n
+	if keepSpilled > len(spilled) {
+		keepSpilled = len(spilled)
+	}
+	var entries []undoEntry
+	for _, ref := range spilled[keepSpilled:] {
+		buf := make([]byte, ref.size)
+		_, err := b.file.ReadAt(buf, ref.pos)
+		if err != nil {
+			return nil, err
+		}
+		entry := undoEntry{
+			offset: int64(binary.BigEndian.Uint64(buf)),
+			insert: buf[lenSpillHeader-1] == 1,
+		}
+		if entry.insert {
+			entry.space = int(binary.BigEndian.Uint32(buf[lenLogOffset:]))
+		} else {
+			entry.old = buf[lenSpillHeader:]
+		}
+		entries = append(entries, entry)
+	}
+
+	mem := b.memByXid[xid]
+	keepMem := n - keepSpilled
+	if keepMem > len(mem) {
+		keepMem = len(mem)
+	}
+	for _, elem := range mem[keepMem:] {
+		u := b.mem.Remove(elem).(*memUndo)
+		b.size -= entrySize(u.entry)
+		entries = append(entries, u.entry)
+	}
+	if keepMem == 0 {
+		delete(b.memByXid, xid)
+	} else {
+		b.memByXid[xid] = mem[:keepMem]
+	}
+
+	b.nSpilled -= len(spilled) - keepSpilled
+	if keepSpilled == 0 {
+		delete(b.spilled, xid)
+	} else {
+		b.spilled[xid] = spilled[:keepSpilled]
+	}
+	// 没有溢出的记录时清空文件，避免文件一直增长
+	if b.nSpilled == 0 && b.fileSize > 0 {
+		err := b.file.Truncate(0)
+		if err != nil {
+			return nil, err
+		}
+		b.fileSize = 0
+	}
+	return entries, nil
+}
+
+// discard 删除 xid 的所有 undo 记录，用于事务提交
+func (b *undoBuffer) discard(xid int64) error {
+	_, err := b.take(xid)
+	return err
+}
+
+// putBack 把回滚失败的记录放回内存，它们比 xid 现有的记录更早
+func (b *undoBuffer) putBack(xid int64, entries []undoEntry) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	elems := make([]*list.Element, len(entries), len(entries)+len(b.memByXid[xid]))
+	for i := len(entries) - 1; i >= 0; i-- {
+		elems[i] = b.mem.PushFront(&memUndo{xid: xid, entry: entries[i]})
+		b.size += entrySize(entries[i])
+	}
+	b.memByXid[xid] = append(elems, b.memByXid[xid]...)
+	return b.spill()
+}
+
+// close 关闭并删除溢出文件
+func (b *undoBuffer) close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if rmErr := os.Remove(b.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package dm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func newTestUndoBuffer(t *testing.T, budget int) *undoBuffer {
+	t.Helper()
+	file, err := os.Create("test_file" + UndoSuffix)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return newUndoBuffer(file, budget)
+}
+
+func TestUndoBufferSpillAtBudget(t *testing.T) {
+	entries := []undoEntry{
+		{offset: 10, old: []byte("aaaa")},
+		{offset: 20, old: []byte("bbbb")},
+		{offset: 30, old: []byte("cccc")},
+	}
+	// 预算正好放下两条记录
+	b := newTestUndoBuffer(t, 2*entrySize(entries[0]))
+	defer b.close()
+
+	b.push(1, entries[0])
+	b.push(1, entries[1])
+	if b.nSpilled != 0 || b.fileSize != 0 {
+		t.Errorf("Expected no spill at the budget, got %d records, %d bytes", b.nSpilled, b.fileSize)
+	}
+
+	// 超过预算一个字节就会溢出最早的记录
+	b.push(2, undoEntry{offset: 40, old: []byte("d")})
+	if b.nSpilled != 1 || len(b.spilled[1]) != 1 || b.size != entrySize(entries[1])+lenLogOffset+1 {
+		t.Errorf("Expected the oldest record to spill, got %d spilled, size %d", b.nSpilled, b.size)
+	}
+	b.push(1, entries[2])
+
+	got, err := b.take(1)
+	if err != nil {
+		t.Fatalf("take failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("Expected %v in order, got %v", entries, got)
+	}
+	if _, err := b.take(2); err != nil {
+		t.Fatalf("take failed: %v", err)
+	}
+	if b.nSpilled != 0 || b.fileSize != 0 || b.size != 0 || b.mem.Len() != 0 {
+		t.Errorf("Expected an empty buffer, got %d spilled, %d file bytes, size %d", b.nSpilled, b.fileSize, b.size)
+	}
+}
+
+func TestUndoBufferPutBack(t *testing.T) {
+	b := newTestUndoBuffer(t, 1<<10)
+	defer b.close()
+
+	b.push(1, undoEntry{offset: 1, old: []byte("x")})
+	b.push(1, undoEntry{offset: 2, old: []byte("y")})
+	entries, _ := b.take(1)
+	b.putBack(1, entries)
+
+	got, _ := b.take(1)
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("Expected %v after putBack, got %v", entries, got)
+	}
+}
+
+func TestRollbackAcrossMemoryAndDisk(t *testing.T) {
+	path := "test_file"
+	defer removeFiles(path)
+
+	dm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer dm.Close()
+	tm := dm.TransactionManager()
+
+	xid, _ := tm.Begin()
+	var offsets []int64
+	for i := 0; i < 8; i++ {
+		offset, _ := dm.Insert(xid, []byte(fmt.Sprintf("value %d", i)))
+		offsets = append(offsets, offset)
+	}
+	tm.Commit(xid)
+
+	// 预算只够在内存中保留两条记录，其余的都溢出到文件
+	dm.undo.budget = 2 * (lenLogOffset + len("value 0"))
+	xid, _ = tm.Begin()
+	for i, offset := range offsets {
+		if err := dm.Update(xid, offset, []byte(fmt.Sprintf("later %d", i))); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+	// 再更新一次第一条，回滚后应该回到最初的值而不是第一次更新的值
+	dm.Update(xid, offsets[0], []byte("again 0"))
+	if dm.undo.nSpilled == 0 || dm.undo.mem.Len() == 0 {
+		t.Fatalf("Expected records both in memory and on disk, got %d spilled, %d in memory", dm.undo.nSpilled, dm.undo.mem.Len())
+	}
+	tm.Abort(xid)
+
+	xid, _ = tm.Begin()
+	for i, offset := range offsets {
+		data, err := dm.Read(xid, offset)
+		if err != nil || !bytes.Equal(data, []byte(fmt.Sprintf("value %d", i))) {
+			t.Errorf("Expected value %d after abort, got %q, %v", i, data, err)
+		}
+	}
+}
+
+func TestUndoBufferSpillKeepsInsertFlag(t *testing.T) {
+	b := newTestUndoBuffer(t, 0)
+	defer b.close()
+
+	entries := []undoEntry{{offset: 10, insert: true}, {offset: 20, old: []byte("old")}, {offset: 30, insert: true, space: 24}}
+	for _, entry := range entries {
+		b.push(1, entry)
+	}
+	if b.nSpilled != 3 {
+		t.Fatalf("Expected all records to spill, got %d", b.nSpilled)
+	}
+	got, err := b.take(1)
+	if err != nil {
+		t.Fatalf("take failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("Expected %v, got %v", entries, got)
+	}
+}
+
+func TestUndoBufferTakeAfter(t *testing.T) {
+	entries := []undoEntry{
+		{offset: 10, old: []byte("a")},
+		{offset: 20, old: []byte("b")},
+		{offset: 30, old: []byte("c")},
+		{offset: 40, old: []byte("d")},
+	}
+	// 预算只放得下一条记录，前三条溢出到文件
+	b := newTestUndoBuffer(t, entrySize(entries[0]))
+	defer b.close()
+	for _, entry := range entries {
+		b.push(1, entry)
+	}
+
+	// 保留第一条溢出的记录，取出其余的溢出记录和内存中的记录
+	got, err := b.takeAfter(1, 1)
+	if err != nil {
+		t.Fatalf("takeAfter failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, entries[1:]) {
+		t.Errorf("Expected %v, got %v", entries[1:], got)
+	}
+	if b.count(1) != 1 || b.nSpilled != 1 {
+		t.Errorf("Expected 1 spilled record left, got %d (%d spilled)", b.count(1), b.nSpilled)
+	}
+	got, _ = b.take(1)
+	if !reflect.DeepEqual(got, entries[:1]) {
+		t.Errorf("Expected %v, got %v", entries[:1], got)
+	}
+}
@@ -0,0 +1,88 @@
+// Package metrics 把页面缓存和事务管理器的统计信息导出为 Prometheus 指标。
+// 它是单独的包，只有使用它的程序才依赖 Prometheus 客户端，common 和 tm 不依赖它
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"mydb-go/backend/common"
+	"mydb-go/backend/tm"
+)
+
+// CacheStatser 是提供缓存统计信息的对象，*common.AbstractCache、*common.ShardedCache 和 *common.PageCache 都实现了它
+type CacheStatser interface {
+	Stats() common.CacheStats
+}
+
+// TMStatser 是提供事务管理器统计信息的对象，*tm.TransactionManagerImpl 实现了它
+type TMStatser interface {
+	Stats() tm.Stats
+}
+
+var (
+	cacheHitsDesc = prometheus.NewDesc("mydb_cache_hits_total",
+		"Number of cache lookups served from the cache.", nil, nil)
+	cacheMissesDesc = prometheus.NewDesc("mydb_cache_misses_total",
+		"Number of cache lookups that called the loader.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("mydb_cache_evictions_total",
+		"Number of entries evicted from the cache.", nil, nil)
+	cacheEntriesDesc = prometheus.NewDesc("mydb_cache_entries",
+		"Number of entries currently cached.", nil, nil)
+
+	tmBeginsDesc = prometheus.NewDesc("mydb_tm_begins_total",
+		"Number of transactions begun.", nil, nil)
+	tmCommitsDesc = prometheus.NewDesc("mydb_tm_commits_total",
+		"Number of transactions committed.", nil, nil)
+	tmAbortsDesc = prometheus.NewDesc("mydb_tm_aborts_total",
+		"Number of transactions aborted.", nil, nil)
+	tmActiveDesc = prometheus.NewDesc("mydb_tm_active_transactions",
+		"Number of transactions currently active.", nil, nil)
+)
+
+// Collector 是一个 prometheus.Collector，每次被抓取时调用一次 Stats 并导出结果，自己不保存任何状态
+type Collector struct {
+	cache CacheStatser
+	tm    TMStatser
+}
+
+// NewCollector 创建导出 cache 和 t 的统计信息的 Collector，其中一个为 nil 时不导出它的指标
+func NewCollector(cache CacheStatser, t TMStatser) *Collector {
+	return &Collector{cache: cache, tm: t}
+}
+
+// Register 创建 Collector 并把它注册到 reg
+func Register(reg prometheus.Registerer, cache CacheStatser, t TMStatser) error {
+	return reg.Register(NewCollector(cache, t))
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	if c.cache != nil {
+		ch <- cacheHitsDesc
+		ch <- cacheMissesDesc
+		ch <- cacheEvictionsDesc
+		ch <- cacheEntriesDesc
+	}
+	if c.tm != nil {
+		ch <- tmBeginsDesc
+		ch <- tmCommitsDesc
+		ch <- tmAbortsDesc
+		ch <- tmActiveDesc
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	if c.cache != nil {
+		stats := c.cache.Stats()
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+		ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(stats.Count))
+	}
+	if c.tm != nil {
+		stats := c.tm.Stats()
+		ch <- prometheus.MustNewConstMetric(tmBeginsDesc, prometheus.CounterValue, float64(stats.Begins))
+		ch <- prometheus.MustNewConstMetric(tmCommitsDesc, prometheus.CounterValue, float64(stats.Commits))
+		ch <- prometheus.MustNewConstMetric(tmAbortsDesc, prometheus.CounterValue, float64(stats.Aborts))
+		ch <- prometheus.MustNewConstMetric(tmActiveDesc, prometheus.GaugeValue, float64(stats.Active))
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"mydb-go/backend/common"
+	"mydb-go/backend/tm"
+)
+
+func TestCollector(t *testing.T) {
+	path := "test_file"
+	txm, err := tm.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer os.Remove(path + tm.XidSuffix)
+	defer txm.Close()
+
+	pc := common.NewMemPageCache(1)
+	defer pc.Close()
+	p0, _ := pc.NewPage(nil)
+	p1, _ := pc.NewPage(nil)
+	for _, pgno := range []int64{p0, p0, p1} {
+		page, err := pc.GetPage(pgno)
+		if err != nil {
+			t.Fatalf("GetPage failed: %v", err)
+		}
+		pc.ReleasePage(page)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := Register(reg, pc, txm); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	committed, _ := txm.Begin()
+	aborted, _ := txm.Begin()
+	txm.Begin()
+	txm.Commit(committed)
+	txm.Abort(aborted)
+
+	expected := `
+# HELP mydb_cache_entries Number of entries currently cached.
+# TYPE mydb_cache_entries gauge
+mydb_cache_entries 1
+# HELP mydb_cache_evictions_total Number of entries evicted from the cache.
+# TYPE mydb_cache_evictions_total counter
+mydb_cache_evictions_total 1
+# HELP mydb_cache_hits_total Number of cache lookups served from the cache.
+# TYPE mydb_cache_hits_total counter
+mydb_cache_hits_total 1
+# HELP mydb_cache_misses_total Number of cache lookups that called the loader.
+# TYPE mydb_cache_misses_total counter
+mydb_cache_misses_total 2
+# HELP mydb_tm_aborts_total Number of transactions aborted.
+# TYPE mydb_tm_aborts_total counter
+mydb_tm_aborts_total 1
+# HELP mydb_tm_active_transactions Number of transactions currently active.
+# TYPE mydb_tm_active_transactions gauge
+mydb_tm_active_transactions 1
+# HELP mydb_tm_begins_total Number of transactions begun.
+# TYPE mydb_tm_begins_total counter
+mydb_tm_begins_total 3
+# HELP mydb_tm_commits_total Number of transactions committed.
+# TYPE mydb_tm_commits_total counter
+mydb_tm_commits_total 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected)); err != nil {
+		t.Errorf("Unexpected metrics: %v", err)
+	}
+
+	// 每次抓取都重新读取统计信息
+	txm.Begin()
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP mydb_tm_begins_total Number of transactions begun.
+# TYPE mydb_tm_begins_total counter
+mydb_tm_begins_total 4
+`), "mydb_tm_begins_total"); err != nil {
+		t.Errorf("Metrics not sampled on scrape: %v", err)
+	}
+}
+
+func TestCollectorCacheOnly(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	if err := Register(reg, common.NewMemPageCache(1), nil); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	n, err := testutil.GatherAndCount(reg)
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("Expected only the 4 cache metrics, got %d", n)
+	}
+}
@@ -0,0 +1,25 @@
+package tm
+
+import "io"
+
+// Snapshot 把 XID 文件当前的内容完整地写入 w，得到的副本可以作为独立的 XID 文件打开，用于在线备份。
+// 与 ActiveSnapshot 不同，它复制的是整个文件而不是活跃事务集合。
+// 复制期间持有 counterLock，新的 Begin 会等待复制结束；Commit 和 Abort 不受影响，
+// 复制期间结束的事务在副本中可能仍是活跃状态，但副本的文件头与状态区总是一致的
+func (t *TransactionManagerImpl) Snapshot(w io.Writer) error {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	err := t.file.Sync()
+	if err != nil {
+		return err
+	}
+	length := t.getXidPosition(t.xidCounter.Load() + 1)
+	_, err = io.Copy(w, io.NewSectionReader(t.file, 0, length))
+	return err
+}
@@ -0,0 +1,56 @@
+package tm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotCopyOpensStandalone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	statuses := make(map[int64]Status)
+	for i := 0; i < 20; i++ {
+		xid := mustBegin(t, tm)
+		switch i % 3 {
+		case 0:
+			tm.Commit(xid)
+		case 1:
+			tm.Abort(xid)
+		}
+		statuses[xid], _ = tm.GetStatus(xid)
+	}
+
+	var buf bytes.Buffer
+	if err := tm.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	// 备份之后的修改不影响副本
+	mustBegin(t, tm)
+
+	backup := filepath.Join(t.TempDir(), "test_backup")
+	if err := os.WriteFile(backup+XidSuffix, buf.Bytes(), 0666); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	copied, err := Open(backup)
+	if err != nil {
+		t.Fatalf("Open of the snapshot failed: %v", err)
+	}
+	defer copied.Close()
+
+	if copied.XidCounter() != 20 {
+		t.Errorf("Expected counter 20 in the snapshot, got %d", copied.XidCounter())
+	}
+	for xid, want := range statuses {
+		got, err := copied.GetStatus(xid)
+		if err != nil || got != want {
+			t.Errorf("Expected xid %d to be %v in the snapshot, got %v, %v", xid, want, got, err)
+		}
+	}
+}
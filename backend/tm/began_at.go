@@ -0,0 +1,87 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBeginTimeUnknown 表示不知道 xid 的开启时间: xid 已经结束，或者它在本次 Open 之前开启
+var ErrBeginTimeUnknown = errors.New("begin time unknown")
+
+// beginTime 是一个活跃事务的开启时间，known 为 false 时 at 是打开文件的时间，只是开启时间的上界
+type beginTime struct {
+	at    time.Time
+	known bool
+}
+
+// WithClock 设置记录事务开启时间所用的时钟，默认为 time.Now
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// seedBeganAt 把打开时仍然活跃或已准备的事务标记为开启时间未知，它们的开启时间按打开文件的时间估计
+func (t *TransactionManagerImpl) seedBeganAt() error {
+	t.counterLock.Lock()
+	active, err := t.collectXIDs(FieldTranActive, FieldTranPrepared)
+	t.counterLock.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, xid := range active {
+		t.markBegan(xid, false)
+	}
+	return nil
+}
+
+// markBegan 记录 xid 在当前时间开启，known 为 false 表示真正的开启时间更早
+func (t *TransactionManagerImpl) markBegan(xid int64, known bool) {
+	now := t.clock()
+	t.beganLock.Lock()
+	defer t.beganLock.Unlock()
+	if t.beganAt == nil {
+		t.beganAt = make(map[int64]beginTime)
+	}
+	t.beganAt[xid] = beginTime{at: now, known: known}
+}
+
+// markEnded 在事务结束时删除它的开启时间
+func (t *TransactionManagerImpl) markEnded(xid int64) {
+	t.beganLock.Lock()
+	defer t.beganLock.Unlock()
+	delete(t.beganAt, xid)
+}
+
+// BeganAt 返回活跃事务的开启时间。只记录活跃事务的开启时间，
+// 已经结束的事务和本次 Open 之前开启的事务返回 ErrBeginTimeUnknown
+func (t *TransactionManagerImpl) BeganAt(xid int64) (time.Time, error) {
+	t.beganLock.Lock()
+	defer t.beganLock.Unlock()
+	began, ok := t.beganAt[xid]
+	if !ok || !began.known {
+		return time.Time{}, fmt.Errorf("%w: xid %d", ErrBeginTimeUnknown, xid)
+	}
+	return began.at, nil
+}
+
+// OldestActive 返回开启最早的活跃事务和它已经运行的时长，没有活跃事务时返回 (0, 0)。
+// 开启时间未知的事务按打开文件的时间计算，返回的时长是它实际运行时长的下界
+func (t *TransactionManagerImpl) OldestActive() (int64, time.Duration) {
+	now := t.clock()
+	t.beganLock.Lock()
+	defer t.beganLock.Unlock()
+
+	var oldest int64
+	var oldestAt time.Time
+	for xid, began := range t.beganAt {
+		if oldest == 0 || began.at.Before(oldestAt) || (began.at.Equal(oldestAt) && xid < oldest) {
+			oldest, oldestAt = xid, began.at
+		}
+	}
+	if oldest == 0 {
+		return 0, 0
+	}
+	return oldest, now.Sub(oldestAt)
+}
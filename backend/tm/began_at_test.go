@@ -0,0 +1,86 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// stepClock 是可以手动推进的时钟
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	return c.now
+}
+
+func TestBeganAtAndOldestActive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	tm, err := Create(path, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	if xid, age := tm.OldestActive(); xid != 0 || age != 0 {
+		t.Errorf("Expected no active transaction, got (%d, %v)", xid, age)
+	}
+
+	xid1 := mustBegin(t, tm)
+	clock.now = clock.now.Add(10 * time.Second)
+	xid2 := mustBegin(t, tm)
+	clock.now = clock.now.Add(5 * time.Second)
+
+	began, err := tm.BeganAt(xid2)
+	if err != nil || !began.Equal(time.Unix(1010, 0)) {
+		t.Errorf("Expected xid %d to begin at 1010, got (%v, %v)", xid2, began, err)
+	}
+	if xid, age := tm.OldestActive(); xid != xid1 || age != 15*time.Second {
+		t.Errorf("Expected xid %d aged 15s, got (%d, %v)", xid1, xid, age)
+	}
+
+	// 最老的事务结束后，次老的事务成为最老的
+	tm.Commit(xid1)
+	if xid, age := tm.OldestActive(); xid != xid2 || age != 5*time.Second {
+		t.Errorf("Expected xid %d aged 5s, got (%d, %v)", xid2, xid, age)
+	}
+	if _, err := tm.BeganAt(xid1); !errors.Is(err, ErrBeginTimeUnknown) {
+		t.Errorf("Expected ErrBeginTimeUnknown for a committed xid, got %v", err)
+	}
+	tm.Abort(xid2)
+	if xid, _ := tm.OldestActive(); xid != 0 {
+		t.Errorf("Expected no active transaction, got %d", xid)
+	}
+}
+
+func TestBeganAtUnknownAfterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	dangling := mustBegin(t, tm)
+	tm.Close()
+
+	clock := &stepClock{now: time.Unix(2000, 0)}
+	tm2, err := Open(path, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm2.Close()
+
+	if _, err := tm2.BeganAt(dangling); !errors.Is(err, ErrBeginTimeUnknown) {
+		t.Errorf("Expected ErrBeginTimeUnknown for a transaction from before Open, got %v", err)
+	}
+
+	// 开启时间未知的事务按打开的时间计算年龄
+	clock.now = clock.now.Add(time.Minute)
+	mustBegin(t, tm2)
+	clock.now = clock.now.Add(time.Minute)
+	if xid, age := tm2.OldestActive(); xid != dangling || age != 2*time.Minute {
+		t.Errorf("Expected xid %d aged at least 2m, got (%d, %v)", dangling, xid, age)
+	}
+}
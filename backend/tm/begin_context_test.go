@@ -0,0 +1,70 @@
+package tm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBeginContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid, err := tm.BeginContext(context.Background())
+	if err != nil {
+		t.Fatalf("BeginContext failed: %v", err)
+	}
+	if !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Xid %d should be active", xid)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tm.BeginContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if _, err := tm.BeginContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if tm.XidCounter() != xid {
+		t.Errorf("Cancelled begins should not allocate xids, counter is %d", tm.XidCounter())
+	}
+	if stats := tm.Stats(); stats.Begins != 1 {
+		t.Errorf("Expected 1 begin, got %+v", stats)
+	}
+}
+
+func TestBeginContextCancelledWhileWaiting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	// 模拟一个持有 counterLock 的长时间操作
+	tm.counterLock.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := tm.BeginContext(ctx)
+		done <- err
+	}()
+	cancel()
+	tm.counterLock.Unlock()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if tm.XidCounter() != 0 {
+		t.Errorf("Cancelled begin should not allocate an xid, counter is %d", tm.XidCounter())
+	}
+}
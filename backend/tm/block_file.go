@@ -0,0 +1,35 @@
+package tm
+
+import (
+	"io"
+	"os"
+)
+
+// BlockFile 是事务管理器读写 XID 文件所需的最小接口，*os.File 实现了它。
+// 可以通过 WithOpenFile 换成其他实现，例如在测试中注入 I/O 错误
+type BlockFile interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Seeker
+	Sync() error
+	Close() error
+	Truncate(size int64) error
+}
+
+// OpenFileFunc 按 os.OpenFile 的参数打开一个 BlockFile
+type OpenFileFunc func(name string, flag int, perm os.FileMode) (BlockFile, error)
+
+// openOSFile 是默认的 OpenFileFunc，打开本地文件
+func openOSFile(name string, flag int, perm os.FileMode) (BlockFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+// WithOpenFile 设置打开 XID 文件的函数，默认打开本地文件。
+// 只有返回 *os.File 时才会加上建议锁，Checkpoint 和迁移旧文件也只支持 *os.File，其他实现返回 ErrReplaceUnsupported。
+// 设置之后不再检查 XID 文件所在的目录是否存在
+func WithOpenFile(open OpenFileFunc) Option {
+	return func(o *options) {
+		o.openFile = open
+		o.customOpen = true
+	}
+}
@@ -0,0 +1,234 @@
+package tm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultFile 包装一个 BlockFile，设置了对应的开关时读、写或刷盘返回 errInjected
+type faultFile struct {
+	BlockFile
+
+	lock      sync.Mutex
+	failRead  bool
+	failWrite bool
+	failSync  bool
+	// failHeader 只让写文件头的操作失败，模拟写完状态之后、写计数器之前崩溃
+	failHeader bool
+}
+
+func (f *faultFile) set(read, write, sync bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.failRead, f.failWrite, f.failSync = read, write, sync
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	f.lock.Lock()
+	fail := f.failRead
+	f.lock.Unlock()
+	if fail {
+		// 模拟读到一半失败
+		n := len(p) / 2
+		f.BlockFile.ReadAt(p[:n], off)
+		return n, errInjected
+	}
+	return f.BlockFile.ReadAt(p, off)
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	f.lock.Lock()
+	fail := f.failWrite || f.failHeader && off < LenXidHeaderLength
+	f.lock.Unlock()
+	if fail {
+		return 0, errInjected
+	}
+	return f.BlockFile.WriteAt(p, off)
+}
+
+func (f *faultFile) Sync() error {
+	f.lock.Lock()
+	fail := f.failSync
+	f.lock.Unlock()
+	if fail {
+		return errInjected
+	}
+	return f.BlockFile.Sync()
+}
+
+// createWrapped 创建一个事务管理器，它的 XID 文件由 wrap 包装
+func createWrapped(t *testing.T, path string, wrap func(BlockFile) BlockFile, opts ...Option) *TransactionManagerImpl {
+	t.Helper()
+	open := func(name string, flag int, perm os.FileMode) (BlockFile, error) {
+		file, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return wrap(file), nil
+	}
+	tm, err := Create(path, append(opts, WithOpenFile(open))...)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return tm
+}
+
+// createFaulty 创建一个 XID 文件由 faultFile 包装的事务管理器
+func createFaulty(t *testing.T, path string, opts ...Option) (*TransactionManagerImpl, *faultFile) {
+	t.Helper()
+	var fault *faultFile
+	tm := createWrapped(t, path, func(file BlockFile) BlockFile {
+		fault = &faultFile{BlockFile: file}
+		return fault
+	}, opts...)
+	return tm, fault
+}
+
+func TestCommitSyncFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, fault := createFaulty(t, path)
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	fault.set(false, false, true)
+	if err := tm.Commit(xid); !errors.Is(err, errInjected) {
+		t.Fatalf("Expected the sync failure to be returned, got %v", err)
+	}
+	if stats := tm.Stats(); stats.Commits != 0 || stats.Active != 1 {
+		t.Errorf("A failed commit should not be counted, got %+v", stats)
+	}
+	select {
+	case ev := <-tm.Events():
+		t.Errorf("Unexpected event %+v for a failed commit", ev)
+	default:
+	}
+
+	// 故障消失之后可以重试
+	fault.set(false, false, false)
+	if err := tm.Commit(xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if !checkStatus(t, tm.IsCommitted, xid) {
+		t.Errorf("Expected xid %d to be committed", xid)
+	}
+}
+
+func TestBlockFileFaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, fault := createFaulty(t, path)
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	fault.set(false, true, false)
+	if _, err := tm.Begin(); !errors.Is(err, errInjected) {
+		t.Errorf("Expected Begin to return the write failure, got %v", err)
+	}
+	if err := tm.Abort(xid); !errors.Is(err, errInjected) {
+		t.Errorf("Expected Abort to return the write failure, got %v", err)
+	}
+
+	fault.set(true, false, false)
+	_, err := tm.GetStatus(xid)
+	var xerr *XIDError
+	if !errors.As(err, &xerr) || xerr.Op != "read status" || !errors.Is(err, errInjected) {
+		t.Errorf("Expected a short read to return an XIDError, got %v", err)
+	}
+
+	fault.set(false, false, false)
+	if !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Expected xid %d to stay active", xid)
+	}
+}
+
+// memFile 是完全在内存中的 BlockFile
+type memFile struct {
+	lock sync.Mutex
+	data []byte
+	pos  int64
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if off > int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	return copy(f.data[off:], p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if size < int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Sync() error  { return nil }
+func (f *memFile) Close() error { return nil }
+
+func TestCheckpointNonOSFile(t *testing.T) {
+	files := make(map[string]*memFile)
+	open := func(name string, flag int, perm os.FileMode) (BlockFile, error) {
+		if files[name] == nil {
+			files[name] = &memFile{}
+		}
+		return files[name], nil
+	}
+
+	// 目录不存在也可以创建，路径只交给 open 解释
+	tm, err := Create(filepath.Join(t.TempDir(), "missing", "test_file"), WithOpenFile(open))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	xid := mustBegin(t, tm)
+	if err := tm.Commit(xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if _, err := tm.Checkpoint(); !errors.Is(err, ErrReplaceUnsupported) {
+		t.Fatalf("Expected ErrReplaceUnsupported, got %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected no temporary file to be opened, got %d files", len(files))
+	}
+	if tm.BaseXID() != 0 || !checkStatus(t, tm.IsCommitted, xid) {
+		t.Errorf("Expected the failed checkpoint to leave the file unchanged")
+	}
+}
@@ -0,0 +1,68 @@
+package tm
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// ChangeRecordSize 是变更流中一条记录的长度: xid(8) + status(1) + timestamp(8)
+const ChangeRecordSize = 17
+
+// Change 表示变更流中的一条事务状态变更记录
+type Change struct {
+	Xid       int64
+	Status    byte
+	Timestamp int64 // UnixNano
+}
+
+// SetChangeStream 设置变更流，之后每次状态变更都会以二进制记录追加写入 w，传入 nil 关闭变更流
+func (t *TransactionManagerImpl) SetChangeStream(w io.Writer) {
+	t.streamLock.Lock()
+	defer t.streamLock.Unlock()
+	t.stream = w
+}
+
+// SetChangeStreamErrorHandler 设置写变更流失败时的回调，写入是尽力而为的，失败不会影响事务本身
+func (t *TransactionManagerImpl) SetChangeStreamErrorHandler(fn func(error)) {
+	t.streamLock.Lock()
+	defer t.streamLock.Unlock()
+	t.onStreamError = fn
+}
+
+func (t *TransactionManagerImpl) emitChange(xid int64, status byte) {
+	t.streamLock.Lock()
+	defer t.streamLock.Unlock()
+	if t.stream == nil {
+		return
+	}
+
+	buf := encodeChange(Change{Xid: xid, Status: status, Timestamp: time.Now().UnixNano()})
+	_, err := t.stream.Write(buf)
+	if err != nil && t.onStreamError != nil {
+		t.onStreamError(err)
+	}
+}
+
+func encodeChange(c Change) []byte {
+	buf := make([]byte, ChangeRecordSize)
+	binary.BigEndian.PutUint64(buf[0:8], uint64(c.Xid))
+	buf[8] = c.Status
+	binary.BigEndian.PutUint64(buf[9:17], uint64(c.Timestamp))
+	return buf
+}
+
+// ReadChange 从变更流中读取一条记录，流结束时返回 io.EOF
+func ReadChange(r io.Reader) (Change, error) {
+	buf := make([]byte, ChangeRecordSize)
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return Change{}, err
+	}
+
+	return Change{
+		Xid:       int64(binary.BigEndian.Uint64(buf[0:8])),
+		Status:    buf[8],
+		Timestamp: int64(binary.BigEndian.Uint64(buf[9:17])),
+	}, nil
+}
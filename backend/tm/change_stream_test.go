@@ -0,0 +1,84 @@
+package tm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestChangeStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	var buf bytes.Buffer
+	tm.SetChangeStream(&buf)
+
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	tm.Commit(xid1)
+	tm.Abort(xid2)
+
+	expected := []Change{
+		{Xid: xid1, Status: FieldTranActive},
+		{Xid: xid2, Status: FieldTranActive},
+		{Xid: xid1, Status: FieldTranCommitted},
+		{Xid: xid2, Status: FieldTranAborted},
+	}
+
+	var last int64
+	for i, want := range expected {
+		got, err := ReadChange(&buf)
+		if err != nil {
+			t.Fatalf("ReadChange %d failed: %v", i, err)
+		}
+		if got.Xid != want.Xid || got.Status != want.Status {
+			t.Errorf("record %d: expected (%d, %d), got (%d, %d)", i, want.Xid, want.Status, got.Xid, got.Status)
+		}
+		if got.Timestamp < last {
+			t.Errorf("record %d: timestamp went backwards", i)
+		}
+		last = got.Timestamp
+	}
+
+	if _, err := ReadChange(&buf); err != io.EOF {
+		t.Errorf("Expected io.EOF after last record, got %v", err)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestChangeStreamErrorHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	var errs int
+	tm.SetChangeStream(failingWriter{})
+	tm.SetChangeStreamErrorHandler(func(err error) {
+		errs++
+	})
+
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+
+	// 写变更流失败不影响事务本身
+	if !checkStatus(t, tm.IsCommitted, xid) {
+		t.Errorf("XID not marked as committed")
+	}
+	if errs != 2 {
+		t.Errorf("Expected 2 stream errors, got %d", errs)
+	}
+}
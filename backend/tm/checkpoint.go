@@ -0,0 +1,70 @@
+package tm
+
+// Checkpoint 丢弃最早的活跃或已准备的事务之前的所有状态来回收空间，返回新的 baseXid。
+// 仍在进行中的只读事务快照里的 XID 也会被保留。
+// 新文件通过临时文件和 rename 替换原文件，崩溃时要么是旧文件要么是新文件。
+// 之后查询 baseXid 及之前的 XID 会返回 ErrXIDCheckpointed
+func (t *TransactionManagerImpl) Checkpoint() (int64, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	active, err := t.collectXIDs(FieldTranActive, FieldTranPrepared)
+	if err != nil {
+		return 0, err
+	}
+	newBase := t.xidCounter.Load()
+	if len(active) > 0 {
+		newBase = active[0] - 1
+	}
+	if oldest, ok := t.oldestReadOnlyXID(); ok && oldest-1 < newBase {
+		newBase = oldest - 1
+	}
+
+	// 持有写锁期间没有任何提交或查询能访问文件
+	t.fileLock.Lock()
+	defer t.fileLock.Unlock()
+	if t.closed {
+		return 0, ErrClosed
+	}
+	if newBase <= t.baseXid {
+		return t.baseXid, nil
+	}
+
+	statuses := make([]byte, (t.xidCounter.Load()-newBase)*XidFieldSize)
+	_, err = t.file.ReadAt(statuses, t.getXidPosition(newBase+1))
+	if err != nil {
+		return 0, err
+	}
+
+	err = t.replaceFile(t.xidCounter.Load(), newBase, statuses)
+	if err != nil {
+		return 0, err
+	}
+	t.baseXid = newBase
+	return newBase, nil
+}
+
+// BaseXID 返回最近一次 Checkpoint 丢弃到的 XID，之前的状态已经不可查询
+func (t *TransactionManagerImpl) BaseXID() int64 {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	return t.baseXid
+}
+
+// oldestReadOnlyXID 返回所有进行中的只读事务快照里最小的 XID
+func (t *TransactionManagerImpl) oldestReadOnlyXID() (int64, bool) {
+	t.readOnlyLock.Lock()
+	defer t.readOnlyLock.Unlock()
+
+	var oldest int64
+	found := false
+	for _, txn := range t.readOnly {
+		for xid := range txn.snapshot {
+			if !found || xid < oldest {
+				oldest = xid
+				found = true
+			}
+		}
+	}
+	return oldest, found
+}
@@ -0,0 +1,135 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_checkpoint")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// 1、2 已提交，3 已取消，4 活跃，5 已提交，6 活跃
+	for i := 0; i < 6; i++ {
+		mustBegin(t, tm)
+	}
+	tm.Commit(1)
+	tm.Commit(2)
+	tm.Abort(3)
+	tm.Commit(5)
+
+	base, err := tm.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if base != 3 || tm.BaseXID() != 3 {
+		t.Fatalf("Expected base xid 3, got %d", base)
+	}
+	if size := fileSize(t, path+XidSuffix); size != LenXidHeaderLength+3*XidFieldSize {
+		t.Errorf("Expected file size %d, got %d", LenXidHeaderLength+3*XidFieldSize, size)
+	}
+
+	mustCheckXID(t, tm, 4, FieldTranActive)
+	mustCheckXID(t, tm, 5, FieldTranCommitted)
+	mustCheckXID(t, tm, 6, FieldTranActive)
+	if _, err := tm.IsCommitted(2); !errors.Is(err, ErrXIDCheckpointed) {
+		t.Errorf("Expected ErrXIDCheckpointed for xid 2, got %v", err)
+	}
+	if ok, err := tm.IsCommitted(SuperXid); !ok || err != nil {
+		t.Errorf("SuperXid should stay committed, got %v, %v", ok, err)
+	}
+
+	// 之后的事务照常工作
+	xid := mustBegin(t, tm)
+	if xid != 7 {
+		t.Errorf("Expected xid 7, got %d", xid)
+	}
+	tm.Commit(4)
+	tm.Abort(6)
+	if err := tm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 重新打开后 baseXid 和状态都被保留
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.BaseXID() != 3 || tm.XidCounter() != 7 {
+		t.Errorf("Expected base 3 and counter 7, got %d and %d", tm.BaseXID(), tm.XidCounter())
+	}
+	mustCheckXID(t, tm, 4, FieldTranCommitted)
+	mustCheckXID(t, tm, 6, FieldTranAborted)
+	mustCheckXID(t, tm, 7, FieldTranActive)
+	active, err := tm.ActiveXIDs()
+	if err != nil || len(active) != 1 || active[0] != 7 {
+		t.Errorf("Expected active xids [7], got %v, %v", active, err)
+	}
+}
+
+func TestCheckpointNoActive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_checkpoint")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.Commit(mustBegin(t, tm))
+	tm.Abort(mustBegin(t, tm))
+
+	base, err := tm.Checkpoint()
+	if err != nil || base != 2 {
+		t.Fatalf("Expected base 2, got %d, %v", base, err)
+	}
+	if size := fileSize(t, path+XidSuffix); size != LenXidHeaderLength {
+		t.Errorf("Expected only the header to remain, got size %d", size)
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed: %v", err)
+	}
+
+	// 没有可以丢弃的状态时不重写文件
+	base, err = tm.Checkpoint()
+	if err != nil || base != 2 {
+		t.Errorf("Expected base to stay 2, got %d, %v", base, err)
+	}
+}
+
+func TestCheckpointKeepsReadOnlySnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_checkpoint")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.Commit(mustBegin(t, tm))
+	xid := mustBegin(t, tm)
+	ro, err := tm.BeginReadOnly()
+	if err != nil {
+		t.Fatalf("BeginReadOnly failed: %v", err)
+	}
+	tm.Commit(xid)
+
+	// 只读事务的快照里还有 xid 2，它的状态不能被丢弃
+	base, err := tm.Checkpoint()
+	if err != nil || base != 1 {
+		t.Fatalf("Expected base 1, got %d, %v", base, err)
+	}
+	mustCheckXID(t, tm, xid, FieldTranCommitted)
+
+	tm.Commit(ro)
+	base, err = tm.Checkpoint()
+	if err != nil || base != 2 {
+		t.Errorf("Expected base 2 after the read-only transaction ends, got %d, %v", base, err)
+	}
+}
@@ -0,0 +1,85 @@
+package tm
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoubleClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := tm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := tm.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Second Close: expected ErrClosed, got %v", err)
+	}
+}
+
+func TestOperationsAfterClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid := mustBegin(t, tm)
+	tm.Close()
+
+	ops := map[string]func() error{
+		"Begin": func() error {
+			_, err := tm.Begin()
+			return err
+		},
+		"BeginReadOnly": func() error {
+			_, err := tm.BeginReadOnly()
+			return err
+		},
+		"Commit": func() error { return tm.Commit(xid) },
+		"Abort":  func() error { return tm.Abort(xid) },
+		"IsActive": func() error {
+			_, err := tm.IsActive(xid)
+			return err
+		},
+		"IsCommitted": func() error {
+			_, err := tm.IsCommitted(xid)
+			return err
+		},
+		"IsAborted": func() error {
+			_, err := tm.IsAborted(xid)
+			return err
+		},
+		"GetStatus": func() error {
+			_, err := tm.GetStatus(xid)
+			return err
+		},
+		"StatusRange": func() error {
+			_, err := tm.StatusRange(1, xid)
+			return err
+		},
+		"Flush": tm.Flush,
+		"Checkpoint": func() error {
+			_, err := tm.Checkpoint()
+			return err
+		},
+		"Snapshot": func() error { return tm.Snapshot(io.Discard) },
+		"FileSize": func() error {
+			_, err := tm.FileSize()
+			return err
+		},
+	}
+	for name, op := range ops {
+		if err := op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s after Close: expected ErrClosed, got %v", name, err)
+		}
+	}
+	if tm.XidCounter() != xid {
+		t.Errorf("Begin after Close should not allocate, counter is %d", tm.XidCounter())
+	}
+}
@@ -0,0 +1,95 @@
+package tm
+
+import "fmt"
+
+// InvalidXIDsError 列出 CommitMany 中未分配或者不处于活跃状态的 XID
+type InvalidXIDsError struct {
+	Xids []int64
+}
+
+func (e *InvalidXIDsError) Error() string {
+	return fmt.Sprintf("cannot commit xids %v: not allocated or not active", e.Xids)
+}
+
+// CommitMany 提交一组事务，所有状态写入后只刷一次盘。重复的 XID 只提交一次，也只回调一次 Observer。
+// 任何一个 XID 不在 [1, xidCounter] 内或者不处于活跃状态时返回 *InvalidXIDsError，且不提交任何事务
+func (t *TransactionManagerImpl) CommitMany(xids []int64) error {
+	if len(xids) == 0 {
+		return nil
+	}
+	xids = uniqueXIDs(xids)
+
+	unlock := t.lockEndMany(xids)
+	err := t.commitManyLocked(xids)
+	unlock()
+	if err != nil {
+		return err
+	}
+	for _, xid := range xids {
+		t.notifyCommit(xid)
+	}
+	return nil
+}
+
+// uniqueXIDs 按第一次出现的顺序返回去掉重复之后的 xids，不修改参数
+func uniqueXIDs(xids []int64) []int64 {
+	seen := make(map[int64]bool, len(xids))
+	unique := make([]int64, 0, len(xids))
+	for _, xid := range xids {
+		if !seen[xid] {
+			seen[xid] = true
+			unique = append(unique, xid)
+		}
+	}
+	return unique
+}
+
+// commitManyLocked 在 xids 的分段锁下检查并提交这些事务，通知由调用者在释放锁之后发出
+func (t *TransactionManagerImpl) commitManyLocked(xids []int64) error {
+	counter := t.XidCounter()
+	var invalid []int64
+	for _, xid := range xids {
+		if xid <= SuperXid || xid > counter {
+			invalid = append(invalid, xid)
+			continue
+		}
+		active, err := t.IsActive(xid)
+		if err != nil {
+			return err
+		}
+		if !active {
+			invalid = append(invalid, xid)
+		}
+	}
+	if len(invalid) > 0 {
+		return &InvalidXIDsError{Xids: invalid}
+	}
+
+	t.fileLock.RLock()
+	if t.closed {
+		t.fileLock.RUnlock()
+		return ErrClosed
+	}
+	for _, xid := range xids {
+		_, err := t.file.WriteAt([]byte{FieldTranCommitted}, t.getXidPosition(xid))
+		if err != nil {
+			t.fileLock.RUnlock()
+			return err
+		}
+	}
+	err := t.maybeSync()
+	t.fileLock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, xid := range xids {
+		t.emitChange(xid, FieldTranCommitted)
+	}
+	t.stats.commits.Add(int64(len(xids)))
+	t.stats.decrActive(int64(len(xids)))
+	for _, xid := range xids {
+		t.markEnded(xid)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCommitMany(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_commit_many")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	xid3 := mustBegin(t, tm)
+
+	if err := tm.CommitMany(nil); err != nil {
+		t.Errorf("CommitMany on an empty slice failed: %v", err)
+	}
+	if err := tm.CommitMany([]int64{xid1, xid3}); err != nil {
+		t.Fatalf("CommitMany failed: %v", err)
+	}
+	mustCheckXID(t, tm, xid1, FieldTranCommitted)
+	mustCheckXID(t, tm, xid3, FieldTranCommitted)
+	if !mustCheckXID(t, tm, xid2, FieldTranActive) {
+		t.Errorf("xid %d should still be active", xid2)
+	}
+	if stats := tm.Stats(); stats.Commits != 2 || stats.Active != 1 {
+		t.Errorf("Expected 2 commits and 1 active, got %+v", stats)
+	}
+}
+
+func TestCommitManyInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_commit_many")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	tm.Abort(xid2)
+
+	err = tm.CommitMany([]int64{xid1, xid2, 10, SuperXid})
+	var invalid *InvalidXIDsError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected *InvalidXIDsError, got %v", err)
+	}
+	if !reflect.DeepEqual(invalid.Xids, []int64{xid2, 10, SuperXid}) {
+		t.Errorf("Unexpected invalid xids %v", invalid.Xids)
+	}
+
+	// 没有任何事务被提交
+	if !mustCheckXID(t, tm, xid1, FieldTranActive) {
+		t.Errorf("xid %d should not be committed", xid1)
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("File should not grow, got %v", err)
+	}
+}
+
+func TestCommitManyDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_commit_many")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	x := mustBegin(t, tm)
+	other := mustBegin(t, tm)
+	o := &recordingObserver{}
+	tm.SetObserver(o)
+
+	if err := tm.CommitMany([]int64{x, x}); err != nil {
+		t.Fatalf("CommitMany failed: %v", err)
+	}
+	if !mustCheckXID(t, tm, x, FieldTranCommitted) {
+		t.Errorf("xid %d should be committed", x)
+	}
+	// 重复的 XID 只算一次，另一个仍然活跃的事务还在计数中
+	if stats := tm.Stats(); stats.Commits != 1 || stats.Active != 1 {
+		t.Errorf("Expected 1 commit and 1 active transaction, got %+v", stats)
+	}
+	if !reflect.DeepEqual(o.events, []string{fmt.Sprintf("commit %d", x)}) {
+		t.Errorf("Expected a single commit callback, got %v", o.events)
+	}
+	if !mustCheckXID(t, tm, other, FieldTranActive) {
+		t.Errorf("xid %d should still be active", other)
+	}
+}
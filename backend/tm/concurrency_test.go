@@ -0,0 +1,248 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentBeginAndCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_concurrency")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	const workers, perWorker = 16, 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	expected := make(map[int64]byte)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				xid, err := tm.Begin()
+				if err != nil {
+					t.Errorf("Begin failed: %v", err)
+					return
+				}
+				// 并发地查询状态和计数器
+				if ok, err := tm.IsActive(xid); !ok || err != nil {
+					t.Errorf("Expected xid %d to be active, got %v, %v", xid, ok, err)
+				}
+				tm.XidCounter()
+
+				status := FieldTranCommitted
+				if (w+i)%3 == 0 {
+					status = FieldTranAborted
+					err = tm.Abort(xid)
+				} else {
+					err = tm.Commit(xid)
+				}
+				if err != nil {
+					t.Errorf("Ending xid %d failed: %v", xid, err)
+					return
+				}
+				mu.Lock()
+				expected[xid] = status
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if tm.XidCounter() != workers*perWorker {
+		t.Fatalf("Expected counter %d, got %d", workers*perWorker, tm.XidCounter())
+	}
+	for xid, status := range expected {
+		if !mustCheckXID(t, tm, xid, status) {
+			t.Errorf("Unexpected status for xid %d", xid)
+		}
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed: %v", err)
+	}
+}
+
+func TestCommitUnallocatedXID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_concurrency")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	mustBegin(t, tm)
+	if err := tm.Commit(2); !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Expected ErrInvalidXID for an unallocated xid, got %v", err)
+	}
+	if err := tm.Abort(SuperXid); !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Expected ErrInvalidXID for SuperXid, got %v", err)
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("File should not grow, got %v", err)
+	}
+}
+
+func TestXidCounterReadsDuringBegin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_concurrency")
+
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	const writers, perWriter, readers = 4, 100, 8
+	done := make(chan struct{})
+	var readWg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		readWg.Add(1)
+		go func() {
+			defer readWg.Done()
+			// 读者不加锁，看到的计数器只增不减，且它之前的 XID 都已经写入了状态
+			var last int64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				counter := tm.XidCounter()
+				if counter < last {
+					t.Errorf("Counter went back from %d to %d", last, counter)
+					return
+				}
+				if counter > 0 {
+					if _, err := tm.GetStatus(counter); err != nil {
+						t.Errorf("GetStatus(%d) failed: %v", counter, err)
+						return
+					}
+				}
+				last = counter
+			}
+		}()
+	}
+
+	var writeWg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writeWg.Add(1)
+		go func() {
+			defer writeWg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := tm.Begin(); err != nil {
+					t.Errorf("Begin failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	writeWg.Wait()
+	close(done)
+	readWg.Wait()
+
+	if counter := tm.XidCounter(); counter != writers*perWriter {
+		t.Errorf("Expected counter %d, got %d", writers*perWriter, counter)
+	}
+}
+
+func TestStressBeginAndEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_concurrency")
+
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	workers, perWorker := 16, 50
+	if testing.Short() {
+		perWorker = 10
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	expected := make(map[int64]Status)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				xid, err := tm.Begin()
+				if err != nil {
+					t.Errorf("Begin failed: %v", err)
+					return
+				}
+				var status Status
+				switch (w + i) % 4 {
+				case 0:
+					status, err = StatusAborted, tm.Abort(xid)
+				case 1:
+					// 同一个事务并发地提交和取消，只有一个能成功
+					status, err = raceCommitAbort(tm, xid)
+				default:
+					status, err = StatusCommitted, tm.Commit(xid)
+				}
+				if err != nil {
+					t.Errorf("Ending xid %d failed: %v", xid, err)
+					return
+				}
+				mu.Lock()
+				expected[xid] = status
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	begins := int64(workers * perWorker)
+	if counter := tm.XidCounter(); counter != begins {
+		t.Fatalf("Expected counter %d, got %d", begins, counter)
+	}
+	// 计数器之内的每个 XID 都由某个协程开启，并处于它选择的终止状态
+	for xid := int64(1); xid <= begins; xid++ {
+		want, ok := expected[xid]
+		if !ok {
+			t.Errorf("Xid %d was skipped", xid)
+			continue
+		}
+		if status, err := tm.GetStatus(xid); err != nil || status != want {
+			t.Errorf("Expected xid %d to be %v, got %v, %v", xid, want, status, err)
+		}
+	}
+	if n := tm.ActiveCount(); n != 0 {
+		t.Errorf("Expected no active transactions, got %d", n)
+	}
+	if stats := tm.Stats(); stats.Commits+stats.Aborts != begins {
+		t.Errorf("Expected each transaction to end once, got %+v", stats)
+	}
+}
+
+// raceCommitAbort 在两个协程中同时提交和取消 xid，返回成功的那一方决定的状态
+func raceCommitAbort(tm *TransactionManagerImpl, xid int64) (Status, error) {
+	var commitErr, abortErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		commitErr = tm.Commit(xid)
+	}()
+	go func() {
+		defer wg.Done()
+		abortErr = tm.Abort(xid)
+	}()
+	wg.Wait()
+
+	switch {
+	case commitErr == nil && abortErr != nil:
+		return StatusCommitted, nil
+	case abortErr == nil && commitErr != nil:
+		return StatusAborted, nil
+	}
+	return 0, fmt.Errorf("expected exactly one of commit and abort to succeed, got %v and %v", commitErr, abortErr)
+}
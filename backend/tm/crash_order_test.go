@@ -0,0 +1,91 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReopenAfterCrashBetweenStatusAndCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	for _, opts := range [][]Option{nil, {WithPreallocate(64)}} {
+		tm, fault := createFaulty(t, path, opts...)
+
+		committed := mustBegin(t, tm)
+		tm.Commit(committed)
+		active := mustBegin(t, tm)
+
+		// 状态已经写入并刷盘，写计数器时崩溃
+		fault.lock.Lock()
+		fault.failHeader = true
+		fault.lock.Unlock()
+		if _, err := tm.Begin(); err == nil {
+			t.Fatalf("Expected Begin to fail when the counter cannot be written")
+		}
+		tm.Close()
+
+		tm, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open after the crash failed: %v", err)
+		}
+		interrupted := active + 1
+		if tm.XidCounter() != interrupted {
+			t.Errorf("Expected the counter to cover the interrupted xid %d, got %d", interrupted, tm.XidCounter())
+		}
+		if !checkStatus(t, tm.IsAborted, interrupted) {
+			t.Errorf("Expected the interrupted xid %d to be aborted", interrupted)
+		}
+		if !checkStatus(t, tm.IsCommitted, committed) || !checkStatus(t, tm.IsActive, active) {
+			t.Errorf("Expected the earlier transactions to keep their status")
+		}
+		if err := tm.Verify(); err != nil {
+			t.Errorf("Verify failed after reopen: %v", err)
+		}
+		if n := tm.ActiveCount(); n != 1 {
+			t.Errorf("Expected 1 active transaction, got %d", n)
+		}
+		if next := mustBegin(t, tm); next != interrupted+1 {
+			t.Errorf("Expected the interrupted xid not to be reused, got %d", next)
+		}
+		tm.Close()
+		os.Remove(path + XidSuffix)
+	}
+}
+
+func TestReopenAfterCrashBeforeStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, fault := createFaulty(t, path)
+
+	xid := mustBegin(t, tm)
+	// 状态还没有写入时崩溃，什么也没有留下
+	fault.set(false, true, false)
+	if _, err := tm.Begin(); err == nil {
+		t.Fatalf("Expected Begin to fail")
+	}
+	tm.Close()
+
+	tm, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open after the crash failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.XidCounter() != xid || !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Expected the file to be unchanged, counter %d", tm.XidCounter())
+	}
+}
+
+func TestOpenRejectsTrailingNonActiveSlot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+	// 计数器之外的已提交状态不是 Begin 中途崩溃能留下的，不自动处理
+	tm.file.WriteAt([]byte{FieldTranCommitted}, tm.getXidPosition(xid+1))
+	tm.Close()
+
+	if _, err := Open(path); err == nil {
+		t.Errorf("Expected Open to report the unexpected trailing status")
+	}
+}
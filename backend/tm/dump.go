@@ -0,0 +1,36 @@
+package tm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Dump 把事务表以 CSV 的形式写入 w，用于排查问题。开头几行以 # 开始，记录 xidCounter、BaseXID 和文件大小，
+// 之后是表头 xid,status 和 (BaseXID, xidCounter] 中每个 XID 一行，Checkpoint 丢弃的 XID 不会列出。
+// 状态按 Status.String 输出，文件中非法的状态字节输出为 Status(n)
+func (t *TransactionManagerImpl) Dump(w io.Writer) error {
+	size, err := t.FileSize()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# xid counter: %d\n", t.XidCounter())
+	fmt.Fprintf(bw, "# base xid: %d\n", t.BaseXID())
+	fmt.Fprintf(bw, "# file size: %d\n", size)
+	fmt.Fprintln(bw, "xid,status")
+
+	var writeErr error
+	err = t.forEachXID(func(xid int64, status byte) bool {
+		_, writeErr = fmt.Fprintf(bw, "%d,%v\n", xid, Status(status))
+		return writeErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return bw.Flush()
+}
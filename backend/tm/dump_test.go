@@ -0,0 +1,72 @@
+package tm
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	// 跨过多个分块: 3 的倍数提交，5 的倍数取消，7 的倍数准备，其余保持活跃
+	const total = iterateChunk + 10
+	counts := make(map[string]int)
+	for i := 1; i <= total; i++ {
+		xid := mustBegin(t, tm)
+		switch {
+		case i%3 == 0:
+			tm.Commit(xid)
+		case i%5 == 0:
+			tm.Abort(xid)
+		case i%7 == 0:
+			tm.Prepare(xid)
+		}
+		status, _ := tm.GetStatus(xid)
+		counts[status.String()]++
+	}
+
+	var buf bytes.Buffer
+	if err := tm.Dump(&buf); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	header := []string{
+		fmt.Sprintf("# xid counter: %d", total),
+		"# base xid: 0",
+		fmt.Sprintf("# file size: %d", tm.ExpectedFileLen()),
+		"xid,status",
+	}
+	if len(lines) != len(header)+total {
+		t.Fatalf("Expected %d lines, got %d", len(header)+total, len(lines))
+	}
+	for i, want := range header {
+		if lines[i] != want {
+			t.Errorf("Expected line %d to be %q, got %q", i, want, lines[i])
+		}
+	}
+
+	got := make(map[string]int)
+	for i, line := range lines[len(header):] {
+		fields := strings.Split(line, ",")
+		if len(fields) != 2 || fields[0] != fmt.Sprint(i+1) {
+			t.Fatalf("Expected a line for xid %d, got %q", i+1, line)
+		}
+		got[fields[1]]++
+	}
+	for status, n := range counts {
+		if got[status] != n {
+			t.Errorf("Expected %d %s xids, got %d", n, status, got[status])
+		}
+	}
+	if lines[len(header)+2] != "3,committed" || lines[len(header)+4] != "5,aborted" || lines[len(header)+6] != "7,prepared" {
+		t.Errorf("Unexpected statuses for xids 3, 5, 7: %v", lines[len(header)+2:len(header)+7])
+	}
+}
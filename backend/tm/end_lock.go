@@ -0,0 +1,36 @@
+package tm
+
+import "sort"
+
+// endLockStripes 是结束事务时使用的分段锁的个数
+const endLockStripes = 64
+
+// lockEnd 锁住 xid 所在的分段，Commit、Abort 和 Prepare 在读状态、检查转换和写状态期间持有它，
+// 同一个事务的并发提交和取消因此只有一个能成功，统计也只记一次
+func (t *TransactionManagerImpl) lockEnd(xid int64) func() {
+	l := &t.endLocks[uint64(xid)%endLockStripes]
+	l.Lock()
+	return l.Unlock
+}
+
+// lockEndMany 按分段的顺序锁住 xids 所在的所有分段，避免与其他批量操作死锁
+func (t *TransactionManagerImpl) lockEndMany(xids []int64) func() {
+	var stripes []int
+	seen := make(map[int]bool)
+	for _, xid := range xids {
+		s := int(uint64(xid) % endLockStripes)
+		if !seen[s] {
+			seen[s] = true
+			stripes = append(stripes, s)
+		}
+	}
+	sort.Ints(stripes)
+	for _, s := range stripes {
+		t.endLocks[s].Lock()
+	}
+	return func() {
+		for i := len(stripes) - 1; i >= 0; i-- {
+			t.endLocks[stripes[i]].Unlock()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tm
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEventBuffer 是没有设置 WithEvents 时事件通道的缓冲区大小
+const DefaultEventBuffer = 256
+
+// EventPolicy 决定事件通道的缓冲区满时如何处理新的事件
+type EventPolicy int
+
+const (
+	// EventsDropOldest 丢弃缓冲区中最旧的事件再放入新的事件，读得慢的消费者不会阻塞事务，这是默认的策略
+	EventsDropOldest EventPolicy = iota
+	// EventsBlock 让提交或取消阻塞到消费者读走事件为止，不丢失事件，但没有人读时事务会一直等待，直到 Close
+	EventsBlock
+)
+
+// TxEvent 是一次提交或取消之后发出的事件
+type TxEvent struct {
+	Xid    int64
+	Status Status
+}
+
+// WithEvents 设置 Events 返回的通道的缓冲区大小和缓冲区满时的策略，buffer <= 0 时使用 DefaultEventBuffer
+func WithEvents(buffer int, policy EventPolicy) Option {
+	return func(o *options) {
+		o.eventBuffer = buffer
+		o.eventPolicy = policy
+	}
+}
+
+// txEvents 把提交和取消事件发送到一个有缓冲的通道
+type txEvents struct {
+	// lock 使同时发送的事件按顺序进入通道，丢弃最旧的事件和放入新的事件也是原子的
+	lock    sync.Mutex
+	ch      chan TxEvent
+	policy  EventPolicy
+	closed  bool
+	stop    chan struct{}
+	dropped atomic.Int64
+}
+
+func newTxEvents(buffer int, policy EventPolicy) *txEvents {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	return &txEvents{ch: make(chan TxEvent, buffer), policy: policy, stop: make(chan struct{})}
+}
+
+// Events 返回提交和取消事件的通道，每次成功的 Commit、CommitMany 和 Abort (包括超时取消)之后发出一个事件，
+// 同一个协程中的事件按发生的顺序到达。缓冲区的大小和满时的策略由 WithEvents 设置，
+// 默认丢弃最旧的事件，丢弃的个数由 DroppedEvents 返回。Close 之后通道被关闭
+func (t *TransactionManagerImpl) Events() <-chan TxEvent {
+	return t.events.ch
+}
+
+// DroppedEvents 返回因为缓冲区已满而丢弃的事件数
+func (t *TransactionManagerImpl) DroppedEvents() int64 {
+	return t.events.dropped.Load()
+}
+
+// send 发送一个事件，通道关闭之后什么也不做
+func (e *txEvents) send(ev TxEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.closed {
+		return
+	}
+	if e.policy == EventsBlock {
+		select {
+		case e.ch <- ev:
+		case <-e.stop:
+		}
+		return
+	}
+	for {
+		select {
+		case e.ch <- ev:
+			return
+		default:
+		}
+		select {
+		case <-e.ch:
+			e.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close 唤醒阻塞的发送者并关闭通道
+func (e *txEvents) close() {
+	close(e.stop)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.closed = true
+	close(e.ch)
+}
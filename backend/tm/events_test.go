@@ -0,0 +1,122 @@
+package tm
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	xid3 := mustBegin(t, tm)
+	xid4 := mustBegin(t, tm)
+	tm.Commit(xid1)
+	tm.Abort(xid2)
+	tm.CommitMany([]int64{xid3, xid4})
+	// 重复和失败的操作不发出事件
+	tm.Commit(xid1)
+	tm.Abort(xid1)
+
+	want := []TxEvent{
+		{Xid: xid1, Status: StatusCommitted},
+		{Xid: xid2, Status: StatusAborted},
+		{Xid: xid3, Status: StatusCommitted},
+		{Xid: xid4, Status: StatusCommitted},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-tm.Events():
+			if ev != w {
+				t.Errorf("Event %d: expected %+v, got %+v", i, w, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for event %d", i)
+		}
+	}
+	select {
+	case ev := <-tm.Events():
+		t.Errorf("Unexpected event %+v", ev)
+	default:
+	}
+
+	tm.Close()
+	if _, ok := <-tm.Events(); ok {
+		t.Errorf("Expected Close to close the channel")
+	}
+}
+
+func TestEventsDropOldest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever), WithEvents(2, EventsDropOldest))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	var xids []int64
+	for i := 0; i < 5; i++ {
+		xid := mustBegin(t, tm)
+		// 没有消费者时提交也不会阻塞
+		if err := tm.Commit(xid); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		xids = append(xids, xid)
+	}
+	if n := tm.DroppedEvents(); n != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", n)
+	}
+	for _, xid := range xids[3:] {
+		if ev := <-tm.Events(); ev.Xid != xid {
+			t.Errorf("Expected the newest events to be kept, got %+v for xid %d", ev, xid)
+		}
+	}
+}
+
+func TestEventsBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever), WithEvents(1, EventsBlock))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	tm.Commit(mustBegin(t, tm))
+	xid := mustBegin(t, tm)
+	done := make(chan error)
+	go func() {
+		done <- tm.Commit(xid)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Expected Commit to block on the full channel")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-tm.Events()
+	if err := <-done; err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if ev := <-tm.Events(); ev.Xid != xid || tm.DroppedEvents() != 0 {
+		t.Errorf("Expected the blocked event for xid %d, got %+v", xid, ev)
+	}
+
+	// Close 唤醒阻塞的提交
+	tm.Commit(mustBegin(t, tm))
+	xid = mustBegin(t, tm)
+	go func() {
+		done <- tm.Abort(xid)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	tm.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Close to unblock the sender")
+	}
+}
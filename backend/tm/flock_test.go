@@ -0,0 +1,44 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenLockedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+
+	if _, err := Open(path); !errors.Is(err, ErrAlreadyLocked) {
+		t.Errorf("Expected ErrAlreadyLocked from Open, got %v", err)
+	}
+	// Create 也不能清空正在使用的文件
+	if _, err := Create(path); !errors.Is(err, ErrAlreadyLocked) {
+		t.Errorf("Expected ErrAlreadyLocked from Create, got %v", err)
+	}
+	if !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Locked file was modified")
+	}
+
+	// Checkpoint 替换文件之后仍然持有锁
+	tm.Commit(xid)
+	if _, err := tm.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrAlreadyLocked) {
+		t.Errorf("Expected ErrAlreadyLocked after Checkpoint, got %v", err)
+	}
+
+	tm.Close()
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open after Close failed: %v", err)
+	}
+	tm.Close()
+}
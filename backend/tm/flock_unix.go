@@ -0,0 +1,23 @@
+//go:build unix
+
+package tm
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile 对 file 加排他的建议锁，已被其他打开的文件持有时立即返回 ErrAlreadyLocked
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrAlreadyLocked
+	}
+	return err
+}
+
+// unlockFile 释放 lockFile 加的锁，关闭文件也会释放锁
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,86 @@
+package tm
+
+import "fmt"
+
+// ReadOnlyFork 是 ForkReadOnly 返回的只读事务管理器，保存着复制时所有 XID 的状态。
+// 它只用于回答可见性查询，Begin/Commit/Abort 返回 ErrReadOnly，之后原管理器上的修改对它不可见
+type ReadOnlyFork struct {
+	baseXid    int64
+	xidCounter int64
+	statuses   []byte // statuses[xid-baseXid-1] 是 xid 的状态
+}
+
+// ForkReadOnly 复制当前 xidCounter 之内所有 XID 的状态，返回一个只读的事务管理器，
+// 例如让只读副本回答可见性查询而不会写入 XID 文件。复制期间 Begin 和 Checkpoint 会等待
+func (t *TransactionManagerImpl) ForkReadOnly() (TransactionManager, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	base := t.BaseXID()
+	counter := t.xidCounter.Load()
+	statuses, err := t.readStatuses(base+1, counter)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range statuses {
+		if !isValidStatus(b) {
+			return nil, fmt.Errorf("%w: invalid status byte %d for xid %d", ErrBadXIDFile, b, base+1+int64(i))
+		}
+	}
+	return &ReadOnlyFork{baseXid: base, xidCounter: counter, statuses: statuses}, nil
+}
+
+func (f *ReadOnlyFork) Begin() (int64, error) {
+	return 0, ErrReadOnly
+}
+
+func (f *ReadOnlyFork) Commit(xid int64) error {
+	return ErrReadOnly
+}
+
+func (f *ReadOnlyFork) Abort(xid int64) error {
+	return ErrReadOnly
+}
+
+// GetStatus 返回 xid 在复制时的状态，SuperXid 总是已提交，复制时已经被 Checkpoint 丢弃的 XID 返回 ErrXIDCheckpointed
+func (f *ReadOnlyFork) GetStatus(xid int64) (Status, error) {
+	if xid == SuperXid {
+		return StatusCommitted, nil
+	}
+	if xid < 1 || xid > f.xidCounter {
+		return 0, fmt.Errorf("%w: %d is outside [1, %d]", ErrInvalidXID, xid, f.xidCounter)
+	}
+	if xid <= f.baseXid {
+		return 0, fmt.Errorf("%w: %d", ErrXIDCheckpointed, xid)
+	}
+	return Status(f.statuses[xid-f.baseXid-1]), nil
+}
+
+func (f *ReadOnlyFork) IsActive(xid int64) (bool, error) {
+	status, err := f.GetStatus(xid)
+	return status == StatusActive && err == nil, err
+}
+
+func (f *ReadOnlyFork) IsCommitted(xid int64) (bool, error) {
+	status, err := f.GetStatus(xid)
+	return status == StatusCommitted && err == nil, err
+}
+
+func (f *ReadOnlyFork) IsAborted(xid int64) (bool, error) {
+	status, err := f.GetStatus(xid)
+	return status == StatusAborted && err == nil, err
+}
+
+// XidCounter 返回复制时的 xidCounter
+func (f *ReadOnlyFork) XidCounter() int64 {
+	return f.xidCounter
+}
+
+// Flush 在只读管理器上什么也不做
+func (f *ReadOnlyFork) Flush() error {
+	return nil
+}
+
+func (f *ReadOnlyFork) Close() error {
+	return nil
+}
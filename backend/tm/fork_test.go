@@ -0,0 +1,92 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestForkReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	committed := mustBegin(t, tm)
+	aborted := mustBegin(t, tm)
+	active := mustBegin(t, tm)
+	tm.Commit(committed)
+	tm.Abort(aborted)
+
+	fork, err := tm.ForkReadOnly()
+	if err != nil {
+		t.Fatalf("ForkReadOnly failed: %v", err)
+	}
+	defer fork.Close()
+
+	if !checkStatus(t, fork.IsCommitted, committed) || !checkStatus(t, fork.IsAborted, aborted) || !checkStatus(t, fork.IsActive, active) {
+		t.Errorf("Fork statuses do not match the original")
+	}
+	if !checkStatus(t, fork.IsCommitted, SuperXid) {
+		t.Errorf("SuperXid should be committed on the fork")
+	}
+	if fork.XidCounter() != 3 {
+		t.Errorf("Fork counter: expected 3, got %d", fork.XidCounter())
+	}
+
+	if _, err := fork.Begin(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Begin on fork: expected ErrReadOnly, got %v", err)
+	}
+	if err := fork.Commit(active); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Commit on fork: expected ErrReadOnly, got %v", err)
+	}
+	if err := fork.Abort(active); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Abort on fork: expected ErrReadOnly, got %v", err)
+	}
+	if !checkStatus(t, tm.IsActive, active) {
+		t.Errorf("Mutations on the fork should not reach the original")
+	}
+
+	// 复制之后原管理器上的修改对副本不可见
+	tm.Commit(active)
+	later := mustBegin(t, tm)
+	if !checkStatus(t, fork.IsActive, active) {
+		t.Errorf("Fork should keep the status at fork time")
+	}
+	if fork.XidCounter() != 3 {
+		t.Errorf("Fork counter changed to %d", fork.XidCounter())
+	}
+	if _, err := fork.IsActive(later); !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Xid begun after the fork: expected ErrInvalidXID, got %v", err)
+	}
+}
+
+func TestForkReadOnlyAfterCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	old := mustBegin(t, tm)
+	tm.Commit(old)
+	if _, err := tm.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+	tm.Abort(xid)
+
+	fork, err := tm.ForkReadOnly()
+	if err != nil {
+		t.Fatalf("ForkReadOnly failed: %v", err)
+	}
+	if _, err := fork.IsCommitted(old); !errors.Is(err, ErrXIDCheckpointed) {
+		t.Errorf("Checkpointed xid: expected ErrXIDCheckpointed, got %v", err)
+	}
+	if !checkStatus(t, fork.IsAborted, xid) {
+		t.Errorf("Xid %d should be aborted on the fork", xid)
+	}
+}
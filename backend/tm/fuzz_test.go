@@ -0,0 +1,54 @@
+package tm
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzOpen(f *testing.F) {
+	// 合法的文件: 三个事务分别提交、取消和活跃
+	valid := append(encodeHeader(3, 0), FieldTranCommitted, FieldTranAborted, FieldTranActive)
+	f.Add(valid)
+	f.Add([]byte{})
+	f.Add(encodeHeader(0, 0))
+	f.Add(append(encodeHeader(2, 1), FieldTranPrepared))
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 2, FieldTranCommitted, FieldTranActive})
+	// 计数器接近 int64 的上限时推算的文件长度会溢出
+	f.Add(encodeHeader(math.MaxInt64, math.MaxInt64))
+	f.Add(encodeHeader(math.MaxInt64, 0))
+	f.Add([]byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// 模糊测试的多个进程同时运行，每次使用独立的目录
+		path := filepath.Join(t.TempDir(), "fuzz")
+		err := os.WriteFile(path+XidSuffix, data, 0666)
+		if err != nil {
+			t.Fatalf("Test setup failed: %v", err)
+		}
+
+		tm, err := Open(path, WithSyncMode(SyncNever))
+		if err != nil {
+			return
+		}
+		defer tm.Close()
+		counter := tm.XidCounter()
+		for _, xid := range []int64{SuperXid, tm.BaseXID(), tm.BaseXID() + 1, counter, counter + 1} {
+			tm.GetStatus(xid)
+		}
+		if _, err := tm.ActiveXIDs(); err != nil {
+			t.Errorf("ActiveXIDs failed on an opened file: %v", err)
+		}
+		if err := tm.Verify(); err != nil {
+			t.Errorf("Verify failed on an opened file: %v", err)
+		}
+		xid, err := tm.Begin()
+		if err != nil {
+			t.Fatalf("Begin failed on an opened file: %v", err)
+		}
+		if err := tm.Commit(xid); err != nil {
+			t.Errorf("Commit failed on an opened file: %v", err)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package tm
+
+import (
+	"fmt"
+	"time"
+)
+
+// GroupCommitMaxBatch 是组提交时一个批次最多包含的提交数，达到后立即刷盘而不等待 flushInterval
+const GroupCommitMaxBatch = 256
+
+// groupCommitter 把并发的 Commit 合并成批次，每个批次只调用一次 Sync
+type groupCommitter struct {
+	interval time.Duration
+	reqs     chan *commitReq
+	// flushes 中的请求让正在收集的批次立即写入，批次刷盘后关闭请求中的通道
+	flushes chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type commitReq struct {
+	xid  int64
+	done chan error
+}
+
+// BeginGroupCommit 开启组提交: Commit 不再各自刷盘，而是由后台协程每 flushInterval 或
+// 每 GroupCommitMaxBatch 个提交写入一批并只调用一次 Sync。无论刷盘模式如何，
+// Commit 都在它所在的批次 Sync 成功之后才返回，返回 nil 时提交已经持久化
+func (t *TransactionManagerImpl) BeginGroupCommit(flushInterval time.Duration) {
+	t.groupLock.Lock()
+	defer t.groupLock.Unlock()
+	if t.group != nil {
+		return
+	}
+
+	t.group = &groupCommitter{
+		interval: flushInterval,
+		reqs:     make(chan *commitReq),
+		flushes:  make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.group.run(t)
+}
+
+// EndGroupCommit 关闭组提交，恢复每次 Commit 各自刷盘
+func (t *TransactionManagerImpl) EndGroupCommit() {
+	// 持有写锁时所有进行中的 Commit 都已返回，没有等待刷盘的请求
+	t.groupLock.Lock()
+	defer t.groupLock.Unlock()
+	if t.group == nil {
+		return
+	}
+
+	close(t.group.stop)
+	<-t.group.done
+	t.group = nil
+}
+
+// submit 提交 xid 并等待它所在的批次刷盘
+func (g *groupCommitter) submit(xid int64) error {
+	req := &commitReq{xid: xid, done: make(chan error, 1)}
+	g.reqs <- req
+	return <-req.done
+}
+
+// CommitAsync 与 Commit 相同但不阻塞调用者，提交结束后向返回的通道发送 Commit 的结果。
+// 开启组提交时结果在 xid 所在的批次刷盘之后才发送，收到 nil 时提交已经持久化
+func (t *TransactionManagerImpl) CommitAsync(xid int64) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- t.Commit(xid)
+	}()
+	return done
+}
+
+// flush 让正在收集的批次立即写入并等待它刷盘，没有正在收集的批次时立即返回
+func (g *groupCommitter) flush() {
+	done := make(chan struct{})
+	g.flushes <- done
+	<-done
+}
+
+func (g *groupCommitter) run(t *TransactionManagerImpl) {
+	defer close(g.done)
+
+	for {
+		var first *commitReq
+		select {
+		case first = <-g.reqs:
+		case done := <-g.flushes:
+			close(done)
+			continue
+		case <-g.stop:
+			return
+		}
+
+		// 收集一个批次，直到等满 interval、凑够 GroupCommitMaxBatch 个提交或者收到 flush 请求
+		batch := []*commitReq{first}
+		var flushed chan struct{}
+		timer := time.NewTimer(g.interval)
+	collect:
+		for len(batch) < GroupCommitMaxBatch {
+			select {
+			case req := <-g.reqs:
+				batch = append(batch, req)
+			case flushed = <-g.flushes:
+				break collect
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		t.flushCommitBatch(batch)
+		if flushed != nil {
+			close(flushed)
+		}
+	}
+}
+
+// flushCommitBatch 写入一批提交状态并只刷一次盘，然后按请求通知每个等待者。
+// 不管刷盘模式如何都调用 Sync，等待者收到 nil 时它的状态已经持久化
+func (t *TransactionManagerImpl) flushCommitBatch(batch []*commitReq) {
+	t.fileLock.RLock()
+	written := false
+	errs := make([]error, len(batch))
+	for i, req := range batch {
+		if t.closed {
+			errs[i] = ErrClosed
+			continue
+		}
+		if req.xid <= t.baseXid {
+			errs[i] = fmt.Errorf("%w: %d", ErrXIDCheckpointed, req.xid)
+			continue
+		}
+		_, errs[i] = t.file.WriteAt([]byte{FieldTranCommitted}, t.getXidPosition(req.xid))
+		written = written || errs[i] == nil
+	}
+
+	var syncErr error
+	if written {
+		syncErr = t.file.Sync()
+	}
+	t.fileLock.RUnlock()
+	for i, req := range batch {
+		err := errs[i]
+		if err == nil {
+			err = syncErr
+		}
+		if err == nil {
+			t.emitChange(req.xid, FieldTranCommitted)
+		}
+		req.done <- err
+	}
+}
@@ -0,0 +1,258 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupCommitSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	tm.BeginGroupCommit(time.Millisecond)
+
+	const workers = 16
+	xids := make([]int64, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			xid, err := tm.Begin()
+			if err != nil {
+				t.Errorf("Begin failed: %v", err)
+				return
+			}
+			if err := tm.Commit(xid); err != nil {
+				t.Errorf("Commit failed: %v", err)
+			}
+			xids[i] = xid
+		}(i)
+	}
+	wg.Wait()
+
+	// 模拟崩溃: 只释放文件锁而不关闭 tm，直接从文件重新打开
+	unlockFile(tm.file.(*os.File))
+	tm2, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm2.Close()
+	defer tm.Close()
+
+	for _, xid := range xids {
+		if !checkStatus(t, tm2.IsCommitted, xid) {
+			t.Errorf("Committed xid %d not durable after reopen", xid)
+		}
+	}
+	if stats := tm.Stats(); stats.Commits != workers || stats.Active != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestEndGroupCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.BeginGroupCommit(time.Millisecond)
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+	tm.EndGroupCommit()
+
+	// 关闭组提交后 Commit 回到每次刷盘的模式
+	xid2 := mustBegin(t, tm)
+	if err := tm.Commit(xid2); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if !checkStatus(t, tm.IsCommitted, xid) || !checkStatus(t, tm.IsCommitted, xid2) {
+		t.Errorf("Expected both transactions to be committed")
+	}
+}
+
+func benchmarkCommit(b *testing.B, group bool) {
+	tm, err := Create(filepath.Join(b.TempDir(), "bench_file"))
+	if err != nil {
+		b.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	if group {
+		tm.BeginGroupCommit(time.Millisecond)
+	}
+
+	// 只统计 Commit 的开销，事务提前开启好
+	xids := make(chan int64, b.N)
+	for i := 0; i < b.N; i++ {
+		xid, err := tm.Begin()
+		if err != nil {
+			b.Fatalf("Begin failed: %v", err)
+		}
+		xids <- xid
+	}
+	close(xids)
+
+	b.ResetTimer()
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tm.Commit(<-xids)
+		}
+	})
+}
+
+func BenchmarkCommitPerOpSync(b *testing.B) {
+	benchmarkCommit(b, false)
+}
+
+func BenchmarkCommitGrouped(b *testing.B) {
+	benchmarkCommit(b, true)
+}
+
+// recordingFile 按顺序记录写入和刷盘，gate 不为 nil 时 Sync 阻塞到 gate 被关闭
+type recordingFile struct {
+	BlockFile
+
+	lock sync.Mutex
+	ops  []string
+	gate chan struct{}
+}
+
+func (f *recordingFile) record(op string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.ops = append(f.ops, op)
+}
+
+func (f *recordingFile) WriteAt(p []byte, off int64) (int, error) {
+	f.record("write")
+	return f.BlockFile.WriteAt(p, off)
+}
+
+func (f *recordingFile) Sync() error {
+	f.lock.Lock()
+	gate := f.gate
+	f.lock.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	f.record("sync")
+	return f.BlockFile.Sync()
+}
+
+// hold 让之后的 Sync 阻塞，并清空之前的记录，返回的函数放行被阻塞的 Sync
+func (f *recordingFile) hold() func() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.ops = nil
+	f.gate = make(chan struct{})
+	gate := f.gate
+	return func() {
+		f.lock.Lock()
+		f.gate = nil
+		f.lock.Unlock()
+		close(gate)
+	}
+}
+
+func (f *recordingFile) history() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.ops...)
+}
+
+func TestGroupCommitWaitsForSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	var rec *recordingFile
+	// SyncNever 下组提交仍然在返回之前刷盘
+	tm := createWrapped(t, path, func(file BlockFile) BlockFile {
+		rec = &recordingFile{BlockFile: file}
+		return rec
+	}, WithSyncMode(SyncNever))
+	defer tm.Close()
+	tm.BeginGroupCommit(time.Millisecond)
+	defer tm.EndGroupCommit()
+
+	xid := mustBegin(t, tm)
+	release := rec.hold()
+	done := make(chan error, 1)
+	go func() {
+		err := tm.Commit(xid)
+		rec.record("return")
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Commit returned before its batch was synced")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	want := []string{"write", "sync", "return"}
+	if got := rec.history(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestCommitAsync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	var rec *recordingFile
+	tm := createWrapped(t, path, func(file BlockFile) BlockFile {
+		rec = &recordingFile{BlockFile: file}
+		return rec
+	}, WithSyncMode(SyncNever))
+	defer tm.Close()
+	tm.BeginGroupCommit(100 * time.Millisecond)
+	defer tm.EndGroupCommit()
+
+	xids := []int64{mustBegin(t, tm), mustBegin(t, tm), mustBegin(t, tm)}
+	release := rec.hold()
+	var chans []<-chan error
+	for _, xid := range xids {
+		chans = append(chans, tm.CommitAsync(xid))
+	}
+	// 批次等满 interval 后刷盘，刷盘被阻塞时所有通道都没有结果
+	time.Sleep(150 * time.Millisecond)
+	for i, ch := range chans {
+		select {
+		case err := <-ch:
+			t.Fatalf("CommitAsync for xid %d signalled before sync: %v", xids[i], err)
+		default:
+		}
+	}
+
+	release()
+	for i, ch := range chans {
+		select {
+		case err := <-ch:
+			if err != nil {
+				t.Errorf("CommitAsync for xid %d failed: %v", xids[i], err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for xid %d", xids[i])
+		}
+		if !checkStatus(t, tm.IsCommitted, xids[i]) {
+			t.Errorf("Expected xid %d to be committed", xids[i])
+		}
+	}
+	// 三个提交落在同一个批次中，只刷了一次盘
+	syncs := 0
+	for _, op := range rec.history() {
+		if op == "sync" {
+			syncs++
+		}
+	}
+	if syncs != 1 {
+		t.Errorf("Expected one sync for the batch, got %v", rec.history())
+	}
+}
@@ -0,0 +1,214 @@
+package tm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 文件头中各字段的偏移，见 transaction_manager.go 中的文件格式说明
+const (
+	offXidMagic    = 0
+	offXidChecksum = 4
+	offXidCounter  = 8
+	offBaseXid     = 16
+
+	// lenLegacyXidHeader 是旧格式文件头的长度，旧文件头只有 8 字节的 xidCounter，没有 magic 和 checksum
+	lenLegacyXidHeader = 8
+	// rewriteSuffix 是整体重写 XID 文件时使用的临时文件后缀
+	rewriteSuffix = ".tmp"
+)
+
+// xidFormatVersion 是当前 XID 文件格式的版本，保存在 magic 的第一个字节中，以后修改格式时递增它。
+// 更早的两种格式没有版本: 只在第一个字节写 xidCounter 最低字节的单字节格式，和 8 字节 xidCounter 的旧格式
+const xidFormatVersion = 0x01
+
+// headerLenForVersion 返回格式版本为 version 的文件头长度。
+// 版本 0 是没有 magic 的旧格式(第一个字节是 xidCounter 的最高字节，总是 0)，文件头只有 8 字节的 xidCounter；
+// 版本 1 的文件头依次是 magic、checksum、xidCounter 和 baseXid。不认识的版本返回 ErrUnsupportedVersion
+func headerLenForVersion(version byte) (int64, error) {
+	switch version {
+	case 0:
+		return lenLegacyXidHeader, nil
+	case xidFormatVersion:
+		return LenXidHeaderLength, nil
+	}
+	return 0, fmt.Errorf("%w: version %d", ErrUnsupportedVersion, version)
+}
+
+// xidMagic 标识新格式的 XID 文件。
+// 它的第一个字节不为 0，而旧文件的第一个字节是 xidCounter 的最高字节，总是 0，以此区分两种格式
+var xidMagic = []byte{xidFormatVersion, 'X', 'I', 'D'}
+
+var (
+	// ErrNeedsMigration 表示文件是不能原地打开的单字节格式，而打开时用 WithAllowMigrate(false) 禁止了迁移
+	ErrNeedsMigration = errors.New("xid file needs migration")
+	// ErrUnsupportedVersion 表示文件是更新的版本写入的，无法识别
+	ErrUnsupportedVersion = errors.New("unsupported xid file version")
+)
+
+// WithAllowMigrate 设置打开旧格式的文件时是否把它迁移成当前格式，默认允许。
+// 不允许时版本 0 的文件按 8 字节的文件头原地打开，之后的写入保持旧格式(Checkpoint 整体重写文件时除外)；
+// 单字节格式的文件无法原地打开，返回 ErrNeedsMigration，文件保持不变
+func WithAllowMigrate(allow bool) Option {
+	return func(o *options) {
+		o.allowMigrate = allow
+	}
+}
+
+// encodeHeader 生成包含 counter 和 base 的文件头
+func encodeHeader(counter, base int64) []byte {
+	buf := make([]byte, LenXidHeaderLength)
+	copy(buf[offXidMagic:], xidMagic)
+	binary.BigEndian.PutUint64(buf[offXidCounter:], uint64(counter))
+	binary.BigEndian.PutUint64(buf[offBaseXid:], uint64(base))
+	binary.BigEndian.PutUint32(buf[offXidChecksum:], crc32.ChecksumIEEE(buf[offXidCounter:]))
+	return buf
+}
+
+// readHeader 先按第一个字节的格式版本得到文件头长度，再读出文件头中的 xidCounter 和 baseXid。
+// 版本 0 的文件头只有 xidCounter，baseXid 为 0；magic 或 checksum 不匹配时返回 ErrBadXIDFile
+func (t *TransactionManagerImpl) readHeader() (counter, base int64, err error) {
+	buf := make([]byte, LenXidHeaderLength)
+	n, err := t.file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	if n == 0 {
+		return 0, 0, fmt.Errorf("%w: truncated header", ErrBadXIDFile)
+	}
+	headerLen, err := headerLenForVersion(buf[offXidMagic])
+	if err != nil {
+		// 后面是 magic 的其余字节时是更新的版本写入的文件，否则文件头已经损坏
+		if !bytes.Equal(buf[offXidMagic+1:offXidChecksum], xidMagic[1:]) {
+			return 0, 0, fmt.Errorf("%w: bad magic %x", ErrBadXIDFile, buf[offXidMagic:offXidChecksum])
+		}
+		return 0, 0, err
+	}
+	if int64(n) < headerLen {
+		return 0, 0, fmt.Errorf("%w: truncated header", ErrBadXIDFile)
+	}
+	t.headerLen = headerLen
+	if headerLen == lenLegacyXidHeader {
+		return int64(binary.BigEndian.Uint64(buf)), 0, nil
+	}
+	if !bytes.Equal(buf[offXidMagic:offXidChecksum], xidMagic) {
+		return 0, 0, fmt.Errorf("%w: bad magic %x", ErrBadXIDFile, buf[offXidMagic:offXidChecksum])
+	}
+	if crc32.ChecksumIEEE(buf[offXidCounter:]) != binary.BigEndian.Uint32(buf[offXidChecksum:]) {
+		return 0, 0, fmt.Errorf("%w: header checksum mismatch", ErrBadXIDFile)
+	}
+	counter = int64(binary.BigEndian.Uint64(buf[offXidCounter:]))
+	base = int64(binary.BigEndian.Uint64(buf[offBaseXid:]))
+	return counter, base, nil
+}
+
+// isLegacyFile 判断文件是否是没有 magic 的旧格式文件
+func (t *TransactionManagerImpl) isLegacyFile() (bool, error) {
+	buf := make([]byte, len(xidMagic))
+	_, err := t.file.ReadAt(buf, offXidMagic)
+	if err != nil {
+		return false, err
+	}
+	return buf[0] == 0 && !bytes.Equal(buf, xidMagic), nil
+}
+
+// isSingleByteFile 判断文件是否是单字节格式: 8 字节的文件头中只有第一个字节写了 xidCounter 的最低字节，
+// 其余字节为 0。这时由文件长度推算出的 xidCounter 的最低字节等于第一个字节，而按 8 字节解码得到的值与它不同
+func (t *TransactionManagerImpl) isSingleByteFile(fileLen int64) (bool, error) {
+	buf := make([]byte, lenLegacyXidHeader)
+	_, err := t.file.ReadAt(buf, 0)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(buf[1:], make([]byte, lenLegacyXidHeader-1)) {
+		return false, nil
+	}
+	counter := (fileLen - lenLegacyXidHeader) / XidFieldSize
+	return byte(counter) == buf[0] && int64(binary.BigEndian.Uint64(buf)) != counter, nil
+}
+
+// migrateSingleByte 把单字节格式的文件重写成当前格式，xidCounter 由文件长度得到
+func (t *TransactionManagerImpl) migrateSingleByte(fileLen int64) error {
+	buf := make([]byte, fileLen)
+	_, err := t.file.ReadAt(buf, 0)
+	if err != nil {
+		return err
+	}
+	return t.replaceFile((fileLen-lenLegacyXidHeader)/XidFieldSize, 0, buf[lenLegacyXidHeader:])
+}
+
+// migrateLegacy 把旧格式文件重写成新格式，旧文件的长度必须与它的 xidCounter 一致
+func (t *TransactionManagerImpl) migrateLegacy(fileLen int64) error {
+	buf := make([]byte, fileLen)
+	_, err := t.file.ReadAt(buf, 0)
+	if err != nil {
+		return err
+	}
+	counter := int64(binary.BigEndian.Uint64(buf))
+	if expected := lenLegacyXidHeader + counter*XidFieldSize; counter < 0 || fileLen != expected {
+		return &FileLengthError{Expected: expected, Actual: fileLen}
+	}
+
+	return t.replaceFile(counter, 0, buf[lenLegacyXidHeader:])
+}
+
+// replaceFile 用包含 counter、base 和 statuses 的新文件替换当前文件，调用者需持有 fileLock 的写锁或者独占 t。
+// 新文件先写入临时文件并刷盘，再通过 rename 替换原文件，崩溃时要么是旧文件要么是新文件。
+// 临时文件在写入前就加上建议锁，替换之后的文件始终处于锁定状态。
+// rename 只对本地文件有意义，WithOpenFile 打开的不是 *os.File 时返回 ErrReplaceUnsupported
+func (t *TransactionManagerImpl) replaceFile(counter, base int64, statuses []byte) error {
+	if _, ok := t.file.(*os.File); !ok {
+		return fmt.Errorf("%w: %s", ErrReplaceUnsupported, t.path)
+	}
+	tmpPath := t.path + rewriteSuffix
+	file, err := openLocked(t.openFile, tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	err = writeXIDFile(file, counter, base, statuses)
+	if err == nil {
+		err = os.Rename(tmpPath, t.path)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	err = syncDir(filepath.Dir(t.path))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	t.file.Close()
+	t.file = file
+	t.headerLen = LenXidHeaderLength
+	t.allocated = LenXidHeaderLength + int64(len(statuses))
+	return nil
+}
+
+// writeXIDFile 把文件头和 statuses 写入 file 并刷盘
+func writeXIDFile(file BlockFile, counter, base int64, statuses []byte) error {
+	buf := append(encodeHeader(counter, base), statuses...)
+	_, err := file.WriteAt(buf, 0)
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// syncDir 刷新目录项，使 rename 在崩溃后仍然可见
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
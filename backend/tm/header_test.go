@@ -0,0 +1,269 @@
+package tm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHeaderCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	corruptions := map[string]int64{
+		"magic":    offXidMagic + 1,
+		"checksum": offXidChecksum,
+		"counter":  offXidCounter + 7,
+		"base":     offBaseXid + 3,
+	}
+	for name, offset := range corruptions {
+		tm, err := Create(path, WithOverwrite(true))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		mustBegin(t, tm)
+		tm.Close()
+
+		file, _ := os.OpenFile(path+XidSuffix, os.O_RDWR, 0666)
+		buf := make([]byte, 1)
+		file.ReadAt(buf, offset)
+		buf[0] ^= 0x10
+		file.WriteAt(buf, offset)
+		file.Close()
+
+		_, err = Open(path)
+		if !errors.Is(err, ErrBadXIDFile) {
+			t.Errorf("Expected ErrBadXIDFile after corrupting the %s, got %v", name, err)
+		}
+	}
+}
+
+// writeLegacyFile 写一个只有 8 字节 xidCounter 文件头的旧格式文件
+func writeLegacyFile(t *testing.T, path string, statuses []byte) {
+	t.Helper()
+	buf := make([]byte, lenLegacyXidHeader)
+	binary.BigEndian.PutUint64(buf, uint64(len(statuses)))
+	err := os.WriteFile(path+XidSuffix, append(buf, statuses...), 0666)
+	if err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+}
+
+func TestOpenMigratesLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	statuses := []byte{FieldTranCommitted, FieldTranAborted, FieldTranActive}
+	writeLegacyFile(t, path, statuses)
+
+	tm, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if tm.XidCounter() != 3 {
+		t.Errorf("Expected xidCounter 3, got %d", tm.XidCounter())
+	}
+	for i, status := range statuses {
+		if !mustCheckXID(t, tm, int64(i+1), status) {
+			t.Errorf("Status of xid %d was not migrated", i+1)
+		}
+	}
+	if xid := mustBegin(t, tm); xid != 4 {
+		t.Errorf("Expected next xid 4, got %d", xid)
+	}
+	tm.Close()
+
+	data, _ := os.ReadFile(path + XidSuffix)
+	if !bytes.HasPrefix(data, xidMagic) || int64(len(data)) != LenXidHeaderLength+4*XidFieldSize {
+		t.Errorf("File was not rewritten in the new format")
+	}
+	if _, err := os.Stat(path + XidSuffix + rewriteSuffix); !os.IsNotExist(err) {
+		t.Errorf("Temporary file was left behind")
+	}
+
+	// 迁移后的文件可以正常打开
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open after migration failed: %v", err)
+	}
+	tm.Close()
+}
+
+func TestOpenLegacyFileBadLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	writeLegacyFile(t, path, []byte{FieldTranCommitted, FieldTranActive})
+	file, _ := os.OpenFile(path+XidSuffix, os.O_RDWR, 0666)
+	file.Truncate(lenLegacyXidHeader + 1)
+	file.Close()
+
+	_, err := Open(path)
+	var lenErr *FileLengthError
+	if !errors.As(err, &lenErr) {
+		t.Fatalf("Expected *FileLengthError, got %v", err)
+	}
+	if data, _ := os.ReadFile(path + XidSuffix); bytes.HasPrefix(data, xidMagic) {
+		t.Errorf("A legacy file with a bad length should not be migrated")
+	}
+}
+
+// writeSingleByteFile 写入单字节格式的 XID 文件: 8 字节的文件头中只有第一个字节是 xidCounter 的最低字节
+func writeSingleByteFile(t *testing.T, path string, statuses []byte) {
+	t.Helper()
+	buf := make([]byte, lenLegacyXidHeader)
+	buf[0] = byte(len(statuses))
+	err := os.WriteFile(path+XidSuffix, append(buf, statuses...), 0666)
+	if err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+}
+
+func TestOpenMigratesSingleByteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	// 300 个事务时第一个字节是 300 & 0xFF = 44
+	statuses := bytes.Repeat([]byte{FieldTranCommitted}, 300)
+	statuses[299] = FieldTranActive
+	for _, n := range []int{3, 256, 300} {
+		writeSingleByteFile(t, path, statuses[300-n:])
+		tm, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open of a single-byte file with %d xids failed: %v", n, err)
+		}
+		if tm.XidCounter() != int64(n) {
+			t.Errorf("Expected xidCounter %d, got %d", n, tm.XidCounter())
+		}
+		if !mustCheckXID(t, tm, 1, FieldTranCommitted) || !mustCheckXID(t, tm, int64(n), FieldTranActive) {
+			t.Errorf("Statuses of the single-byte file with %d xids were not migrated", n)
+		}
+		if xid := mustBegin(t, tm); xid != int64(n+1) {
+			t.Errorf("Expected next xid %d, got %d", n+1, xid)
+		}
+		tm.Close()
+
+		data, _ := os.ReadFile(path + XidSuffix)
+		if !bytes.HasPrefix(data, xidMagic) {
+			t.Errorf("Single-byte file with %d xids was not rewritten in the new format", n)
+		}
+	}
+}
+
+func TestOpenWithoutMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	// 单字节格式无法原地打开
+	writeSingleByteFile(t, path, []byte{FieldTranCommitted, FieldTranActive})
+	before, _ := os.ReadFile(path + XidSuffix)
+	_, err := Open(path, WithAllowMigrate(false))
+	if !errors.Is(err, ErrNeedsMigration) {
+		t.Errorf("Expected ErrNeedsMigration, got %v", err)
+	}
+	if after, _ := os.ReadFile(path + XidSuffix); !bytes.Equal(before, after) {
+		t.Errorf("File was modified although migration is not allowed")
+	}
+}
+
+func TestOpenLegacyInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	writeLegacyFile(t, path, []byte{FieldTranCommitted, FieldTranActive})
+	tm, err := Open(path, WithAllowMigrate(false))
+	if err != nil {
+		t.Fatalf("Open of a version 0 file failed: %v", err)
+	}
+	if tm.headerLen != lenLegacyXidHeader || tm.XidCounter() != 2 {
+		t.Fatalf("Expected an 8 byte header and counter 2, got %d, %d", tm.headerLen, tm.XidCounter())
+	}
+	if !mustCheckXID(t, tm, 1, FieldTranCommitted) || !mustCheckXID(t, tm, 2, FieldTranActive) {
+		t.Errorf("Statuses of the version 0 file were not read in place")
+	}
+	xid := mustBegin(t, tm)
+	if err := tm.Commit(xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := tm.Abort(2); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	tm.Close()
+
+	// 文件仍然是 8 字节文件头的旧格式
+	raw, _ := os.ReadFile(path + XidSuffix)
+	want := make([]byte, lenLegacyXidHeader)
+	binary.BigEndian.PutUint64(want, 3)
+	want = append(want, FieldTranCommitted, FieldTranAborted, FieldTranCommitted)
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("Expected the file to stay in version 0 layout %v, got %v", want, raw)
+	}
+
+	// 默认打开时迁移成当前格式，状态保持不变
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open with migration failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.headerLen != LenXidHeaderLength || !mustCheckXID(t, tm, 3, FieldTranCommitted) || !mustCheckXID(t, tm, 2, FieldTranAborted) {
+		t.Errorf("Statuses were not preserved when migrating the file")
+	}
+}
+
+func TestOpenUnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	header := encodeHeader(0, 0)
+	header[offXidMagic] = xidFormatVersion + 1
+	os.WriteFile(path+XidSuffix, header, 0666)
+	if _, err := Open(path); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestVersionedHeaderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	first := mustBegin(t, tm)
+	tm.Commit(first)
+	// Checkpoint 丢弃已经结束的事务，文件头中的 baseXid 不为 0
+	if _, err := tm.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	committed := mustBegin(t, tm)
+	tm.Commit(committed)
+	aborted := mustBegin(t, tm)
+	tm.Abort(aborted)
+	base := tm.baseXid
+	tm.Close()
+
+	data, err := os.ReadFile(path + XidSuffix)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if data[offXidMagic] != xidFormatVersion || !bytes.Equal(data[offXidMagic:offXidChecksum], xidMagic) {
+		t.Errorf("Expected the file to start with the version and magic, got %x", data[:offXidChecksum])
+	}
+	if counter := int64(binary.BigEndian.Uint64(data[offXidCounter:])); counter != aborted {
+		t.Errorf("Expected counter %d in the header, got %d", aborted, counter)
+	}
+	if b := int64(binary.BigEndian.Uint64(data[offBaseXid:])); b != base || base == 0 {
+		t.Errorf("Expected base xid %d in the header, got %d", base, b)
+	}
+
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.headerLen != LenXidHeaderLength || tm.baseXid != base || tm.XidCounter() != aborted {
+		t.Errorf("Unexpected header after reopen: len %d, base %d, counter %d", tm.headerLen, tm.baseXid, tm.XidCounter())
+	}
+	if data[tm.getXidPosition(committed)] != FieldTranCommitted || data[tm.getXidPosition(aborted)] != FieldTranAborted {
+		t.Errorf("Expected the statuses right after the %d byte header", tm.headerLen)
+	}
+	if !checkStatus(t, tm.IsCommitted, committed) || !checkStatus(t, tm.IsAborted, aborted) {
+		t.Errorf("Expected the statuses to survive reopen")
+	}
+}
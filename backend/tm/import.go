@@ -0,0 +1,168 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrXIDGap 表示 BeginAt 的 XID 与 xidCounter 之间有空隙，并且没有要求把空隙标记为已取消
+	ErrXIDGap = errors.New("xid leaves a gap after the xid counter")
+	// ErrInvalidStatus 表示 BeginAt 给出的状态字节不是合法的事务状态
+	ErrInvalidStatus = errors.New("invalid transaction status")
+)
+
+// ImportRemapped 把 src 中的每个事务状态复制到本地的 srcXid+offset 处，并返回旧 XID 到新 XID 的映射。
+// 导入后的 XID 必须全部大于本地当前的 xidCounter，中间空出的 XID 会被标记为已取消
+func (t *TransactionManagerImpl) ImportRemapped(src TransactionManager, offset int64) (map[int64]int64, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	// 导入的事务可能处于活跃状态，Quiesce 之后同样不允许
+	if t.quiesced {
+		return nil, ErrQuiesced
+	}
+	srcCounter := src.XidCounter()
+	if srcCounter > 0 && offset+1 <= t.xidCounter.Load() {
+		return nil, fmt.Errorf("%w: first imported xid %d, local counter %d", ErrImportOverlap, offset+1, t.xidCounter.Load())
+	}
+
+	mapping := make(map[int64]int64, srcCounter)
+	if srcCounter == 0 {
+		return mapping, nil
+	}
+
+	// 先把空隙和导入的状态拼成一段连续的数据，一次写入
+	base := t.xidCounter.Load()
+	newCounter := srcCounter + offset
+	buf := make([]byte, (newCounter-base)*XidFieldSize)
+	for i := range buf {
+		buf[i] = FieldTranAborted
+	}
+	for srcXid := int64(1); srcXid <= srcCounter; srcXid++ {
+		status, err := statusOf(src, srcXid)
+		if err != nil {
+			return nil, err
+		}
+
+		newXid := srcXid + offset
+		buf[(newXid-base-1)*XidFieldSize] = status
+		mapping[srcXid] = newXid
+	}
+
+	t.fileLock.RLock()
+	err := ErrClosed
+	if !t.closed {
+		_, err = t.file.WriteAt(buf, t.getXidPosition(base+1))
+	}
+	t.fileLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	err = t.writeXIDCounter(newCounter)
+	if err != nil {
+		return nil, err
+	}
+	t.xidCounter.Store(newCounter)
+
+	for srcXid := int64(1); srcXid <= srcCounter; srcXid++ {
+		newXid := mapping[srcXid]
+		status := buf[(newXid-base-1)*XidFieldSize]
+		t.emitChange(newXid, status)
+		if status == FieldTranActive {
+			t.stats.active.Add(1)
+		}
+		if status == FieldTranActive || status == FieldTranPrepared {
+			t.markBegan(newXid, false)
+		}
+	}
+	return mapping, nil
+}
+
+// BeginAt 在恢复或导入时把 xid 的状态直接写为 status，并把 xidCounter 推进到 xid，
+// 用于按另一个实例日志中的原始 XID 重建事务。xid 必须大于当前的 xidCounter；
+// xid 与 xidCounter 之间有空隙时，fillAborted 为 true 则把空出的 XID 标记为已取消，否则返回 ErrXIDGap。
+// 与 ImportRemapped 一样不回调 Observer
+func (t *TransactionManagerImpl) BeginAt(xid int64, status byte, fillAborted bool) error {
+	if !isValidStatus(status) {
+		return fmt.Errorf("%w: %d", ErrInvalidStatus, status)
+	}
+
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	if t.quiesced {
+		return ErrQuiesced
+	}
+	base := t.xidCounter.Load()
+	if xid <= base {
+		return fmt.Errorf("%w: xid %d, local counter %d", ErrImportOverlap, xid, base)
+	}
+	if xid > base+1 && !fillAborted {
+		return fmt.Errorf("%w: xid %d, local counter %d", ErrXIDGap, xid, base)
+	}
+
+	buf := make([]byte, (xid-base)*XidFieldSize)
+	for i := range buf {
+		buf[i] = FieldTranAborted
+	}
+	buf[len(buf)-XidFieldSize] = status
+
+	t.fileLock.RLock()
+	err := ErrClosed
+	if !t.closed {
+		_, err = t.file.WriteAt(buf, t.getXidPosition(base+1))
+	}
+	t.fileLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	err = t.writeXIDCounter(xid)
+	if err != nil {
+		return err
+	}
+	t.xidCounter.Store(xid)
+
+	for filled := base + 1; filled < xid; filled++ {
+		t.emitChange(filled, FieldTranAborted)
+	}
+	t.emitChange(xid, status)
+	if status == FieldTranActive {
+		t.stats.active.Add(1)
+	}
+	if status == FieldTranActive || status == FieldTranPrepared {
+		t.markBegan(xid, false)
+	}
+	return nil
+}
+
+// statusOf 通过 TransactionManager 接口查询 xid 的状态字节，src 支持 IsPrepared 时也能查出已准备的状态
+func statusOf(src TransactionManager, xid int64) (byte, error) {
+	if p, ok := src.(interface{ IsPrepared(int64) (bool, error) }); ok {
+		prepared, err := p.IsPrepared(xid)
+		if err != nil {
+			return 0, err
+		}
+		if prepared {
+			return FieldTranPrepared, nil
+		}
+	}
+	checks := []struct {
+		is     func(int64) (bool, error)
+		status byte
+	}{
+		{src.IsActive, FieldTranActive},
+		{src.IsCommitted, FieldTranCommitted},
+		{src.IsAborted, FieldTranAborted},
+	}
+	for _, c := range checks {
+		ok, err := c.is(xid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return c.status, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown status for xid %d", ErrBadXIDFile, xid)
+}
@@ -0,0 +1,154 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportRemapped(t *testing.T) {
+	src, err := Create(filepath.Join(t.TempDir(), "test_src"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer src.Close()
+
+	committed := mustBegin(t, src)
+	aborted := mustBegin(t, src)
+	active := mustBegin(t, src)
+	src.Commit(committed)
+	src.Abort(aborted)
+
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	local := mustBegin(t, tm)
+	tm.Commit(local)
+
+	mapping, err := tm.ImportRemapped(src, 10)
+	if err != nil {
+		t.Fatalf("ImportRemapped failed: %v", err)
+	}
+
+	expected := map[int64]int64{committed: 11, aborted: 12, active: 13}
+	for oldXid, newXid := range expected {
+		if mapping[oldXid] != newXid {
+			t.Errorf("Expected xid %d to map to %d, got %d", oldXid, newXid, mapping[oldXid])
+		}
+	}
+	if !checkStatus(t, tm.IsCommitted, 11) || !checkStatus(t, tm.IsAborted, 12) || !checkStatus(t, tm.IsActive, 13) {
+		t.Errorf("Imported statuses do not match the source")
+	}
+	if tm.XidCounter() != 13 {
+		t.Errorf("Expected xidCounter 13, got %d", tm.XidCounter())
+	}
+
+	// 本地事务不受影响，空隙被标记为已取消
+	if !checkStatus(t, tm.IsCommitted, local) {
+		t.Errorf("Local transaction was overwritten")
+	}
+	for xid := int64(2); xid <= 10; xid++ {
+		if !checkStatus(t, tm.IsAborted, xid) {
+			t.Errorf("Expected gap xid %d to be aborted", xid)
+		}
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed after import: %v", err)
+	}
+}
+
+func TestImportRemappedOverlap(t *testing.T) {
+	src, err := Create(filepath.Join(t.TempDir(), "test_src"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer src.Close()
+	src.Begin()
+
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	tm.Begin()
+
+	_, err = tm.ImportRemapped(src, 0)
+	if !errors.Is(err, ErrImportOverlap) {
+		t.Errorf("Expected ErrImportOverlap, got %v", err)
+	}
+}
+
+func TestBeginAtInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	statuses := []byte{FieldTranCommitted, FieldTranAborted, FieldTranActive, FieldTranPrepared}
+	for i, status := range statuses {
+		if err := tm.BeginAt(int64(i+1), status, false); err != nil {
+			t.Fatalf("BeginAt(%d) failed: %v", i+1, err)
+		}
+	}
+	if tm.XidCounter() != 4 || tm.ActiveCount() != 1 {
+		t.Errorf("Expected counter 4 with 1 active, got %d and %d", tm.XidCounter(), tm.ActiveCount())
+	}
+	if err := tm.BeginAt(4, FieldTranCommitted, false); !errors.Is(err, ErrImportOverlap) {
+		t.Errorf("Reserving an allocated xid: expected ErrImportOverlap, got %v", err)
+	}
+	if err := tm.BeginAt(5, 9, false); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+	tm.Close()
+
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm.Close()
+	for i, status := range statuses {
+		got, err := tm.GetStatus(int64(i + 1))
+		if err != nil || got != Status(status) {
+			t.Errorf("Xid %d: expected %v, got %v (%v)", i+1, Status(status), got, err)
+		}
+	}
+	// 之后的 Begin 从保留的 XID 之后继续分配
+	if xid := mustBegin(t, tm); xid != 5 {
+		t.Errorf("Expected the next xid to be 5, got %d", xid)
+	}
+}
+
+func TestBeginAtGap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	mustBegin(t, tm)
+	if err := tm.BeginAt(4, FieldTranCommitted, false); !errors.Is(err, ErrXIDGap) {
+		t.Fatalf("Expected ErrXIDGap, got %v", err)
+	}
+	if tm.XidCounter() != 1 {
+		t.Errorf("Rejected BeginAt should not move the counter, got %d", tm.XidCounter())
+	}
+
+	if err := tm.BeginAt(4, FieldTranCommitted, true); err != nil {
+		t.Fatalf("BeginAt with fillAborted failed: %v", err)
+	}
+	for _, xid := range []int64{2, 3} {
+		if !checkStatus(t, tm.IsAborted, xid) {
+			t.Errorf("Gap xid %d should be aborted", xid)
+		}
+	}
+	if !checkStatus(t, tm.IsCommitted, 4) || tm.XidCounter() != 4 {
+		t.Errorf("Expected xid 4 committed with counter 4, got counter %d", tm.XidCounter())
+	}
+}
@@ -0,0 +1,47 @@
+package tm
+
+// iterateChunk 是 ForEachCommitted/ForEachAborted/Dump 每次从文件读取的 XID 个数
+const iterateChunk = 4096
+
+// ForEachCommitted 按升序对每个已提交的 XID 调用 fn，fn 返回 false 时停止遍历。
+// 遍历范围是调用时的 (BaseXID, xidCounter]，Checkpoint 丢弃的 XID 不会被访问
+func (t *TransactionManagerImpl) ForEachCommitted(fn func(xid int64) bool) error {
+	return t.forEachStatus(FieldTranCommitted, fn)
+}
+
+// ForEachAborted 按升序对每个已取消的 XID 调用 fn，fn 返回 false 时停止遍历
+func (t *TransactionManagerImpl) ForEachAborted(fn func(xid int64) bool) error {
+	return t.forEachStatus(FieldTranAborted, fn)
+}
+
+// forEachStatus 按升序对每个处于 status 状态的 XID 调用 fn
+func (t *TransactionManagerImpl) forEachStatus(status byte, fn func(xid int64) bool) error {
+	return t.forEachXID(func(xid int64, b byte) bool {
+		return b != status || fn(xid)
+	})
+}
+
+// forEachXID 分块读取状态字节，按升序对 (BaseXID, xidCounter] 中的每个 XID 和它的状态字节调用 fn。
+// 回调时不持有任何锁，fn 中可以继续使用事务管理器
+func (t *TransactionManagerImpl) forEachXID(fn func(xid int64, status byte) bool) error {
+	counter := t.XidCounter()
+	from := t.BaseXID() + 1
+
+	for from <= counter {
+		to := from + iterateChunk - 1
+		if to > counter {
+			to = counter
+		}
+		statuses, err := t.readStatuses(from, to)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(statuses); i += XidFieldSize {
+			if !fn(from+int64(i/XidFieldSize), statuses[i]) {
+				return nil
+			}
+		}
+		from = to + 1
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package tm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestForEachCommitted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	// 跨过多个分块: 3 的倍数提交，5 的倍数取消，其余保持活跃
+	var committed, aborted []int64
+	for i := 1; i <= iterateChunk*2+10; i++ {
+		xid := mustBegin(t, tm)
+		switch {
+		case i%3 == 0:
+			tm.Commit(xid)
+			committed = append(committed, xid)
+		case i%5 == 0:
+			tm.Abort(xid)
+			aborted = append(aborted, xid)
+		}
+	}
+
+	var got []int64
+	err = tm.ForEachCommitted(func(xid int64) bool {
+		got = append(got, xid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachCommitted failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, committed) {
+		t.Errorf("Expected %d committed xids, got %d", len(committed), len(got))
+	}
+
+	got = nil
+	err = tm.ForEachAborted(func(xid int64) bool {
+		got = append(got, xid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachAborted failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, aborted) {
+		t.Errorf("Expected %d aborted xids, got %d", len(aborted), len(got))
+	}
+}
+
+func TestForEachCommittedStopsEarly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	var committed []int64
+	for i := 0; i < 10; i++ {
+		xid := mustBegin(t, tm)
+		tm.Commit(xid)
+		committed = append(committed, xid)
+	}
+
+	var got []int64
+	err = tm.ForEachCommitted(func(xid int64) bool {
+		got = append(got, xid)
+		return len(got) < 3
+	})
+	if err != nil {
+		t.Fatalf("ForEachCommitted failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, committed[:3]) {
+		t.Errorf("Expected %v, got %v", committed[:3], got)
+	}
+}
+
+func TestForEachCommittedEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	err = tm.ForEachCommitted(func(xid int64) bool {
+		t.Errorf("Unexpected xid %d", xid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachCommitted failed: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package tm
+
+import (
+	"fmt"
+	"sync"
+)
+
+// MemoryTransactionManager 是完全在内存中保存事务状态的 TransactionManager，
+// 行为与 TransactionManagerImpl 相同，但关闭后状态全部丢失，主要用于测试
+type MemoryTransactionManager struct {
+	lock     sync.Mutex
+	statuses []byte // statuses[xid-1] 是 xid 的状态
+	// closed 在 Close 之后为 true，之后的操作与文件实现一样返回 ErrClosed
+	closed bool
+
+	observerLock sync.RWMutex
+	observer     Observer
+}
+
+// NewMemoryTransactionManager 创建一个空的内存事务管理器
+func NewMemoryTransactionManager() *MemoryTransactionManager {
+	return &MemoryTransactionManager{}
+}
+
+func (m *MemoryTransactionManager) Begin() (int64, error) {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return 0, ErrClosed
+	}
+	m.statuses = append(m.statuses, FieldTranActive)
+	xid := int64(len(m.statuses))
+	m.lock.Unlock()
+
+	if o := m.getObserver(); o != nil {
+		o.OnBegin(xid)
+	}
+	return xid, nil
+}
+
+func (m *MemoryTransactionManager) Commit(xid int64) error {
+	done, err := m.update(xid, StatusCommitted)
+	if o := m.getObserver(); err == nil && !done && o != nil {
+		o.OnCommit(xid)
+	}
+	return err
+}
+
+func (m *MemoryTransactionManager) Abort(xid int64) error {
+	done, err := m.update(xid, StatusAborted)
+	if o := m.getObserver(); err == nil && !done && o != nil {
+		o.OnAbort(xid)
+	}
+	return err
+}
+
+// BeginWithSnapshot 与 TransactionManagerImpl.BeginWithSnapshot 相同，在同一次加锁中拍快照并分配 XID
+func (m *MemoryTransactionManager) BeginWithSnapshot() (int64, Snapshot, error) {
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return 0, nil, ErrClosed
+	}
+	snap := make(Snapshot)
+	for i, status := range m.statuses {
+		if status == FieldTranActive || status == FieldTranPrepared {
+			snap[int64(i)+1] = struct{}{}
+		}
+	}
+	m.statuses = append(m.statuses, FieldTranActive)
+	xid := int64(len(m.statuses))
+	m.lock.Unlock()
+
+	if o := m.getObserver(); o != nil {
+		o.OnBegin(xid)
+	}
+	return xid, snap, nil
+}
+
+// SetObserver 与 TransactionManagerImpl.SetObserver 相同，在锁外回调
+func (m *MemoryTransactionManager) SetObserver(o Observer) {
+	m.observerLock.Lock()
+	defer m.observerLock.Unlock()
+	m.observer = o
+}
+
+func (m *MemoryTransactionManager) getObserver() Observer {
+	m.observerLock.RLock()
+	defer m.observerLock.RUnlock()
+	return m.observer
+}
+
+// update 把 xid 结束为 status，重复的结束 done 为 true，不合法的转换返回 ErrIllegalTransition
+func (m *MemoryTransactionManager) update(xid int64, status Status) (done bool, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if xid < 1 || xid > int64(len(m.statuses)) {
+		return false, fmt.Errorf("%w: %d is outside [1, %d]", ErrInvalidXID, xid, len(m.statuses))
+	}
+	if m.closed {
+		return false, ErrClosed
+	}
+	done, err = checkTransition(xid, Status(m.statuses[xid-1]), status)
+	if done || err != nil {
+		return done, err
+	}
+	m.statuses[xid-1] = byte(status)
+	return false, nil
+}
+
+// GetStatus 返回 xid 的状态，SuperXid 总是已提交
+func (m *MemoryTransactionManager) GetStatus(xid int64) (Status, error) {
+	if xid == SuperXid {
+		return StatusCommitted, nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if xid < 1 || xid > int64(len(m.statuses)) {
+		return 0, fmt.Errorf("%w: %d is outside [1, %d]", ErrInvalidXID, xid, len(m.statuses))
+	}
+	if m.closed {
+		return 0, ErrClosed
+	}
+	return Status(m.statuses[xid-1]), nil
+}
+
+func (m *MemoryTransactionManager) IsActive(xid int64) (bool, error) {
+	status, err := m.GetStatus(xid)
+	return status == StatusActive && err == nil, err
+}
+
+func (m *MemoryTransactionManager) IsCommitted(xid int64) (bool, error) {
+	status, err := m.GetStatus(xid)
+	return status == StatusCommitted && err == nil, err
+}
+
+func (m *MemoryTransactionManager) IsAborted(xid int64) (bool, error) {
+	status, err := m.GetStatus(xid)
+	return status == StatusAborted && err == nil, err
+}
+
+func (m *MemoryTransactionManager) XidCounter() int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return int64(len(m.statuses))
+}
+
+// Flush 在内存实现中什么也不做，关闭之后返回 ErrClosed
+func (m *MemoryTransactionManager) Flush() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close 之后除 XidCounter 和 SuperXid 的状态查询以外的操作都返回 ErrClosed，再次 Close 也返回 ErrClosed
+func (m *MemoryTransactionManager) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,47 @@
+package tm
+
+// Observer 在事务开启、提交、取消之后被调用，可以用来记录追踪或者指标。
+// 回调时不持有 counterLock，回调里可以再调用事务管理器的方法；
+// 并发的事务会并发地回调，Observer 需要自己保证并发安全
+type Observer interface {
+	OnBegin(xid int64)
+	OnCommit(xid int64)
+	OnAbort(xid int64)
+}
+
+// SetObserver 设置事务生命周期的观察者，传入 nil 取消观察。只读事务不会触发回调
+func (t *TransactionManagerImpl) SetObserver(o Observer) {
+	t.observerLock.Lock()
+	defer t.observerLock.Unlock()
+	t.observer = o
+}
+
+// getObserver 返回当前的观察者，调用者在锁外回调，避免用户代码阻塞 SetObserver
+func (t *TransactionManagerImpl) getObserver() Observer {
+	t.observerLock.RLock()
+	defer t.observerLock.RUnlock()
+	return t.observer
+}
+
+func (t *TransactionManagerImpl) notifyBegin(xid int64) {
+	if o := t.getObserver(); o != nil {
+		o.OnBegin(xid)
+	}
+}
+
+// notifyCommit 和 notifyAbort 在回调 Observer 之后发出 Events 事件，并唤醒 WaitDecided
+func (t *TransactionManagerImpl) notifyCommit(xid int64) {
+	if o := t.getObserver(); o != nil {
+		o.OnCommit(xid)
+	}
+	t.events.send(TxEvent{Xid: xid, Status: StatusCommitted})
+	t.wakeDecided(xid)
+}
+
+func (t *TransactionManagerImpl) notifyAbort(xid int64) {
+	if o := t.getObserver(); o != nil {
+		o.OnAbort(xid)
+	}
+	t.events.send(TxEvent{Xid: xid, Status: StatusAborted})
+	t.wakeDecided(xid)
+}
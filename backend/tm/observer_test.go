@@ -0,0 +1,114 @@
+package tm
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordingObserver 按顺序记录收到的回调
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(event string, xid int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf("%s %d", event, xid))
+}
+
+func (o *recordingObserver) OnBegin(xid int64)  { o.record("begin", xid) }
+func (o *recordingObserver) OnCommit(xid int64) { o.record("commit", xid) }
+func (o *recordingObserver) OnAbort(xid int64)  { o.record("abort", xid) }
+
+func TestObserver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	// 未设置观察者时不应该 panic
+	xid0 := mustBegin(t, tm)
+	tm.Commit(xid0)
+
+	o := &recordingObserver{}
+	tm.SetObserver(o)
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	if err := tm.Commit(xid1); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := tm.Abort(xid2); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	// 失败的操作不回调
+	tm.Commit(xid2 + 100)
+
+	tm.SetObserver(nil)
+	tm.Commit(mustBegin(t, tm))
+
+	want := []string{
+		fmt.Sprintf("begin %d", xid1),
+		fmt.Sprintf("begin %d", xid2),
+		fmt.Sprintf("commit %d", xid1),
+		fmt.Sprintf("abort %d", xid2),
+	}
+	if !reflect.DeepEqual(o.events, want) {
+		t.Errorf("Expected events %v, got %v", want, o.events)
+	}
+}
+
+// reentrantObserver 在回调中再调用事务管理器，持有 counterLock 回调时会死锁
+type reentrantObserver struct {
+	recordingObserver
+	tm *TransactionManagerImpl
+}
+
+func (o *reentrantObserver) OnBegin(xid int64) {
+	o.tm.XidCounter()
+	o.record("begin", xid)
+}
+
+func TestObserverReentrant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	o := &reentrantObserver{tm: tm}
+	tm.SetObserver(o)
+	xid := mustBegin(t, tm)
+	if len(o.events) != 1 || o.events[0] != fmt.Sprintf("begin %d", xid) {
+		t.Errorf("Unexpected events %v", o.events)
+	}
+}
+
+func TestRepeatedEndNotifiesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	o := &recordingObserver{}
+	tm.SetObserver(o)
+
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+	tm.Commit(xid)
+	tm.Abort(xid)
+	if stats := tm.Stats(); stats.Commits != 1 || stats.Aborts != 0 || stats.Active != 0 {
+		t.Errorf("Expected one commit and no aborts, got %+v", stats)
+	}
+	if len(o.events) != 2 {
+		t.Errorf("Expected begin and commit events only, got %v", o.events)
+	}
+}
@@ -0,0 +1,83 @@
+package tm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenOrCreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	// 文件不存在时创建
+	tm, err := OpenOrCreate(path)
+	if err != nil {
+		t.Fatalf("OpenOrCreate failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+	if err := tm.Commit(xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	tm.Close()
+
+	// 文件存在时打开，不清空已有的状态
+	tm, err = OpenOrCreate(path)
+	if err != nil {
+		t.Fatalf("OpenOrCreate failed: %v", err)
+	}
+	if tm.XidCounter() != 1 || !checkStatus(t, tm.IsCommitted, xid) {
+		t.Errorf("Expected the existing file to be opened, counter %d", tm.XidCounter())
+	}
+	tm.Close()
+
+	// 已有文件的文件头损坏时像 Open 一样报错，也不覆盖它
+	os.WriteFile(path+XidSuffix, []byte{1, 2, 3}, 0666)
+	if _, err := OpenOrCreate(path); !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected ErrBadXIDFile for a corrupt file, got %v", err)
+	}
+	if data, _ := os.ReadFile(path + XidSuffix); len(data) != 3 {
+		t.Errorf("Expected the corrupt file to be left alone, got %d bytes", len(data))
+	}
+}
+
+func TestCreateRefusesToClobber(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	mustBegin(t, tm)
+	tm.Close()
+
+	if _, err := Create(path); !errors.Is(err, ErrFileExists) {
+		t.Fatalf("Expected ErrFileExists, got %v", err)
+	}
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if tm.XidCounter() != 1 {
+		t.Errorf("Expected the refused Create to keep the file, counter %d", tm.XidCounter())
+	}
+	tm.Close()
+
+	// WithOverwrite 明确要求时清空原有的内容
+	tm, err = Create(path, WithOverwrite(true))
+	if err != nil {
+		t.Fatalf("Create with overwrite failed: %v", err)
+	}
+	if tm.XidCounter() != 0 {
+		t.Errorf("Expected a fresh file, counter %d", tm.XidCounter())
+	}
+	tm.Close()
+
+	// 空文件不算已经存在
+	os.WriteFile(path+XidSuffix, nil, 0666)
+	tm, err = Create(path)
+	if err != nil {
+		t.Fatalf("Create over an empty file failed: %v", err)
+	}
+	tm.Close()
+}
@@ -0,0 +1,90 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrDirNotFound 表示 XID 文件所在的目录不存在
+var ErrDirNotFound = errors.New("xid file directory does not exist")
+
+// options 保存 Create/Open 的可选配置
+type options struct {
+	syncMode SyncMode
+	suffix   string
+	clock    func() time.Time
+	// reapInterval 是 SetTransactionTimeout 检查超时事务的间隔
+	reapInterval time.Duration
+	// eventBuffer 和 eventPolicy 配置 Events 返回的通道
+	eventBuffer int
+	eventPolicy EventPolicy
+	// preallocChunk 是 XID 文件每次增长的字节数，见 WithPreallocate
+	preallocChunk int64
+	allowMigrate  bool
+	openFile      OpenFileFunc
+	// customOpen 表示 openFile 由 WithOpenFile 设置，路径不一定在本地文件系统上
+	customOpen bool
+	overwrite  bool
+}
+
+// Option 用于在 Create/Open 时配置事务管理器
+type Option func(*options)
+
+// WithSyncMode 设置刷盘模式，默认为 SyncAlways
+func WithSyncMode(mode SyncMode) Option {
+	return func(o *options) {
+		o.syncMode = mode
+	}
+}
+
+// WithSuffix 设置 XID 文件的后缀，默认为 XidSuffix。
+// 传入空字符串时 path 本身就是完整的文件名
+func WithSuffix(suffix string) Option {
+	return func(o *options) {
+		o.suffix = suffix
+	}
+}
+
+// WithOverwrite 为 true 时 Create 清空已经存在的非空文件，默认拒绝覆盖并返回 ErrFileExists
+func WithOverwrite(overwrite bool) Option {
+	return func(o *options) {
+		o.overwrite = overwrite
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{suffix: XidSuffix, clock: time.Now, allowMigrate: true, openFile: openOSFile}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// filePath 返回 path 对应的 XID 文件路径，并检查所在目录是否存在。
+// path 已经以后缀结尾时不会重复追加，设置了 WithOpenFile 时不检查目录
+func (o options) filePath(path string) (string, error) {
+	filePath := path
+	if !strings.HasSuffix(path, o.suffix) {
+		filePath = path + o.suffix
+	}
+	if o.customOpen {
+		return filePath, nil
+	}
+
+	dir := filepath.Dir(filePath)
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s", ErrDirNotFound, dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%w: %s is not a directory", ErrDirNotFound, dir)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,73 @@
+package tm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCustomSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSuffix(".tx"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+	tm.Close()
+
+	if _, err := os.Stat(path + XidSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s file, got %v", XidSuffix, err)
+	}
+	tm2, err := Open(path, WithSuffix(".tx"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm2.Close()
+	if !checkStatus(t, tm2.IsCommitted, xid) {
+		t.Errorf("Expected xid %d to be committed", xid)
+	}
+}
+
+func TestFullFileName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file.state")
+	tm, err := Create(path, WithSuffix(""))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	tm.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected %s to exist: %v", path, err)
+	}
+}
+
+func TestPathAlreadyHasSuffix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path + XidSuffix)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	tm.Close()
+
+	// 带后缀和不带后缀的路径指向同一个文件
+	tm2, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	tm2.Close()
+	if _, err := os.Stat(path + XidSuffix + XidSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected suffix not to be appended twice, got %v", err)
+	}
+}
+
+func TestMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "no_such_dir", "test_file")
+	if _, err := Create(path); !errors.Is(err, ErrDirNotFound) {
+		t.Errorf("Expected ErrDirNotFound from Create, got %v", err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrDirNotFound) {
+		t.Errorf("Expected ErrDirNotFound from Open, got %v", err)
+	}
+}
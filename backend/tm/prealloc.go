@@ -0,0 +1,59 @@
+package tm
+
+import "bytes"
+
+// fieldReserved 填充预分配的、还没有分配给任何 XID 的状态区。
+// 它不是合法的状态，所以文件末尾的预留空间可以和崩溃时写入了状态但没有推进 xidCounter 的 XID 区分开
+const fieldReserved = byte(0xFF)
+
+// WithPreallocate 让 XID 文件按 chunk 字节一块一块地增长: Begin 分配的 XID 超出已分配的状态区时，
+// 一次写入 chunk 字节的预留空间，而不是每次 Begin 把文件加长一个字节，以减少小的写入和文件系统碎片。
+// 文件的实际长度因此可能大于 ExpectedFileLen，多出的部分在 Verify 和打开文件时被当作预留空间。
+// chunk <= 0 时不预分配，这是默认的行为
+func WithPreallocate(chunk int64) Option {
+	return func(o *options) {
+		o.preallocChunk = chunk
+	}
+}
+
+// reserve 在 begin 写入 xid 的状态之前调用，xid 超出已分配的状态区时从 xid 的位置开始追加一块预留空间。
+// 调用者需持有 counterLock
+func (t *TransactionManagerImpl) reserve(xid int64) error {
+	if t.preallocChunk <= 0 {
+		return nil
+	}
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	if t.closed {
+		return ErrClosed
+	}
+
+	pos := t.getXidPosition(xid)
+	if pos < t.allocated {
+		return nil
+	}
+	_, err := t.file.WriteAt(bytes.Repeat([]byte{fieldReserved}, int(t.preallocChunk)), pos)
+	if err != nil {
+		return &XIDError{Op: "reserve", Xid: xid, Offset: pos, Err: err}
+	}
+	t.allocated = pos + t.preallocChunk
+	return nil
+}
+
+// trimReserved 返回去掉文件末尾预留空间之后的长度，只检查 [from, fileLen) 中末尾连续的 fieldReserved 字节。
+// 调用者需持有 fileLock
+func (t *TransactionManagerImpl) trimReserved(from, fileLen int64) (int64, error) {
+	if fileLen <= from {
+		return fileLen, nil
+	}
+	tail := make([]byte, fileLen-from)
+	_, err := t.file.ReadAt(tail, from)
+	if err != nil {
+		return 0, err
+	}
+	end := len(tail)
+	for end > 0 && tail[end-1] == fieldReserved {
+		end--
+	}
+	return from + int64(end), nil
+}
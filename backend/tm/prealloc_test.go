@@ -0,0 +1,109 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreallocateGrowsInChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithPreallocate(64))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	mustBegin(t, tm)
+	if size, _ := tm.FileSize(); size != LenXidHeaderLength+64 {
+		t.Errorf("Expected the first Begin to allocate a chunk, file size %d", size)
+	}
+	for i := 1; i < 64; i++ {
+		mustBegin(t, tm)
+	}
+	if size, _ := tm.FileSize(); size != LenXidHeaderLength+64 {
+		t.Errorf("Expected 64 XIDs to fit in one chunk, file size %d", size)
+	}
+	mustBegin(t, tm)
+	if size, _ := tm.FileSize(); size != LenXidHeaderLength+128 {
+		t.Errorf("Expected a second chunk, file size %d", size)
+	}
+
+	if expected := int64(LenXidHeaderLength + 65*XidFieldSize); tm.ExpectedFileLen() != expected {
+		t.Errorf("Expected logical length %d, got %d", expected, tm.ExpectedFileLen())
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed with reserved space: %v", err)
+	}
+	if err := tm.Verify(); err != nil {
+		t.Errorf("Verify failed with reserved space: %v", err)
+	}
+}
+
+func TestReopenPreallocatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithPreallocate(4096))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	committed := mustBegin(t, tm)
+	tm.Commit(committed)
+	aborted := mustBegin(t, tm)
+	tm.Abort(aborted)
+	active := mustBegin(t, tm)
+	tm.Close()
+
+	// 不带 WithPreallocate 也能打开预分配过的文件
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if tm.XidCounter() != active {
+		t.Errorf("Expected xidCounter %d, got %d", active, tm.XidCounter())
+	}
+	if !checkStatus(t, tm.IsCommitted, committed) || !checkStatus(t, tm.IsAborted, aborted) || !checkStatus(t, tm.IsActive, active) {
+		t.Errorf("Statuses not preserved across reopen")
+	}
+	if next := mustBegin(t, tm); next != active+1 {
+		t.Errorf("Expected next xid %d, got %d", active+1, next)
+	}
+	tm.Close()
+
+	tm, err = Open(path, WithPreallocate(4096))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer tm.Close()
+	next := mustBegin(t, tm)
+	if size, _ := tm.FileSize(); size != LenXidHeaderLength+4096 {
+		t.Errorf("Expected Begin to reuse the reserved space, file size %d", size)
+	}
+	if !checkStatus(t, tm.IsActive, next) {
+		t.Errorf("Expected xid %d to be active", next)
+	}
+}
+
+func TestPreallocateCounterBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithPreallocate(64))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	mustBegin(t, tm)
+	xid := mustBegin(t, tm)
+	// 模拟崩溃时状态已经写入而计数器没有推进，预留空间不影响检测
+	tm.writeXIDCounter(1)
+	tm.xidCounter.Store(1)
+	if err := tm.Verify(); !errors.Is(err, ErrXIDCounterBehind) {
+		t.Fatalf("Expected ErrXIDCounterBehind, got %v", err)
+	}
+	if err := tm.Repair(); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if tm.XidCounter() != xid {
+		t.Errorf("Expected xidCounter %d after repair, got %d", xid, tm.XidCounter())
+	}
+}
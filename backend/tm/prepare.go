@@ -0,0 +1,83 @@
+package tm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 两阶段提交:
+//
+// Prepare 把活跃的事务标记为已准备并立即刷盘，之后它只能被 Commit 或 Abort。
+// 已准备的事务不再算作活跃事务(IsActive 返回 false，也不计入活跃事务数)，
+// 但它还没有提交，快照和 Checkpoint 仍然把它当作进行中的事务
+
+// ErrNotActive 表示事务不处于活跃状态，不能被准备
+var ErrNotActive = errors.New("transaction is not active")
+
+// RecoveryReport 是打开时还没有结束的事务，Active 是崩溃时还在进行的事务，
+// Prepared 是已经准备、等待协调者决定提交还是取消的事务
+type RecoveryReport struct {
+	Active   []int64
+	Prepared []int64
+}
+
+// Prepare 把活跃的事务 xid 标记为已准备，无论刷盘模式如何，返回时状态都已经持久化
+func (t *TransactionManagerImpl) Prepare(xid int64) error {
+	err := t.checkAllocated(xid)
+	if err != nil {
+		return err
+	}
+	unlock := t.lockEnd(xid)
+	defer unlock()
+	active, err := t.IsActive(xid)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("%w: %d", ErrNotActive, xid)
+	}
+
+	err = t.updateXID(xid, FieldTranPrepared)
+	if err == nil && t.syncMode != SyncAlways {
+		t.fileLock.RLock()
+		err = t.file.Sync()
+		t.fileLock.RUnlock()
+	}
+	if err != nil {
+		return err
+	}
+	t.stats.decrActive(1)
+	return nil
+}
+
+func (t *TransactionManagerImpl) IsPrepared(xid int64) (bool, error) {
+	status, err := t.GetStatus(xid)
+	return status == StatusPrepared && err == nil, err
+}
+
+// PreparedXIDs 按升序返回所有已准备、还没有提交或取消的 XID
+func (t *TransactionManagerImpl) PreparedXIDs() ([]int64, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	return t.collectXIDs(FieldTranPrepared)
+}
+
+// OpenWithRecoveryReport 与 OpenWithRecovery 相同，但把已准备的事务与活跃的事务分开返回，
+// 协调者据此决定已准备的事务提交还是取消
+func OpenWithRecoveryReport(path string, opts ...Option) (*TransactionManagerImpl, RecoveryReport, error) {
+	t, err := Open(path, opts...)
+	if err != nil {
+		return nil, RecoveryReport{}, err
+	}
+
+	var report RecoveryReport
+	report.Active, err = t.ActiveXIDs()
+	if err == nil {
+		report.Prepared, err = t.PreparedXIDs()
+	}
+	if err != nil {
+		t.Close()
+		return nil, RecoveryReport{}, err
+	}
+	return t, report, nil
+}
@@ -0,0 +1,103 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareThenCommitOrAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	toCommit := mustBegin(t, tm)
+	toAbort := mustBegin(t, tm)
+	for _, xid := range []int64{toCommit, toAbort} {
+		if err := tm.Prepare(xid); err != nil {
+			t.Fatalf("Prepare failed: %v", err)
+		}
+		if prepared, _ := tm.IsPrepared(xid); !prepared {
+			t.Errorf("Expected xid %d to be prepared", xid)
+		}
+		// 已准备的事务不再是活跃事务，也不能再次准备
+		if active, _ := tm.IsActive(xid); active {
+			t.Errorf("Expected prepared xid %d not to be active", xid)
+		}
+		if err := tm.Prepare(xid); !errors.Is(err, ErrNotActive) {
+			t.Errorf("Expected ErrNotActive when preparing twice, got %v", err)
+		}
+	}
+	if tm.ActiveCount() != 0 {
+		t.Errorf("Expected 0 active transactions, got %d", tm.ActiveCount())
+	}
+	// 已准备的事务还没有提交，快照中仍然包含它们
+	snap, _ := tm.ActiveSnapshot()
+	if !tm.IsActiveAt(toCommit, snap) || !tm.IsActiveAt(toAbort, snap) {
+		t.Errorf("Expected prepared xids in the snapshot, got %v", snap)
+	}
+
+	if err := tm.Commit(toCommit); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := tm.Abort(toAbort); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if !mustCheckXID(t, tm, toCommit, FieldTranCommitted) || !mustCheckXID(t, tm, toAbort, FieldTranAborted) {
+		t.Errorf("Expected prepared xids to reach their final states")
+	}
+	if tm.ActiveCount() != 0 {
+		t.Errorf("Expected ending prepared xids to keep the active count at 0, got %d", tm.ActiveCount())
+	}
+	if err := tm.Prepare(toCommit); !errors.Is(err, ErrNotActive) {
+		t.Errorf("Expected ErrNotActive when preparing a committed xid, got %v", err)
+	}
+	if status, _ := tm.GetStatus(toCommit); status.String() != "committed" {
+		t.Errorf("Unexpected status %v", status)
+	}
+}
+
+func TestOpenWithRecoveryReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	active := mustBegin(t, tm)
+	inDoubt := mustBegin(t, tm)
+	committed := mustBegin(t, tm)
+	tm.Prepare(inDoubt)
+	tm.Prepare(committed)
+	tm.Commit(committed)
+	// 模拟崩溃: active 还在进行，inDoubt 已准备但还没有决定
+	tm.Close()
+
+	tm, report, err := OpenWithRecoveryReport(path)
+	if err != nil {
+		t.Fatalf("OpenWithRecoveryReport failed: %v", err)
+	}
+	defer tm.Close()
+	expected := RecoveryReport{Active: []int64{active}, Prepared: []int64{inDoubt}}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, report)
+	}
+	if status, _ := tm.GetStatus(inDoubt); status != StatusPrepared || status.String() != "prepared" {
+		t.Errorf("Expected xid %d to be prepared after reopen, got %v", inDoubt, status)
+	}
+	if tm.ActiveCount() != 1 {
+		t.Errorf("Expected 1 active transaction after reopen, got %d", tm.ActiveCount())
+	}
+
+	// 协调者决定提交
+	if err := tm.Commit(inDoubt); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if prepared, _ := tm.PreparedXIDs(); len(prepared) != 0 {
+		t.Errorf("Expected no prepared xids after resolving, got %v", prepared)
+	}
+}
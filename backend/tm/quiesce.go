@@ -0,0 +1,48 @@
+package tm
+
+import "errors"
+
+// ErrQuiesced 表示事务管理器已经被 Quiesce，不再开启新的事务
+var ErrQuiesced = errors.New("transaction manager is quiesced")
+
+// AbortAllActive 取消所有仍处于活跃状态的事务，和 Abort 一样回调 Observer，返回被取消的事务数。
+// 扫描状态区时持有 counterLock，扫描之后开启的事务不会被取消，需要先 Quiesce 才能保证结束时没有活跃事务。
+// 扫描之后已经被提交或取消的事务跳过，不算作错误；已准备的事务不是活跃事务，不会被取消
+func (t *TransactionManagerImpl) AbortAllActive() (int, error) {
+	t.counterLock.Lock()
+	active, err := t.collectXIDs(FieldTranActive)
+	t.counterLock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, xid := range active {
+		ended, err := t.abortLocked(xid)
+		if ended {
+			aborted++
+			t.notifyAbort(xid)
+		}
+		if err != nil && !errors.Is(err, ErrIllegalTransition) {
+			return aborted, err
+		}
+	}
+	return aborted, nil
+}
+
+// Quiesce 禁止开启新的事务，然后取消所有活跃事务，用于关闭前让恢复没有需要回滚的事务。
+// 之后的 Begin、BeginAt 和 ImportRemapped 返回 ErrQuiesced，直到调用 Resume；只读事务不写文件，不受影响
+func (t *TransactionManagerImpl) Quiesce() (int, error) {
+	// 在 counterLock 下设置，已经拿到锁的 Begin 在扫描之前完成，之后的 Begin 都会看到这个标记
+	t.counterLock.Lock()
+	t.quiesced = true
+	t.counterLock.Unlock()
+	return t.AbortAllActive()
+}
+
+// Resume 撤销 Quiesce，重新允许开启新的事务
+func (t *TransactionManagerImpl) Resume() {
+	t.counterLock.Lock()
+	t.quiesced = false
+	t.counterLock.Unlock()
+}
@@ -0,0 +1,136 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAbortAllActive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	committed := mustBegin(t, tm)
+	active := []int64{mustBegin(t, tm), mustBegin(t, tm), mustBegin(t, tm)}
+	prepared := mustBegin(t, tm)
+	if err := tm.Commit(committed); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := tm.Prepare(prepared); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	o := &recordingObserver{}
+	tm.SetObserver(o)
+	n, err := tm.AbortAllActive()
+	if err != nil {
+		t.Fatalf("AbortAllActive failed: %v", err)
+	}
+	if n != len(active) {
+		t.Errorf("Expected %d aborted transactions, got %d", len(active), n)
+	}
+	for _, xid := range active {
+		if aborted, err := tm.IsAborted(xid); err != nil || !aborted {
+			t.Errorf("Expected xid %d to be aborted, got %v, %v", xid, aborted, err)
+		}
+	}
+	if len(o.events) != len(active) {
+		t.Errorf("Expected %d abort callbacks, got %v", len(active), o.events)
+	}
+	if tm.ActiveCount() != 0 {
+		t.Errorf("Expected no active transactions, got %d", tm.ActiveCount())
+	}
+	// 已提交和已准备的事务不受影响
+	if !checkStatus(t, tm.IsCommitted, committed) {
+		t.Errorf("Expected xid %d to stay committed", committed)
+	}
+	if status, err := tm.GetStatus(prepared); err != nil || status != StatusPrepared {
+		t.Errorf("Expected xid %d to stay prepared, got %v, %v", prepared, status, err)
+	}
+
+	if n, err := tm.AbortAllActive(); err != nil || n != 0 {
+		t.Errorf("Expected nothing left to abort, got %d, %v", n, err)
+	}
+}
+
+func TestQuiesce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	for i := 0; i < 5; i++ {
+		mustBegin(t, tm)
+	}
+
+	// Quiesce 与并发的 Begin 竞争: 成功开启的事务都必须被取消
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var began []int64
+	start := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for {
+				xid, err := tm.Begin()
+				if errors.Is(err, ErrQuiesced) {
+					return
+				}
+				if err != nil {
+					t.Errorf("Begin failed: %v", err)
+					return
+				}
+				mu.Lock()
+				began = append(began, xid)
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	if _, err := tm.Quiesce(); err != nil {
+		t.Fatalf("Quiesce failed: %v", err)
+	}
+	wg.Wait()
+
+	for _, xid := range began {
+		if !checkStatus(t, tm.IsAborted, xid) {
+			t.Errorf("Expected xid %d to be aborted", xid)
+		}
+	}
+	if tm.ActiveCount() != 0 {
+		t.Errorf("Expected no active transactions, got %d", tm.ActiveCount())
+	}
+	if active, err := tm.ActiveXIDs(); err != nil || len(active) != 0 {
+		t.Errorf("Expected no active xids, got %v, %v", active, err)
+	}
+	if _, err := tm.Begin(); !errors.Is(err, ErrQuiesced) {
+		t.Errorf("Expected ErrQuiesced, got %v", err)
+	}
+	if err := tm.BeginAt(tm.XidCounter()+1, FieldTranActive, false); !errors.Is(err, ErrQuiesced) {
+		t.Errorf("Expected ErrQuiesced from BeginAt, got %v", err)
+	}
+	src := NewMemoryTransactionManager()
+	src.Begin()
+	counter := tm.XidCounter()
+	if _, err := tm.ImportRemapped(src, counter); !errors.Is(err, ErrQuiesced) {
+		t.Errorf("Expected ErrQuiesced from ImportRemapped, got %v", err)
+	}
+	if tm.XidCounter() != counter || tm.ActiveCount() != 0 {
+		t.Errorf("Expected a rejected import to leave the file unchanged, counter %d, active %d", tm.XidCounter(), tm.ActiveCount())
+	}
+
+	tm.Resume()
+	xid := mustBegin(t, tm)
+	if !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Expected xid %d to be active after Resume", xid)
+	}
+}
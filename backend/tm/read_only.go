@@ -0,0 +1,83 @@
+package tm
+
+import "fmt"
+
+// 只读事务:
+//
+// BeginReadOnly 返回的 XID 是负数，不会占用 XID 文件中的位置，也不会推进 xidCounter。
+// 只读事务在开启时记录当时的活跃事务快照和 xidCounter，用于可见性判断。
+// 对只读事务调用 Commit/Abort 只会结束它，不会写文件；结束后它被视为已提交，
+// 因为它没有做过任何修改，提交和取消的效果相同
+
+// readOnlyTxn 是一个只读事务开启时的可见性快照
+type readOnlyTxn struct {
+	snapshot   Snapshot
+	xidCounter int64
+}
+
+func isReadOnlyXid(xid int64) bool {
+	return xid < SuperXid
+}
+
+// BeginReadOnly 开启一个只读事务
+func (t *TransactionManagerImpl) BeginReadOnly() (int64, error) {
+	t.counterLock.Lock()
+	active, err := t.collectXIDs(FieldTranActive, FieldTranPrepared)
+	counter := t.xidCounter.Load()
+	t.counterLock.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	snap := make(Snapshot, len(active))
+	for _, xid := range active {
+		snap[xid] = struct{}{}
+	}
+
+	t.readOnlyLock.Lock()
+	defer t.readOnlyLock.Unlock()
+	if t.readOnly == nil {
+		t.readOnly = make(map[int64]*readOnlyTxn)
+	}
+	t.readOnlyNext--
+	xid := t.readOnlyNext
+	t.readOnly[xid] = &readOnlyTxn{snapshot: snap, xidCounter: counter}
+	return xid, nil
+}
+
+// IsReadOnly 判断 xid 是否是一个只读事务
+func (t *TransactionManagerImpl) IsReadOnly(xid int64) bool {
+	return isReadOnlyXid(xid)
+}
+
+// ReadOnlySnapshot 返回只读事务开启时的活跃事务快照和 xidCounter，事务已结束时 ok 为 false。
+// xidCounter 之后开启的事务对这个只读事务都不可见
+func (t *TransactionManagerImpl) ReadOnlySnapshot(xid int64) (snap Snapshot, xidCounter int64, ok bool) {
+	t.readOnlyLock.Lock()
+	defer t.readOnlyLock.Unlock()
+	txn, ok := t.readOnly[xid]
+	if !ok {
+		return nil, 0, false
+	}
+	return txn.snapshot, txn.xidCounter, true
+}
+
+func (t *TransactionManagerImpl) endReadOnly(xid int64) {
+	t.readOnlyLock.Lock()
+	delete(t.readOnly, xid)
+	t.readOnlyLock.Unlock()
+	t.wakeDecided(xid)
+}
+
+// readOnlyStatus 返回只读事务的状态，xid 小于所有分配过的只读 XID 时返回 ErrInvalidXID
+func (t *TransactionManagerImpl) readOnlyStatus(xid int64) (Status, error) {
+	t.readOnlyLock.Lock()
+	defer t.readOnlyLock.Unlock()
+	if xid < t.readOnlyNext || t.readOnlyNext == 0 {
+		return 0, fmt.Errorf("%w: read-only xid %d was never allocated", ErrInvalidXID, xid)
+	}
+	if _, ok := t.readOnly[xid]; ok {
+		return StatusActive, nil
+	}
+	return StatusCommitted, nil
+}
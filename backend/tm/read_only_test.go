@@ -0,0 +1,86 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	return info.Size()
+}
+
+func TestBeginReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	writer := mustBegin(t, tm)
+	size := fileSize(t, path+XidSuffix)
+
+	ro, err := tm.BeginReadOnly()
+	if err != nil {
+		t.Fatalf("BeginReadOnly failed: %v", err)
+	}
+	if !tm.IsReadOnly(ro) || tm.IsReadOnly(writer) {
+		t.Errorf("IsReadOnly misreports transaction kinds")
+	}
+	if fileSize(t, path+XidSuffix) != size || tm.XidCounter() != writer {
+		t.Errorf("Read-only transaction grew the xid file")
+	}
+	if !checkStatus(t, tm.IsActive, ro) {
+		t.Errorf("Read-only transaction should be active while open")
+	}
+
+	snap, counter, ok := tm.ReadOnlySnapshot(ro)
+	if !ok || counter != writer || !tm.IsActiveAt(writer, snap) {
+		t.Errorf("Unexpected read-only snapshot (%v, %d, %v)", snap, counter, ok)
+	}
+
+	// 提交只读事务不写文件
+	if err := tm.Commit(ro); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if fileSize(t, path+XidSuffix) != size {
+		t.Errorf("Committing a read-only transaction wrote to the xid file")
+	}
+	if checkStatus(t, tm.IsActive, ro) {
+		t.Errorf("Read-only transaction still active after Commit")
+	}
+	if _, _, ok := tm.ReadOnlySnapshot(ro); ok {
+		t.Errorf("Snapshot should be dropped once the transaction ends")
+	}
+	if !checkStatus(t, tm.IsActive, writer) {
+		t.Errorf("Read-only transaction affected the writer")
+	}
+}
+
+func TestReadOnlyXidsAreDistinct(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	ro1, _ := tm.BeginReadOnly()
+	ro2, _ := tm.BeginReadOnly()
+	if ro1 == ro2 || ro1 == SuperXid || ro2 == SuperXid {
+		t.Errorf("Read-only xids must be unique, got %d and %d", ro1, ro2)
+	}
+	tm.Abort(ro1)
+	if checkStatus(t, tm.IsActive, ro1) || !checkStatus(t, tm.IsActive, ro2) {
+		t.Errorf("Ending one read-only transaction affected the other")
+	}
+	if stats := tm.Stats(); stats.Begins != 0 || stats.Aborts != 0 {
+		t.Errorf("Read-only transactions should not be counted, got %+v", stats)
+	}
+}
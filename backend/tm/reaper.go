@@ -0,0 +1,108 @@
+package tm
+
+import (
+	"errors"
+	"time"
+)
+
+// WithReapInterval 设置 SetTransactionTimeout 启动的后台协程检查超时事务的间隔，默认为超时时长本身
+func WithReapInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.reapInterval = interval
+	}
+}
+
+// SetTransactionTimeout 启动一个后台协程，定期取消运行时间超过 d 的活跃事务，取消时和 Abort 一样回调 Observer。
+// 运行时间按 WithClock 设置的时钟计算，本次 Open 之前开启的事务按打开文件的时间计算；已准备的事务不会被取消。
+// 再次调用时替换之前的超时时长，d <= 0 时停止后台协程。Close 时自动停止
+func (t *TransactionManagerImpl) SetTransactionTimeout(d time.Duration) {
+	t.reapLock.Lock()
+	done := t.stopReaper()
+	t.reapTimeout = d
+	if d > 0 {
+		interval := t.reapInterval
+		if interval <= 0 {
+			interval = d
+		}
+		t.reapStop = make(chan struct{})
+		t.reapDone = make(chan struct{})
+		go t.reapLoop(interval, t.reapStop, t.reapDone)
+	}
+	t.reapLock.Unlock()
+
+	// 旧的协程可能正在 ReapExpired 中等待 reapLock，释放锁之后再等它退出
+	if done != nil {
+		<-done
+	}
+}
+
+// ReapExpired 立即取消运行时间超过 SetTransactionTimeout 设置的时长的活跃事务，返回被取消的 XID。
+// 没有设置超时时长时什么也不做
+func (t *TransactionManagerImpl) ReapExpired() ([]int64, error) {
+	t.reapLock.Lock()
+	timeout := t.reapTimeout
+	t.reapLock.Unlock()
+	if timeout <= 0 {
+		return nil, nil
+	}
+
+	now := t.clock()
+	var expired []int64
+	t.beganLock.Lock()
+	for xid, began := range t.beganAt {
+		if now.Sub(began.at) > timeout {
+			expired = append(expired, xid)
+		}
+	}
+	t.beganLock.Unlock()
+
+	var reaped []int64
+	for _, xid := range expired {
+		active, err := t.IsActive(xid)
+		if err != nil {
+			return reaped, err
+		}
+		if !active {
+			continue
+		}
+		// 检查之后事务可能已经被提交，这时不算作错误
+		err = t.Abort(xid)
+		if errors.Is(err, ErrIllegalTransition) {
+			continue
+		}
+		if err != nil {
+			return reaped, err
+		}
+		reaped = append(reaped, xid)
+	}
+	return reaped, nil
+}
+
+// reapLoop 每隔 interval 调用一次 ReapExpired，直到 stop 被关闭
+func (t *TransactionManagerImpl) reapLoop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.ReapExpired()
+		}
+	}
+}
+
+// stopReaper 通知后台协程退出，返回它退出时关闭的通道，没有后台协程时返回 nil。
+// 调用者需持有 reapLock，并且要在释放 reapLock 之后再等待返回的通道
+func (t *TransactionManagerImpl) stopReaper() chan struct{} {
+	if t.reapStop == nil {
+		return nil
+	}
+	close(t.reapStop)
+	done := t.reapDone
+	t.reapStop = nil
+	t.reapDone = nil
+	return done
+}
@@ -0,0 +1,131 @@
+package tm
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReapExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	tm, err := Create(path, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	o := &recordingObserver{}
+	tm.SetObserver(o)
+
+	old := mustBegin(t, tm)
+	prepared := mustBegin(t, tm)
+	tm.Prepare(prepared)
+	clock.now = clock.now.Add(40 * time.Second)
+	young := mustBegin(t, tm)
+	clock.now = clock.now.Add(30 * time.Second)
+
+	// 没有设置超时时长时不取消任何事务
+	if reaped, err := tm.ReapExpired(); err != nil || len(reaped) != 0 {
+		t.Fatalf("Expected nothing to be reaped without a timeout, got %v, %v", reaped, err)
+	}
+
+	// 后台协程的间隔很长，这里手动检查一次
+	tm.SetTransactionTimeout(time.Minute)
+	reaped, err := tm.ReapExpired()
+	if err != nil {
+		t.Fatalf("ReapExpired failed: %v", err)
+	}
+	if !reflect.DeepEqual(reaped, []int64{old}) {
+		t.Errorf("Expected only xid %d to be reaped, got %v", old, reaped)
+	}
+	if !checkStatus(t, tm.IsAborted, old) {
+		t.Errorf("Expected xid %d to be aborted", old)
+	}
+	if !checkStatus(t, tm.IsActive, young) {
+		t.Errorf("Expected xid %d to stay active", young)
+	}
+	if ok, _ := tm.IsPrepared(prepared); !ok {
+		t.Errorf("Expected prepared xid %d not to be reaped", prepared)
+	}
+	if o.events[len(o.events)-1] != "abort 1" {
+		t.Errorf("Expected an abort callback for xid %d, got %v", old, o.events)
+	}
+}
+
+func TestTransactionTimeoutLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	tm, err := Create(path, WithClock(clock.Now), WithReapInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid := mustBegin(t, tm)
+	clock.now = clock.now.Add(2 * time.Minute)
+	tm.SetTransactionTimeout(time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for !checkStatus(t, tm.IsAborted, xid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected xid %d to be aborted by the background sweep", xid)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Close 停止后台协程
+	if err := tm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if tm.reapStop != nil {
+		t.Errorf("Expected the reaper to be stopped")
+	}
+}
+
+// blockingObserver 在第一次 OnAbort 时通知 aborting，然后等待 resume 被关闭
+type blockingObserver struct {
+	once     sync.Once
+	aborting chan struct{}
+	resume   chan struct{}
+}
+
+func (o *blockingObserver) OnBegin(int64)  {}
+func (o *blockingObserver) OnCommit(int64) {}
+func (o *blockingObserver) OnAbort(int64) {
+	o.once.Do(func() { close(o.aborting) })
+	<-o.resume
+}
+
+func TestCloseDuringSweep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	clock := &stepClock{now: time.Unix(1000, 0)}
+	tm, err := Create(path, WithClock(clock.Now), WithReapInterval(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	o := &blockingObserver{aborting: make(chan struct{}), resume: make(chan struct{})}
+	tm.SetObserver(o)
+
+	for i := 0; i < 3; i++ {
+		mustBegin(t, tm)
+	}
+	clock.now = clock.now.Add(2 * time.Minute)
+	tm.SetTransactionTimeout(time.Minute)
+
+	// 后台协程正在一次检查中时开始 Close，Close 持有 reapLock 等待它退出，它的下一次检查也要拿 reapLock
+	<-o.aborting
+	closed := make(chan error)
+	go func() { closed <- tm.Close() }()
+	time.Sleep(10 * time.Millisecond)
+	close(o.resume)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close deadlocked with a running sweep")
+	}
+}
@@ -0,0 +1,132 @@
+package tm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// OpenWithRecovery 打开一个已存在的 TransactionManagerImpl，并返回上次关闭时仍处于活跃状态的 XID。
+// 这些事务通常是进程崩溃时没有完成的事务，调用者可以在恢复时把它们回滚并 Abort
+func OpenWithRecovery(path string, opts ...Option) (*TransactionManagerImpl, []int64, error) {
+	t, err := Open(path, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	active, err := t.ActiveXIDs()
+	if err != nil {
+		t.Close()
+		return nil, nil, err
+	}
+	return t, active, nil
+}
+
+// ActiveXIDs 按升序返回所有仍处于活跃状态的 XID
+func (t *TransactionManagerImpl) ActiveXIDs() ([]int64, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	return t.collectXIDs(FieldTranActive)
+}
+
+// OpenWithRepair 与 Open 相同，但文件长度与 xidCounter 不一致时尝试修复而不是返回 *FileLengthError，
+// 返回因修复而被标记为已取消的 XID。
+//
+// 文件比 xidCounter 推算的长度短(崩溃时状态区末尾没有写完)时，把缺失的 XID 补写为已取消。
+// 这些事务的状态已经丢失: 即使它们在崩溃前已经提交，修复后也会被当作已取消，它们的修改会被回滚。
+// 这里不把 xidCounter 调小，因为这些 XID 可能已经被写进了数据文件，重新分配出去会让新事务认领旧事务的数据。
+// 文件比推算的长度长时与 Repair 相同，把 xidCounter 推进到最大的已写入 XID
+func OpenWithRepair(path string, opts ...Option) (*TransactionManagerImpl, []int64, error) {
+	t, o, err := openExisting(path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lost []int64
+	err = t.checkXIDCounter()
+	var lenErr *FileLengthError
+	if errors.As(err, &lenErr) {
+		lost, err = t.repairLength(lenErr)
+		if err == nil {
+			err = t.checkXIDCounter()
+		}
+	}
+	if err != nil {
+		t.file.Close()
+		return nil, nil, err
+	}
+
+	t.applyOptions(o)
+	err = t.seedBeganAt()
+	if err != nil {
+		t.Close()
+		return nil, nil, err
+	}
+	return t, lost, nil
+}
+
+// repairLength 修复 checkXIDCounter 发现的长度不一致，调用时文件头已经读入 xidCounter 和 baseXid
+func (t *TransactionManagerImpl) repairLength(lenErr *FileLengthError) ([]int64, error) {
+	if lenErr.Actual > lenErr.Expected {
+		return nil, t.Repair()
+	}
+	if lenErr.Actual < t.headerLen {
+		return nil, lenErr
+	}
+
+	// 状态区从 Actual 开始缺失，XidFieldSize 为 1，不存在写了一半的状态
+	first := t.baseXid + (lenErr.Actual-t.headerLen)/XidFieldSize + 1
+	pad := bytes.Repeat([]byte{FieldTranAborted}, int(lenErr.Expected-lenErr.Actual))
+	_, err := t.file.WriteAt(pad, lenErr.Actual)
+	if err == nil {
+		err = t.file.Sync()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lost := make([]int64, 0, t.xidCounter.Load()-first+1)
+	for xid := first; xid <= t.xidCounter.Load(); xid++ {
+		lost = append(lost, xid)
+	}
+	return lost, nil
+}
+
+// recoverInterruptedBegin 处理 Begin 写入状态之后、推进计数器之前崩溃留下的槽位:
+// 文件(去掉预留空间)恰好比计数器推算的长度多一个槽位并且它是活跃状态时，这个 XID 从没有返回给调用者，
+// 把它标记为已取消并推进计数器，而不是把这个 XID 再分配一次。其他的长度不一致留给 VerifyLength 报告
+func (t *TransactionManagerImpl) recoverInterruptedBegin() error {
+	fileLen, err := t.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	xid := t.xidCounter.Load() + 1
+	pos := t.getXidPosition(xid)
+	fileLen, err = t.trimReserved(pos, fileLen)
+	if err != nil || fileLen != pos+XidFieldSize {
+		return err
+	}
+	buf := make([]byte, XidFieldSize)
+	_, err = t.file.ReadAt(buf, pos)
+	if err != nil {
+		return &XIDError{Op: "read status", Xid: xid, Offset: pos, Err: err}
+	}
+	if buf[0] != FieldTranActive {
+		return nil
+	}
+
+	// 已取消的状态先落盘，再推进计数器
+	_, err = t.file.WriteAt([]byte{FieldTranAborted}, pos)
+	if err == nil {
+		err = t.file.Sync()
+	}
+	if err != nil {
+		return &XIDError{Op: "abort interrupted begin", Xid: xid, Offset: pos, Err: err}
+	}
+	err = t.writeXIDCounter(xid)
+	if err != nil {
+		return err
+	}
+	t.xidCounter.Store(xid)
+	return nil
+}
@@ -0,0 +1,184 @@
+package tm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenWithRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	committed := mustBegin(t, tm)
+	dangling1 := mustBegin(t, tm)
+	aborted := mustBegin(t, tm)
+	dangling2 := mustBegin(t, tm)
+	tm.Commit(committed)
+	tm.Abort(aborted)
+	// 模拟崩溃: 两个事务没有结束就关闭了
+	tm.Close()
+
+	tm2, active, err := OpenWithRecovery(path)
+	if err != nil {
+		t.Fatalf("OpenWithRecovery failed: %v", err)
+	}
+	defer tm2.Close()
+
+	if !reflect.DeepEqual(active, []int64{dangling1, dangling2}) {
+		t.Errorf("Expected dangling xids %v, got %v", []int64{dangling1, dangling2}, active)
+	}
+
+	for _, xid := range active {
+		tm2.Abort(xid)
+	}
+	active, err = tm2.ActiveXIDs()
+	if err != nil || len(active) != 0 {
+		t.Errorf("Expected no active xids after aborting, got (%v, %v)", active, err)
+	}
+}
+
+func TestOpenWithRecoveryHeaderOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	tm.Close()
+
+	tm2, active, err := OpenWithRecovery(path)
+	if err != nil {
+		t.Fatalf("OpenWithRecovery failed: %v", err)
+	}
+	defer tm2.Close()
+	if len(active) != 0 {
+		t.Errorf("Expected no active xids, got %v", active)
+	}
+}
+
+func TestOpenWithRecoveryEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	if err := os.WriteFile(path+XidSuffix, nil, 0666); err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+
+	_, _, err := OpenWithRecovery(path)
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected ErrBadXIDFile for an empty file, got %v", err)
+	}
+}
+
+func TestOpenWithRepairTruncatedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var xids []int64
+	for i := 0; i < 5; i++ {
+		xid := mustBegin(t, tm)
+		tm.Commit(xid)
+		xids = append(xids, xid)
+	}
+	counter := tm.XidCounter()
+	tm.Close()
+
+	// 模拟崩溃: 最后两个事务的状态没有写进文件
+	info, err := os.Stat(path + XidSuffix)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.Truncate(path+XidSuffix, info.Size()-2); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	var lenErr *FileLengthError
+	if _, err := Open(path); !errors.As(err, &lenErr) {
+		t.Fatalf("Expected *FileLengthError from Open, got %v", err)
+	}
+
+	tm2, lost, err := OpenWithRepair(path)
+	if err != nil {
+		t.Fatalf("OpenWithRepair failed: %v", err)
+	}
+	defer tm2.Close()
+
+	if !reflect.DeepEqual(lost, xids[3:]) {
+		t.Errorf("Expected lost xids %v, got %v", xids[3:], lost)
+	}
+	if tm2.XidCounter() != counter {
+		t.Errorf("Expected counter %d to be kept, got %d", counter, tm2.XidCounter())
+	}
+	if err := tm2.VerifyLength(); err != nil {
+		t.Errorf("Expected consistent length after repair, got %v", err)
+	}
+	for _, xid := range xids[:3] {
+		if !checkStatus(t, tm2.IsCommitted, xid) {
+			t.Errorf("Expected xid %d to stay committed", xid)
+		}
+	}
+	for _, xid := range lost {
+		if !checkStatus(t, tm2.IsAborted, xid) {
+			t.Errorf("Expected lost xid %d to be aborted", xid)
+		}
+	}
+
+	// 修复后分配的 XID 不会与丢失的 XID 重复
+	if xid := mustBegin(t, tm2); xid != counter+1 {
+		t.Errorf("Expected next xid %d, got %d", counter+1, xid)
+	}
+}
+
+func TestOpenWithRepairCounterBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+
+	// 状态已经写入但文件头的 xidCounter 没有更新
+	tm.file.WriteAt([]byte{FieldTranCommitted}, tm.getXidPosition(xid+1))
+	tm.Close()
+
+	tm2, lost, err := OpenWithRepair(path)
+	if err != nil {
+		t.Fatalf("OpenWithRepair failed: %v", err)
+	}
+	defer tm2.Close()
+	if len(lost) != 0 {
+		t.Errorf("Expected no lost xids, got %v", lost)
+	}
+	if tm2.XidCounter() != xid+1 {
+		t.Errorf("Expected counter %d, got %d", xid+1, tm2.XidCounter())
+	}
+}
+
+func TestOpenWithRepairHealthyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	mustBegin(t, tm)
+	tm.Close()
+
+	tm2, lost, err := OpenWithRepair(path)
+	if err != nil {
+		t.Fatalf("OpenWithRepair failed: %v", err)
+	}
+	defer tm2.Close()
+	if lost != nil {
+		t.Errorf("Expected no lost xids, got %v", lost)
+	}
+	if active := tm2.ActiveCount(); active != 1 {
+		t.Errorf("Expected 1 active transaction, got %d", active)
+	}
+}
@@ -0,0 +1,32 @@
+package tm
+
+import "io"
+
+// 容量规划: XID 文件由固定长度的文件头和每个事务 XidFieldSize 字节的状态组成。
+// Checkpoint 丢弃的事务不再占用空间，所以 numTxns 应按最近一次 Checkpoint 之后的事务数计算
+
+// EstimateSize 返回保存 numTxns 个事务状态的 XID 文件的长度
+func EstimateSize(numTxns int64) int64 {
+	if numTxns < 0 {
+		numTxns = 0
+	}
+	return LenXidHeaderLength + numTxns*XidFieldSize
+}
+
+// XIDCapacityForBytes 返回 bytes 字节的 XID 文件最多能保存的事务数，是 EstimateSize 的逆运算
+func XIDCapacityForBytes(bytes int64) int64 {
+	if bytes < LenXidHeaderLength {
+		return 0
+	}
+	return (bytes - LenXidHeaderLength) / XidFieldSize
+}
+
+// FileSize 返回 XID 文件当前的实际长度
+func (t *TransactionManagerImpl) FileSize() (int64, error) {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	if t.closed {
+		return 0, ErrClosed
+	}
+	return t.file.Seek(0, io.SeekEnd)
+}
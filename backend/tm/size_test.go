@@ -0,0 +1,47 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateSizeRoundTrip(t *testing.T) {
+	for _, n := range []int64{0, 1, 100, 1 << 20} {
+		if got := XIDCapacityForBytes(EstimateSize(n)); got != n {
+			t.Errorf("Expected capacity %d for EstimateSize(%d), got %d", n, n, got)
+		}
+	}
+	// 放不下文件头时容量为 0
+	if got := XIDCapacityForBytes(LenXidHeaderLength - 1); got != 0 {
+		t.Errorf("Expected no capacity below the header length, got %d", got)
+	}
+	if got := EstimateSize(-1); got != LenXidHeaderLength {
+		t.Errorf("Expected a negative count to estimate an empty file, got %d", got)
+	}
+}
+
+func TestFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		mustBegin(t, tm)
+	}
+	size, err := tm.FileSize()
+	if err != nil {
+		t.Fatalf("FileSize failed: %v", err)
+	}
+	if size != EstimateSize(n) {
+		t.Errorf("Expected file size %d after %d begins, got %d", EstimateSize(n), n, size)
+	}
+	info, err := os.Stat(path + XidSuffix)
+	if err != nil || info.Size() != size {
+		t.Errorf("Expected FileSize to match the file on disk, got %d and %v", size, info)
+	}
+}
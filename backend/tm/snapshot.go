@@ -0,0 +1,87 @@
+package tm
+
+import (
+	"bytes"
+	"context"
+)
+
+// Snapshot 是某一时刻仍处于活跃状态的 XID 集合
+type Snapshot map[int64]struct{}
+
+// ActiveSnapshot 返回当前所有处于活跃状态的 XID，已准备的事务还没有提交，也包含在内。
+// 扫描在 counterLock 下进行，期间不会有新事务开启，因此结果是一个时间点上的视图
+func (t *TransactionManagerImpl) ActiveSnapshot() (Snapshot, error) {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	xids, err := t.collectXIDs(FieldTranActive, FieldTranPrepared)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := make(Snapshot, len(xids))
+	for _, xid := range xids {
+		snap[xid] = struct{}{}
+	}
+	return snap, nil
+}
+
+// SnapshotTransactionManager 是开启事务时能同时拍下活跃事务快照的 TransactionManager，
+// TransactionManagerImpl 和 MemoryTransactionManager 都实现了它
+type SnapshotTransactionManager interface {
+	TransactionManager
+	BeginWithSnapshot() (int64, Snapshot, error)
+}
+
+// BeginWithSnapshot 开启一个新事务，同时返回开启前一刻的活跃事务快照(不包含新事务自己)。
+// 快照和分配 XID 在同一次 counterLock 下完成，两者之间不会有事务开启或者被漏掉的提交，
+// 分开调用 Begin 和 ActiveSnapshot 时，中间提交的事务既不在快照中又小于新的 XID，会被误判为开启前已提交
+func (t *TransactionManagerImpl) BeginWithSnapshot() (int64, Snapshot, error) {
+	t.counterLock.Lock()
+	xids, err := t.collectXIDs(FieldTranActive, FieldTranPrepared)
+	var xid int64
+	if err == nil {
+		xid, err = t.beginLocked(context.Background())
+	}
+	t.counterLock.Unlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	snap := make(Snapshot, len(xids))
+	for _, x := range xids {
+		snap[x] = struct{}{}
+	}
+	t.markBegan(xid, true)
+	t.notifyBegin(xid)
+	return xid, snap, nil
+}
+
+// collectXIDs 按升序返回 baseXid 之后到 xidCounter 之间所有处于 statuses 之一的 XID，调用者需持有 counterLock
+func (t *TransactionManagerImpl) collectXIDs(want ...byte) ([]int64, error) {
+	t.fileLock.RLock()
+	base := t.baseXid
+	t.fileLock.RUnlock()
+
+	statuses, err := t.readStatuses(base+1, t.xidCounter.Load())
+	if err != nil {
+		return nil, err
+	}
+
+	var xids []int64
+	for i := 0; i < len(statuses); i += XidFieldSize {
+		if bytes.IndexByte(want, statuses[i]) >= 0 {
+			xids = append(xids, base+int64(i/XidFieldSize)+1)
+		}
+	}
+	return xids, nil
+}
+
+// IsActiveAt 判断 xid 在快照 snap 拍下时是否处于活跃状态
+func (t *TransactionManagerImpl) IsActiveAt(xid int64, snap Snapshot) bool {
+	if xid == SuperXid {
+		return false
+	}
+	_, ok := snap[xid]
+	return ok
+}
@@ -0,0 +1,66 @@
+package tm
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestActiveSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xids := make([]int64, 5)
+	for i := range xids {
+		xids[i] = mustBegin(t, tm)
+	}
+	tm.Commit(xids[0])
+	tm.Abort(xids[2])
+	tm.Commit(xids[4])
+
+	snap, err := tm.ActiveSnapshot()
+	if err != nil {
+		t.Fatalf("ActiveSnapshot failed: %v", err)
+	}
+	if len(snap) != 2 {
+		t.Errorf("Expected 2 active xids, got %v", snap)
+	}
+	for _, xid := range []int64{xids[1], xids[3]} {
+		if !tm.IsActiveAt(xid, snap) {
+			t.Errorf("Expected xid %d to be active in the snapshot", xid)
+		}
+	}
+
+	// 快照之后的变化不影响已拍下的快照
+	tm.Commit(xids[1])
+	later := mustBegin(t, tm)
+	if !tm.IsActiveAt(xids[1], snap) {
+		t.Errorf("Snapshot changed after a later commit")
+	}
+	if tm.IsActiveAt(later, snap) {
+		t.Errorf("Transaction begun after the snapshot should not be in it")
+	}
+	if tm.IsActiveAt(SuperXid, snap) {
+		t.Errorf("SuperXid is never active")
+	}
+}
+
+func TestActiveSnapshotEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	snap, err := tm.ActiveSnapshot()
+	if err != nil {
+		t.Fatalf("ActiveSnapshot failed: %v", err)
+	}
+	if len(snap) != 0 {
+		t.Errorf("Expected an empty snapshot, got %v", snap)
+	}
+}
@@ -0,0 +1,39 @@
+package tm
+
+import "sync/atomic"
+
+// Stats 是事务管理器自打开以来的统计信息
+type Stats struct {
+	Begins  int64 // 开启的事务数
+	Commits int64 // 提交的事务数
+	Aborts  int64 // 取消的事务数
+	Active  int64 // 当前活跃的事务数，包括打开文件时就处于活跃状态的事务
+}
+
+// txStats 保存统计计数器，可以在不加锁的情况下并发读写
+type txStats struct {
+	begins  atomic.Int64
+	commits atomic.Int64
+	aborts  atomic.Int64
+	active  atomic.Int64
+}
+
+// Stats 返回当前统计信息的一份拷贝
+func (t *TransactionManagerImpl) Stats() Stats {
+	return Stats{
+		Begins:  t.stats.begins.Load(),
+		Commits: t.stats.commits.Load(),
+		Aborts:  t.stats.aborts.Load(),
+		Active:  t.stats.active.Load(),
+	}
+}
+
+// ActiveCount 返回当前活跃的事务数，不需要扫描文件
+func (t *TransactionManagerImpl) ActiveCount() int {
+	return int(t.stats.active.Load())
+}
+
+// decrActive 把活跃事务数减去 n，每个结束的事务只能减一次
+func (s *txStats) decrActive(n int64) {
+	s.active.Add(-n)
+}
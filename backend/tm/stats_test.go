@@ -0,0 +1,108 @@
+package tm
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	const workers = 8
+	const perWorker = 10
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				xid, err := tm.Begin()
+				if err != nil {
+					t.Errorf("Begin failed: %v", err)
+					return
+				}
+				switch i % 3 {
+				case 0:
+					tm.Commit(xid)
+				case 1:
+					tm.Abort(xid)
+				}
+				// i%3 == 2 的事务保持活跃
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := tm.Stats()
+	expected := Stats{
+		Begins:  workers * perWorker,
+		Commits: workers * 4,
+		Aborts:  workers * 3,
+		Active:  workers * 3,
+	}
+	if stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+// scanActiveCount 扫描整个文件统计活跃的事务数
+func scanActiveCount(t *testing.T, tm *TransactionManagerImpl) int {
+	t.Helper()
+	active, err := tm.ActiveXIDs()
+	if err != nil {
+		t.Fatalf("ActiveXIDs failed: %v", err)
+	}
+	return len(active)
+}
+
+func TestActiveCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid1 := mustBegin(t, tm)
+	xid2 := mustBegin(t, tm)
+	xid3 := mustBegin(t, tm)
+	xid4 := mustBegin(t, tm)
+	if tm.ActiveCount() != 4 {
+		t.Errorf("Expected 4 active, got %d", tm.ActiveCount())
+	}
+
+	tm.Commit(xid1)
+	tm.Commit(xid1) // 重复提交
+	tm.Abort(xid2)
+	tm.Commit(xid2) // 取消后再提交
+	tm.Abort(xid2)
+	if tm.ActiveCount() != 2 || scanActiveCount(t, tm) != 2 {
+		t.Errorf("Expected 2 active, got %d (scan %d)", tm.ActiveCount(), scanActiveCount(t, tm))
+	}
+
+	tm.CommitMany([]int64{xid3})
+	if tm.ActiveCount() != 1 || scanActiveCount(t, tm) != 1 {
+		t.Errorf("Expected 1 active, got %d (scan %d)", tm.ActiveCount(), scanActiveCount(t, tm))
+	}
+	tm.Close()
+
+	// 重新打开时扫描一次文件得到活跃事务数
+	tm, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.ActiveCount() != 1 {
+		t.Errorf("Expected 1 active after reopen, got %d", tm.ActiveCount())
+	}
+	tm.Abort(xid4)
+	tm.Abort(xid4)
+	if tm.ActiveCount() != 0 || scanActiveCount(t, tm) != 0 {
+		t.Errorf("Expected 0 active, got %d (scan %d)", tm.ActiveCount(), scanActiveCount(t, tm))
+	}
+}
@@ -0,0 +1,105 @@
+package tm
+
+import "fmt"
+
+// Status 表示一个事务的状态
+type Status byte
+
+const (
+	StatusActive    = Status(FieldTranActive)
+	StatusCommitted = Status(FieldTranCommitted)
+	StatusAborted   = Status(FieldTranAborted)
+	StatusPrepared  = Status(FieldTranPrepared)
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusActive:
+		return "active"
+	case StatusCommitted:
+		return "committed"
+	case StatusAborted:
+		return "aborted"
+	case StatusPrepared:
+		return "prepared"
+	default:
+		return fmt.Sprintf("Status(%d)", byte(s))
+	}
+}
+
+// isValidStatus 判断 b 是否是合法的状态字节
+func isValidStatus(b byte) bool {
+	switch b {
+	case FieldTranActive, FieldTranCommitted, FieldTranAborted, FieldTranPrepared:
+		return true
+	}
+	return false
+}
+
+// checkTransition 检查处于 from 状态的 xid 能否结束为 to。活跃或已准备的事务可以结束；
+// 已经是 to 时 done 为 true，重复的提交或取消什么也不做；已经以另一种方式结束时返回 ErrIllegalTransition。
+// 调用者在同一个锁下检查和写入(TransactionManagerImpl 持有 xid 的 lockEnd 分段锁，内存实现持有自己的锁)，
+// 所以同一个 XID 并发的提交和取消只有一个能成功
+func checkTransition(xid int64, from, to Status) (done bool, err error) {
+	switch from {
+	case StatusActive, StatusPrepared:
+		return false, nil
+	case to:
+		return true, nil
+	}
+	return false, fmt.Errorf("%w: xid %d is %v, cannot become %v", ErrIllegalTransition, xid, from, to)
+}
+
+// statusFromByte 把文件中的状态字节转换为 Status，遇到未知的状态字节时返回 ErrBadXIDFile
+func statusFromByte(xid int64, b byte) (Status, error) {
+	if !isValidStatus(b) {
+		return 0, fmt.Errorf("%w: invalid status byte %d for xid %d", ErrBadXIDFile, b, xid)
+	}
+	return Status(b), nil
+}
+
+// GetStatus 只读一次文件返回 xid 的状态，SuperXid 总是已提交，没有分配过的 xid 返回 ErrInvalidXID
+func (t *TransactionManagerImpl) GetStatus(xid int64) (Status, error) {
+	if xid == SuperXid {
+		return StatusCommitted, nil
+	}
+	if isReadOnlyXid(xid) {
+		return t.readOnlyStatus(xid)
+	}
+
+	err := t.checkAllocated(xid)
+	if err != nil {
+		return 0, err
+	}
+	b, err := t.readStatus(xid)
+	if err != nil {
+		return 0, err
+	}
+	return statusFromByte(xid, b)
+}
+
+// StatusRange 用一次 ReadAt 读出 [from, to] 中每个 XID 的状态，result[i] 是 from+i 的状态，
+// 用于范围扫描时的可见性判断。from 和 to 必须是已经分配的 XID，to < from 时返回空切片
+func (t *TransactionManagerImpl) StatusRange(from, to int64) ([]Status, error) {
+	if to < from {
+		return nil, nil
+	}
+	for _, xid := range []int64{from, to} {
+		err := t.checkAllocated(xid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	buf, err := t.readStatuses(from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Status, to-from+1)
+	for i := range result {
+		result[i], err = statusFromByte(from+int64(i), buf[i*XidFieldSize])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	want := []Status{StatusCommitted, StatusAborted, StatusActive, StatusPrepared, StatusCommitted}
+	for _, status := range want {
+		xid := mustBegin(t, tm)
+		switch status {
+		case StatusCommitted:
+			tm.Commit(xid)
+		case StatusAborted:
+			tm.Abort(xid)
+		case StatusPrepared:
+			tm.Prepare(xid)
+		}
+	}
+
+	got, err := tm.StatusRange(1, 5)
+	if err != nil {
+		t.Fatalf("StatusRange failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d statuses, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Xid %d: expected %v, got %v", i+1, want[i], got[i])
+		}
+	}
+	if got, _ := tm.StatusRange(2, 3); len(got) != 2 || got[0] != StatusAborted || got[1] != StatusActive {
+		t.Errorf("Unexpected sub-range %v", got)
+	}
+	if got, err := tm.StatusRange(3, 2); err != nil || len(got) != 0 {
+		t.Errorf("Expected an empty range, got %v (%v)", got, err)
+	}
+
+	for _, r := range [][2]int64{{0, 3}, {2, 6}, {-1, 1}} {
+		if _, err := tm.StatusRange(r[0], r[1]); !errors.Is(err, ErrInvalidXID) {
+			t.Errorf("StatusRange(%d, %d): expected ErrInvalidXID, got %v", r[0], r[1], err)
+		}
+	}
+}
+
+// benchmarkStatusRange 准备 n 个状态交替的 XID
+func benchmarkStatusRange(b *testing.B, n int) *TransactionManagerImpl {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "test_bench")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		b.Fatalf("Create failed: %v", err)
+	}
+	b.Cleanup(func() { tm.Close() })
+	for i := 0; i < n; i++ {
+		xid, _ := tm.Begin()
+		if i%2 == 0 {
+			tm.Commit(xid)
+		}
+	}
+	b.ResetTimer()
+	return tm
+}
+
+func BenchmarkIsCommittedPerXID(b *testing.B) {
+	tm := benchmarkStatusRange(b, 1024)
+	for i := 0; i < b.N; i++ {
+		for xid := int64(1); xid <= 1024; xid++ {
+			if _, err := tm.IsCommitted(xid); err != nil {
+				b.Fatalf("IsCommitted failed: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkStatusRange(b *testing.B) {
+	tm := benchmarkStatusRange(b, 1024)
+	for i := 0; i < b.N; i++ {
+		if _, err := tm.StatusRange(1, 1024); err != nil {
+			b.Fatalf("StatusRange failed: %v", err)
+		}
+	}
+}
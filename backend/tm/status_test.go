@@ -0,0 +1,71 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	active := mustBegin(t, tm)
+	committed := mustBegin(t, tm)
+	aborted := mustBegin(t, tm)
+	tm.Commit(committed)
+	tm.Abort(aborted)
+
+	cases := map[int64]Status{
+		SuperXid:  StatusCommitted,
+		active:    StatusActive,
+		committed: StatusCommitted,
+		aborted:   StatusAborted,
+	}
+	for xid, want := range cases {
+		got, err := tm.GetStatus(xid)
+		if err != nil {
+			t.Fatalf("GetStatus(%d) failed: %v", xid, err)
+		}
+		if got != want {
+			t.Errorf("GetStatus(%d): expected %v, got %v", xid, want, got)
+		}
+	}
+
+	if ok := checkStatus(t, tm.IsCommitted, SuperXid); !ok {
+		t.Errorf("SuperXid should be committed")
+	}
+	if checkStatus(t, tm.IsActive, SuperXid) || checkStatus(t, tm.IsAborted, SuperXid) {
+		t.Errorf("SuperXid should be neither active nor aborted")
+	}
+}
+
+func TestGetStatusInvalidByte(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	tm.file.WriteAt([]byte{7}, tm.getXidPosition(xid))
+
+	_, err = tm.GetStatus(xid)
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected ErrBadXIDFile for an unknown status byte, got %v", err)
+	}
+	if _, err := tm.IsActive(xid); err == nil {
+		t.Errorf("Expected IsActive to report the bad status byte")
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	if StatusActive.String() != "active" || StatusCommitted.String() != "committed" || StatusAborted.String() != "aborted" {
+		t.Errorf("Unexpected status names")
+	}
+}
@@ -0,0 +1,149 @@
+package tm
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// testTransactionManagerSuite 对任意 TransactionManager 实现运行同一组行为测试
+func testTransactionManagerSuite(t *testing.T, newTM func(t *testing.T) TransactionManager) {
+	t.Run("BeginCommitAbort", func(t *testing.T) {
+		tm := newTM(t)
+		defer tm.Close()
+
+		xid1 := mustBegin(t, tm)
+		xid2 := mustBegin(t, tm)
+		xid3 := mustBegin(t, tm)
+		if xid1 != 1 || xid2 != 2 || xid3 != 3 || tm.XidCounter() != 3 {
+			t.Fatalf("Unexpected xids %d, %d, %d (counter %d)", xid1, xid2, xid3, tm.XidCounter())
+		}
+
+		if err := tm.Commit(xid1); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if err := tm.Abort(xid2); err != nil {
+			t.Fatalf("Abort failed: %v", err)
+		}
+
+		if !checkStatus(t, tm.IsCommitted, xid1) || checkStatus(t, tm.IsActive, xid1) || checkStatus(t, tm.IsAborted, xid1) {
+			t.Errorf("xid %d should only be committed", xid1)
+		}
+		if !checkStatus(t, tm.IsAborted, xid2) || checkStatus(t, tm.IsActive, xid2) || checkStatus(t, tm.IsCommitted, xid2) {
+			t.Errorf("xid %d should only be aborted", xid2)
+		}
+		if !checkStatus(t, tm.IsActive, xid3) || checkStatus(t, tm.IsCommitted, xid3) || checkStatus(t, tm.IsAborted, xid3) {
+			t.Errorf("xid %d should only be active", xid3)
+		}
+	})
+
+	t.Run("Transitions", func(t *testing.T) {
+		tm := newTM(t)
+		defer tm.Close()
+
+		committed := mustBegin(t, tm)
+		aborted := mustBegin(t, tm)
+		if err := tm.Commit(committed); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if err := tm.Abort(aborted); err != nil {
+			t.Fatalf("Abort failed: %v", err)
+		}
+
+		// 重复同一种结束什么也不做
+		if err := tm.Commit(committed); err != nil {
+			t.Errorf("Expected repeated Commit to succeed, got %v", err)
+		}
+		if err := tm.Abort(aborted); err != nil {
+			t.Errorf("Expected repeated Abort to succeed, got %v", err)
+		}
+
+		// 已经结束的事务不能以另一种方式结束，状态保持不变
+		if err := tm.Abort(committed); !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("Expected ErrIllegalTransition from aborting a committed xid, got %v", err)
+		}
+		if err := tm.Commit(aborted); !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("Expected ErrIllegalTransition from committing an aborted xid, got %v", err)
+		}
+		if !checkStatus(t, tm.IsCommitted, committed) || !checkStatus(t, tm.IsAborted, aborted) {
+			t.Errorf("Expected rejected transitions to leave the statuses unchanged")
+		}
+	})
+
+	t.Run("SuperXid", func(t *testing.T) {
+		tm := newTM(t)
+		defer tm.Close()
+
+		if !checkStatus(t, tm.IsCommitted, SuperXid) {
+			t.Errorf("SuperXid should be committed")
+		}
+		if checkStatus(t, tm.IsActive, SuperXid) || checkStatus(t, tm.IsAborted, SuperXid) {
+			t.Errorf("SuperXid should be neither active nor aborted")
+		}
+	})
+
+	t.Run("UseAfterClose", func(t *testing.T) {
+		tm := newTM(t)
+		committed := mustBegin(t, tm)
+		active := mustBegin(t, tm)
+		if err := tm.Commit(committed); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+		if err := tm.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		ops := map[string]func() error{
+			"Begin": func() error {
+				_, err := tm.Begin()
+				return err
+			},
+			"Commit":      func() error { return tm.Commit(active) },
+			"Abort":       func() error { return tm.Abort(active) },
+			"Flush":       tm.Flush,
+			"Close":       tm.Close,
+			"IsActive":    func() error { _, err := tm.IsActive(active); return err },
+			"IsCommitted": func() error { _, err := tm.IsCommitted(committed); return err },
+			"IsAborted":   func() error { _, err := tm.IsAborted(active); return err },
+		}
+		for name, op := range ops {
+			if err := op(); !errors.Is(err, ErrClosed) {
+				t.Errorf("%s after Close: expected ErrClosed, got %v", name, err)
+			}
+		}
+		// 不需要访问状态的查询在关闭之后仍然可用
+		if tm.XidCounter() != 2 {
+			t.Errorf("Expected counter 2 after Close, got %d", tm.XidCounter())
+		}
+		if ok, err := tm.IsCommitted(SuperXid); !ok || err != nil {
+			t.Errorf("Expected SuperXid to stay committed after Close, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("UnknownXid", func(t *testing.T) {
+		tm := newTM(t)
+		defer tm.Close()
+
+		mustBegin(t, tm)
+		if _, err := tm.IsActive(5); !errors.Is(err, ErrInvalidXID) {
+			t.Errorf("Expected ErrInvalidXID for an unknown xid, got %v", err)
+		}
+	})
+}
+
+func TestFileTransactionManagerSuite(t *testing.T) {
+	testTransactionManagerSuite(t, func(t *testing.T) TransactionManager {
+		path := filepath.Join(t.TempDir(), "test_suite")
+		tm, err := Create(path)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		return tm
+	})
+}
+
+func TestMemoryTransactionManagerSuite(t *testing.T) {
+	testTransactionManagerSuite(t, func(t *testing.T) TransactionManager {
+		return NewMemoryTransactionManager()
+	})
+}
@@ -0,0 +1,121 @@
+package tm
+
+import "time"
+
+// SyncMode 控制状态和 xidCounter 写入文件后是否以及何时调用 file.Sync
+type SyncMode struct {
+	interval time.Duration
+	never    bool
+}
+
+var (
+	// SyncAlways 每次写入后都刷盘，Commit/Abort/Begin 返回时状态已经持久化，这是默认的模式
+	SyncAlways = SyncMode{}
+	// SyncNever 从不主动刷盘，由操作系统决定何时写回。
+	// 进程崩溃不会丢数据，但机器掉电可能丢失任意多的已提交事务，甚至使文件头和状态区不一致
+	SyncNever = SyncMode{never: true}
+)
+
+// SyncInterval 返回一个由后台协程每隔 d 刷盘一次的模式，期间没有写入时不刷盘。
+// 掉电时最多丢失最近 d 时间内的写入，Close 时会再刷一次盘
+func SyncInterval(d time.Duration) SyncMode {
+	return SyncMode{interval: d}
+}
+
+// applyOptions 保存配置，SyncInterval 模式下启动后台刷盘协程
+func (t *TransactionManagerImpl) applyOptions(o options) {
+	t.clock = o.clock
+	t.reapInterval = o.reapInterval
+	t.syncMode = o.syncMode
+	t.events = newTxEvents(o.eventBuffer, o.eventPolicy)
+	t.preallocChunk = o.preallocChunk
+	if t.syncMode.interval > 0 {
+		t.syncStop = make(chan struct{})
+		t.syncDone = make(chan struct{})
+		go t.syncLoop()
+	}
+}
+
+// maybeSync 按刷盘模式在写入之后刷盘，调用者需持有 fileLock 的读锁
+func (t *TransactionManagerImpl) maybeSync() error {
+	switch {
+	case t.syncMode.never:
+		return nil
+	case t.syncMode.interval > 0:
+		t.syncDirty.Store(true)
+		return nil
+	default:
+		return t.file.Sync()
+	}
+}
+
+// syncLoop 每隔 interval 把 SyncInterval 模式下积累的写入刷盘，刷盘失败时下一次再试
+func (t *TransactionManagerImpl) syncLoop() {
+	defer close(t.syncDone)
+
+	ticker := time.NewTicker(t.syncMode.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.syncStop:
+			return
+		case <-ticker.C:
+			t.syncIfDirty()
+		}
+	}
+}
+
+// syncIfDirty 在上次刷盘之后有写入时刷盘
+func (t *TransactionManagerImpl) syncIfDirty() error {
+	if !t.syncDirty.Swap(false) {
+		return nil
+	}
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	err := t.file.Sync()
+	if err != nil {
+		t.syncDirty.Store(true)
+	}
+	return err
+}
+
+// Flush 让组提交正在收集的批次立即写入，并把已经写入的状态和 xidCounter 刷盘，返回时之前的写入都已经持久化。
+// SyncAlways 模式下每次写入都已经刷盘，Flush 只需要等待组提交的批次
+func (t *TransactionManagerImpl) Flush() error {
+	t.groupLock.RLock()
+	if t.group != nil {
+		t.group.flush()
+	}
+	t.groupLock.RUnlock()
+
+	if t.syncMode == SyncAlways {
+		t.fileLock.RLock()
+		defer t.fileLock.RUnlock()
+		if t.closed {
+			return ErrClosed
+		}
+		return nil
+	}
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	if t.closed {
+		return ErrClosed
+	}
+	t.syncDirty.Store(false)
+	err := t.file.Sync()
+	if err != nil {
+		t.syncDirty.Store(true)
+	}
+	return err
+}
+
+// stopSyncLoop 停止后台刷盘协程并把剩余的写入刷盘
+func (t *TransactionManagerImpl) stopSyncLoop() error {
+	if t.syncStop == nil {
+		return nil
+	}
+	close(t.syncStop)
+	<-t.syncDone
+	t.syncStop = nil
+	return t.syncIfDirty()
+}
@@ -0,0 +1,191 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncNever(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_sync_mode")
+
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	var xids []int64
+	for i := 0; i < 10; i++ {
+		xid := mustBegin(t, tm)
+		switch i % 3 {
+		case 0:
+			tm.Commit(xid)
+		case 1:
+			tm.Abort(xid)
+		}
+		xids = append(xids, xid)
+	}
+	for i, xid := range xids {
+		status := []byte{FieldTranCommitted, FieldTranAborted, FieldTranActive}[i%3]
+		if !mustCheckXID(t, tm, xid, status) {
+			t.Errorf("Unexpected status for xid %d", xid)
+		}
+	}
+	if err := tm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 同一个进程内重新打开仍能读到所有写入
+	tm, err = Open(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm.Close()
+	if tm.XidCounter() != 10 {
+		t.Errorf("Expected xidCounter 10, got %d", tm.XidCounter())
+	}
+}
+
+func TestSyncInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_sync_mode")
+
+	tm, err := Create(path, WithSyncMode(SyncInterval(time.Millisecond)))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid := mustBegin(t, tm)
+	tm.Commit(xid)
+	if !tm.syncDirty.Load() {
+		t.Errorf("Expected pending writes before the timer fires")
+	}
+	deadline := time.Now().Add(time.Second)
+	for tm.syncDirty.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if tm.syncDirty.Load() {
+		t.Errorf("Background goroutine did not sync")
+	}
+
+	mustBegin(t, tm)
+	if err := tm.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-tm.syncDone:
+	default:
+		t.Errorf("Close did not stop the background goroutine")
+	}
+	if tm.syncDirty.Load() {
+		t.Errorf("Close did not sync the remaining writes")
+	}
+}
+
+func benchmarkSyncMode(b *testing.B, mode SyncMode) {
+	path := filepath.Join(b.TempDir(), "bench_sync_mode")
+
+	tm, err := Create(path, WithSyncMode(mode))
+	if err != nil {
+		b.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		xid, err := tm.Begin()
+		if err != nil {
+			b.Fatalf("Begin failed: %v", err)
+		}
+		tm.Commit(xid)
+	}
+}
+
+func BenchmarkSyncAlways(b *testing.B) {
+	benchmarkSyncMode(b, SyncAlways)
+}
+
+func BenchmarkSyncInterval(b *testing.B) {
+	benchmarkSyncMode(b, SyncInterval(10*time.Millisecond))
+}
+
+func BenchmarkSyncNever(b *testing.B) {
+	benchmarkSyncMode(b, SyncNever)
+}
+
+func TestFlushPersistsDeferredWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_sync_mode")
+
+	for _, mode := range []SyncMode{SyncNever, SyncInterval(time.Hour)} {
+		tm, err := Create(path, WithSyncMode(mode))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		committed := mustBegin(t, tm)
+		aborted := mustBegin(t, tm)
+		tm.Commit(committed)
+		tm.Abort(aborted)
+		if err := tm.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		if tm.syncDirty.Load() {
+			t.Errorf("Expected no pending writes after Flush")
+		}
+
+		// 不调用 Close，直接关闭文件模拟进程被杀死
+		tm.stopSyncLoop()
+		unlockFile(tm.file.(*os.File))
+		tm.file.Close()
+
+		tm, err = Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if !mustCheckXID(t, tm, committed, FieldTranCommitted) {
+			t.Errorf("Expected xid %d to be committed", committed)
+		}
+		if !mustCheckXID(t, tm, aborted, FieldTranAborted) {
+			t.Errorf("Expected xid %d to be aborted", aborted)
+		}
+		tm.Close()
+		os.Remove(path + XidSuffix)
+	}
+}
+
+func TestFlushCutsGroupCommitBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_sync_mode")
+
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+	tm.BeginGroupCommit(time.Hour)
+	defer tm.EndGroupCommit()
+
+	xid := mustBegin(t, tm)
+	done := make(chan error, 1)
+	go func() { done <- tm.Commit(xid) }()
+
+	// Commit 可能还没有进入批次，重复 Flush 直到它返回
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := tm.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Commit failed: %v", err)
+			}
+			if !mustCheckXID(t, tm, xid, FieldTranCommitted) {
+				t.Errorf("Expected xid %d to be committed", xid)
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Flush did not cut the pending batch")
+		}
+	}
+}
@@ -1,192 +1,853 @@
 package tm
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// XID 文件格式:
+//
+//	[magic: 4 字节][checksum: 4 字节][xidCounter: 8 字节, 大端序][baseXid: 8 字节, 大端序]
+//	[xid baseXid+1 的状态][xid baseXid+2 的状态]...
+//
+// magic 的第一个字节是格式版本，文件头中有哪些字段、文件头有多长都由版本决定(见 headerLenForVersion)，
+// 当前版本的文件头长 LenXidHeaderLength 字节。checksum 是对 xidCounter 和 baseXid 计算的 CRC32。
+// 每个事务的状态占 XidFieldSize 个字节，xid 的状态位于 文件头长度 + (xid-baseXid-1)*XidFieldSize。
+// baseXid 及之前的状态已经被 Checkpoint 丢弃，新建的文件 baseXid 为 0。
+// FieldTranPrepared 是两阶段提交中已准备的状态，不使用 Prepare 的文件中不会出现
 const (
-	LenXidHeaderLength = 8
+	LenXidHeaderLength = 24
 	XidFieldSize       = 1
 	FieldTranActive    = byte(0)
 	FieldTranCommitted = byte(1)
 	FieldTranAborted   = byte(2)
+	FieldTranPrepared  = byte(3)
 	SuperXid           = int64(0)
 	XidSuffix          = ".xid"
 )
 
+var (
+	// ErrBadXIDFile 表示 XID 文件格式不正确
+	ErrBadXIDFile = errors.New("bad xid file")
+	// ErrXIDCounterBehind 表示文件中写有超出 xidCounter 范围的事务状态
+	ErrXIDCounterBehind = errors.New("xid counter is behind the written status region")
+	// ErrImportOverlap 表示导入的 XID 与本地已有的 XID 重叠
+	ErrImportOverlap = errors.New("imported xids overlap existing xids")
+	// ErrXIDCheckpointed 表示 xid 的状态已经被 Checkpoint 丢弃
+	ErrXIDCheckpointed = errors.New("xid status was discarded by checkpoint")
+	// ErrAlreadyLocked 表示 XID 文件已经被另一个事务管理器打开
+	ErrAlreadyLocked = errors.New("xid file is locked by another transaction manager")
+	// ErrInvalidXID 表示 XID 不是 SuperXid，也没有被 Begin 或 BeginReadOnly 分配过
+	ErrInvalidXID = errors.New("invalid xid")
+	// ErrIllegalTransition 表示取消已经提交的事务或者提交已经取消的事务
+	ErrIllegalTransition = errors.New("illegal transaction status transition")
+	// ErrReadOnly 表示在只读的事务管理器上开启或结束事务
+	ErrReadOnly = errors.New("transaction manager is read-only")
+	// ErrClosed 表示事务管理器已经关闭
+	ErrClosed = errors.New("transaction manager is closed")
+	// ErrFileExists 表示 Create 的目标文件已经存在且不为空
+	ErrFileExists = errors.New("xid file already exists")
+	// ErrReplaceUnsupported 表示 XID 文件不是本地文件，Checkpoint 和迁移无法通过 rename 替换它
+	ErrReplaceUnsupported = errors.New("xid file cannot be replaced on a non-os backend")
+)
+
+// FileLengthError 表示 XID 文件的实际长度与 xidCounter 推算出的长度不一致
+type FileLengthError struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *FileLengthError) Error() string {
+	return fmt.Sprintf("bad xid file: file length %d, expected %d", e.Actual, e.Expected)
+}
+
+func (e *FileLengthError) Unwrap() error {
+	return ErrBadXIDFile
+}
+
+// XIDError 记录读写 XID 文件失败时的操作、XID 和文件偏移，Err 是底层的 I/O 错误。
+// 状态区在 Offset 之前结束时 Err 是 io.EOF，此时 errors.Is(err, ErrBadXIDFile) 也成立
+type XIDError struct {
+	Op     string
+	Xid    int64
+	Offset int64
+	Err    error
+}
+
+func (e *XIDError) Error() string {
+	return fmt.Sprintf("%s xid %d at offset %d: %v", e.Op, e.Xid, e.Offset, e.Err)
+}
+
+func (e *XIDError) Unwrap() error {
+	return e.Err
+}
+
+func (e *XIDError) Is(target error) bool {
+	return target == ErrBadXIDFile && e.Err == io.EOF
+}
+
 // TransactionManager 定义了一个事务管理器接口
 type TransactionManager interface {
-	Begin() int64               // 开启一个新事务
-	Commit(xid int64)           // 提交一个事务
-	Abort(xid int64)            // 取消一个事务
-	IsActive(xid int64) bool    // 查询一个事务的状态是否是正在进行的状态
-	IsCommitted(xid int64) bool // 查询一个事务的状态是否是已提交
-	IsAborted(xid int64) bool   // 查询一个事务的状态是否是已取消
-	Close()                     // 关闭TM
+	Begin() (int64, error)               // 开启一个新事务
+	Commit(xid int64) error              // 提交一个事务
+	Abort(xid int64) error               // 取消一个事务
+	IsActive(xid int64) (bool, error)    // 查询一个事务的状态是否是正在进行的状态
+	IsCommitted(xid int64) (bool, error) // 查询一个事务的状态是否是已提交
+	IsAborted(xid int64) (bool, error)   // 查询一个事务的状态是否是已取消
+	XidCounter() int64                   // 返回已分配的最大 XID
+	Flush() error                        // 把已经写入的状态刷盘
+	Close() error                        // 关闭TM
 }
 
-// TransactionManagerImpl 结构体实现了 TransactionManager 接口
+// TransactionManagerImpl 结构体实现了 TransactionManager 接口。
+//
+// 加锁顺序为 counterLock -> fileLock:
+// counterLock 保护 xidCounter 的修改，分配 XID 和修改文件头时持有，读取 xidCounter 不需要加锁；
+// fileLock 保护 file 和 baseXid，读写状态字节时持有读锁，Checkpoint 替换文件时持有写锁。
+// 不同 XID 的状态字节互不重叠，WriteAt/ReadAt 不依赖文件偏移，所以状态读写之间不需要互斥
 type TransactionManagerImpl struct {
-	file        *os.File
+	// fileLock 保护 file 和 baseXid，Checkpoint 替换文件时持有写锁，其他读写文件的操作持有读锁。
+	// file 在打开期间一直持有操作系统的建议锁，防止两个进程同时打开同一个文件
+	fileLock sync.RWMutex
+	path     string
+	file     BlockFile
+	openFile OpenFileFunc
+	baseXid  int64
+	// headerLen 是文件头的长度，由文件第一个字节记录的格式版本决定，状态区从这里开始
+	headerLen int64
+	// closed 在 Close 关闭文件时设置，之后访问文件的操作返回 ErrClosed。
+	// 它在持有 closeLock 和 fileLock 写锁时修改，closeLock 保证 Close 的各个步骤只执行一次
+	closeLock sync.Mutex
+	closed    bool
+
+	// xidCounter 只在持有 counterLock 时修改，读取不需要加锁
 	counterLock sync.Mutex
-	xidCounter  int64
+	xidCounter  atomic.Int64
+	// allocated 是状态区已经分配到的文件长度，大于 ExpectedFileLen 的部分是预留空间，在 counterLock 下修改
+	allocated     int64
+	preallocChunk int64
+	// allowMigrate 为 true 时打开旧格式的文件会把它迁移成当前格式
+	allowMigrate bool
+	// quiesced 在 Quiesce 之后为 true，之后开启事务返回 ErrQuiesced，在 counterLock 下读写
+	quiesced bool
+
+	// 刷盘模式，SyncInterval 模式下 syncDirty 表示上次刷盘之后有新的写入
+	syncMode  SyncMode
+	syncDirty atomic.Bool
+	syncStop  chan struct{}
+	syncDone  chan struct{}
+
+	streamLock    sync.Mutex
+	stream        io.Writer
+	onStreamError func(error)
+
+	stats txStats
+	// endLocks 按 XID 分段，串行化同一个事务的 Commit、Abort 和 Prepare
+	endLocks [endLockStripes]sync.Mutex
+
+	observerLock sync.RWMutex
+	observer     Observer
+	events       *txEvents
+
+	// decided 保存 WaitDecided 等待的 XID，事务结束时关闭对应的通道
+	decidedLock   sync.Mutex
+	decided       map[int64]chan struct{}
+	decidedClosed bool
+
+	// beganAt 记录每个活跃事务的开启时间，事务结束时删除
+	clock     func() time.Time
+	beganLock sync.Mutex
+	beganAt   map[int64]beginTime
+
+	// 组提交开启时 Commit 交给 group 批量刷盘
+	groupLock sync.RWMutex
+	group     *groupCommitter
+
+	// SetTransactionTimeout 启动的后台协程取消运行时间超过 reapTimeout 的事务
+	reapLock     sync.Mutex
+	reapTimeout  time.Duration
+	reapInterval time.Duration
+	reapStop     chan struct{}
+	reapDone     chan struct{}
+
+	// 只读事务不写文件，只在内存中记录它开启时的快照
+	readOnlyLock sync.Mutex
+	readOnlyNext int64
+	readOnly     map[int64]*readOnlyTxn
 }
 
-// Create 创建一个新的 TransactionManagerImpl
-func Create(path string) (*TransactionManagerImpl, error) {
-	filePath := path + XidSuffix
+// Create 创建一个新的 TransactionManagerImpl。
+// 文件已经存在且不为空时返回 ErrFileExists，除非设置了 WithOverwrite(true)，此时原有的内容被清空
+func Create(path string, opts ...Option) (*TransactionManagerImpl, error) {
+	o := newOptions(opts)
+	filePath, err := o.filePath(path)
+	if err != nil {
+		return nil, err
+	}
 
-	file, err := os.Create(filePath)
+	// 先加锁再检查和清空文件，避免清空另一个事务管理器正在使用的文件
+	file, err := openLocked(o.openFile, filePath, os.O_RDWR|os.O_CREATE)
 	if err != nil {
 		return nil, err
 	}
+	if !o.overwrite {
+		var size int64
+		size, err = file.Seek(0, io.SeekEnd)
+		if err == nil && size > 0 {
+			err = fmt.Errorf("%w: %s", ErrFileExists, filePath)
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return createLocked(filePath, file, o)
+}
 
+// createLocked 清空已经加锁的 file 并写入空的文件头
+func createLocked(filePath string, file BlockFile, o options) (*TransactionManagerImpl, error) {
 	// 写空XID文件头
-	buf := make([]byte, LenXidHeaderLength)
-	_, err = file.Write(buf)
+	err := file.Truncate(0)
+	if err == nil {
+		_, err = file.WriteAt(encodeHeader(0, 0), 0)
+	}
 	if err != nil {
 		file.Close()
 		return nil, err
 	}
 
-	return &TransactionManagerImpl{file: file}, nil
+	t := newManager(filePath, file, o)
+	t.applyOptions(o)
+	return t, nil
 }
 
 // Open 打开一个已存在的 TransactionManagerImpl
-func Open(path string) (*TransactionManagerImpl, error) {
-	filePath := path + XidSuffix
+func Open(path string, opts ...Option) (*TransactionManagerImpl, error) {
+	t, o, err := openExisting(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return loadOpened(t, o)
+}
 
-	file, err := os.OpenFile(filePath, os.O_RDWR, 0666)
+// OpenOrCreate 在文件存在时像 Open 一样打开并校验它，文件不存在或者为空时像 Create 一样创建它，
+// 从不清空已有内容的文件。两步在同一次加锁中完成，不会与另一个进程的创建交错
+func OpenOrCreate(path string, opts ...Option) (*TransactionManagerImpl, error) {
+	o := newOptions(opts)
+	filePath, err := o.filePath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// 分配8个字节给buf
-	buf := make([]byte, LenXidHeaderLength)
-	// 使用文件对象 t.file 的 ReadAt 方法，将文件的内容读取到 buf
-	_, err = file.ReadAt(buf, 0)
+	file, err := openLocked(o.openFile, filePath, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return nil, err
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if size == 0 {
+		return createLocked(filePath, file, o)
+	}
+	return loadOpened(newManager(filePath, file, o), o)
+}
+
+// loadOpened 读取已经加锁打开的 t 的文件头并校验文件长度，失败时关闭文件
+func loadOpened(t *TransactionManagerImpl, o options) (*TransactionManagerImpl, error) {
+	// 读取文件头中的 xidCounter 并校验文件长度
+	err := t.checkXIDCounter()
+	if err != nil {
+		t.file.Close()
+		return nil, err
+	}
+
+	t.applyOptions(o)
+	err = t.seedBeganAt()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// openExisting 加锁打开已存在的 XID 文件，还没有读取文件头
+func openExisting(path string, opts []Option) (*TransactionManagerImpl, options, error) {
+	o := newOptions(opts)
+	filePath, err := o.filePath(path)
+	if err != nil {
+		return nil, o, err
+	}
+
+	file, err := openLocked(o.openFile, filePath, os.O_RDWR)
 	if err != nil {
-		panic(err)
+		return nil, o, err
 	}
+	return newManager(filePath, file, o), o, nil
+}
 
-	xid := int64(buf[0])
+// newManager 用已经加锁打开的 file 构造事务管理器，还没有应用 applyOptions 中的配置
+func newManager(filePath string, file BlockFile, o options) *TransactionManagerImpl {
+	return &TransactionManagerImpl{
+		path:         filePath,
+		file:         file,
+		openFile:     o.openFile,
+		headerLen:    LenXidHeaderLength,
+		allowMigrate: o.allowMigrate,
+	}
+}
 
-	return &TransactionManagerImpl{file: file, counterLock: sync.Mutex{}, xidCounter: xid}, nil
+// openLocked 用 open 打开文件，本地文件会加上建议锁，文件已被锁住时返回 ErrAlreadyLocked
+func openLocked(open OpenFileFunc, filePath string, flag int) (BlockFile, error) {
+	file, err := open(filePath, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := file.(*os.File)
+	if !ok {
+		return file, nil
+	}
+	err = lockFile(f)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return file, nil
 }
 
-func (t *TransactionManagerImpl) checkXIDCounter() {
+func (t *TransactionManagerImpl) checkXIDCounter() error {
 	// 将文件指针移动到文件的末尾，然后返回文件的长度，并将其存储在 fileLen
 	fileLen, err := t.file.Seek(0, io.SeekEnd)
 	if err != nil {
-		panic("BadXIDFileError")
+		return err
 	}
-	if fileLen < LenXidHeaderLength {
-		panic("BadXIDFileError")
+	if fileLen < lenLegacyXidHeader {
+		return fmt.Errorf("%w: file length %d is shorter than the header", ErrBadXIDFile, fileLen)
 	}
 
-	// 分配8个字节给buf
-	buf := make([]byte, LenXidHeaderLength)
-	// 使用文件对象 t.file 的 ReadAt 方法，将文件的内容读取到 buf
-	_, err = t.file.ReadAt(buf, 0)
+	// 没有 magic 的旧文件先迁移成新格式，不允许迁移时版本 0 的文件由 readHeader 原地打开。
+	// 单字节格式的第一个字节可能是 0，要先于 8 字节的旧格式检查
+	singleByte, err := t.isSingleByteFile(fileLen)
+	if err != nil {
+		return err
+	}
+	legacy, err := t.isLegacyFile()
+	if err != nil {
+		return err
+	}
+	if singleByte && !t.allowMigrate {
+		return fmt.Errorf("%w: %s", ErrNeedsMigration, t.path)
+	}
+	if singleByte {
+		err = t.migrateSingleByte(fileLen)
+		if err != nil {
+			return err
+		}
+	} else if legacy && t.allowMigrate {
+		err = t.migrateLegacy(fileLen)
+		if err != nil {
+			return err
+		}
+	}
+
+	var counter int64
+	counter, t.baseXid, err = t.readHeader()
+	if err != nil {
+		return err
+	}
+	t.xidCounter.Store(counter)
+	if t.baseXid < 0 || t.baseXid > t.xidCounter.Load() {
+		return fmt.Errorf("%w: base xid %d is outside [0, %d]", ErrBadXIDFile, t.baseXid, t.xidCounter.Load())
+	}
+	// 计数器还要能再分配一个 XID，状态区推算出的文件长度也不能溢出
+	if counter == math.MaxInt64 || counter-t.baseXid >= (math.MaxInt64-t.headerLen)/XidFieldSize {
+		return fmt.Errorf("%w: xid counter %d is too large", ErrBadXIDFile, counter)
+	}
+	err = t.recoverInterruptedBegin()
+	if err != nil {
+		return err
+	}
+	err = t.VerifyLength()
+	if err != nil {
+		return err
+	}
+	t.allocated, err = t.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// 扫描一次状态区得到打开时的活跃事务数
+	active, err := t.collectXIDs(FieldTranActive)
+	if err != nil {
+		return err
+	}
+	t.stats.active.Store(int64(len(active)))
+	return nil
+}
+
+// ExpectedFileLen 返回按当前 xidCounter 推算出的 XID 文件长度
+func (t *TransactionManagerImpl) ExpectedFileLen() int64 {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+	return t.getXidPosition(t.xidCounter.Load() + 1)
+}
+
+// VerifyLength 检查 XID 文件的实际长度是否等于 ExpectedFileLen，末尾的预留空间不计入长度，不一致时返回 *FileLengthError
+func (t *TransactionManagerImpl) VerifyLength() error {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	fileLen, err := t.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	expected := t.getXidPosition(t.xidCounter.Load() + 1)
+	fileLen, err = t.trimReserved(expected, fileLen)
+	if err != nil {
+		return err
+	}
+	if fileLen != expected {
+		return &FileLengthError{Expected: expected, Actual: fileLen}
+	}
+	return nil
+}
+
+// Verify 检查文件头中的 xidCounter 与文件实际写入的状态区是否一致
+func (t *TransactionManagerImpl) Verify() error {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+	_, err := t.scanStatusRegion()
+	return err
+}
+
+// Repair 在文件头的 xidCounter 落后于实际写入的状态区时，把 xidCounter 修复到最大的已写入 XID
+func (t *TransactionManagerImpl) Repair() error {
+	t.counterLock.Lock()
+	defer t.counterLock.Unlock()
+
+	highest, err := t.scanStatusRegion()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrXIDCounterBehind) {
+		return err
+	}
+
+	err = t.writeXIDCounter(highest)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	t.xidCounter.Store(highest)
 
-	t.xidCounter = int64(buf[0])
-	end := t.getXidPosition(t.xidCounter + 1)
-	if end != fileLen {
-		panic("BadXIDFileException")
+	// 重新纳入的事务中可能有活跃的事务
+	active, err := t.collectXIDs(FieldTranActive)
+	if err != nil {
+		return err
 	}
+	t.stats.active.Store(int64(len(active)))
+	return nil
+}
+
+// scanStatusRegion 扫描文件头之后的状态区，返回文件中最大的已写入 XID
+func (t *TransactionManagerImpl) scanStatusRegion() (int64, error) {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	fileLen, err := t.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if fileLen < t.headerLen {
+		return 0, ErrBadXIDFile
+	}
+
+	counter, err := t.readXIDCounter()
+	if err != nil {
+		return 0, err
+	}
+
+	end := t.getXidPosition(counter + 1)
+	fileLen, err = t.trimReserved(end, fileLen)
+	if err != nil {
+		return 0, err
+	}
+	if fileLen < end {
+		return 0, &FileLengthError{Expected: end, Actual: fileLen}
+	}
+	if fileLen == end {
+		return counter, nil
+	}
+
+	// 文件头之后还有超出 xidCounter 的状态，逐个检查是否为合法状态
+	tail := make([]byte, fileLen-end)
+	_, err = t.file.ReadAt(tail, end)
+	if err != nil {
+		return 0, err
+	}
+	for _, status := range tail {
+		if !isValidStatus(status) {
+			return 0, fmt.Errorf("%w: invalid status byte %d", ErrBadXIDFile, status)
+		}
+	}
+	highest := counter + int64(len(tail))/XidFieldSize
+	return highest, fmt.Errorf("%w: counter %d, highest written xid %d", ErrXIDCounterBehind, counter, highest)
 }
 
 func (t *TransactionManagerImpl) getXidPosition(xid int64) int64 {
-	return LenXidHeaderLength + (xid-1)*XidFieldSize
+	return t.headerLen + (xid-t.baseXid-1)*XidFieldSize
 }
 
-func (t *TransactionManagerImpl) updateXID(xid int64, status byte) {
+func (t *TransactionManagerImpl) updateXID(xid int64, status byte) error {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	if xid <= t.baseXid {
+		return fmt.Errorf("%w: %d", ErrXIDCheckpointed, xid)
+	}
 	offset := t.getXidPosition(xid)
 	tmp := []byte{status}
 	_, err := t.file.WriteAt(tmp, offset)
 	if err != nil {
-		panic(err)
+		return &XIDError{Op: "update status", Xid: xid, Offset: offset, Err: err}
+	}
+
+	err = t.maybeSync()
+	if err != nil {
+		return err
 	}
 
-	err = t.file.Sync()
+	t.emitChange(xid, status)
+	return nil
+}
+
+func (t *TransactionManagerImpl) incrXIDCounter() error {
+	err := t.writeXIDCounter(t.xidCounter.Load() + 1)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	t.xidCounter.Add(1)
+	return nil
 }
 
-func (t *TransactionManagerImpl) incrXIDCounter() {
-	t.xidCounter++
-	buf := []byte{byte(t.xidCounter)}
-	// 更新后的 xidCounter 写入文件的开头
-	_, err := t.file.WriteAt(buf, 0)
+// readXIDCounter 从文件头读取 xidCounter
+func (t *TransactionManagerImpl) readXIDCounter() (int64, error) {
+	// 分配8个字节给buf
+	buf := make([]byte, 8)
+	// 使用文件对象 t.file 的 ReadAt 方法，将文件的内容读取到 buf
+	_, err := t.file.ReadAt(buf, t.counterOffset())
+	if err == io.EOF {
+		return 0, fmt.Errorf("%w: truncated header", ErrBadXIDFile)
+	}
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
 
-	err = t.file.Sync()
+// counterOffset 返回 xidCounter 在文件头中的偏移，版本 0 的文件头只有 xidCounter
+func (t *TransactionManagerImpl) counterOffset() int64 {
+	if t.headerLen == lenLegacyXidHeader {
+		return 0
+	}
+	return offXidCounter
+}
+
+// writeXIDCounter 把 counter 和新的 checksum 写入文件头并刷盘，版本 0 的文件只写 xidCounter
+func (t *TransactionManagerImpl) writeXIDCounter(counter int64) error {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+	var err error
+	offset := int64(offXidChecksum)
+	if t.headerLen == lenLegacyXidHeader {
+		buf := make([]byte, lenLegacyXidHeader)
+		binary.BigEndian.PutUint64(buf, uint64(counter))
+		offset = 0
+		_, err = t.file.WriteAt(buf, offset)
+	} else {
+		header := encodeHeader(counter, t.baseXid)
+		_, err = t.file.WriteAt(header[offXidChecksum:offBaseXid], offset)
+	}
 	if err != nil {
-		panic(err)
+		return &XIDError{Op: "write counter", Xid: counter, Offset: offset, Err: err}
 	}
+	return t.maybeSync()
 }
 
-func (t *TransactionManagerImpl) Begin() int64 {
+func (t *TransactionManagerImpl) Begin() (int64, error) {
+	return t.BeginContext(context.Background())
+}
+
+// BeginContext 与 Begin 相同，但 ctx 已经结束时不分配 XID 而是返回 ctx.Err()。
+// 在 counterLock 上等待时(例如 Checkpoint 正在重写文件)无法中断，拿到锁后会再检查一次 ctx。
+// 开启事务的状态按刷盘模式直接写入，不经过组提交的批次，所以分配之后没有需要放弃的等待
+func (t *TransactionManagerImpl) BeginContext(ctx context.Context) (int64, error) {
+	err := ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	xid, err := t.begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	t.markBegan(xid, true)
+	t.notifyBegin(xid)
+	return xid, nil
+}
+
+// begin 在 counterLock 下分配并写入新的 XID，Observer 由 Begin 在释放锁之后回调
+func (t *TransactionManagerImpl) begin(ctx context.Context) (int64, error) {
 	t.counterLock.Lock()
 	defer t.counterLock.Unlock()
+	return t.beginLocked(ctx)
+}
+
+// beginLocked 实现 begin，调用者需持有 counterLock
+func (t *TransactionManagerImpl) beginLocked(ctx context.Context) (int64, error) {
+	err := ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	if t.quiesced {
+		return 0, ErrQuiesced
+	}
+	xid := t.xidCounter.Load() + 1
+	err = t.reserve(xid)
+	if err != nil {
+		return 0, err
+	}
+	// 先写状态再推进计数器，SyncAlways 下每次写入之后都刷盘。崩溃时最多留下一个计数器之外的活跃槽位，
+	// 打开时由 recoverInterruptedBegin 处理；反过来的顺序会让计数器覆盖一个从未写入的槽位
+	err = t.updateXID(xid, FieldTranActive)
+	if err != nil {
+		return 0, err
+	}
+	err = t.incrXIDCounter()
+	if err != nil {
+		return 0, err
+	}
+	t.stats.begins.Add(1)
+	t.stats.active.Add(1)
+	return xid, nil
+}
+
+func (t *TransactionManagerImpl) Commit(xid int64) error {
+	if isReadOnlyXid(xid) {
+		t.endReadOnly(xid)
+		return nil
+	}
+	ended, err := t.commitLocked(xid)
+	if ended {
+		t.notifyCommit(xid)
+	}
+	return err
+}
+
+// commitLocked 在 xid 的分段锁下把事务标记为已提交，ended 表示这次调用结束了事务。
+// 回调 Observer 可能再调用事务管理器的方法，所以由调用者在释放锁之后通知
+func (t *TransactionManagerImpl) commitLocked(xid int64) (ended bool, err error) {
+	err = t.checkAllocated(xid)
+	if err != nil {
+		return false, err
+	}
+	unlock := t.lockEnd(xid)
+	defer unlock()
+	status, err := t.GetStatus(xid)
+	if err != nil {
+		return false, err
+	}
+	done, err := checkTransition(xid, status, StatusCommitted)
+	if done || err != nil {
+		return false, err
+	}
+	// 已准备的事务在 Prepare 时已经不算作活跃事务
+	wasActive := status == StatusActive
+
+	t.groupLock.RLock()
+	if t.group != nil {
+		err = t.group.submit(xid)
+	} else {
+		err = t.updateXID(xid, FieldTranCommitted)
+	}
+	t.groupLock.RUnlock()
+	if err != nil {
+		return false, err
+	}
+	t.stats.commits.Add(1)
+	if wasActive {
+		t.stats.decrActive(1)
+	}
+	t.markEnded(xid)
+	return true, nil
+}
+
+func (t *TransactionManagerImpl) Abort(xid int64) error {
+	if isReadOnlyXid(xid) {
+		t.endReadOnly(xid)
+		return nil
+	}
+	ended, err := t.abortLocked(xid)
+	if ended {
+		t.notifyAbort(xid)
+	}
+	return err
+}
+
+// abortLocked 在 xid 的分段锁下把事务标记为已取消，与 commitLocked 相同
+func (t *TransactionManagerImpl) abortLocked(xid int64) (ended bool, err error) {
+	err = t.checkAllocated(xid)
+	if err != nil {
+		return false, err
+	}
+	unlock := t.lockEnd(xid)
+	defer unlock()
+	status, err := t.GetStatus(xid)
+	if err != nil {
+		return false, err
+	}
+	done, err := checkTransition(xid, status, StatusAborted)
+	if done || err != nil {
+		return false, err
+	}
+	wasActive := status == StatusActive
 
-	xid := t.xidCounter + 1
-	t.updateXID(xid, FieldTranActive)
-	t.incrXIDCounter()
-	return xid
+	err = t.updateXID(xid, FieldTranAborted)
+	if err != nil {
+		return false, err
+	}
+	t.stats.aborts.Add(1)
+	if wasActive {
+		t.stats.decrActive(1)
+	}
+	t.markEnded(xid)
+	return true, nil
 }
 
-func (t *TransactionManagerImpl) Commit(xid int64) {
-	t.updateXID(xid, FieldTranCommitted)
+// checkAllocated 检查 xid 已经由 Begin 分配，即位于 [1, xidCounter] 内，
+// 避免读到文件末尾之外或者写到 xidCounter 之后的位置把文件写长
+func (t *TransactionManagerImpl) checkAllocated(xid int64) error {
+	counter := t.XidCounter()
+	if xid <= SuperXid || xid > counter {
+		return fmt.Errorf("%w: %d is outside [1, %d]", ErrInvalidXID, xid, counter)
+	}
+	return nil
 }
 
-func (t *TransactionManagerImpl) Abort(xid int64) {
-	t.updateXID(xid, FieldTranAborted)
+// readStatuses 一次读出 [from, to] 范围内所有 XID 的状态字节
+func (t *TransactionManagerImpl) readStatuses(from, to int64) ([]byte, error) {
+	if to < from {
+		return nil, nil
+	}
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	if t.closed {
+		return nil, ErrClosed
+	}
+	if from <= t.baseXid {
+		return nil, fmt.Errorf("%w: %d", ErrXIDCheckpointed, from)
+	}
+	buf := make([]byte, (to-from+1)*XidFieldSize)
+	offset := t.getXidPosition(from)
+	_, err := t.file.ReadAt(buf, offset)
+	if err != nil {
+		return nil, &XIDError{Op: "read status", Xid: from, Offset: offset, Err: err}
+	}
+	return buf, nil
 }
 
 // 通过检查事务xid来检查事务是否可以正常提交运行
-func (t *TransactionManagerImpl) checkXID(xid int64, status byte) bool {
+func (t *TransactionManagerImpl) checkXID(xid int64, status byte) (bool, error) {
+	err := t.checkAllocated(xid)
+	if err != nil {
+		return false, err
+	}
+	b, err := t.readStatus(xid)
+	if err != nil {
+		return false, err
+	}
+	return b == status, nil
+}
+
+// readStatus 读出 xid 在文件中的状态字节
+func (t *TransactionManagerImpl) readStatus(xid int64) (byte, error) {
+	t.fileLock.RLock()
+	defer t.fileLock.RUnlock()
+
+	if t.closed {
+		return 0, ErrClosed
+	}
+	if xid <= t.baseXid {
+		return 0, fmt.Errorf("%w: %d", ErrXIDCheckpointed, xid)
+	}
 	offset := t.getXidPosition(xid)
 	buf := make([]byte, XidFieldSize)
 	_, err := t.file.ReadAt(buf, offset)
 	if err != nil {
-		panic(err)
+		return 0, &XIDError{Op: "read status", Xid: xid, Offset: offset, Err: err}
 	}
-	return buf[0] == status
+	return buf[0], nil
 }
 
-func (t *TransactionManagerImpl) IsActive(xid int64) bool {
-	if xid == SuperXid {
-		return false
-	}
-	return t.checkXID(xid, FieldTranActive)
+func (t *TransactionManagerImpl) IsActive(xid int64) (bool, error) {
+	status, err := t.GetStatus(xid)
+	return status == StatusActive && err == nil, err
 }
 
-func (t *TransactionManagerImpl) IsCommitted(xid int64) bool {
-	if xid == SuperXid {
-		return true
-	}
-	return t.checkXID(xid, FieldTranCommitted)
+func (t *TransactionManagerImpl) IsCommitted(xid int64) (bool, error) {
+	status, err := t.GetStatus(xid)
+	return status == StatusCommitted && err == nil, err
 }
 
-func (t *TransactionManagerImpl) IsAborted(xid int64) bool {
-	if xid == SuperXid {
-		return false
-	}
-	return t.checkXID(xid, FieldTranAborted)
+func (t *TransactionManagerImpl) IsAborted(xid int64) (bool, error) {
+	status, err := t.GetStatus(xid)
+	return status == StatusAborted && err == nil, err
+}
+
+// XidCounter 返回已分配的最大 XID，不需要等待正在写文件的 Begin
+func (t *TransactionManagerImpl) XidCounter() int64 {
+	return t.xidCounter.Load()
 }
 
-func (t *TransactionManagerImpl) Close() {
+func (t *TransactionManagerImpl) Close() error {
+	t.closeLock.Lock()
+	defer t.closeLock.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+
+	t.SetTransactionTimeout(0)
+	t.EndGroupCommit()
+	syncErr := t.stopSyncLoop()
+	t.fileLock.Lock()
+	defer t.fileLock.Unlock()
+	t.closed = true
 	err := t.file.Close()
-	if err != nil {
-		panic(err)
+	if t.events != nil {
+		t.events.close()
+	}
+	t.wakeAllDecided()
+	if syncErr != nil {
+		return syncErr
 	}
+	return err
 }
@@ -1,44 +1,75 @@
 package tm
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
+func mustBegin(t *testing.T, tm TransactionManager) int64 {
+	t.Helper()
+	xid, err := tm.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	return xid
+}
+
+// checkStatus 调用 IsActive/IsCommitted/IsAborted 之一，出错时直接终止测试
+func checkStatus(t *testing.T, query func(int64) (bool, error), xid int64) bool {
+	t.Helper()
+	ok, err := query(xid)
+	if err != nil {
+		t.Fatalf("Status query for xid %d failed: %v", xid, err)
+	}
+	return ok
+}
+
+func mustCheckXID(t *testing.T, tm *TransactionManagerImpl, xid int64, status byte) bool {
+	t.Helper()
+	ok, err := tm.checkXID(xid, status)
+	if err != nil {
+		t.Fatalf("checkXID for xid %d failed: %v", xid, err)
+	}
+	return ok
+}
+
 func TestTransactionManager(t *testing.T) {
 	path := "test_file"
 	tm, err := Create(path)
 	if err != nil {
 		t.Errorf("Create failed: %v", err)
 	}
-	//defer os.Remove(path + XidSuffix)
+	defer os.Remove(path + XidSuffix)
 
 	// 测试 Begin、Commit 和 Abort
-	xid := tm.Begin()
-	if !tm.IsActive(xid) {
+	xid := mustBegin(t, tm)
+	if !checkStatus(t, tm.IsActive, xid) {
 		t.Errorf("Expected IsActive(xid) to be true")
 	}
 
-	if tm.IsActive(xid) {
+	if checkStatus(t, tm.IsActive, xid) {
 		fmt.Println("事务活跃")
 	}
 	tm.Commit(xid)
-	if !tm.IsCommitted(xid) {
+	if !checkStatus(t, tm.IsCommitted, xid) {
 		t.Errorf("Expected IsCommitted(xid) to be true")
 	}
-	if tm.IsCommitted(xid) {
+	if checkStatus(t, tm.IsCommitted, xid) {
 		fmt.Println("事务提交")
 	}
+	xid = mustBegin(t, tm)
 	tm.Abort(xid)
-	if !tm.IsAborted(xid) {
+	if !checkStatus(t, tm.IsAborted, xid) {
 		t.Errorf("Expected IsAborted(xid) to be true")
 	}
-	if tm.IsAborted(xid) {
+	if checkStatus(t, tm.IsAborted, xid) {
 		fmt.Println("事务取消")
 	}
 
-	xidTest := tm.xidCounter + 1
+	xidTest := tm.xidCounter.Load() + 1
 	tm.updateXID(xidTest, FieldTranActive)
 	tm.incrXIDCounter()
 
@@ -47,7 +78,7 @@ func TestTransactionManager(t *testing.T) {
 	fmt.Println(xidTest)
 
 	// Close the transaction manager
-	defer tm.Close()
+	tm.Close()
 
 	// Reopen the transaction manager
 	tm2, err := Open(path)
@@ -58,7 +89,7 @@ func TestTransactionManager(t *testing.T) {
 
 	tm2.Begin()
 
-	fmt.Println(tm2.xidCounter)
+	fmt.Println(tm2.xidCounter.Load())
 
 	// Check if the transaction manager reopens successfully
 	if tm2 == nil {
@@ -66,30 +97,25 @@ func TestTransactionManager(t *testing.T) {
 	}
 
 	// Check if the transaction is still committed after reopening
-	if !tm2.IsActive(tm2.xidCounter) {
+	if !checkStatus(t, tm2.IsActive, tm2.xidCounter.Load()) {
 		t.Errorf("Transaction not marked as committed after reopening")
 	}
 
 }
 
 func TestIncrXIDCounter(t *testing.T) {
-	// 创建测试目录
-	err := os.MkdirAll("D:\\data\\db", 0755)
-	if err != nil {
-		t.Fatal("Failed to create test directory:", err)
-	}
-
 	// 创建一个 TransactionManager
-	tm, err := Create("D:\\data\\db\\test_tm")
+	path := filepath.Join(t.TempDir(), "test_tm")
+	tm, err := Create(path)
 	if err != nil {
 		t.Fatal("Failed to create TransactionManager:", err)
 	}
-	defer os.Remove("D:\\data\\db\\test_tm.xid")
+	defer tm.Close()
 
 	// 测试 incrXIDCounter
 	tm.incrXIDCounter()
-	if tm.xidCounter != 1 {
-		t.Errorf("Expected xidCounter to be 1, but got %d", tm.xidCounter)
+	if tm.xidCounter.Load() != 1 {
+		t.Errorf("Expected xidCounter to be 1, but got %d", tm.xidCounter.Load())
 	}
 
 	// 进行其他测试逻辑
@@ -116,28 +142,40 @@ func TestCreate(t *testing.T) {
 	tm.Begin()
 
 	// Check the initial state of XID counter
-	if tm.xidCounter != 1 {
+	if tm.xidCounter.Load() != 1 {
 		t.Errorf("XID counter not initialized correctly")
 	}
 }
 
 func TestOpen(t *testing.T) {
 	path := "test_file"
-	_, err := os.Create(path + XidSuffix)
+	created, err := Create(path)
 	if err != nil {
-		t.Errorf("Test setup failed: %v", err)
+		t.Fatalf("Test setup failed: %v", err)
 	}
+	xid := mustBegin(t, created)
+	created.Commit(xid)
+	created.Close()
+
 	tm, err := Open(path)
 	if err != nil {
-		t.Errorf("Open failed: %v", err)
+		t.Fatalf("Open failed: %v", err)
 	}
 	defer os.Remove(path + XidSuffix)
-	defer tm.Close()
 
 	// Check if the file was opened successfully
 	if tm == nil {
-		t.Errorf("Transaction manager not created")
+		t.Fatalf("Transaction manager not created")
+	}
+	if tm.xidCounter.Load() != xid {
+		t.Errorf("Expected xidCounter %d after Open, got %d", xid, tm.xidCounter.Load())
+	}
+	if !checkStatus(t, tm.IsCommitted, xid) {
+		t.Errorf("XID not marked as committed after Open")
 	}
+
+	// 打开已有文件后可以正常关闭
+	tm.Close()
 }
 
 func TestCheckXIDCounter(t *testing.T) {
@@ -150,22 +188,47 @@ func TestCheckXIDCounter(t *testing.T) {
 	defer tm.Close()
 
 	tm.Begin()
-	tm.checkXIDCounter()
+	if err := tm.checkXIDCounter(); err != nil {
+		t.Errorf("checkXIDCounter failed: %v", err)
+	}
 
 	// Check if XID counter is initialized to 1
-	if tm.xidCounter != 1 {
+	if tm.xidCounter.Load() != 1 {
 		t.Errorf("XID counter not initialized correctly")
 	}
 }
 
 func TestXidPosition(t *testing.T) {
-	// 测试 getXidPosition
-	tm := &TransactionManagerImpl{}
-	xid := int64(123)
-	expectedPosition := int64(LenXidHeaderLength + (xid-1)*XidFieldSize)
-	position := tm.getXidPosition(xid)
-	if position != expectedPosition {
-		t.Errorf("Expected position to be %d, but got %d", expectedPosition, position)
+	// 测试 getXidPosition，状态区从文件记录的版本对应的文件头之后开始
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	open := map[byte]func() (*TransactionManagerImpl, error){
+		0: func() (*TransactionManagerImpl, error) {
+			writeLegacyFile(t, path, make([]byte, 123))
+			return Open(path, WithAllowMigrate(false))
+		},
+		xidFormatVersion: func() (*TransactionManagerImpl, error) {
+			return Create(path, WithOverwrite(true))
+		},
+	}
+	for version, headerLen := range map[byte]int64{0: 8, xidFormatVersion: LenXidHeaderLength} {
+		tm, err := open[version]()
+		if err != nil {
+			t.Fatalf("Version %d: open failed: %v", version, err)
+		}
+		xid := int64(123)
+		if position := tm.getXidPosition(xid); position != headerLen+(xid-1)*XidFieldSize {
+			t.Errorf("Version %d: expected position to be %d, but got %d", version, headerLen+(xid-1)*XidFieldSize, position)
+		}
+		tm.baseXid = 100
+		if position := tm.getXidPosition(xid); position != headerLen+22*XidFieldSize {
+			t.Errorf("Version %d: expected position after base xid to be %d, got %d", version, headerLen+22, position)
+		}
+		tm.baseXid = 0
+		tm.Close()
+	}
+	if _, err := headerLenForVersion(7); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion for an unknown version, got %v", err)
 	}
 }
 
@@ -178,14 +241,13 @@ func TestUpdateXID(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	tm.Begin()
-	xid := tm.xidCounter + 1
+	xid := mustBegin(t, tm)
 
 	status := FieldTranCommitted
 	tm.updateXID(xid, status)
 
 	// Check if the status of the transaction was updated correctly
-	if !tm.checkXID(xid, status) {
+	if !mustCheckXID(t, tm, xid, status) {
 		t.Errorf("XID status not updated correctly")
 	}
 }
@@ -199,13 +261,13 @@ func TestBegin(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 
 	// Check if the XID was incremented and marked as active
 	if xid != 1 {
 		t.Errorf("XID not incremented correctly")
 	}
-	if !tm.IsActive(xid) {
+	if !checkStatus(t, tm.IsActive, xid) {
 		t.Errorf("XID not marked as active")
 	}
 }
@@ -219,11 +281,11 @@ func TestCommit(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 	tm.Commit(xid)
 
 	// Check if the XID is marked as committed
-	if !tm.IsCommitted(xid) {
+	if !checkStatus(t, tm.IsCommitted, xid) {
 		t.Errorf("XID not marked as committed")
 	}
 }
@@ -237,11 +299,11 @@ func TestAbort(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 	tm.Abort(xid)
 
 	// Check if the XID is marked as aborted
-	if !tm.IsAborted(xid) {
+	if !checkStatus(t, tm.IsAborted, xid) {
 		t.Errorf("XID not marked as aborted")
 	}
 }
@@ -255,19 +317,20 @@ func TestCheckXID(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
-	if !tm.checkXID(xid, FieldTranActive) {
+	xid := mustBegin(t, tm)
+	if !mustCheckXID(t, tm, xid, FieldTranActive) {
 		t.Errorf("XID status not checked correctly")
 	}
 
 	tm.Commit(xid)
 	// Check if the XID status is correctly reported
-	if !tm.checkXID(xid, FieldTranCommitted) {
+	if !mustCheckXID(t, tm, xid, FieldTranCommitted) {
 		t.Errorf("XID status not checked correctly")
 	}
 
+	xid = mustBegin(t, tm)
 	tm.Abort(xid)
-	if !tm.checkXID(xid, FieldTranAborted) {
+	if !mustCheckXID(t, tm, xid, FieldTranAborted) {
 		t.Errorf("XID status not checked correctly")
 	}
 
@@ -282,10 +345,10 @@ func TestIsActive(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 
 	// Check if the XID is marked as active
-	if !tm.IsActive(xid) {
+	if !checkStatus(t, tm.IsActive, xid) {
 		t.Errorf("XID not marked as active")
 	}
 }
@@ -299,11 +362,11 @@ func TestIsCommitted(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 	tm.Commit(xid)
 
 	// Check if the XID is marked as committed
-	if !tm.IsCommitted(xid) {
+	if !checkStatus(t, tm.IsCommitted, xid) {
 		t.Errorf("XID not marked as committed")
 	}
 }
@@ -317,11 +380,11 @@ func TestIsAborted(t *testing.T) {
 	defer os.Remove(path + XidSuffix)
 	defer tm.Close()
 
-	xid := tm.Begin()
+	xid := mustBegin(t, tm)
 	tm.Abort(xid)
 
 	// Check if the XID is marked as aborted
-	if !tm.IsAborted(xid) {
+	if !checkStatus(t, tm.IsAborted, xid) {
 		t.Errorf("XID not marked as aborted")
 	}
 }
@@ -336,3 +399,224 @@ func TestClose(t *testing.T) {
 	defer tm.Close()
 
 }
+
+func TestVerifyCounterBehind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.Begin()
+	tm.Begin()
+	xid := mustBegin(t, tm)
+	if err := tm.Verify(); err != nil {
+		t.Fatalf("Verify failed on a consistent file: %v", err)
+	}
+
+	// 模拟文件头中的计数器被破坏成比实际活跃事务更小的值
+	tm.writeXIDCounter(1)
+	tm.xidCounter.Store(1)
+
+	err = tm.Verify()
+	if !errors.Is(err, ErrXIDCounterBehind) {
+		t.Fatalf("Expected ErrXIDCounterBehind, got %v", err)
+	}
+
+	if err := tm.Repair(); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if tm.xidCounter.Load() != xid {
+		t.Errorf("Expected xidCounter %d after repair, got %d", xid, tm.xidCounter.Load())
+	}
+	if err := tm.Verify(); err != nil {
+		t.Errorf("Verify failed after repair: %v", err)
+	}
+
+	// 修复后新事务不会覆盖仍然活跃的事务
+	if next := mustBegin(t, tm); next != xid+1 {
+		t.Errorf("Expected next xid %d, got %d", xid+1, next)
+	}
+	if !checkStatus(t, tm.IsActive, xid) {
+		t.Errorf("Live transaction was overwritten")
+	}
+}
+
+func TestVerifyLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.Begin()
+	tm.Begin()
+
+	if expected := int64(LenXidHeaderLength + 2*XidFieldSize); tm.ExpectedFileLen() != expected {
+		t.Errorf("Expected file length %d, got %d", expected, tm.ExpectedFileLen())
+	}
+	if err := tm.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed on a consistent file: %v", err)
+	}
+}
+
+func TestVerifyLengthMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	tm.Begin()
+	tm.Begin()
+	tm.file.Truncate(LenXidHeaderLength + XidFieldSize)
+
+	err = tm.VerifyLength()
+	var lenErr *FileLengthError
+	if !errors.As(err, &lenErr) {
+		t.Fatalf("Expected *FileLengthError, got %v", err)
+	}
+	if lenErr.Expected != tm.ExpectedFileLen() || lenErr.Actual != LenXidHeaderLength+XidFieldSize {
+		t.Errorf("Unexpected lengths in error: %+v", lenErr)
+	}
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected error to match ErrBadXIDFile")
+	}
+}
+
+func TestCounterBeyondOneByte(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	const total = 300
+	for i := 0; i < total; i++ {
+		xid := mustBegin(t, tm)
+		switch xid % 3 {
+		case 0:
+			tm.Commit(xid)
+		case 1:
+			tm.Abort(xid)
+		}
+	}
+	tm.Close()
+
+	tm2, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer tm2.Close()
+
+	if tm2.xidCounter.Load() != total {
+		t.Fatalf("Expected xidCounter %d after reopen, got %d", total, tm2.xidCounter.Load())
+	}
+	if err := tm2.VerifyLength(); err != nil {
+		t.Errorf("VerifyLength failed after reopen: %v", err)
+	}
+	for xid := int64(total - 10); xid <= total; xid++ {
+		var ok bool
+		switch xid % 3 {
+		case 0:
+			ok = checkStatus(t, tm2.IsCommitted, xid)
+		case 1:
+			ok = checkStatus(t, tm2.IsAborted, xid)
+		default:
+			ok = checkStatus(t, tm2.IsActive, xid)
+		}
+		if !ok {
+			t.Errorf("Status of xid %d did not survive reopen", xid)
+		}
+	}
+}
+
+func TestOpenTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+
+	// 文件比文件头还短
+	err := os.WriteFile(path+XidSuffix, []byte{0, 0, 0}, 0666)
+	if err != nil {
+		t.Fatalf("Test setup failed: %v", err)
+	}
+	_, err = Open(path)
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected ErrBadXIDFile for a short header, got %v", err)
+	}
+
+	// 文件头中的计数器超出了实际写入的状态
+	tm, err := Create(path, WithOverwrite(true))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	mustBegin(t, tm)
+	mustBegin(t, tm)
+	tm.file.Truncate(LenXidHeaderLength + XidFieldSize)
+	tm.Close()
+
+	_, err = Open(path)
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected ErrBadXIDFile for a truncated status region, got %v", err)
+	}
+}
+
+func TestCheckXIDPastCounter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	_, err = tm.IsCommitted(5)
+	if !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Expected ErrInvalidXID when reading past xidCounter, got %v", err)
+	}
+}
+
+func TestXIDBounds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	fileLen := tm.ExpectedFileLen()
+	for _, bad := range []int64{SuperXid, xid + 1, xid + 100} {
+		if err := tm.Commit(bad); !errors.Is(err, ErrInvalidXID) {
+			t.Errorf("Expected ErrInvalidXID from Commit(%d), got %v", bad, err)
+		}
+		if err := tm.Abort(bad); !errors.Is(err, ErrInvalidXID) {
+			t.Errorf("Expected ErrInvalidXID from Abort(%d), got %v", bad, err)
+		}
+	}
+	for _, bad := range []int64{-1, -100, xid + 1, xid + 100} {
+		if _, err := tm.IsAborted(bad); !errors.Is(err, ErrInvalidXID) {
+			t.Errorf("Expected ErrInvalidXID from IsAborted(%d), got %v", bad, err)
+		}
+	}
+	// 越界的写入被拒绝，文件长度不变
+	if tm.ExpectedFileLen() != fileLen || tm.VerifyLength() != nil {
+		t.Errorf("Expected file length to stay %d", fileLen)
+	}
+
+	// SuperXid 仍然视为已提交，分配过的只读 XID 是合法的
+	if !checkStatus(t, tm.IsCommitted, SuperXid) {
+		t.Errorf("Expected SuperXid to be committed")
+	}
+	ro, err := tm.BeginReadOnly()
+	if err != nil {
+		t.Fatalf("BeginReadOnly failed: %v", err)
+	}
+	if !checkStatus(t, tm.IsActive, ro) {
+		t.Errorf("Expected read-only xid %d to be active", ro)
+	}
+	if _, err := tm.IsActive(ro - 1); !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Expected ErrInvalidXID for an unallocated read-only xid, got %v", err)
+	}
+}
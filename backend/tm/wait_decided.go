@@ -0,0 +1,66 @@
+package tm
+
+import "context"
+
+// WaitDecided 等待 xid 提交或取消并返回它的状态，xid 已经结束时立即返回，用来代替轮询 IsCommitted。
+// 已准备的事务还没有结束，会继续等待。ctx 被取消时返回 ctx.Err()，Close 时返回 ErrClosed，
+// xid 没有分配过时返回 ErrInvalidXID。只读事务结束后的状态是已提交
+func (t *TransactionManagerImpl) WaitDecided(ctx context.Context, xid int64) (Status, error) {
+	for {
+		// 先登记再读状态，读完状态之后才结束的事务一定会唤醒这次等待
+		ch := t.decidedChan(xid)
+		status, err := t.GetStatus(xid)
+		if err != nil {
+			return 0, err
+		}
+		if status == StatusCommitted || status == StatusAborted {
+			return status, nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// decidedChan 返回 xid 结束时被关闭的通道，Close 之后返回已经关闭的通道
+func (t *TransactionManagerImpl) decidedChan(xid int64) <-chan struct{} {
+	t.decidedLock.Lock()
+	defer t.decidedLock.Unlock()
+	if t.decidedClosed {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	if t.decided == nil {
+		t.decided = make(map[int64]chan struct{})
+	}
+	ch, ok := t.decided[xid]
+	if !ok {
+		ch = make(chan struct{})
+		t.decided[xid] = ch
+	}
+	return ch
+}
+
+// wakeDecided 唤醒等待 xid 结束的 WaitDecided
+func (t *TransactionManagerImpl) wakeDecided(xid int64) {
+	t.decidedLock.Lock()
+	defer t.decidedLock.Unlock()
+	if ch, ok := t.decided[xid]; ok {
+		close(ch)
+		delete(t.decided, xid)
+	}
+}
+
+// wakeAllDecided 在 Close 时唤醒所有的 WaitDecided，它们重新读状态时得到 ErrClosed
+func (t *TransactionManagerImpl) wakeAllDecided() {
+	t.decidedLock.Lock()
+	defer t.decidedLock.Unlock()
+	t.decidedClosed = true
+	for xid, ch := range t.decided {
+		close(ch)
+		delete(t.decided, xid)
+	}
+}
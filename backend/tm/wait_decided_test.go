@@ -0,0 +1,89 @@
+package tm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitDecided(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	type result struct {
+		status Status
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		status, err := tm.WaitDecided(context.Background(), xid)
+		done <- result{status, err}
+	}()
+
+	// 已准备的事务还没有结束
+	tm.Prepare(xid)
+	select {
+	case r := <-done:
+		t.Fatalf("Expected WaitDecided to block, got %v, %v", r.status, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tm.Commit(xid)
+	select {
+	case r := <-done:
+		if r.err != nil || r.status != StatusCommitted {
+			t.Errorf("Expected committed, got %v, %v", r.status, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("WaitDecided did not return after Commit")
+	}
+
+	// 已经结束的事务立即返回
+	aborted := mustBegin(t, tm)
+	tm.Abort(aborted)
+	if status, err := tm.WaitDecided(context.Background(), aborted); err != nil || status != StatusAborted {
+		t.Errorf("Expected aborted, got %v, %v", status, err)
+	}
+	if _, err := tm.WaitDecided(context.Background(), aborted+100); !errors.Is(err, ErrInvalidXID) {
+		t.Errorf("Expected ErrInvalidXID, got %v", err)
+	}
+}
+
+func TestWaitDecidedTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	xid := mustBegin(t, tm)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tm.WaitDecided(ctx, xid); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Close 唤醒等待者
+	done := make(chan error)
+	go func() {
+		_, err := tm.WaitDecided(context.Background(), xid)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	tm.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("WaitDecided did not return after Close")
+	}
+}
@@ -0,0 +1,71 @@
+package tm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXIDErrorReadPastEOF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	mustBegin(t, tm)
+	xid := mustBegin(t, tm)
+	// 截掉 xid 的状态字节，读取时越过文件末尾
+	tm.file.Truncate(LenXidHeaderLength + XidFieldSize)
+
+	_, err = tm.GetStatus(xid)
+	var xerr *XIDError
+	if !errors.As(err, &xerr) {
+		t.Fatalf("Expected an XIDError, got %v", err)
+	}
+	if xerr.Op != "read status" || xerr.Xid != xid || xerr.Offset != LenXidHeaderLength+XidFieldSize {
+		t.Errorf("Unexpected error fields %+v", xerr)
+	}
+	if errors.Unwrap(err) != io.EOF {
+		t.Errorf("Expected Unwrap to reach io.EOF, got %v", errors.Unwrap(err))
+	}
+	if !errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Expected a read past EOF to match ErrBadXIDFile")
+	}
+
+	_, err = tm.StatusRange(1, xid)
+	if !errors.As(err, &xerr) || xerr.Xid != 1 || xerr.Offset != LenXidHeaderLength {
+		t.Errorf("Expected StatusRange to report the start of the range, got %v", err)
+	}
+}
+
+func TestXIDErrorOnClosedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file")
+	tm, err := Create(path, WithSyncMode(SyncNever))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tm.Close()
+
+	xid := mustBegin(t, tm)
+	// 绕过 Close 直接关闭文件，写入返回底层的 os.ErrClosed
+	tm.file.Close()
+
+	err = tm.updateXID(xid, FieldTranCommitted)
+	var xerr *XIDError
+	if !errors.As(err, &xerr) {
+		t.Fatalf("Expected an XIDError, got %v", err)
+	}
+	if xerr.Op != "update status" || xerr.Xid != xid || xerr.Offset != tm.getXidPosition(xid) {
+		t.Errorf("Unexpected error fields %+v", xerr)
+	}
+	if !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected Unwrap to reach os.ErrClosed, got %v", err)
+	}
+	if errors.Is(err, ErrBadXIDFile) {
+		t.Errorf("Only a read past EOF should match ErrBadXIDFile")
+	}
+}
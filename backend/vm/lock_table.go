@@ -0,0 +1,145 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrDeadlock 表示等待这把锁会形成死锁，调用者应当取消事务
+var ErrDeadlock = errors.New("deadlock detected")
+
+// ErrLockWaitCanceled 表示事务在等待锁的时候被 Release，不会再获得这把锁
+var ErrLockWaitCanceled = errors.New("lock wait canceled by release")
+
+// LockTable 是记录级别的排他锁表，同时维护等待图用于检测死锁。
+// 每个事务同一时刻最多等待一把锁，所以等待图中每个事务最多只有一条出边
+type LockTable struct {
+	lock sync.Mutex
+
+	holder  map[int64]int64   // 记录 -> 持有它的 XID
+	held    map[int64][]int64 // XID -> 它持有的记录
+	waitFor map[int64]int64   // XID -> 它正在等待的记录
+	waiters map[int64][]int64 // 记录 -> 按先后顺序等待它的 XID
+	// granted 是等待中的 XID 的通知通道，获得锁时收到 nil，等待被取消时收到 ErrLockWaitCanceled
+	granted map[int64]chan error
+}
+
+// NewLockTable 创建一个空的锁表
+func NewLockTable() *LockTable {
+	return &LockTable{
+		holder:  make(map[int64]int64),
+		held:    make(map[int64][]int64),
+		waitFor: make(map[int64]int64),
+		waiters: make(map[int64][]int64),
+		granted: make(map[int64]chan error),
+	}
+}
+
+// Acquire 为 xid 获取 recordID 上的锁，锁被其他事务持有时阻塞直到获得锁。
+// 等待会形成环时不等待，直接返回 ErrDeadlock；等待期间 xid 被 Release 时返回 ErrLockWaitCanceled
+func (lt *LockTable) Acquire(xid int64, recordID int64) error {
+	lt.lock.Lock()
+	holder, ok := lt.holder[recordID]
+	if !ok {
+		lt.grant(xid, recordID)
+		lt.lock.Unlock()
+		return nil
+	}
+	if holder == xid {
+		lt.lock.Unlock()
+		return nil
+	}
+
+	lt.waitFor[xid] = recordID
+	if lt.hasCycle(xid) {
+		delete(lt.waitFor, xid)
+		lt.lock.Unlock()
+		return fmt.Errorf("%w: xid %d waiting for record %d held by xid %d", ErrDeadlock, xid, recordID, holder)
+	}
+	lt.waiters[recordID] = append(lt.waiters[recordID], xid)
+	ch := make(chan error, 1)
+	lt.granted[xid] = ch
+	lt.lock.Unlock()
+
+	return <-ch
+}
+
+// hasCycle 沿等待图从 xid 出发，检查是否会回到 xid，调用者需持有锁
+func (lt *LockTable) hasCycle(xid int64) bool {
+	visited := make(map[int64]bool)
+	for cur := xid; !visited[cur]; {
+		visited[cur] = true
+		recordID, waiting := lt.waitFor[cur]
+		if !waiting {
+			return false
+		}
+		holder, ok := lt.holder[recordID]
+		if !ok {
+			return false
+		}
+		if holder == xid {
+			return true
+		}
+		cur = holder
+	}
+	return false
+}
+
+// grant 把 recordID 上的锁交给 xid，调用者需持有锁
+func (lt *LockTable) grant(xid int64, recordID int64) {
+	lt.holder[recordID] = xid
+	lt.held[xid] = append(lt.held[xid], recordID)
+}
+
+// Release 释放 xid 持有的所有锁，每把锁交给最早等待它的事务。
+// xid 正在等待锁时把它移出等待队列，它的 Acquire 返回 ErrLockWaitCanceled
+func (lt *LockTable) Release(xid int64) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+
+	if recordID, waiting := lt.waitFor[xid]; waiting {
+		lt.removeWaiter(xid, recordID)
+		delete(lt.waitFor, xid)
+		if ch, ok := lt.granted[xid]; ok {
+			ch <- fmt.Errorf("%w: xid %d waiting for record %d", ErrLockWaitCanceled, xid, recordID)
+			delete(lt.granted, xid)
+		}
+	}
+
+	for _, recordID := range lt.held[xid] {
+		delete(lt.holder, recordID)
+		queue := lt.waiters[recordID]
+		if len(queue) == 0 {
+			continue
+		}
+		next := queue[0]
+		if len(queue) == 1 {
+			delete(lt.waiters, recordID)
+		} else {
+			lt.waiters[recordID] = queue[1:]
+		}
+
+		delete(lt.waitFor, next)
+		lt.grant(next, recordID)
+		lt.granted[next] <- nil
+		delete(lt.granted, next)
+	}
+	delete(lt.held, xid)
+}
+
+// removeWaiter 把 xid 从 recordID 的等待队列中移除，调用者需持有锁
+func (lt *LockTable) removeWaiter(xid int64, recordID int64) {
+	queue := lt.waiters[recordID]
+	for i, w := range queue {
+		if w == xid {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(lt.waiters, recordID)
+	} else {
+		lt.waiters[recordID] = queue
+	}
+}
@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"mydb-go/backend/tm"
+)
+
+func TestLockTableDeadlock(t *testing.T) {
+	lt := NewLockTable()
+	const t1, t2 = 1, 2
+	const recA, recB = 100, 200
+
+	if err := lt.Acquire(t1, recA); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if err := lt.Acquire(t2, recB); err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	// T1 等待 T2 持有的 B
+	done := make(chan error, 1)
+	go func() {
+		done <- lt.Acquire(t1, recB)
+	}()
+	waitUntilWaiting(t, lt, t1)
+
+	// T2 再等待 T1 持有的 A 会形成环
+	if err := lt.Acquire(t2, recA); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected ErrDeadlock for T2, got %v", err)
+	}
+
+	// T2 取消并释放锁之后 T1 拿到 B
+	lt.Release(t2)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected T1 to get the lock, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("T1 still waiting after T2 released")
+	}
+	if lt.holder[recB] != t1 {
+		t.Errorf("Expected T1 to hold B, got %d", lt.holder[recB])
+	}
+}
+
+func TestLockTableLongCycle(t *testing.T) {
+	lt := NewLockTable()
+	// T1 持有 1，T2 持有 2，T3 持有 3；T1 等 2，T2 等 3，T3 再等 1 形成环
+	for xid := int64(1); xid <= 3; xid++ {
+		lt.Acquire(xid, xid)
+	}
+	go lt.Acquire(1, 2)
+	waitUntilWaiting(t, lt, 1)
+	go lt.Acquire(2, 3)
+	waitUntilWaiting(t, lt, 2)
+
+	if err := lt.Acquire(3, 1); !errors.Is(err, ErrDeadlock) {
+		t.Errorf("Expected ErrDeadlock, got %v", err)
+	}
+	lt.Release(3)
+	lt.Release(2)
+	lt.Release(1)
+}
+
+func TestLockTableReentrantAndFIFO(t *testing.T) {
+	lt := NewLockTable()
+	lt.Acquire(1, 10)
+	if err := lt.Acquire(1, 10); err != nil {
+		t.Errorf("Expected reentrant Acquire to succeed, got %v", err)
+	}
+
+	order := make(chan int64, 2)
+	for _, xid := range []int64{2, 3} {
+		xid := xid
+		go func() {
+			lt.Acquire(xid, 10)
+			order <- xid
+			lt.Release(xid)
+		}()
+		waitUntilWaiting(t, lt, xid)
+	}
+	lt.Release(1)
+
+	if first, second := <-order, <-order; first != 2 || second != 3 {
+		t.Errorf("Expected waiters to be granted in order, got %d, %d", first, second)
+	}
+}
+
+func TestLockTableReleaseWaiter(t *testing.T) {
+	lt := NewLockTable()
+	const recA, recB = 100, 200
+	lt.Acquire(1, recA)
+
+	// T2 和 T3 依次等待 A，T2 在等待时被取消
+	done := make(chan error, 1)
+	go func() {
+		done <- lt.Acquire(2, recA)
+	}()
+	waitUntilWaiting(t, lt, 2)
+	third := make(chan error, 1)
+	go func() {
+		third <- lt.Acquire(3, recA)
+	}()
+	waitUntilWaiting(t, lt, 3)
+
+	lt.Release(2)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrLockWaitCanceled) {
+			t.Errorf("Expected ErrLockWaitCanceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("T2 still waiting after it was released")
+	}
+	if _, ok := lt.waitFor[2]; ok || len(lt.waiters[recA]) != 1 {
+		t.Errorf("Expected T2 to leave the wait queue, got %v", lt.waiters[recA])
+	}
+
+	// T2 留下的等待边不能让 T1 等待 T2 的锁时被误判为死锁
+	lt.Acquire(2, recB)
+	go func() {
+		done <- lt.Acquire(1, recB)
+	}()
+	waitUntilWaiting(t, lt, 1)
+
+	// A 交给 T3 而不是已经取消的 T2
+	lt.Release(2)
+	if err := <-done; err != nil {
+		t.Errorf("Expected T1 to get B, got %v", err)
+	}
+	lt.Release(1)
+	if err := <-third; err != nil || lt.holder[recA] != 3 {
+		t.Errorf("Expected T3 to get A, got holder %d, %v", lt.holder[recA], err)
+	}
+	lt.Release(3)
+}
+
+// waitUntilWaiting 等待 xid 进入等待状态
+func waitUntilWaiting(t *testing.T, lt *LockTable, xid int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		lt.lock.Lock()
+		_, ok := lt.granted[xid]
+		lt.lock.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("xid %d never started waiting", xid)
+}
+
+func TestVersionManagerReleasesLocks(t *testing.T) {
+	path := "test_file"
+	vm, tmi := newTestVersionManager(t, path)
+	defer os.Remove(path + tm.XidSuffix)
+	defer tmi.Close()
+
+	t1 := mustBegin(t, vm, ReadCommitted)
+	t2 := mustBegin(t, vm, ReadCommitted)
+	if err := vm.Lock(t1.Xid, 1); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- vm.Lock(t2.Xid, 1)
+	}()
+	waitUntilWaiting(t, vm.locks, t2.Xid)
+	vm.Commit(t1.Xid)
+	if err := <-done; err != nil {
+		t.Errorf("Expected t2 to get the lock after t1 committed, got %v", err)
+	}
+	vm.Abort(t2.Xid)
+
+	if err := vm.Lock(t1.Xid, 2); !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("Expected ErrUnknownTransaction for an ended transaction, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"mydb-go/backend/tm"
+)
+
+// IsolationLevel 是事务的隔离级别
+type IsolationLevel int
+
+const (
+	// ReadCommitted 读已提交: 每次读取都能看到读取时已经提交的版本
+	ReadCommitted IsolationLevel = iota
+	// RepeatableRead 可重复读: 只能看到事务开启时已经提交的版本
+	RepeatableRead
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case ReadCommitted:
+		return "read-committed"
+	case RepeatableRead:
+		return "repeatable-read"
+	default:
+		return fmt.Sprintf("IsolationLevel(%d)", int(l))
+	}
+}
+
+// ErrUnknownTransaction 表示 xid 不是通过 VersionManager 开启的事务或者已经结束
+var ErrUnknownTransaction = errors.New("unknown transaction")
+
+// Version 是一条记录的一个版本，Xmin 是创建它的事务，Xmax 是删除它的事务，0 表示没有被删除
+type Version struct {
+	Xmin int64
+	Xmax int64
+}
+
+// Transaction 是 VersionManager 中的一个读写事务，snapshot 是它开启时其他仍处于活跃状态的事务
+type Transaction struct {
+	Xid      int64
+	Level    IsolationLevel
+	snapshot tm.Snapshot
+}
+
+// inSnapshot 判断 xid 在事务开启时是否处于活跃状态
+func (t *Transaction) inSnapshot(xid int64) bool {
+	_, ok := t.snapshot[xid]
+	return ok
+}
+
+// VersionManager 根据事务状态和快照判断记录的版本对事务是否可见
+type VersionManager struct {
+	tm    tm.SnapshotTransactionManager
+	locks *LockTable
+
+	lock         sync.Mutex
+	transactions map[int64]*Transaction
+}
+
+// NewVersionManager 创建一个使用 t 记录事务状态的 VersionManager，t 可以是文件或者内存实现
+func NewVersionManager(t tm.SnapshotTransactionManager) *VersionManager {
+	return &VersionManager{tm: t, locks: NewLockTable(), transactions: make(map[int64]*Transaction)}
+}
+
+// Lock 为事务获取记录上的排他锁，事务提交或取消时释放。返回 ErrDeadlock 时调用者应当取消事务
+func (vm *VersionManager) Lock(xid int64, recordID int64) error {
+	_, err := vm.Transaction(xid)
+	if err != nil {
+		return err
+	}
+	return vm.locks.Acquire(xid, recordID)
+}
+
+// Begin 以 level 隔离级别开启一个事务，可重复读的事务会记录开启时的活跃事务快照。
+// 快照与分配 XID 原子地完成，否则两者之间提交的更小的 XID 会被当作开启前就已提交
+func (vm *VersionManager) Begin(level IsolationLevel) (*Transaction, error) {
+	txn := &Transaction{Level: level}
+	var err error
+	if level == RepeatableRead {
+		txn.Xid, txn.snapshot, err = vm.tm.BeginWithSnapshot()
+	} else {
+		txn.Xid, err = vm.tm.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	xid := txn.Xid
+
+	vm.lock.Lock()
+	vm.transactions[xid] = txn
+	vm.lock.Unlock()
+	return txn, nil
+}
+
+// Commit 提交事务
+func (vm *VersionManager) Commit(xid int64) error {
+	_, err := vm.end(xid)
+	if err != nil {
+		return err
+	}
+	return vm.tm.Commit(xid)
+}
+
+// Abort 取消事务
+func (vm *VersionManager) Abort(xid int64) error {
+	_, err := vm.end(xid)
+	if err != nil {
+		return err
+	}
+	return vm.tm.Abort(xid)
+}
+
+func (vm *VersionManager) end(xid int64) (*Transaction, error) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	txn, ok := vm.transactions[xid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTransaction, xid)
+	}
+	delete(vm.transactions, xid)
+	vm.locks.Release(xid)
+	return txn, nil
+}
+
+// Transaction 返回仍在进行中的事务
+func (vm *VersionManager) Transaction(xid int64) (*Transaction, error) {
+	vm.lock.Lock()
+	defer vm.lock.Unlock()
+	txn, ok := vm.transactions[xid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTransaction, xid)
+	}
+	return txn, nil
+}
+
+// IsVisible 判断版本 v 对事务 txn 是否可见
+func (vm *VersionManager) IsVisible(txn *Transaction, v Version) (bool, error) {
+	if txn.Level == RepeatableRead {
+		return vm.repeatableRead(txn, v)
+	}
+	return vm.readCommitted(txn, v)
+}
+
+// readCommitted: 自己创建且没有删除的版本可见；
+// 已提交的事务创建、且没有被删除或者删除它的事务还没有提交的版本可见
+func (vm *VersionManager) readCommitted(txn *Transaction, v Version) (bool, error) {
+	if v.Xmin == txn.Xid {
+		return v.Xmax == 0, nil
+	}
+
+	committed, err := vm.tm.IsCommitted(v.Xmin)
+	if err != nil || !committed {
+		return false, err
+	}
+	if v.Xmax == 0 {
+		return true, nil
+	}
+	if v.Xmax == txn.Xid {
+		return false, nil
+	}
+	committed, err = vm.tm.IsCommitted(v.Xmax)
+	return !committed && err == nil, err
+}
+
+// repeatableRead: 自己创建且没有删除的版本可见；
+// 创建者在事务开启之前已经提交，且删除者不存在、没有提交、在事务开启之后才开启或者开启时仍活跃的版本可见
+func (vm *VersionManager) repeatableRead(txn *Transaction, v Version) (bool, error) {
+	if v.Xmin == txn.Xid {
+		return v.Xmax == 0, nil
+	}
+	if v.Xmin > txn.Xid || txn.inSnapshot(v.Xmin) {
+		return false, nil
+	}
+
+	committed, err := vm.tm.IsCommitted(v.Xmin)
+	if err != nil || !committed {
+		return false, err
+	}
+	if v.Xmax == 0 {
+		return true, nil
+	}
+	if v.Xmax == txn.Xid {
+		return false, nil
+	}
+	if v.Xmax > txn.Xid || txn.inSnapshot(v.Xmax) {
+		return true, nil
+	}
+	committed, err = vm.tm.IsCommitted(v.Xmax)
+	return !committed && err == nil, err
+}
@@ -0,0 +1,196 @@
+package vm
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"mydb-go/backend/tm"
+)
+
+func newTestVersionManager(t *testing.T, path string) (*VersionManager, *tm.TransactionManagerImpl) {
+	t.Helper()
+	tmi, err := tm.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	return NewVersionManager(tmi), tmi
+}
+
+func mustBegin(t *testing.T, vm *VersionManager, level IsolationLevel) *Transaction {
+	t.Helper()
+	txn, err := vm.Begin(level)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	return txn
+}
+
+func TestVisibilityMatrix(t *testing.T) {
+	for _, level := range []IsolationLevel{ReadCommitted, RepeatableRead} {
+		path := "test_file"
+		vm, tmi := newTestVersionManager(t, path)
+
+		// 读者开启之前: before 已提交，activeBefore 仍活跃，aborted 已取消
+		before := mustBegin(t, vm, ReadCommitted)
+		vm.Commit(before.Xid)
+		deleter := mustBegin(t, vm, ReadCommitted)
+		vm.Commit(deleter.Xid)
+		aborted := mustBegin(t, vm, ReadCommitted)
+		vm.Abort(aborted.Xid)
+		activeBefore := mustBegin(t, vm, ReadCommitted)
+		commitsLater := mustBegin(t, vm, ReadCommitted)
+
+		reader := mustBegin(t, vm, level)
+
+		// 读者开启之后: after 开启并提交，commitsLater 也在读者开启之后提交
+		after := mustBegin(t, vm, ReadCommitted)
+		vm.Commit(after.Xid)
+		vm.Commit(commitsLater.Xid)
+
+		cases := []struct {
+			name   string
+			v      Version
+			rc, rr bool
+		}{
+			{"self written", Version{Xmin: reader.Xid}, true, true},
+			{"self deleted", Version{Xmin: reader.Xid, Xmax: reader.Xid}, false, false},
+			{"committed before snapshot", Version{Xmin: before.Xid}, true, true},
+			{"deleted by self", Version{Xmin: before.Xid, Xmax: reader.Xid}, false, false},
+			{"deleted before snapshot", Version{Xmin: before.Xid, Xmax: deleter.Xid}, false, false},
+			{"aborted writer", Version{Xmin: aborted.Xid}, false, false},
+			{"active writer", Version{Xmin: activeBefore.Xid}, false, false},
+			{"deleted by active writer", Version{Xmin: before.Xid, Xmax: activeBefore.Xid}, true, true},
+			{"writer committed after snapshot", Version{Xmin: commitsLater.Xid}, true, false},
+			{"deleted after snapshot", Version{Xmin: before.Xid, Xmax: commitsLater.Xid}, false, true},
+			{"writer began after snapshot", Version{Xmin: after.Xid}, true, false},
+			{"deleted by later writer", Version{Xmin: before.Xid, Xmax: after.Xid}, false, true},
+			{"deleted by aborted writer", Version{Xmin: before.Xid, Xmax: aborted.Xid}, true, true},
+		}
+		for _, c := range cases {
+			want := c.rc
+			if level == RepeatableRead {
+				want = c.rr
+			}
+			got, err := vm.IsVisible(reader, c.v)
+			if err != nil {
+				t.Fatalf("IsVisible failed: %v", err)
+			}
+			if got != want {
+				t.Errorf("Level %v, %s: expected visible=%v, got %v", level, c.name, want, got)
+			}
+		}
+
+		tmi.Close()
+		os.Remove(path + tm.XidSuffix)
+	}
+}
+
+func TestEndUnknownTransaction(t *testing.T) {
+	path := "test_file"
+	vm, tmi := newTestVersionManager(t, path)
+	defer os.Remove(path + tm.XidSuffix)
+	defer tmi.Close()
+
+	txn := mustBegin(t, vm, RepeatableRead)
+	if _, err := vm.Transaction(txn.Xid); err != nil {
+		t.Errorf("Expected transaction %d to be tracked, got %v", txn.Xid, err)
+	}
+	if err := vm.Commit(txn.Xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := vm.Abort(txn.Xid); !errors.Is(err, ErrUnknownTransaction) {
+		t.Errorf("Expected ErrUnknownTransaction, got %v", err)
+	}
+}
+
+func TestIsolationLevelMidTransactionCommit(t *testing.T) {
+	path := "test_file"
+	vm, tmi := newTestVersionManager(t, path)
+	defer os.Remove(path + tm.XidSuffix)
+	defer tmi.Close()
+
+	old := mustBegin(t, vm, ReadCommitted)
+	vm.Commit(old.Xid)
+	rc := mustBegin(t, vm, ReadCommitted)
+	rr := mustBegin(t, vm, RepeatableRead)
+
+	// writer 在两个读者开启之后插入一个新版本并删除旧版本
+	writer := mustBegin(t, vm, ReadCommitted)
+	inserted := Version{Xmin: writer.Xid}
+	deleted := Version{Xmin: old.Xid, Xmax: writer.Xid}
+
+	check := func(when string, reader *Transaction, insertedVisible, deletedVisible bool) {
+		t.Helper()
+		got, err := vm.IsVisible(reader, inserted)
+		if err != nil || got != insertedVisible {
+			t.Errorf("%v %s: expected the inserted version visible=%v, got %v, %v", reader.Level, when, insertedVisible, got, err)
+		}
+		got, err = vm.IsVisible(reader, deleted)
+		if err != nil || got != deletedVisible {
+			t.Errorf("%v %s: expected the deleted version visible=%v, got %v, %v", reader.Level, when, deletedVisible, got, err)
+		}
+	}
+	check("before commit", rc, false, true)
+	check("before commit", rr, false, true)
+
+	if err := vm.Commit(writer.Xid); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	// 读已提交的读者在同一个事务中看到了 writer 的修改，可重复读的读者仍然看到开启时的数据
+	check("after commit", rc, true, false)
+	check("after commit", rr, false, true)
+}
+
+// beginHook 在事务开启之后回调 onBegin
+type beginHook struct {
+	onBegin func(xid int64)
+}
+
+func (h *beginHook) OnBegin(xid int64) { h.onBegin(xid) }
+func (h *beginHook) OnCommit(int64)    {}
+func (h *beginHook) OnAbort(int64)     {}
+
+func TestRepeatableReadSnapshotIsAtomic(t *testing.T) {
+	path := "test_file"
+	defer os.Remove(path + tm.XidSuffix)
+	tmi, err := tm.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer tmi.Close()
+
+	backends := map[string]interface {
+		tm.SnapshotTransactionManager
+		SetObserver(tm.Observer)
+	}{
+		"file":   tmi,
+		"memory": tm.NewMemoryTransactionManager(),
+	}
+	for name, backend := range backends {
+		vm := NewVersionManager(backend)
+		writer := mustBegin(t, vm, ReadCommitted)
+
+		// 读者的 XID 分配之后、Begin 返回之前提交 writer，这正是分开拍快照时的窗口
+		backend.SetObserver(&beginHook{onBegin: func(xid int64) {
+			if xid == writer.Xid+1 {
+				if err := backend.Commit(writer.Xid); err != nil {
+					t.Errorf("%s: Commit failed: %v", name, err)
+				}
+			}
+		}})
+		reader := mustBegin(t, vm, RepeatableRead)
+		backend.SetObserver(nil)
+
+		if committed, _ := backend.IsCommitted(writer.Xid); !committed {
+			t.Fatalf("%s: expected the writer to commit while the reader began", name)
+		}
+		visible, err := vm.IsVisible(reader, Version{Xmin: writer.Xid})
+		if err != nil {
+			t.Fatalf("%s: IsVisible failed: %v", name, err)
+		}
+		if visible {
+			t.Errorf("%s: a writer active when the reader began must stay invisible", name)
+		}
+	}
+}